@@ -1,8 +1,11 @@
 package context
 
 import (
+	"errors"
 	"github.com/team-ide/go-tool/db"
 	"go.uber.org/zap"
+	"path/filepath"
+	"strings"
 	"teamide/internal/config"
 )
 
@@ -34,3 +37,22 @@ func (this_ *ServerContext) GetFilesDir() string {
 func (this_ *ServerContext) GetFilesFile(path string) string {
 	return this_.GetFilesDir() + path
 }
+
+// GetFilesFileInDir 根据客户端传入的相对路径获取文件，路径超出文件目录时返回异常
+func (this_ *ServerContext) GetFilesFileInDir(path string) (res string, err error) {
+	dir, err := filepath.Abs(this_.GetFilesDir())
+	if err != nil {
+		return
+	}
+	res = filepath.Join(dir, filepath.FromSlash(path))
+	rel, err := filepath.Rel(dir, res)
+	if err != nil {
+		return
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		err = errors.New("文件路径[" + path + "]不在文件目录中")
+		res = ""
+		return
+	}
+	return
+}
@@ -21,6 +21,15 @@ func NewSetting() (setting *Setting) {
 
 	setting.LogRetentionDays = 0
 
+	setting.TerminalRecordEnable = false
+	setting.TerminalRecordRetentionDays = 0
+	setting.TerminalSessionKeepSeconds = 0
+
+	setting.SSHAgentEnable = false
+	setting.SSHForwardBindAnyEnable = false
+
+	setting.MockServerBindAnyEnable = false
+
 	return
 }
 
@@ -37,6 +46,15 @@ type Setting struct {
 
 	LogRetentionDays int `json:"logRetentionDays"` // 日志 保留天数 默认 0 一直保留
 
+	TerminalRecordEnable        bool `json:"terminalRecordEnable"`        // 启用 终端录像 默认关闭
+	TerminalRecordRetentionDays int  `json:"terminalRecordRetentionDays"` // 终端录像 保留天数 默认 0 一直保留
+	TerminalSessionKeepSeconds  int  `json:"terminalSessionKeepSeconds"`  // 终端 断开后会话保留秒数 默认 0 断开即关闭
+
+	SSHAgentEnable          bool `json:"sshAgentEnable"`          // 启用 SSH 使用服务端 ssh-agent 认证和转发 默认关闭
+	SSHForwardBindAnyEnable bool `json:"sshForwardBindAnyEnable"` // 启用 SSH 本地、动态端口转发监听非本机地址 默认关闭 只能监听 127.0.0.1
+
+	MockServerBindAnyEnable bool `json:"mockServerBindAnyEnable"` // 启用 Mock 服务监听非本机地址 默认关闭 只能监听 127.0.0.1
+
 	StandAloneUserId int64 `json:"standAloneUserId"` // StandAloneUserId 单机版本 用户 ID
 	AnonymousUserId  int64 `json:"anonymousUserId"`  // AnonymousUserId 匿名 用户 ID
 }
@@ -72,6 +90,33 @@ func (this_ *Setting) Set(name string, value interface{}) (find bool, err error)
 		}
 		this_.LogRetentionDays, err = strconv.Atoi(sv)
 		break
+
+	case "terminalRecordEnable":
+		this_.TerminalRecordEnable = util.IsTrue(value)
+		break
+	case "terminalRecordRetentionDays":
+		sv := util.GetStringValue(value)
+		if sv == "" {
+			sv = "0"
+		}
+		this_.TerminalRecordRetentionDays, err = strconv.Atoi(sv)
+		break
+	case "terminalSessionKeepSeconds":
+		sv := util.GetStringValue(value)
+		if sv == "" {
+			sv = "0"
+		}
+		this_.TerminalSessionKeepSeconds, err = strconv.Atoi(sv)
+		break
+	case "sshAgentEnable":
+		this_.SSHAgentEnable = util.IsTrue(value)
+		break
+	case "sshForwardBindAnyEnable":
+		this_.SSHForwardBindAnyEnable = util.IsTrue(value)
+		break
+	case "mockServerBindAnyEnable":
+		this_.MockServerBindAnyEnable = util.IsTrue(value)
+		break
 	case "standAloneUserId":
 		sv := util.GetStringValue(value)
 		if sv == "" {
@@ -12,14 +12,20 @@ import (
 	"teamide/internal/context"
 	"teamide/internal/module/module_database"
 	"teamide/internal/module/module_elasticsearch"
+	"teamide/internal/module/module_etcd"
 	"teamide/internal/module/module_file_manager"
+	"teamide/internal/module/module_grpc"
+	"teamide/internal/module/module_http"
 	"teamide/internal/module/module_id"
 	"teamide/internal/module/module_javascript"
 	"teamide/internal/module/module_kafka"
 	"teamide/internal/module/module_log"
 	"teamide/internal/module/module_login"
+	"teamide/internal/module/module_mongodb"
 	"teamide/internal/module/module_node"
 	"teamide/internal/module/module_power"
+	"teamide/internal/module/module_preferences"
+	"teamide/internal/module/module_rabbitmq"
 	"teamide/internal/module/module_redis"
 	"teamide/internal/module/module_register"
 	"teamide/internal/module/module_setting"
@@ -46,6 +52,7 @@ func NewApi(ServerContext *context.ServerContext) (api *Api, err error) {
 		powerRouteService: module_power.NewPowerRouteService(ServerContext),
 		powerUserService:  module_power.NewPowerUserService(ServerContext),
 		logService:        module_log.NewLogService(ServerContext),
+		recordService:     module_terminal.NewTerminalRecordService(ServerContext),
 		settingService:    module_setting.NewSettingService(ServerContext),
 		idService:         module_id.NewIDService(ServerContext),
 		apiCache:          make(map[string]*base.ApiWorker),
@@ -66,11 +73,16 @@ func NewApi(ServerContext *context.ServerContext) (api *Api, err error) {
 	if err != nil {
 		return
 	}
+	api.toolboxService.InitHostKeyChecker()
+	api.toolboxService.InitKeyboardInteractive()
+	api.toolboxService.InitSSHAgent()
+	api.toolboxService.InitSSHForward()
 
 	err = api.InitSetting()
 	if err != nil {
 		return
 	}
+	api.recordService.StartClean()
 
 	if ServerContext.IsServer {
 		err = api.initServer()
@@ -116,6 +128,7 @@ type Api struct {
 	powerRouteService *module_power.PowerRouteService
 	powerUserService  *module_power.PowerUserService
 	logService        *module_log.LogService
+	recordService     *module_terminal.TerminalRecordService
 	settingService    *module_setting.SettingService
 	idService         *module_id.IDService
 	installService    *InstallService
@@ -155,13 +168,19 @@ func (this_ *Api) GetApis() (apis []*base.ApiWorker, err error) {
 	apis = append(apis, module_redis.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_database.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_zookeeper.NewApi(this_.toolboxService).GetApis()...)
+	apis = append(apis, module_etcd.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_kafka.NewApi(this_.toolboxService).GetApis()...)
+	apis = append(apis, module_rabbitmq.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_elasticsearch.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_log.NewApi(this_.logService).GetApis()...)
 	apis = append(apis, module_power.NewApi(this_.powerRoleService).GetApis()...)
 	apis = append(apis, module_tools.NewApi(this_.ServerContext).GetApis()...)
 	apis = append(apis, module_setting.NewApi(this_.settingService).GetApis()...)
+	apis = append(apis, module_preferences.NewApi(module_preferences.NewPreferencesService(this_.ServerContext)).GetApis()...)
 	apis = append(apis, module_thrift.NewApi(this_.toolboxService).GetApis()...)
+	apis = append(apis, module_grpc.NewApi(this_.toolboxService).GetApis()...)
+	apis = append(apis, module_http.NewApi(this_.toolboxService, this_.nodeService).GetApis()...)
+	apis = append(apis, module_mongodb.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_javascript.NewApi(this_.toolboxService).GetApis()...)
 
 	return
@@ -12,6 +12,7 @@ import (
 	"teamide/internal/module/module_login"
 	"teamide/internal/module/module_node"
 	"teamide/internal/module/module_power"
+	"teamide/internal/module/module_preferences"
 	"teamide/internal/module/module_register"
 	"teamide/internal/module/module_setting"
 	"teamide/internal/module/module_terminal"
@@ -117,6 +118,11 @@ func (this_ *InstallService) Install() (err error) {
 		return
 	}
 
+	err = this_.InstallSteps(module_preferences.GetInstallStages())
+	if err != nil {
+		return
+	}
+
 	err = this_.InstallSteps(module_toolbox.GetInstallStages())
 	if err != nil {
 		return
@@ -1,7 +1,9 @@
 package module_elasticsearch
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/olivere/elastic/v7"
 	"github.com/team-ide/go-tool/elasticsearch"
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
@@ -42,7 +44,31 @@ var (
 	taskStatusPower  = base.AppendPower(&base.PowerAction{Action: "taskStatus", Text: "ES任务状态", ShouldLogin: true, StandAlone: true, Parent: Power})
 	taskStopPower    = base.AppendPower(&base.PowerAction{Action: "taskStop", Text: "ES任务停止", ShouldLogin: true, StandAlone: true, Parent: Power})
 	taskCleanPower   = base.AppendPower(&base.PowerAction{Action: "taskClean", Text: "ES任务清理", ShouldLogin: true, StandAlone: true, Parent: Power})
+	copyPower        = base.AppendPower(&base.PowerAction{Action: "copy", Text: "ES跨集群复制索引", ShouldLogin: true, StandAlone: true, Parent: Power})
+	taskResumePower  = base.AppendPower(&base.PowerAction{Action: "taskResume", Text: "ES任务继续", ShouldLogin: true, StandAlone: true, Parent: Power})
 	closePower       = base.AppendPower(&base.PowerAction{Action: "close", Text: "ES关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	clusterHealthPower     = base.AppendPower(&base.PowerAction{Action: "clusterHealth", Text: "ES集群健康", ShouldLogin: true, StandAlone: true, Parent: Power})
+	catNodesPower          = base.AppendPower(&base.PowerAction{Action: "catNodes", Text: "ES节点状态", ShouldLogin: true, StandAlone: true, Parent: Power})
+	catShardsPower         = base.AppendPower(&base.PowerAction{Action: "catShards", Text: "ES分片分配", ShouldLogin: true, StandAlone: true, Parent: Power})
+	allocationExplainPower = base.AppendPower(&base.PowerAction{Action: "allocationExplain", Text: "ES分片分配原因", ShouldLogin: true, StandAlone: true, Parent: Power})
+	pendingTasksPower      = base.AppendPower(&base.PowerAction{Action: "pendingTasks", Text: "ES等待任务", ShouldLogin: true, StandAlone: true, Parent: Power})
+	hotThreadsPower        = base.AppendPower(&base.PowerAction{Action: "hotThreads", Text: "ES热点线程", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	templatesPower                = base.AppendPower(&base.PowerAction{Action: "templates", Text: "ES模板查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	putTemplatePower              = base.AppendPower(&base.PowerAction{Action: "putTemplate", Text: "ES模板保存", ShouldLogin: true, StandAlone: true, Parent: Power})
+	deleteTemplatePower           = base.AppendPower(&base.PowerAction{Action: "deleteTemplate", Text: "ES模板删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+	ilmPoliciesPower              = base.AppendPower(&base.PowerAction{Action: "ilmPolicies", Text: "ES生命周期策略查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	putIlmPolicyPower             = base.AppendPower(&base.PowerAction{Action: "putIlmPolicy", Text: "ES生命周期策略保存", ShouldLogin: true, StandAlone: true, Parent: Power})
+	deleteIlmPolicyPower          = base.AppendPower(&base.PowerAction{Action: "deleteIlmPolicy", Text: "ES生命周期策略删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+	ilmExplainPower               = base.AppendPower(&base.PowerAction{Action: "ilmExplain", Text: "ES索引生命周期", ShouldLogin: true, StandAlone: true, Parent: Power})
+	snapshotRepositoriesPower     = base.AppendPower(&base.PowerAction{Action: "snapshotRepositories", Text: "ES快照仓库查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	putSnapshotRepositoryPower    = base.AppendPower(&base.PowerAction{Action: "putSnapshotRepository", Text: "ES快照仓库保存", ShouldLogin: true, StandAlone: true, Parent: Power})
+	deleteSnapshotRepositoryPower = base.AppendPower(&base.PowerAction{Action: "deleteSnapshotRepository", Text: "ES快照仓库删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+	snapshotsPower                = base.AppendPower(&base.PowerAction{Action: "snapshots", Text: "ES快照查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	createSnapshotPower           = base.AppendPower(&base.PowerAction{Action: "createSnapshot", Text: "ES快照创建", ShouldLogin: true, StandAlone: true, Parent: Power})
+	deleteSnapshotPower           = base.AppendPower(&base.PowerAction{Action: "deleteSnapshot", Text: "ES快照删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+	restoreSnapshotPower          = base.AppendPower(&base.PowerAction{Action: "restoreSnapshot", Text: "ES快照恢复", ShouldLogin: true, StandAlone: true, Parent: Power})
 )
 
 func (this_ *api) GetApis() (apis []*base.ApiWorker) {
@@ -66,8 +92,32 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: taskListPower, Do: this_.taskList})
 	apis = append(apis, &base.ApiWorker{Power: taskStopPower, Do: this_.taskStop})
 	apis = append(apis, &base.ApiWorker{Power: taskCleanPower, Do: this_.taskClean})
+	apis = append(apis, &base.ApiWorker{Power: copyPower, Do: this_.copy})
+	apis = append(apis, &base.ApiWorker{Power: taskResumePower, Do: this_.taskResume})
 	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
 
+	apis = append(apis, &base.ApiWorker{Power: clusterHealthPower, Do: this_.clusterHealth})
+	apis = append(apis, &base.ApiWorker{Power: catNodesPower, Do: this_.catNodes})
+	apis = append(apis, &base.ApiWorker{Power: catShardsPower, Do: this_.catShards})
+	apis = append(apis, &base.ApiWorker{Power: allocationExplainPower, Do: this_.allocationExplain})
+	apis = append(apis, &base.ApiWorker{Power: pendingTasksPower, Do: this_.pendingTasks})
+	apis = append(apis, &base.ApiWorker{Power: hotThreadsPower, Do: this_.hotThreads})
+
+	apis = append(apis, &base.ApiWorker{Power: templatesPower, Do: this_.templates})
+	apis = append(apis, &base.ApiWorker{Power: putTemplatePower, Do: this_.putTemplate})
+	apis = append(apis, &base.ApiWorker{Power: deleteTemplatePower, Do: this_.deleteTemplate})
+	apis = append(apis, &base.ApiWorker{Power: ilmPoliciesPower, Do: this_.ilmPolicies})
+	apis = append(apis, &base.ApiWorker{Power: putIlmPolicyPower, Do: this_.putIlmPolicy})
+	apis = append(apis, &base.ApiWorker{Power: deleteIlmPolicyPower, Do: this_.deleteIlmPolicy})
+	apis = append(apis, &base.ApiWorker{Power: ilmExplainPower, Do: this_.ilmExplain})
+	apis = append(apis, &base.ApiWorker{Power: snapshotRepositoriesPower, Do: this_.snapshotRepositories})
+	apis = append(apis, &base.ApiWorker{Power: putSnapshotRepositoryPower, Do: this_.putSnapshotRepository})
+	apis = append(apis, &base.ApiWorker{Power: deleteSnapshotRepositoryPower, Do: this_.deleteSnapshotRepository})
+	apis = append(apis, &base.ApiWorker{Power: snapshotsPower, Do: this_.snapshots})
+	apis = append(apis, &base.ApiWorker{Power: createSnapshotPower, Do: this_.createSnapshot})
+	apis = append(apis, &base.ApiWorker{Power: deleteSnapshotPower, Do: this_.deleteSnapshot})
+	apis = append(apis, &base.ApiWorker{Power: restoreSnapshotPower, Do: this_.restoreSnapshot})
+
 	return
 }
 
@@ -142,6 +192,19 @@ type BaseRequest struct {
 	WhereList       []*elasticsearch.Where `json:"whereList"`
 	OrderList       []*elasticsearch.Order `json:"orderList"`
 	TaskId          string                 `json:"taskId"`
+	Shard           int                    `json:"shard"`
+	Primary         bool                   `json:"primary"`
+	NodeId          string                 `json:"nodeId"`
+	Threads         int                    `json:"threads"`
+	ThreadType      string                 `json:"threadType"`
+	TemplateType    string                 `json:"templateType"`
+	TemplateName    string                 `json:"templateName"`
+	PolicyName      string                 `json:"policyName"`
+	Body            map[string]interface{} `json:"body"`
+	Repository      string                 `json:"repository"`
+	RepositoryType  string                 `json:"repositoryType"`
+	Settings        map[string]interface{} `json:"settings"`
+	Snapshot        string                 `json:"snapshot"`
 }
 
 func (this_ *api) info(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
@@ -461,6 +524,63 @@ func (this_ *api) _import(requestBean *base.RequestBean, c *gin.Context) (res in
 	return
 }
 
+func (this_ *api) copy(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	var request = &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	var task = &CopyTask{}
+	if !base.RequestJSON(task, c) {
+		return
+	}
+	if task.DestToolboxId == 0 {
+		err = errors.New("请选择目标Elasticsearch")
+		return
+	}
+	destToolbox, err := this_.toolboxService.Get(task.DestToolboxId)
+	if err != nil {
+		return
+	}
+	if destToolbox == nil || destToolbox.ToolboxType != "elasticsearch" {
+		err = errors.New("目标Elasticsearch工具不存在")
+		return
+	}
+	if destToolbox.UserId != 0 && destToolbox.UserId != requestBean.JWT.UserId {
+		err = errors.New("目标工具[" + destToolbox.Name + "]不属于当前用户，无法操作")
+		return
+	}
+	destConfig := &elasticsearch.Config{}
+	_, err = this_.toolboxService.BindToolboxConfig(requestBean, task.DestToolboxId, destConfig)
+	if err != nil {
+		return
+	}
+
+	task.sourceClient, err = newCopyTaskClient(config)
+	if err != nil {
+		return
+	}
+	task.destClient, err = newCopyTaskClient(destConfig)
+	if err != nil {
+		err = errors.New("dest elasticsearch error:" + err.Error())
+		return
+	}
+
+	// 任务ID由服务端生成，防止覆盖其它任务
+	task.TaskId = util.GetUUID()
+	task.userId = requestBean.JWT.UserId
+	StartCopyTask(task)
+	addWorkerTask(request.WorkerId, task.TaskId)
+	res = GetCopyTask(task.TaskId)
+
+	return
+}
+
 func (this_ *api) export(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	//config, err := this_.getConfig(requestBean, c)
 	//if err != nil {
@@ -490,6 +610,10 @@ func (this_ *api) taskStatus(requestBean *base.RequestBean, c *gin.Context) (res
 		return
 	}
 
+	if copyTask := GetCopyTask(request.TaskId); copyTask != nil {
+		res = copyTask
+		return
+	}
 	res = elasticsearch.GetTask(request.TaskId)
 	return
 }
@@ -501,10 +625,22 @@ func (this_ *api) taskStop(requestBean *base.RequestBean, c *gin.Context) (res i
 		return
 	}
 
+	StopCopyTask(request.TaskId)
 	elasticsearch.StopTask(request.TaskId)
 	return
 }
 
+func (this_ *api) taskResume(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	var request = &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = ResumeCopyTask(request.TaskId, requestBean.JWT.UserId)
+	return
+}
+
 func (this_ *api) taskClean(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 
 	var request = &BaseRequest{}
@@ -552,7 +688,7 @@ func addWorkerTask(workerId string, taskId string) {
 	return
 }
 
-func getWorkerTasks(workerId string) (taskList []*elasticsearch.Task) {
+func getWorkerTasks(workerId string) (taskList []interface{}) {
 	workerTasksCacheLock.Lock()
 	defer workerTasksCacheLock.Unlock()
 	taskIds := workerTasksCache[workerId]
@@ -562,6 +698,9 @@ func getWorkerTasks(workerId string) (taskList []*elasticsearch.Task) {
 			taskList = append(taskList, task)
 		}
 	}
+	for _, task := range getCopyTasks(taskIds) {
+		taskList = append(taskList, task)
+	}
 	return
 }
 
@@ -572,6 +711,7 @@ func removeWorkerTasks(workerId string) {
 	for _, taskId := range taskIds {
 		elasticsearch.StopTask(taskId)
 		elasticsearch.CleanTask(taskId)
+		CleanCopyTask(taskId)
 	}
 	delete(workerTasksCache, workerId)
 	return
@@ -583,6 +723,7 @@ func removeWorkerTask(workerId string, taskId string) {
 
 	elasticsearch.StopTask(taskId)
 	elasticsearch.CleanTask(taskId)
+	CleanCopyTask(taskId)
 
 	taskIds := workerTasksCache[workerId]
 	var newIds []string
@@ -599,3 +740,369 @@ func removeWorkerTask(workerId string, taskId string) {
 	}
 	return
 }
+
+func (this_ *api) getClient(requestBean *base.RequestBean, c *gin.Context) (client *elastic.Client, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config)
+	if err != nil {
+		return
+	}
+	client, err = getClient(service)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) clusterHealth(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = ClusterHealth(client, request.IndexName)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) catNodes(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	res, err = CatNodes(client)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) catShards(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = CatShards(client, request.IndexName)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) allocationExplain(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = AllocationExplain(client, request.IndexName, request.Shard, request.Primary)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) pendingTasks(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	res, err = PendingTasks(client)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) hotThreads(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = HotThreads(client, request.NodeId, request.Threads, request.ThreadType)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) templates(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = GetTemplates(client, request.TemplateType, request.TemplateName)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) putTemplate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = PutTemplate(client, request.TemplateType, request.TemplateName, request.Body)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) deleteTemplate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = DeleteTemplate(client, request.TemplateType, request.TemplateName)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) ilmPolicies(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = GetIlmPolicies(client, request.PolicyName)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) putIlmPolicy(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = PutIlmPolicy(client, request.PolicyName, request.Body)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) deleteIlmPolicy(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = DeleteIlmPolicy(client, request.PolicyName)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) ilmExplain(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = IlmExplain(client, request.IndexName)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) snapshotRepositories(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = GetSnapshotRepositories(client, request.Repository)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) putSnapshotRepository(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = PutSnapshotRepository(client, request.Repository, request.RepositoryType, request.Settings)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) deleteSnapshotRepository(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = DeleteSnapshotRepository(client, request.Repository)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) snapshots(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = GetSnapshots(client, request.Repository)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) createSnapshot(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &SnapshotCreateRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = CreateSnapshot(client, request)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) deleteSnapshot(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = DeleteSnapshot(client, request.Repository, request.Snapshot)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) restoreSnapshot(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	client, err := this_.getClient(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &SnapshotRestoreRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = RestoreSnapshot(client, request)
+	if err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,200 @@
+package module_elasticsearch
+
+import (
+	"context"
+	"errors"
+	"github.com/olivere/elastic/v7"
+	"github.com/team-ide/go-tool/elasticsearch"
+	"github.com/team-ide/go-tool/util"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// getClient 获取 elasticsearch 原生客户端，用于 IService 未提供的集群接口
+func getClient(service elasticsearch.IService) (client *elastic.Client, err error) {
+	v7Service, ok := service.(*elasticsearch.V7Service)
+	if !ok {
+		err = errors.New("elasticsearch service not support client")
+		return
+	}
+	client, err = v7Service.GetClient()
+	return
+}
+
+// performRequest 执行请求 并将返回的 JSON 解析为结构化数据
+func performRequest(client *elastic.Client, method string, path string, params url.Values, body interface{}) (res interface{}, err error) {
+	response, err := client.PerformRequest(context.Background(), elastic.PerformRequestOptions{
+		Method: method,
+		Path:   path,
+		Params: params,
+		Body:   body,
+	})
+	if err != nil {
+		return
+	}
+	if len(response.Body) == 0 {
+		return
+	}
+	err = util.JSONDecodeUseNumber(response.Body, &res)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// ClusterHealth 集群健康状态，指定索引时返回索引和分片级别的状态
+func ClusterHealth(client *elastic.Client, indexName string) (res *elastic.ClusterHealthResponse, err error) {
+	s := client.ClusterHealth()
+	if indexName != "" {
+		s.Index(indexName).Level("shards")
+	} else {
+		s.Level("indices")
+	}
+	res, err = s.Do(context.Background())
+	if err != nil {
+		return
+	}
+	return
+}
+
+// CatNodes 节点列表及资源使用情况
+func CatNodes(client *elastic.Client) (res interface{}, err error) {
+	params := url.Values{}
+	params.Set("format", "json")
+	params.Set("bytes", "b")
+	params.Set("h", "id,name,ip,port,version,node.role,master,heap.percent,heap.max,ram.percent,ram.max,cpu,load_1m,load_5m,load_15m,disk.used_percent,disk.total,uptime")
+	params.Set("full_id", "true")
+	res, err = performRequest(client, "GET", "/_cat/nodes", params, nil)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// CatShards 分片分配情况
+func CatShards(client *elastic.Client, indexName string) (res elastic.CatShardsResponse, err error) {
+	s := client.CatShards().Bytes("b")
+	if indexName != "" {
+		s.Index(indexName)
+	}
+	res, err = s.Do(context.Background())
+	if err != nil {
+		return
+	}
+	return
+}
+
+// AllocationExplain 分片分配原因，不指定索引时解释第一个未分配的分片
+func AllocationExplain(client *elastic.Client, indexName string, shard int, primary bool) (res interface{}, err error) {
+	params := url.Values{}
+	params.Set("include_yes_decisions", "false")
+	var body interface{}
+	if indexName != "" {
+		body = map[string]interface{}{
+			"index":   indexName,
+			"shard":   shard,
+			"primary": primary,
+		}
+	}
+	res, err = performRequest(client, "POST", "/_cluster/allocation/explain", params, body)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// PendingTasks 集群等待执行的任务
+func PendingTasks(client *elastic.Client) (res interface{}, err error) {
+	res, err = performRequest(client, "GET", "/_cluster/pending_tasks", nil, nil)
+	if err != nil {
+		return
+	}
+	return
+}
+
+type HotThreadsNode struct {
+	Name    string   `json:"name"`
+	Id      string   `json:"id"`
+	Address string   `json:"address"`
+	Header  string   `json:"header"`
+	Threads []string `json:"threads"`
+}
+
+// HotThreads 节点热点线程，将文本结果按节点和线程拆分
+func HotThreads(client *elastic.Client, nodeId string, threads int, threadType string) (res []*HotThreadsNode, err error) {
+	path := "/_nodes/hot_threads"
+	if nodeId != "" {
+		path = "/_nodes/" + url.PathEscape(nodeId) + "/hot_threads"
+	}
+	params := url.Values{}
+	if threads > 0 {
+		params.Set("threads", strconv.Itoa(threads))
+	}
+	if threadType != "" {
+		params.Set("type", threadType)
+	}
+	response, err := client.PerformRequest(context.Background(), elastic.PerformRequestOptions{
+		Method: "GET",
+		Path:   path,
+		Params: params,
+	})
+	if err != nil {
+		return
+	}
+	res = parseHotThreads(string(response.Body))
+	return
+}
+
+var (
+	hotThreadsNodeRegexp   = regexp.MustCompile(`^:::\s*\{(.*?)}\{(.*?)}\{.*?}\{(.*?)}`)
+	hotThreadsThreadRegexp = regexp.MustCompile(`^\s+[\d.]+%`)
+)
+
+func parseHotThreads(text string) (res []*HotThreadsNode) {
+	var node *HotThreadsNode
+	var thread []string
+	endThread := func() {
+		if node != nil && len(thread) > 0 {
+			node.Threads = append(node.Threads, strings.TrimRight(strings.Join(thread, "\n"), "\n "))
+		}
+		thread = nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, ":::") {
+			endThread()
+			node = &HotThreadsNode{}
+			match := hotThreadsNodeRegexp.FindStringSubmatch(line)
+			if len(match) > 0 {
+				node.Name = match[1]
+				node.Id = match[2]
+				node.Address = match[3]
+			} else {
+				node.Name = strings.TrimSpace(strings.TrimPrefix(line, ":::"))
+			}
+			res = append(res, node)
+			continue
+		}
+		if node == nil {
+			continue
+		}
+		if hotThreadsThreadRegexp.MatchString(line) {
+			endThread()
+			thread = append(thread, strings.TrimSpace(line))
+			continue
+		}
+		if thread != nil {
+			thread = append(thread, line)
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			if node.Header != "" {
+				node.Header += "\n"
+			}
+			node.Header += strings.TrimSpace(line)
+		}
+	}
+	endThread()
+	return
+}
@@ -0,0 +1,421 @@
+package module_elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/olivere/elastic/v7"
+	"github.com/team-ide/go-tool/elasticsearch"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"io"
+	"sync"
+	"time"
+)
+
+var (
+	copyTaskCache     = map[string]*CopyTask{}
+	copyTaskCacheLock = &sync.Mutex{}
+)
+
+// CopyTask 跨集群复制索引，从源工具滚动读取数据，批量写入目标工具
+type CopyTask struct {
+	TaskId          string                 `json:"taskId,omitempty"`
+	TaskType        string                 `json:"taskType,omitempty"`
+	SourceIndexName string                 `json:"sourceIndexName,omitempty"`
+	DestToolboxId   int64                  `json:"destToolboxId,omitempty"`
+	DestIndexName   string                 `json:"destIndexName,omitempty"`
+	CopyMapping     bool                   `json:"copyMapping"`
+	Query           map[string]interface{} `json:"query,omitempty"`
+	BatchNumber     int                    `json:"batchNumber,omitempty"`
+	// 每秒最多写入的数据条数，小于等于0不限制
+	RequestsPerSecond float64 `json:"requestsPerSecond,omitempty"`
+	// 滚动查询上下文保持时间，停止后需要在该时间内继续任务
+	ScrollKeepAlive string `json:"scrollKeepAlive,omitempty"`
+	ErrorContinue   bool   `json:"errorContinue"`
+
+	ScrollId     string `json:"scrollId,omitempty"`
+	Total        int64  `json:"total"`
+	ReadCount    int64  `json:"readCount"`
+	SuccessCount int64  `json:"successCount"`
+	ErrorCount   int64  `json:"errorCount"`
+	Percent      string `json:"percent,omitempty"`
+
+	IsEnd     bool      `json:"isEnd"`
+	IsStop    bool      `json:"isStop"`
+	StartTime time.Time `json:"startTime,omitempty"`
+	NowTime   time.Time `json:"nowTime,omitempty"`
+	EndTime   time.Time `json:"endTime,omitempty"`
+	UseTime   int64     `json:"useTime"`
+	Error     string    `json:"error,omitempty"`
+
+	// 创建任务的用户，只能继续自己的任务
+	userId int64
+	// 写入失败的数据，继续任务时重新写入
+	retryHits []*elastic.SearchHit
+	// 任务已清理，结束时需要清理滚动查询
+	cleaned bool
+
+	sourceClient *elastic.Client
+	destClient   *elastic.Client
+	lock         *sync.Mutex
+}
+
+func StartCopyTask(task *CopyTask) {
+	copyTaskCacheLock.Lock()
+	defer copyTaskCacheLock.Unlock()
+
+	if task.TaskId == "" {
+		task.TaskId = util.GetUUID()
+	}
+	task.TaskType = "copy"
+	task.lock = &sync.Mutex{}
+	copyTaskCache[task.TaskId] = task
+	go task.Start()
+}
+
+func GetCopyTask(taskId string) *CopyTask {
+	copyTaskCacheLock.Lock()
+	defer copyTaskCacheLock.Unlock()
+
+	task := copyTaskCache[taskId]
+	if task == nil {
+		return nil
+	}
+	return task.info()
+}
+
+func StopCopyTask(taskId string) *CopyTask {
+	copyTaskCacheLock.Lock()
+	defer copyTaskCacheLock.Unlock()
+
+	task := copyTaskCache[taskId]
+	if task == nil {
+		return nil
+	}
+	task.Stop()
+	return task.info()
+}
+
+func CleanCopyTask(taskId string) *CopyTask {
+	copyTaskCacheLock.Lock()
+	defer copyTaskCacheLock.Unlock()
+
+	task := copyTaskCache[taskId]
+	if task == nil {
+		return nil
+	}
+	delete(copyTaskCache, taskId)
+
+	task.lock.Lock()
+	task.IsStop = true
+	task.cleaned = true
+	isEnd := task.IsEnd
+	task.lock.Unlock()
+	// 运行中的任务在结束时清理滚动查询
+	if isEnd {
+		task.clearScroll()
+	}
+	return task.info()
+}
+
+// ResumeCopyTask 继续已停止或异常结束的任务，从结束时的滚动位置继续复制
+func ResumeCopyTask(taskId string, userId int64) (res *CopyTask, err error) {
+	copyTaskCacheLock.Lock()
+	defer copyTaskCacheLock.Unlock()
+
+	task := copyTaskCache[taskId]
+	if task == nil || task.userId != userId {
+		err = errors.New("task [" + taskId + "] not found")
+		return
+	}
+	task.lock.Lock()
+	if !task.IsEnd {
+		task.lock.Unlock()
+		err = errors.New("task [" + taskId + "] is running")
+		return
+	}
+	if task.ScrollId == "" && len(task.retryHits) == 0 {
+		task.lock.Unlock()
+		err = errors.New("task [" + taskId + "] has no scroll to resume, please restart the task")
+		return
+	}
+	task.IsStop = false
+	task.IsEnd = false
+	task.Error = ""
+	task.lock.Unlock()
+
+	go task.Start()
+	res = task.info()
+	return
+}
+
+// info 任务当前状态的副本，用于返回给前端
+func (this_ *CopyTask) info() *CopyTask {
+	this_.Statistics()
+
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	res := *this_
+	res.retryHits = nil
+	res.sourceClient = nil
+	res.destClient = nil
+	return &res
+}
+
+// clearScroll 清理源集群上的滚动查询
+func (this_ *CopyTask) clearScroll() {
+	this_.lock.Lock()
+	scrollId := this_.ScrollId
+	this_.ScrollId = ""
+	this_.retryHits = nil
+	this_.lock.Unlock()
+
+	if this_.sourceClient != nil && scrollId != "" {
+		_, _ = this_.sourceClient.ClearScroll(scrollId).Do(context.Background())
+	}
+}
+
+func (this_ *CopyTask) Statistics() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.Total > 0 {
+		this_.Percent = fmt.Sprintf("%.2f", float64(this_.ReadCount*100)/float64(this_.Total))
+	}
+	if this_.StartTime.IsZero() {
+		return
+	}
+	if this_.IsEnd {
+		this_.NowTime = this_.EndTime
+	} else {
+		this_.NowTime = time.Now()
+	}
+	this_.UseTime = util.GetMilliByTime(this_.NowTime) - util.GetMilliByTime(this_.StartTime)
+}
+
+func (this_ *CopyTask) Stop() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.IsStop = true
+}
+
+func (this_ *CopyTask) needStop() bool {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	return this_.IsStop
+}
+
+func (this_ *CopyTask) Start() {
+	this_.lock.Lock()
+	if this_.StartTime.IsZero() {
+		this_.StartTime = time.Now()
+	}
+	this_.lock.Unlock()
+
+	var err error
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New(fmt.Sprint(e))
+		}
+		if err != nil {
+			util.Logger.Error("复制任务执行异常", zap.Any("taskId", this_.TaskId), zap.Error(err))
+		}
+		this_.lock.Lock()
+		if err != nil {
+			this_.Error = err.Error()
+		}
+		this_.EndTime = time.Now()
+		this_.IsEnd = true
+		cleaned := this_.cleaned
+		this_.lock.Unlock()
+
+		if cleaned {
+			this_.clearScroll()
+		}
+		this_.Statistics()
+	}()
+
+	if this_.SourceIndexName == "" || this_.DestIndexName == "" {
+		err = errors.New("必须配置sourceIndexName和destIndexName")
+		return
+	}
+	if this_.sourceClient == nil || this_.destClient == nil {
+		err = errors.New("source or dest client is null")
+		return
+	}
+	this_.lock.Lock()
+	scrollId := this_.ScrollId
+	this_.lock.Unlock()
+	if scrollId == "" && this_.CopyMapping {
+		err = this_.copyMapping()
+		if err != nil {
+			return
+		}
+	}
+	err = this_.doCopy()
+}
+
+func (this_ *CopyTask) copyMapping() (err error) {
+	ctx := context.Background()
+	exists, err := this_.destClient.IndexExists(this_.DestIndexName).Do(ctx)
+	if err != nil {
+		return
+	}
+	if exists {
+		return
+	}
+	mappingRes, err := this_.sourceClient.GetMapping().Index(this_.SourceIndexName).Do(ctx)
+	if err != nil {
+		return
+	}
+	body := map[string]interface{}{}
+	// 源索引可能是别名，取第一个索引的结构
+	for _, one := range mappingRes {
+		indexMapping, ok := one.(map[string]interface{})
+		if ok && indexMapping["mappings"] != nil {
+			body["mappings"] = indexMapping["mappings"]
+		}
+		break
+	}
+	_, err = this_.destClient.CreateIndex(this_.DestIndexName).BodyJson(body).Do(ctx)
+	if err != nil {
+		err = errors.New("create dest index error:" + err.Error())
+		return
+	}
+	return
+}
+
+func (this_ *CopyTask) doCopy() (err error) {
+	ctx := context.Background()
+
+	batchNumber := this_.BatchNumber
+	if batchNumber <= 0 {
+		batchNumber = 500
+	}
+	keepAlive := this_.ScrollKeepAlive
+	if keepAlive == "" {
+		keepAlive = "30m"
+	}
+
+	scroll := this_.sourceClient.Scroll(this_.SourceIndexName).Size(batchNumber).KeepAlive(keepAlive).TrackTotalHits(true)
+	if len(this_.Query) > 0 {
+		var bs []byte
+		bs, err = json.Marshal(this_.Query)
+		if err != nil {
+			return
+		}
+		scroll.Query(elastic.NewRawStringQuery(string(bs)))
+	}
+	this_.lock.Lock()
+	if this_.ScrollId != "" {
+		scroll.ScrollId(this_.ScrollId)
+	}
+	// 上次写入失败的数据先重新写入
+	hits := this_.retryHits
+	this_.retryHits = nil
+	this_.lock.Unlock()
+
+	for !this_.needStop() {
+		var startTime = time.Now()
+		if len(hits) == 0 {
+			var searchResult *elastic.SearchResult
+			searchResult, err = scroll.Do(ctx)
+			if err == io.EOF {
+				err = nil
+				this_.clearScroll()
+				return
+			}
+			if err != nil {
+				err = errors.New("scroll source error:" + err.Error())
+				return
+			}
+			if searchResult.Hits == nil || len(searchResult.Hits.Hits) == 0 {
+				this_.lock.Lock()
+				this_.ScrollId = searchResult.ScrollId
+				this_.lock.Unlock()
+				this_.clearScroll()
+				return
+			}
+			this_.lock.Lock()
+			this_.ScrollId = searchResult.ScrollId
+			if this_.Total == 0 && searchResult.Hits.TotalHits != nil {
+				this_.Total = searchResult.Hits.TotalHits.Value
+			}
+			this_.lock.Unlock()
+			hits = searchResult.Hits.Hits
+		}
+
+		bulk := this_.destClient.Bulk()
+		for _, hit := range hits {
+			bulk.Add(elastic.NewBulkIndexRequest().Index(this_.DestIndexName).Id(hit.Id).Doc(hit.Source))
+		}
+		size := int64(len(hits))
+
+		var bulkResponse *elastic.BulkResponse
+		bulkResponse, err = bulk.Do(ctx)
+		if err != nil {
+			util.Logger.Error("copy bulk error", zap.Any("taskId", this_.TaskId), zap.Any("size", size), zap.Error(err))
+			if !this_.ErrorContinue {
+				// 保留失败的数据，继续任务时重新写入
+				this_.lock.Lock()
+				this_.retryHits = hits
+				this_.lock.Unlock()
+				err = errors.New("bulk dest error:" + err.Error())
+				return
+			}
+		}
+
+		this_.lock.Lock()
+		this_.ReadCount += size
+		if err != nil {
+			this_.ErrorCount += size
+		} else {
+			failed := int64(len(bulkResponse.Failed()))
+			this_.ErrorCount += failed
+			this_.SuccessCount += size - failed
+		}
+		this_.lock.Unlock()
+		err = nil
+		hits = nil
+
+		this_.throttle(startTime, size)
+	}
+	return
+}
+
+// throttle 按 RequestsPerSecond 限制写入速度
+func (this_ *CopyTask) throttle(startTime time.Time, size int64) {
+	if this_.RequestsPerSecond <= 0 {
+		return
+	}
+	expect := time.Duration(float64(size) / this_.RequestsPerSecond * float64(time.Second))
+	wait := expect - time.Since(startTime)
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// getCopyTasks 工作区下的复制任务
+func getCopyTasks(taskIds []string) (taskList []*CopyTask) {
+	for _, id := range taskIds {
+		task := GetCopyTask(id)
+		if task != nil {
+			taskList = append(taskList, task)
+		}
+	}
+	return
+}
+
+func newCopyTaskClient(config *elasticsearch.Config) (client *elastic.Client, err error) {
+	service, err := getService(config)
+	if err != nil {
+		return
+	}
+	client, err = getClient(service)
+	return
+}
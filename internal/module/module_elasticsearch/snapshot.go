@@ -0,0 +1,160 @@
+package module_elasticsearch
+
+import (
+	"errors"
+	"github.com/olivere/elastic/v7"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GetSnapshotRepositories 查询快照仓库，名称为空查询全部
+func GetSnapshotRepositories(client *elastic.Client, repository string) (res interface{}, err error) {
+	path := "/_snapshot"
+	if repository != "" {
+		path += "/" + url.PathEscape(repository)
+	}
+	res, err = performRequest(client, "GET", path, nil, nil)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// PutSnapshotRepository 创建或修改快照仓库，repositoryType 如 fs、s3、hdfs
+func PutSnapshotRepository(client *elastic.Client, repository string, repositoryType string, settings map[string]interface{}) (res interface{}, err error) {
+	if repository == "" {
+		err = errors.New("repository is empty")
+		return
+	}
+	if repositoryType == "" {
+		err = errors.New("repository type is empty")
+		return
+	}
+	body := map[string]interface{}{
+		"type":     repositoryType,
+		"settings": settings,
+	}
+	res, err = performRequest(client, "PUT", "/_snapshot/"+url.PathEscape(repository), nil, body)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// DeleteSnapshotRepository 删除快照仓库，不会删除仓库中的快照文件
+func DeleteSnapshotRepository(client *elastic.Client, repository string) (res interface{}, err error) {
+	if repository == "" {
+		err = errors.New("repository is empty")
+		return
+	}
+	res, err = performRequest(client, "DELETE", "/_snapshot/"+url.PathEscape(repository), nil, nil)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// GetSnapshots 查询仓库中的快照
+func GetSnapshots(client *elastic.Client, repository string) (res interface{}, err error) {
+	if repository == "" {
+		err = errors.New("repository is empty")
+		return
+	}
+	res, err = performRequest(client, "GET", "/_snapshot/"+url.PathEscape(repository)+"/_all", nil, nil)
+	if err != nil {
+		return
+	}
+	return
+}
+
+type SnapshotCreateRequest struct {
+	Repository         string `json:"repository"`
+	Snapshot           string `json:"snapshot"`
+	Indices            string `json:"indices"`
+	IgnoreUnavailable  bool   `json:"ignoreUnavailable"`
+	IncludeGlobalState bool   `json:"includeGlobalState"`
+	WaitForCompletion  bool   `json:"waitForCompletion"`
+}
+
+// CreateSnapshot 创建快照，Indices 为空时备份全部索引
+func CreateSnapshot(client *elastic.Client, request *SnapshotCreateRequest) (res interface{}, err error) {
+	if request.Repository == "" || request.Snapshot == "" {
+		err = errors.New("repository or snapshot is empty")
+		return
+	}
+	params := url.Values{}
+	params.Set("wait_for_completion", strconv.FormatBool(request.WaitForCompletion))
+	body := map[string]interface{}{
+		"ignore_unavailable":   request.IgnoreUnavailable,
+		"include_global_state": request.IncludeGlobalState,
+	}
+	if strings.TrimSpace(request.Indices) != "" {
+		body["indices"] = request.Indices
+	}
+	res, err = performRequest(client, "PUT", "/_snapshot/"+url.PathEscape(request.Repository)+"/"+url.PathEscape(request.Snapshot), params, body)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// DeleteSnapshot 删除快照
+func DeleteSnapshot(client *elastic.Client, repository string, snapshot string) (res interface{}, err error) {
+	if repository == "" || snapshot == "" {
+		err = errors.New("repository or snapshot is empty")
+		return
+	}
+	res, err = performRequest(client, "DELETE", "/_snapshot/"+url.PathEscape(repository)+"/"+url.PathEscape(snapshot), nil, nil)
+	if err != nil {
+		return
+	}
+	return
+}
+
+type SnapshotRestoreRequest struct {
+	Repository         string                 `json:"repository"`
+	Snapshot           string                 `json:"snapshot"`
+	Indices            string                 `json:"indices"`
+	RenamePattern      string                 `json:"renamePattern"`
+	RenameReplacement  string                 `json:"renameReplacement"`
+	IncludeAliases     bool                   `json:"includeAliases"`
+	IncludeGlobalState bool                   `json:"includeGlobalState"`
+	Partial            bool                   `json:"partial"`
+	IndexSettings      map[string]interface{} `json:"indexSettings"`
+	WaitForCompletion  bool                   `json:"waitForCompletion"`
+}
+
+// RestoreSnapshot 恢复快照，可通过 RenamePattern 和 RenameReplacement 恢复为新的索引名称
+func RestoreSnapshot(client *elastic.Client, request *SnapshotRestoreRequest) (res interface{}, err error) {
+	if request.Repository == "" || request.Snapshot == "" {
+		err = errors.New("repository or snapshot is empty")
+		return
+	}
+	if request.RenamePattern != "" && request.RenameReplacement == "" {
+		err = errors.New("rename replacement is empty")
+		return
+	}
+	params := url.Values{}
+	params.Set("wait_for_completion", strconv.FormatBool(request.WaitForCompletion))
+	body := map[string]interface{}{
+		"include_aliases":      request.IncludeAliases,
+		"include_global_state": request.IncludeGlobalState,
+		"partial":              request.Partial,
+	}
+	if strings.TrimSpace(request.Indices) != "" {
+		body["indices"] = request.Indices
+	}
+	if request.RenamePattern != "" {
+		body["rename_pattern"] = request.RenamePattern
+		body["rename_replacement"] = request.RenameReplacement
+	}
+	if len(request.IndexSettings) > 0 {
+		body["index_settings"] = request.IndexSettings
+	}
+	res, err = performRequest(client, "POST", "/_snapshot/"+url.PathEscape(request.Repository)+"/"+url.PathEscape(request.Snapshot)+"/_restore", params, body)
+	if err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,125 @@
+package module_elasticsearch
+
+import (
+	"errors"
+	"github.com/olivere/elastic/v7"
+	"net/url"
+)
+
+// getTemplatePath 模板类型：index（索引模板）、component（组件模板）、legacy（旧版模板）
+func getTemplatePath(templateType string, name string) (path string, err error) {
+	switch templateType {
+	case "", "index":
+		path = "/_index_template"
+	case "component":
+		path = "/_component_template"
+	case "legacy":
+		path = "/_template"
+	default:
+		err = errors.New("template type [" + templateType + "] not support")
+		return
+	}
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return
+}
+
+// GetTemplates 查询模板，名称为空查询全部
+func GetTemplates(client *elastic.Client, templateType string, name string) (res interface{}, err error) {
+	path, err := getTemplatePath(templateType, name)
+	if err != nil {
+		return
+	}
+	res, err = performRequest(client, "GET", path, nil, nil)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// PutTemplate 创建或修改模板
+func PutTemplate(client *elastic.Client, templateType string, name string, body map[string]interface{}) (res interface{}, err error) {
+	if name == "" {
+		err = errors.New("template name is empty")
+		return
+	}
+	path, err := getTemplatePath(templateType, name)
+	if err != nil {
+		return
+	}
+	res, err = performRequest(client, "PUT", path, nil, body)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// DeleteTemplate 删除模板
+func DeleteTemplate(client *elastic.Client, templateType string, name string) (res interface{}, err error) {
+	if name == "" {
+		err = errors.New("template name is empty")
+		return
+	}
+	path, err := getTemplatePath(templateType, name)
+	if err != nil {
+		return
+	}
+	res, err = performRequest(client, "DELETE", path, nil, nil)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// GetIlmPolicies 查询生命周期策略，名称为空查询全部
+func GetIlmPolicies(client *elastic.Client, name string) (res interface{}, err error) {
+	path := "/_ilm/policy"
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	res, err = performRequest(client, "GET", path, nil, nil)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// PutIlmPolicy 创建或修改生命周期策略，body 为 {"policy":{...}}
+func PutIlmPolicy(client *elastic.Client, name string, body map[string]interface{}) (res interface{}, err error) {
+	if name == "" {
+		err = errors.New("policy name is empty")
+		return
+	}
+	res, err = performRequest(client, "PUT", "/_ilm/policy/"+url.PathEscape(name), nil, body)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// DeleteIlmPolicy 删除生命周期策略
+func DeleteIlmPolicy(client *elastic.Client, name string) (res interface{}, err error) {
+	if name == "" {
+		err = errors.New("policy name is empty")
+		return
+	}
+	res, err = performRequest(client, "DELETE", "/_ilm/policy/"+url.PathEscape(name), nil, nil)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// IlmExplain 查看索引当前所处的生命周期阶段
+func IlmExplain(client *elastic.Client, indexName string) (res interface{}, err error) {
+	if indexName == "" {
+		err = errors.New("index name is empty")
+		return
+	}
+	res, err = performRequest(client, "GET", "/"+url.PathEscape(indexName)+"/_ilm/explain", nil, nil)
+	if err != nil {
+		return
+	}
+	return
+}
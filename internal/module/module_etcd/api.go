@@ -0,0 +1,418 @@
+package module_etcd
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+)
+
+type api struct {
+	toolboxService *module_toolbox.ToolboxService
+}
+
+func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
+	return &api{
+		toolboxService: toolboxService,
+	}
+}
+
+var (
+	// Etcd 权限
+	Power               = base.AppendPower(&base.PowerAction{Action: "etcd", Text: "Etcd", ShouldLogin: true, StandAlone: true})
+	keysPower           = base.AppendPower(&base.PowerAction{Action: "keys", Text: "Etcd查询键", ShouldLogin: true, StandAlone: true, Parent: Power})
+	getPower            = base.AppendPower(&base.PowerAction{Action: "get", Text: "Etcd获取键值", ShouldLogin: true, StandAlone: true, Parent: Power})
+	putPower            = base.AppendPower(&base.PowerAction{Action: "put", Text: "Etcd保存键值", ShouldLogin: true, StandAlone: true, Parent: Power})
+	deletePower         = base.AppendPower(&base.PowerAction{Action: "delete", Text: "Etcd删除键", ShouldLogin: true, StandAlone: true, Parent: Power})
+	historyPower        = base.AppendPower(&base.PowerAction{Action: "history", Text: "Etcd修改历史", ShouldLogin: true, StandAlone: true, Parent: Power})
+	leasesPower         = base.AppendPower(&base.PowerAction{Action: "leases", Text: "Etcd租约列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	leaseInfoPower      = base.AppendPower(&base.PowerAction{Action: "leaseInfo", Text: "Etcd租约信息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	leaseGrantPower     = base.AppendPower(&base.PowerAction{Action: "leaseGrant", Text: "Etcd创建租约", ShouldLogin: true, StandAlone: true, Parent: Power})
+	leaseRevokePower    = base.AppendPower(&base.PowerAction{Action: "leaseRevoke", Text: "Etcd撤销租约", ShouldLogin: true, StandAlone: true, Parent: Power})
+	leaseKeepAlivePower = base.AppendPower(&base.PowerAction{Action: "leaseKeepAlive", Text: "Etcd续约", ShouldLogin: true, StandAlone: true, Parent: Power})
+	membersPower        = base.AppendPower(&base.PowerAction{Action: "members", Text: "Etcd成员列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	endpointStatusPower = base.AppendPower(&base.PowerAction{Action: "endpointStatus", Text: "Etcd节点状态", ShouldLogin: true, StandAlone: true, Parent: Power})
+	watchPower          = base.AppendPower(&base.PowerAction{Action: "watch", Text: "Etcd监听键", ShouldLogin: true, StandAlone: true, Parent: Power})
+	unwatchPower        = base.AppendPower(&base.PowerAction{Action: "unwatch", Text: "Etcd取消监听", ShouldLogin: true, StandAlone: true, Parent: Power})
+	watchesPower        = base.AppendPower(&base.PowerAction{Action: "watches", Text: "Etcd监听列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	closePower          = base.AppendPower(&base.PowerAction{Action: "close", Text: "Etcd关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+	apis = append(apis, &base.ApiWorker{Power: keysPower, Do: this_.keys})
+	apis = append(apis, &base.ApiWorker{Power: getPower, Do: this_.get})
+	apis = append(apis, &base.ApiWorker{Power: putPower, Do: this_.put})
+	apis = append(apis, &base.ApiWorker{Power: deletePower, Do: this_.delete})
+	apis = append(apis, &base.ApiWorker{Power: historyPower, Do: this_.history})
+	apis = append(apis, &base.ApiWorker{Power: leasesPower, Do: this_.leases})
+	apis = append(apis, &base.ApiWorker{Power: leaseInfoPower, Do: this_.leaseInfo})
+	apis = append(apis, &base.ApiWorker{Power: leaseGrantPower, Do: this_.leaseGrant})
+	apis = append(apis, &base.ApiWorker{Power: leaseRevokePower, Do: this_.leaseRevoke})
+	apis = append(apis, &base.ApiWorker{Power: leaseKeepAlivePower, Do: this_.leaseKeepAlive})
+	apis = append(apis, &base.ApiWorker{Power: membersPower, Do: this_.members})
+	apis = append(apis, &base.ApiWorker{Power: endpointStatusPower, Do: this_.endpointStatus})
+	apis = append(apis, &base.ApiWorker{Power: watchPower, Do: this_.watch})
+	apis = append(apis, &base.ApiWorker{Power: unwatchPower, Do: this_.unwatch})
+	apis = append(apis, &base.ApiWorker{Power: watchesPower, Do: this_.watches})
+	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
+
+	return
+}
+
+func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (config *Config, sshConfig *ssh.Config, err error) {
+	config = &Config{}
+	sshConfig, err = this_.toolboxService.BindConfig(requestBean, c, config)
+	if err != nil {
+		return
+	}
+	config.Password = this_.toolboxService.DecryptOptionAttr(config.Password)
+	if config.CaCertPath != "" {
+		config.CaCertPath = this_.toolboxService.GetFilesFile(config.CaCertPath)
+	}
+	if config.CertPath != "" {
+		config.CertPath = this_.toolboxService.GetFilesFile(config.CertPath)
+	}
+	if config.KeyPath != "" {
+		config.KeyPath = this_.toolboxService.GetFilesFile(config.KeyPath)
+	}
+	return
+}
+
+func getService(etcdConfig *Config, sshConfig *ssh.Config) (res *Service, err error) {
+	key := "etcd-" + etcdConfig.Endpoints
+	if etcdConfig.Username != "" {
+		key += "-" + base.GetMd5String(key+etcdConfig.Username)
+	}
+	if etcdConfig.Password != "" {
+		key += "-" + base.GetMd5String(key+etcdConfig.Password)
+	}
+	if etcdConfig.isTLS() {
+		key += "-tls-" + base.GetMd5String(etcdConfig.CaCertPath+etcdConfig.CertPath+etcdConfig.KeyPath+etcdConfig.ServerName+etcdConfig.InsecureSkipVerify)
+	}
+	if sshConfig != nil {
+		key += "-ssh-" + sshConfig.Address
+		key += "-ssh-" + sshConfig.Username
+	}
+	var serviceInfo *base.ServiceInfo
+	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
+		var s *Service
+		s, err = NewService(etcdConfig, sshConfig)
+		if err != nil {
+			util.Logger.Error("getEtcdService error", zap.Any("key", key), zap.Error(err))
+			return
+		}
+		err = s.Ping()
+		if err != nil {
+			util.Logger.Error("getEtcdService error", zap.Any("key", key), zap.Error(err))
+			s.Close()
+			return
+		}
+		res = &base.ServiceInfo{
+			WaitTime:    10 * 60 * 1000,
+			LastUseTime: util.GetNowMilli(),
+			Service:     s,
+			Stop:        s.Close,
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = serviceInfo.Service.(*Service)
+	serviceInfo.SetLastUseTime()
+	return
+}
+
+type BaseRequest struct {
+	WorkerId      string    `json:"workerId"`
+	Key           string    `json:"key"`
+	Prefix        bool      `json:"prefix"`
+	Separator     string    `json:"separator"`
+	Limit         int64     `json:"limit"`
+	Revision      int64     `json:"revision"`
+	StartRevision int64     `json:"startRevision"`
+	EndRevision   int64     `json:"endRevision"`
+	Put           *PutParam `json:"put"`
+	LeaseId       int64     `json:"leaseId"`
+	TTL           int64     `json:"ttl"`
+	WatchId       string    `json:"watchId"`
+}
+
+func (this_ *api) keys(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = service.Keys(request.Key, request.Separator, request.Limit)
+	return
+}
+
+func (this_ *api) get(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = service.Get(request.Key, request.Revision)
+	return
+}
+
+func (this_ *api) put(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Put == nil {
+		request.Put = &PutParam{Key: request.Key}
+	}
+
+	res, err = service.Put(request.Put)
+	return
+}
+
+func (this_ *api) delete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = service.Delete(request.Key, request.Prefix)
+	return
+}
+
+func (this_ *api) history(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = service.History(request.Key, request.Prefix, request.StartRevision, request.EndRevision, int(request.Limit))
+	return
+}
+
+func (this_ *api) leases(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	res, err = service.Leases()
+	return
+}
+
+func (this_ *api) leaseInfo(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = service.LeaseInfo(request.LeaseId)
+	return
+}
+
+func (this_ *api) leaseGrant(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = service.LeaseGrant(request.TTL)
+	return
+}
+
+func (this_ *api) leaseRevoke(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	err = service.LeaseRevoke(request.LeaseId)
+	return
+}
+
+func (this_ *api) leaseKeepAlive(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = service.LeaseKeepAliveOnce(request.LeaseId)
+	return
+}
+
+func (this_ *api) members(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	res, err = service.Members()
+	return
+}
+
+func (this_ *api) endpointStatus(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	res = service.EndpointStatus()
+	return
+}
+
+func (this_ *api) watch(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	// 先校验连接，避免监听协程反复重试
+	_, err = getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	w := &watcher{
+		WorkerId:     request.WorkerId,
+		Key:          request.Key,
+		Prefix:       request.Prefix,
+		Revision:     request.Revision,
+		clientTabKey: requestBean.ClientTabKey,
+		config:       config,
+		sshConfig:    sshConfig,
+	}
+	err = startWatcher(w)
+	if err != nil {
+		return
+	}
+	res = w.info()
+	return
+}
+
+func (this_ *api) unwatch(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	stopWatcher(request.WatchId)
+	return
+}
+
+func (this_ *api) watches(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res = getWorkerWatchers(request.WorkerId)
+	return
+}
+
+func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.WorkerId != "" {
+		stopWorkerWatchers(request.WorkerId)
+	}
+	return
+}
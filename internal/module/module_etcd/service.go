@@ -0,0 +1,589 @@
+package module_etcd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	goSSH "golang.org/x/crypto/ssh"
+	"google.golang.org/grpc"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"teamide/pkg/ssh"
+	"time"
+	"unicode/utf8"
+)
+
+type Config struct {
+	// 连接地址 127.0.0.1:2379，多个地址逗号分隔
+	Endpoints string `json:"endpoints"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	// TLS 证书，配置了 CA 或客户端证书时使用 TLS 连接
+	CaCertPath         string `json:"caCertPath"`
+	CertPath           string `json:"certPath"`
+	KeyPath            string `json:"keyPath"`
+	ServerName         string `json:"serverName"`
+	InsecureSkipVerify string `json:"insecureSkipVerify"`
+}
+
+func (this_ *Config) getEndpoints() (endpoints []string) {
+	for _, one := range strings.Split(this_.Endpoints, ",") {
+		one = strings.TrimSpace(one)
+		if one != "" {
+			endpoints = append(endpoints, one)
+		}
+	}
+	return
+}
+
+func (this_ *Config) isTLS() bool {
+	return this_.CaCertPath != "" || this_.CertPath != "" || this_.InsecureSkipVerify == "1" ||
+		strings.HasPrefix(strings.TrimSpace(this_.Endpoints), "https://")
+}
+
+func (this_ *Config) newTLSConfig() (res *tls.Config, err error) {
+	res = &tls.Config{
+		ServerName:         this_.ServerName,
+		InsecureSkipVerify: this_.InsecureSkipVerify == "1",
+	}
+	if this_.CaCertPath != "" {
+		var bs []byte
+		bs, err = os.ReadFile(this_.CaCertPath)
+		if err != nil {
+			err = errors.New("read ca cert error:" + err.Error())
+			return
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bs) {
+			err = errors.New("ca cert is invalid")
+			return
+		}
+		res.RootCAs = pool
+	}
+	if this_.CertPath != "" || this_.KeyPath != "" {
+		var cert tls.Certificate
+		cert, err = tls.LoadX509KeyPair(this_.CertPath, this_.KeyPath)
+		if err != nil {
+			err = errors.New("load client cert error:" + err.Error())
+			return
+		}
+		res.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+type Service struct {
+	config    *Config
+	client    *clientv3.Client
+	sshClient *goSSH.Client
+}
+
+func NewService(config *Config, sshConfig *ssh.Config) (res *Service, err error) {
+	res = &Service{
+		config: config,
+	}
+	endpoints := config.getEndpoints()
+	if len(endpoints) == 0 {
+		err = errors.New("endpoints is empty")
+		return
+	}
+	etcdConfig := clientv3.Config{
+		Endpoints:   endpoints,
+		Username:    config.Username,
+		Password:    config.Password,
+		DialTimeout: time.Second * 10,
+	}
+	if config.isTLS() {
+		etcdConfig.TLS, err = config.newTLSConfig()
+		if err != nil {
+			return
+		}
+	}
+	if sshConfig != nil {
+		res.sshClient, err = ssh.NewClient(*sshConfig)
+		if err != nil {
+			return
+		}
+		etcdConfig.DialOptions = append(etcdConfig.DialOptions, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return res.sshClient.Dial("tcp", addr)
+		}))
+	}
+	res.client, err = clientv3.New(etcdConfig)
+	if err != nil {
+		res.Close()
+		return
+	}
+	return
+}
+
+func (this_ *Service) Close() {
+	if this_.client != nil {
+		_ = this_.client.Close()
+	}
+	if this_.sshClient != nil {
+		_ = this_.sshClient.Close()
+	}
+}
+
+func (this_ *Service) newContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), time.Second*30)
+}
+
+// Ping 查询键数量，校验连接及认证
+func (this_ *Service) Ping() (err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	_, err = this_.client.Get(ctx, "ping", clientv3.WithCountOnly())
+	return
+}
+
+type KeyValue struct {
+	Key            string `json:"key"`
+	Value          string `json:"value"`
+	ValueBase64    bool   `json:"valueBase64,omitempty"`
+	CreateRevision int64  `json:"createRevision"`
+	ModRevision    int64  `json:"modRevision"`
+	Version        int64  `json:"version"`
+	Lease          int64  `json:"lease"`
+}
+
+func toKeyValue(key []byte, value []byte, createRevision int64, modRevision int64, version int64, lease int64) (res *KeyValue) {
+	res = &KeyValue{
+		Key:            string(key),
+		CreateRevision: createRevision,
+		ModRevision:    modRevision,
+		Version:        version,
+		Lease:          lease,
+	}
+	// 值不是 UTF-8 文本时使用 base64 编码
+	if utf8.Valid(value) {
+		res.Value = string(value)
+	} else {
+		res.Value = base64.StdEncoding.EncodeToString(value)
+		res.ValueBase64 = true
+	}
+	return
+}
+
+type KeyNode struct {
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	IsKey       bool   `json:"isKey"`
+	HasChildren bool   `json:"hasChildren"`
+}
+
+type KeysResult struct {
+	Nodes []*KeyNode `json:"nodes"`
+	// 前缀下的键超过限制时只返回部分
+	Truncated bool  `json:"truncated"`
+	Revision  int64 `json:"revision"`
+}
+
+// Keys 按分隔符将前缀下的键组织为树的一层，如前缀 /a/ 下的 /a/b/c 显示为 b 节点
+func (this_ *Service) Keys(prefix string, separator string, limit int64) (res *KeysResult, err error) {
+	if separator == "" {
+		separator = "/"
+	}
+	if limit <= 0 {
+		limit = 10000
+	}
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	opts := []clientv3.OpOption{clientv3.WithKeysOnly(), clientv3.WithLimit(limit), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend)}
+	if prefix == "" {
+		opts = append(opts, clientv3.WithFromKey())
+		prefix = "\x00"
+	} else {
+		opts = append(opts, clientv3.WithPrefix())
+	}
+	resp, err := this_.client.Get(ctx, prefix, opts...)
+	if err != nil {
+		return
+	}
+	if prefix == "\x00" {
+		prefix = ""
+	}
+	res = &KeysResult{
+		Truncated: resp.More,
+		Revision:  resp.Header.Revision,
+	}
+	nodeCache := map[string]*KeyNode{}
+	for _, kv := range resp.Kvs {
+		rest := strings.TrimPrefix(string(kv.Key), prefix)
+		name := rest
+		index := strings.Index(rest, separator)
+		if index >= 0 {
+			name = rest[:index+len(separator)]
+		}
+		node := nodeCache[name]
+		if node == nil {
+			node = &KeyNode{Name: name, Key: prefix + name}
+			nodeCache[name] = node
+			res.Nodes = append(res.Nodes, node)
+		}
+		if index >= 0 && len(rest) > len(name) {
+			node.HasChildren = true
+		} else {
+			node.IsKey = true
+		}
+	}
+	sort.Slice(res.Nodes, func(i, j int) bool {
+		return res.Nodes[i].Name < res.Nodes[j].Name
+	})
+	return
+}
+
+type GetResult struct {
+	Exists   bool      `json:"exists"`
+	Kv       *KeyValue `json:"kv,omitempty"`
+	LeaseTTL int64     `json:"leaseTTL"`
+	Revision int64     `json:"revision"`
+}
+
+// Get 查询键，revision 大于 0 时查询该版本时的值
+func (this_ *Service) Get(key string, revision int64) (res *GetResult, err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	var opts []clientv3.OpOption
+	if revision > 0 {
+		opts = append(opts, clientv3.WithRev(revision))
+	}
+	resp, err := this_.client.Get(ctx, key, opts...)
+	if err != nil {
+		return
+	}
+	res = &GetResult{
+		Revision: resp.Header.Revision,
+	}
+	if len(resp.Kvs) == 0 {
+		return
+	}
+	kv := resp.Kvs[0]
+	res.Exists = true
+	res.Kv = toKeyValue(kv.Key, kv.Value, kv.CreateRevision, kv.ModRevision, kv.Version, kv.Lease)
+	if kv.Lease != 0 && revision <= 0 {
+		ttl, e := this_.client.TimeToLive(ctx, clientv3.LeaseID(kv.Lease))
+		if e == nil {
+			res.LeaseTTL = ttl.TTL
+		}
+	}
+	return
+}
+
+type PutParam struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// 租约ID，为 0 时如果 TTL 大于 0 则创建新的租约
+	Lease int64 `json:"lease"`
+	TTL   int64 `json:"ttl"`
+	// 保留原有租约
+	IgnoreLease bool `json:"ignoreLease"`
+	// 仅当键的修改版本等于该值时修改，为空不校验，0 表示键不存在时才创建
+	ExpectModRevision *int64 `json:"expectModRevision"`
+}
+
+type PutResult struct {
+	Succeeded bool      `json:"succeeded"`
+	Revision  int64     `json:"revision"`
+	Lease     int64     `json:"lease,omitempty"`
+	PrevKv    *KeyValue `json:"prevKv,omitempty"`
+}
+
+func (this_ *Service) Put(param *PutParam) (res *PutResult, err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	res = &PutResult{}
+	opts := []clientv3.OpOption{clientv3.WithPrevKV()}
+	lease := param.Lease
+	if lease == 0 && param.TTL > 0 {
+		var grant *clientv3.LeaseGrantResponse
+		grant, err = this_.client.Grant(ctx, param.TTL)
+		if err != nil {
+			return
+		}
+		lease = int64(grant.ID)
+	}
+	if lease != 0 {
+		opts = append(opts, clientv3.WithLease(clientv3.LeaseID(lease)))
+		res.Lease = lease
+	} else if param.IgnoreLease {
+		opts = append(opts, clientv3.WithIgnoreLease())
+	}
+	put := clientv3.OpPut(param.Key, param.Value, opts...)
+
+	if param.ExpectModRevision == nil {
+		var resp *clientv3.PutResponse
+		resp, err = this_.client.Put(ctx, param.Key, param.Value, opts...)
+		if err != nil {
+			return
+		}
+		res.Succeeded = true
+		res.Revision = resp.Header.Revision
+		if resp.PrevKv != nil {
+			res.PrevKv = toKeyValue(resp.PrevKv.Key, resp.PrevKv.Value, resp.PrevKv.CreateRevision, resp.PrevKv.ModRevision, resp.PrevKv.Version, resp.PrevKv.Lease)
+		}
+		return
+	}
+	// 比较修改版本，避免覆盖他人的修改
+	txnResp, err := this_.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(param.Key), "=", *param.ExpectModRevision)).
+		Then(put).
+		Commit()
+	if err != nil {
+		return
+	}
+	res.Succeeded = txnResp.Succeeded
+	res.Revision = txnResp.Header.Revision
+	return
+}
+
+// Delete 删除键，prefix 为 true 时删除前缀下所有键
+func (this_ *Service) Delete(key string, prefix bool) (deleted int64, err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	var opts []clientv3.OpOption
+	if prefix {
+		if key == "" {
+			err = errors.New("prefix delete key can not be empty")
+			return
+		}
+		opts = append(opts, clientv3.WithPrefix())
+	}
+	resp, err := this_.client.Delete(ctx, key, opts...)
+	if err != nil {
+		return
+	}
+	deleted = resp.Deleted
+	return
+}
+
+type HistoryEvent struct {
+	Type     string    `json:"type"`
+	Revision int64     `json:"revision"`
+	Kv       *KeyValue `json:"kv"`
+}
+
+type HistoryResult struct {
+	Events []*HistoryEvent `json:"events"`
+	// 当前版本
+	Revision int64 `json:"revision"`
+	// 已压缩的版本，小于该版本的历史不可查询
+	CompactRevision int64 `json:"compactRevision,omitempty"`
+	Truncated       bool  `json:"truncated"`
+}
+
+// History 通过从历史版本开始监听回放 [startRevision, endRevision] 之间的修改，endRevision 为 0 表示当前版本
+func (this_ *Service) History(key string, prefix bool, startRevision int64, endRevision int64, limit int) (res *HistoryResult, err error) {
+	if limit <= 0 {
+		limit = 1000
+	}
+	res = &HistoryResult{}
+	ctx, cancel := this_.newContext()
+	defer cancel()
+
+	current, err := this_.client.Get(ctx, key, clientv3.WithCountOnly())
+	if err != nil {
+		return
+	}
+	res.Revision = current.Header.Revision
+	if endRevision <= 0 || endRevision > res.Revision {
+		endRevision = res.Revision
+	}
+	if startRevision <= 0 {
+		startRevision = 1
+	}
+	if startRevision > endRevision {
+		return
+	}
+
+	opts := []clientv3.OpOption{clientv3.WithRev(startRevision), clientv3.WithPrevKV()}
+	if prefix {
+		opts = append(opts, clientv3.WithPrefix())
+	}
+	watchCtx, watchCancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer watchCancel()
+	watchChan := this_.client.Watch(watchCtx, key, opts...)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second * 2):
+			// 历史事件会立即返回，一段时间没有新事件说明已读取完
+			return
+		case resp, ok := <-watchChan:
+			if !ok {
+				return
+			}
+			if resp.CompactRevision > 0 {
+				res.CompactRevision = resp.CompactRevision
+				err = fmt.Errorf("revision %d has been compacted, min available revision is %d", startRevision, resp.CompactRevision)
+				return
+			}
+			if err = resp.Err(); err != nil {
+				return
+			}
+			for _, event := range resp.Events {
+				if event.Kv.ModRevision > endRevision {
+					return
+				}
+				one := &HistoryEvent{
+					Type:     event.Type.String(),
+					Revision: event.Kv.ModRevision,
+					Kv:       toKeyValue(event.Kv.Key, event.Kv.Value, event.Kv.CreateRevision, event.Kv.ModRevision, event.Kv.Version, event.Kv.Lease),
+				}
+				if event.Type == clientv3.EventTypeDelete && event.PrevKv != nil {
+					// 删除事件使用删除前的值
+					one.Kv = toKeyValue(event.PrevKv.Key, event.PrevKv.Value, event.PrevKv.CreateRevision, event.PrevKv.ModRevision, event.PrevKv.Version, event.PrevKv.Lease)
+				}
+				res.Events = append(res.Events, one)
+				if len(res.Events) >= limit {
+					res.Truncated = true
+					return
+				}
+				if event.Kv.ModRevision == endRevision {
+					return
+				}
+			}
+		}
+	}
+}
+
+type LeaseInfo struct {
+	Id         int64    `json:"id"`
+	TTL        int64    `json:"ttl"`
+	GrantedTTL int64    `json:"grantedTTL"`
+	Keys       []string `json:"keys,omitempty"`
+}
+
+func (this_ *Service) Leases() (list []*LeaseInfo, err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	resp, err := this_.client.Leases(ctx)
+	if err != nil {
+		return
+	}
+	for _, one := range resp.Leases {
+		list = append(list, &LeaseInfo{Id: int64(one.ID)})
+	}
+	return
+}
+
+func (this_ *Service) LeaseInfo(id int64) (res *LeaseInfo, err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	resp, err := this_.client.TimeToLive(ctx, clientv3.LeaseID(id), clientv3.WithAttachedKeys())
+	if err != nil {
+		return
+	}
+	res = &LeaseInfo{
+		Id:         int64(resp.ID),
+		TTL:        resp.TTL,
+		GrantedTTL: resp.GrantedTTL,
+	}
+	for _, key := range resp.Keys {
+		res.Keys = append(res.Keys, string(key))
+	}
+	return
+}
+
+func (this_ *Service) LeaseGrant(ttl int64) (res *LeaseInfo, err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	resp, err := this_.client.Grant(ctx, ttl)
+	if err != nil {
+		return
+	}
+	res = &LeaseInfo{Id: int64(resp.ID), TTL: resp.TTL, GrantedTTL: resp.TTL}
+	return
+}
+
+func (this_ *Service) LeaseRevoke(id int64) (err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	_, err = this_.client.Revoke(ctx, clientv3.LeaseID(id))
+	return
+}
+
+func (this_ *Service) LeaseKeepAliveOnce(id int64) (res *LeaseInfo, err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	resp, err := this_.client.KeepAliveOnce(ctx, clientv3.LeaseID(id))
+	if err != nil {
+		return
+	}
+	res = &LeaseInfo{Id: int64(resp.ID), TTL: resp.TTL}
+	return
+}
+
+type MemberInfo struct {
+	Id         string   `json:"id"`
+	Name       string   `json:"name"`
+	PeerURLs   []string `json:"peerURLs"`
+	ClientURLs []string `json:"clientURLs"`
+	IsLearner  bool     `json:"isLearner"`
+}
+
+type EndpointStatus struct {
+	Endpoint  string   `json:"endpoint"`
+	Id        string   `json:"id,omitempty"`
+	Version   string   `json:"version,omitempty"`
+	DbSize    int64    `json:"dbSize"`
+	Leader    string   `json:"leader,omitempty"`
+	IsLeader  bool     `json:"isLeader"`
+	IsLearner bool     `json:"isLearner"`
+	RaftIndex uint64   `json:"raftIndex"`
+	RaftTerm  uint64   `json:"raftTerm"`
+	Errors    []string `json:"errors,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+func (this_ *Service) Members() (list []*MemberInfo, err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	resp, err := this_.client.MemberList(ctx)
+	if err != nil {
+		return
+	}
+	for _, one := range resp.Members {
+		list = append(list, &MemberInfo{
+			Id:         fmt.Sprintf("%x", one.ID),
+			Name:       one.Name,
+			PeerURLs:   one.PeerURLs,
+			ClientURLs: one.ClientURLs,
+			IsLearner:  one.IsLearner,
+		})
+	}
+	return
+}
+
+// EndpointStatus 查询配置的每个节点状态，单个节点异常不影响其它节点
+func (this_ *Service) EndpointStatus() (list []*EndpointStatus) {
+	for _, endpoint := range this_.client.Endpoints() {
+		one := &EndpointStatus{Endpoint: endpoint}
+		list = append(list, one)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		resp, err := this_.client.Status(ctx, endpoint)
+		cancel()
+		if err != nil {
+			one.Error = err.Error()
+			continue
+		}
+		one.Id = fmt.Sprintf("%x", resp.Header.MemberId)
+		one.Version = resp.Version
+		one.DbSize = resp.DbSize
+		one.Leader = fmt.Sprintf("%x", resp.Leader)
+		one.IsLeader = resp.Leader == resp.Header.MemberId
+		one.IsLearner = resp.IsLearner
+		one.RaftIndex = resp.RaftIndex
+		one.RaftTerm = resp.RaftTerm
+		one.Errors = resp.Errors
+	}
+	return
+}
@@ -0,0 +1,263 @@
+package module_etcd
+
+import (
+	goContext "context"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+	"teamide/internal/context"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+var (
+	watcherCache           = map[string]*watcher{}
+	workerWatcherIdsCache  = map[string][]string{}
+	watcherCacheLock       = &sync.Mutex{}
+	watchEventName         = "etcd-watch-event"
+	watchRetryWaitDuration = time.Second * 3
+)
+
+// watcher 键监听，连接断开后从最后收到的版本继续监听，不丢失事件
+type watcher struct {
+	WatchId  string `json:"watchId"`
+	WorkerId string `json:"workerId"`
+	Key      string `json:"key"`
+	Prefix   bool   `json:"prefix"`
+	// 开始监听的版本，为 0 从当前版本开始
+	Revision     int64 `json:"revision"`
+	StartTime    int64 `json:"startTime"`
+	EventCount   int64 `json:"eventCount"` // 原子读写
+	clientTabKey string
+	config       *Config
+	sshConfig    *ssh.Config
+	stopChan     chan struct{}
+	stopOnce     sync.Once
+}
+
+type WatchEvent struct {
+	WatchId  string           `json:"watchId"`
+	WorkerId string           `json:"workerId"`
+	Key      string           `json:"key"`
+	Prefix   bool             `json:"prefix"`
+	Revision int64            `json:"revision,omitempty"`
+	Events   []*WatchKeyEvent `json:"events,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Time     int64            `json:"time"`
+}
+
+type WatchKeyEvent struct {
+	Type   string    `json:"type"`
+	Kv     *KeyValue `json:"kv"`
+	PrevKv *KeyValue `json:"prevKv,omitempty"`
+}
+
+func startWatcher(w *watcher) (err error) {
+	if w.Key == "" && !w.Prefix {
+		err = errors.New("watch key is empty")
+		return
+	}
+	w.WatchId = util.GetUUID()
+	w.StartTime = util.GetNowMilli()
+	w.stopChan = make(chan struct{})
+
+	watcherCacheLock.Lock()
+	watcherCache[w.WatchId] = w
+	workerWatcherIdsCache[w.WorkerId] = append(workerWatcherIdsCache[w.WorkerId], w.WatchId)
+	watcherCacheLock.Unlock()
+
+	go w.run()
+	return
+}
+
+func getWorkerWatchers(workerId string) (list []*watcher) {
+	watcherCacheLock.Lock()
+	defer watcherCacheLock.Unlock()
+
+	for _, watchId := range workerWatcherIdsCache[workerId] {
+		w := watcherCache[watchId]
+		if w != nil {
+			list = append(list, w.info())
+		}
+	}
+	return
+}
+
+func stopWatcher(watchId string) {
+	watcherCacheLock.Lock()
+	defer watcherCacheLock.Unlock()
+
+	w := watcherCache[watchId]
+	if w == nil {
+		return
+	}
+	w.stop()
+	delete(watcherCache, watchId)
+
+	var newIds []string
+	for _, id := range workerWatcherIdsCache[w.WorkerId] {
+		if id != watchId {
+			newIds = append(newIds, id)
+		}
+	}
+	if len(newIds) == 0 {
+		delete(workerWatcherIdsCache, w.WorkerId)
+	} else {
+		workerWatcherIdsCache[w.WorkerId] = newIds
+	}
+}
+
+func stopWorkerWatchers(workerId string) {
+	watcherCacheLock.Lock()
+	defer watcherCacheLock.Unlock()
+
+	for _, watchId := range workerWatcherIdsCache[workerId] {
+		w := watcherCache[watchId]
+		if w != nil {
+			w.stop()
+		}
+		delete(watcherCache, watchId)
+	}
+	delete(workerWatcherIdsCache, workerId)
+}
+
+// info 监听信息副本，用于返回给前端
+func (this_ *watcher) info() *watcher {
+	return &watcher{
+		WatchId:    this_.WatchId,
+		WorkerId:   this_.WorkerId,
+		Key:        this_.Key,
+		Prefix:     this_.Prefix,
+		Revision:   this_.Revision,
+		StartTime:  this_.StartTime,
+		EventCount: atomic.LoadInt64(&this_.EventCount),
+	}
+}
+
+func (this_ *watcher) stop() {
+	this_.stopOnce.Do(func() {
+		close(this_.stopChan)
+	})
+}
+
+func (this_ *watcher) isStopped() bool {
+	select {
+	case <-this_.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+func (this_ *watcher) callEvent(event *WatchEvent) {
+	event.WatchId = this_.WatchId
+	event.WorkerId = this_.WorkerId
+	event.Key = this_.Key
+	event.Prefix = this_.Prefix
+	event.Time = util.GetNowMilli()
+	atomic.AddInt64(&this_.EventCount, 1)
+	context.CallClientTabKeyEvent(this_.clientTabKey, context.NewListenEvent(watchEventName, event))
+}
+
+func (this_ *watcher) run() {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("etcd watcher panic", zap.Any("key", this_.Key), zap.Any("error", e))
+		}
+	}()
+
+	// 下次监听开始的版本
+	nextRevision := this_.Revision
+	for !this_.isStopped() {
+		// 每次监听都从缓存获取服务，保证服务在监听期间不被回收，连接断开后重新创建
+		service, err := getService(this_.config, this_.sshConfig)
+		if err != nil {
+			this_.callEvent(&WatchEvent{Error: err.Error()})
+			if !this_.wait(watchRetryWaitDuration) {
+				return
+			}
+			continue
+		}
+
+		nextRevision, err = this_.watch(service, nextRevision)
+		if err != nil {
+			this_.callEvent(&WatchEvent{Error: err.Error()})
+		}
+		if !this_.wait(watchRetryWaitDuration) {
+			return
+		}
+	}
+}
+
+// watch 监听直到停止或监听通道关闭，返回下次监听开始的版本
+func (this_ *watcher) watch(service *Service, revision int64) (nextRevision int64, err error) {
+	nextRevision = revision
+	ctx, cancel := goContext.WithCancel(goContext.Background())
+	defer cancel()
+
+	// WithCreatedNotify 监听创建后返回当前版本，未指定版本时从该版本继续
+	opts := []clientv3.OpOption{clientv3.WithPrevKV(), clientv3.WithCreatedNotify()}
+	if this_.Prefix {
+		opts = append(opts, clientv3.WithPrefix())
+	}
+	if revision > 0 {
+		opts = append(opts, clientv3.WithRev(revision))
+	}
+	watchChan := service.client.Watch(clientv3.WithRequireLeader(ctx), this_.Key, opts...)
+	for {
+		select {
+		case <-this_.stopChan:
+			return
+		case resp, ok := <-watchChan:
+			if !ok {
+				err = errors.New("watch channel closed")
+				return
+			}
+			if resp.CompactRevision > 0 {
+				// 监听的版本已被压缩，从压缩版本继续
+				nextRevision = resp.CompactRevision
+				err = fmt.Errorf("revision %d has been compacted, watch from revision %d", revision, resp.CompactRevision)
+				return
+			}
+			if err = resp.Err(); err != nil {
+				return
+			}
+			if resp.Created {
+				if nextRevision <= 0 {
+					nextRevision = resp.Header.Revision + 1
+				}
+				this_.callEvent(&WatchEvent{Revision: resp.Header.Revision})
+				continue
+			}
+			if len(resp.Events) == 0 {
+				continue
+			}
+			event := &WatchEvent{Revision: resp.Header.Revision}
+			for _, one := range resp.Events {
+				keyEvent := &WatchKeyEvent{
+					Type: one.Type.String(),
+					Kv:   toKeyValue(one.Kv.Key, one.Kv.Value, one.Kv.CreateRevision, one.Kv.ModRevision, one.Kv.Version, one.Kv.Lease),
+				}
+				if one.PrevKv != nil {
+					keyEvent.PrevKv = toKeyValue(one.PrevKv.Key, one.PrevKv.Value, one.PrevKv.CreateRevision, one.PrevKv.ModRevision, one.PrevKv.Version, one.PrevKv.Lease)
+				}
+				event.Events = append(event.Events, keyEvent)
+				nextRevision = one.Kv.ModRevision + 1
+			}
+			this_.callEvent(event)
+		}
+	}
+}
+
+func (this_ *watcher) wait(duration time.Duration) bool {
+	select {
+	case <-this_.stopChan:
+		return false
+	case <-time.After(duration):
+		return true
+	}
+}
@@ -99,6 +99,7 @@ func (this_ *api) create(r *base.RequestBean, c *gin.Context) (res interface{},
 		return
 	}
 	request.ClientTabKey = r.ClientTabKey
+	request.UserId = r.JWT.UserId
 	res, err = this_.Create(request.BaseParam, request.FileWorkerKey, request.Path, request.IsDir)
 	return
 }
@@ -109,6 +110,7 @@ func (this_ *api) file(r *base.RequestBean, c *gin.Context) (res interface{}, er
 		return
 	}
 	request.ClientTabKey = r.ClientTabKey
+	request.UserId = r.JWT.UserId
 	res, err = this_.File(request.BaseParam, request.FileWorkerKey, request.Path)
 	return
 }
@@ -120,6 +122,7 @@ func (this_ *api) files(r *base.RequestBean, c *gin.Context) (res interface{}, e
 	}
 
 	request.ClientTabKey = r.ClientTabKey
+	request.UserId = r.JWT.UserId
 	var data = map[string]interface{}{}
 	data["dir"], data["files"], err = this_.Files(request.BaseParam, request.FileWorkerKey, request.Dir)
 
@@ -136,6 +139,7 @@ func (this_ *api) read(r *base.RequestBean, c *gin.Context) (res interface{}, er
 	response := map[string]interface{}{}
 	res = response
 	request.ClientTabKey = r.ClientTabKey
+	request.UserId = r.JWT.UserId
 
 	fileInfo, err := this_.File(request.BaseParam, request.FileWorkerKey, request.Path)
 	if err != nil {
@@ -173,6 +177,7 @@ func (this_ *api) write(r *base.RequestBean, c *gin.Context) (res interface{}, e
 	}
 
 	request.ClientTabKey = r.ClientTabKey
+	request.UserId = r.JWT.UserId
 	reader := strings.NewReader(request.Text)
 	res, err = this_.Write(request.BaseParam, request.FileWorkerKey, request.Path, reader, reader.Len())
 	if err != nil {
@@ -187,6 +192,7 @@ func (this_ *api) rename(r *base.RequestBean, c *gin.Context) (res interface{},
 		return
 	}
 	request.ClientTabKey = r.ClientTabKey
+	request.UserId = r.JWT.UserId
 	res, err = this_.Rename(request.BaseParam, request.FileWorkerKey, request.OldPath, request.NewPath)
 	return
 }
@@ -197,6 +203,7 @@ func (this_ *api) remove(r *base.RequestBean, c *gin.Context) (res interface{},
 		return
 	}
 	request.ClientTabKey = r.ClientTabKey
+	request.UserId = r.JWT.UserId
 	err = this_.Remove(request.BaseParam, request.FileWorkerKey, request.Path)
 	return
 }
@@ -207,6 +214,7 @@ func (this_ *api) move(r *base.RequestBean, c *gin.Context) (res interface{}, er
 		return
 	}
 	request.ClientTabKey = r.ClientTabKey
+	request.UserId = r.JWT.UserId
 	err = this_.Move(request.BaseParam, request.FileWorkerKey, request.OldPath, request.NewPath)
 	return
 }
@@ -217,6 +225,7 @@ func (this_ *api) copy(r *base.RequestBean, c *gin.Context) (res interface{}, er
 		return
 	}
 	request.ClientTabKey = r.ClientTabKey
+	request.UserId = r.JWT.UserId
 	go this_.Copy(request.BaseParam, request.FileWorkerKey, request.Path, request.FromPlace, request.FromPlaceId, request.FromPath)
 	return
 }
@@ -275,6 +284,7 @@ func (this_ *api) upload(r *base.RequestBean, c *gin.Context) (res interface{},
 			PlaceId:      placeId,
 			WorkerId:     workerId,
 			ClientTabKey: r.ClientTabKey,
+			UserId:       r.JWT.UserId,
 		}, fileWorkerKey, dir, fullPath, fileList)
 	}()
 
@@ -310,6 +320,7 @@ func (this_ *api) download(r *base.RequestBean, c *gin.Context) (res interface{}
 		PlaceId:      placeId,
 		WorkerId:     workerId,
 		ClientTabKey: r.ClientTabKey,
+		UserId:       r.JWT.UserId,
 	}, fileWorkerKey, path)
 	if err != nil {
 		return
@@ -326,6 +337,7 @@ func (this_ *api) download(r *base.RequestBean, c *gin.Context) (res interface{}
 		PlaceId:      placeId,
 		WorkerId:     workerId,
 		ClientTabKey: r.ClientTabKey,
+		UserId:       r.JWT.UserId,
 	}, fileWorkerKey, path, &cWriter{
 		c: c,
 	})
@@ -377,6 +389,7 @@ func (this_ *api) open(r *base.RequestBean, c *gin.Context) (res interface{}, er
 		PlaceId:      placeId,
 		WorkerId:     workerId,
 		ClientTabKey: r.ClientTabKey,
+		UserId:       r.JWT.UserId,
 	}, fileWorkerKey, path, &cWriter{
 		c: c,
 	})
@@ -157,6 +157,7 @@ type BaseParam struct {
 	PlaceId      string `json:"placeId"`
 	WorkerId     string `json:"workerId"`
 	ClientTabKey string `json:"clientTabKey"`
+	UserId       int64  `json:"-"`
 }
 
 func newProgress(param *BaseParam, work string, callStop func()) (progress *Progress) {
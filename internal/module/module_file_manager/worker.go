@@ -52,7 +52,11 @@ func (this_ *worker) GetService(fileWorkerKey string, param *BaseParam) (service
 		}
 
 		var config *ssh.Config
-		config, err = this_.toolboxService.GetSSHConfig(tD.Option)
+		config, err = this_.toolboxService.GetSSHConfig(tD.Option, param.UserId)
+		if err != nil {
+			return
+		}
+		config.ToolboxId = tD.ToolboxId
 
 		service = ssh.CreateOrGetClient(fileWorkerKey, config)
 	case "node":
@@ -0,0 +1,411 @@
+package module_grpc
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/team-ide/go-tool/task"
+	"google.golang.org/grpc"
+	"sort"
+	"strings"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"time"
+)
+
+type api struct {
+	toolboxService *module_toolbox.ToolboxService
+	report         *module_toolbox.LoadTaskReport
+}
+
+func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
+	return &api{
+		toolboxService: toolboxService,
+		report:         module_toolbox.NewLoadTaskReport(toolboxService),
+	}
+}
+
+var (
+	// gRPC 权限
+	Power              = base.AppendPower(&base.PowerAction{Action: "grpc", Text: "gRPC", ShouldLogin: true, StandAlone: true})
+	contextPower       = base.AppendPower(&base.PowerAction{Action: "context", Text: "上下文", ShouldLogin: true, StandAlone: true, Parent: Power})
+	getMethodTemplate  = base.AppendPower(&base.PowerAction{Action: "getMethodTemplate", Text: "请求模板", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokePower        = base.AppendPower(&base.PowerAction{Action: "invoke", Text: "执行", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeReports      = base.AppendPower(&base.PowerAction{Action: "invokeReports", Text: "执行报告", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeReportDelete = base.AppendPower(&base.PowerAction{Action: "invokeReportDelete", Text: "执行报告", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeStop         = base.AppendPower(&base.PowerAction{Action: "invokeStop", Text: "执行停止", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeInfo         = base.AppendPower(&base.PowerAction{Action: "invokeInfo", Text: "执行信息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	downloadRecords    = base.AppendPower(&base.PowerAction{Action: "downloadRecords", Text: "执行信息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeMetric       = base.AppendPower(&base.PowerAction{Action: "invokeMetric", Text: "执行信息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeMarkdown     = base.AppendPower(&base.PowerAction{Action: "invokeMarkdown", Text: "执行信息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	closePower         = base.AppendPower(&base.PowerAction{Action: "close", Text: "关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+
+	apis = append(apis, &base.ApiWorker{Power: contextPower, Do: this_.context})
+	apis = append(apis, &base.ApiWorker{Power: getMethodTemplate, Do: this_.getMethodTemplate})
+	apis = append(apis, &base.ApiWorker{Power: invokePower, Do: this_.invoke})
+	apis = append(apis, &base.ApiWorker{Power: invokeReports, Do: this_.invokeReports})
+	apis = append(apis, &base.ApiWorker{Power: invokeReportDelete, Do: this_.invokeReportDelete})
+	apis = append(apis, &base.ApiWorker{Power: downloadRecords, Do: this_.report.DownloadRecords, IsGet: true})
+	apis = append(apis, &base.ApiWorker{Power: invokeStop, Do: this_.invokeStop})
+	apis = append(apis, &base.ApiWorker{Power: invokeInfo, Do: this_.invokeInfo})
+	apis = append(apis, &base.ApiWorker{Power: invokeMetric, Do: this_.invokeMetric})
+	apis = append(apis, &base.ApiWorker{Power: invokeMarkdown, Do: this_.invokeMarkdown})
+	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
+
+	return
+}
+
+type Config struct {
+	// proto 文件目录，为空时只能使用服务端反射
+	ProtoDir string `json:"protoDir"`
+}
+
+func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (config *Config, err error) {
+	config = &Config{}
+	_, err = this_.toolboxService.BindConfig(requestBean, c, config)
+	if err != nil {
+		return
+	}
+	return
+}
+
+type BaseRequest struct {
+	ServiceName   string `json:"serviceName,omitempty"`
+	MethodName    string `json:"methodName,omitempty"`
+	ServerAddress string `json:"serverAddress,omitempty"`
+	// 使用服务端反射获取服务定义，不需要 proto 文件
+	UseReflection bool `json:"useReflection,omitempty"`
+	// 请求消息 JSON，客户端流方法按顺序发送多条，支持 ${脚本}
+	Messages []string          `json:"messages,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Reload   bool              `json:"reload,omitempty"`
+	TaskKey  string            `json:"taskKey,omitempty"`
+
+	ToolboxId int64 `json:"toolboxId,omitempty"`
+	IsTest    bool  `json:"isTest,omitempty"`
+	Worker    int   `json:"worker,omitempty"`
+	Duration  int   `json:"duration,omitempty"`
+	Frequency int   `json:"frequency,omitempty"`
+	// 超时时长 毫秒
+	Timeout int  `json:"timeout,omitempty"`
+	Minute  bool `json:"minute,omitempty"`
+	Second  bool `json:"second,omitempty"`
+
+	TLS                   bool   `json:"tls,omitempty"`
+	TLSCaCert             string `json:"tlsCaCert,omitempty"`
+	TLSClientCert         string `json:"tlsClientCert,omitempty"`
+	TLSClientKey          string `json:"tlsClientKey,omitempty"`
+	TLSServerName         string `json:"tlsServerName,omitempty"`
+	TLSInsecureSkipVerify bool   `json:"tlsInsecureSkipVerify,omitempty"`
+	// 由 initTLSConfig 根据证书配置创建
+	tlsConfig *tls.Config
+}
+
+func (this_ *api) context(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	data := map[string]interface{}{}
+	res = data
+
+	var list []*ServiceInfo
+	if request.UseReflection {
+		err = this_.initTLSConfig(request)
+		if err != nil {
+			return
+		}
+		list, err = getReflectionServices(request)
+		if err != nil {
+			return
+		}
+	} else {
+		var workspace *Workspace
+		workspace, err = getOrCreateWorkspace(config)
+		if err != nil {
+			return
+		}
+		if request.Reload {
+			workspace.Reload()
+		}
+		list = workspace.ServiceList
+		data["errors"] = workspace.Errors
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name) //升序  即前面的值比后面的小  忽略大小写排序
+	})
+	for _, one := range list {
+		sort.Slice(one.Methods, func(i, j int) bool {
+			return strings.ToLower(one.Methods[i].Name) < strings.ToLower(one.Methods[j].Name) //升序  即前面的值比后面的小  忽略大小写排序
+		})
+	}
+
+	data["serviceList"] = list
+	return
+}
+
+func (this_ *api) getMethod(config *Config, request *BaseRequest) (method *desc.MethodDescriptor, err error) {
+	err = this_.initTLSConfig(request)
+	if err != nil {
+		return
+	}
+	if request.UseReflection {
+		method, err = getReflectionMethod(request)
+		return
+	}
+	workspace, err := getOrCreateWorkspace(config)
+	if err != nil {
+		return
+	}
+	method, err = workspace.GetMethod(request.ServiceName, request.MethodName)
+	return
+}
+
+func (this_ *api) getMethodTemplate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	method, err := this_.getMethod(config, request)
+	if err != nil {
+		return
+	}
+
+	data := map[string]interface{}{}
+	res = data
+
+	bs, _ := json.MarshalIndent(getMessageTemplate(method.GetInputType(), 0), "", "  ")
+	data["requestTemplate"] = string(bs)
+	bs, _ = json.MarshalIndent(getMessageTemplate(method.GetOutputType(), 0), "", "  ")
+	data["responseTemplate"] = string(bs)
+	data["clientStreaming"] = method.IsClientStreaming()
+	data["serverStreaming"] = method.IsServerStreaming()
+	return
+}
+
+func (this_ *api) invoke(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	method, err := this_.getMethod(config, request)
+	if err != nil {
+		return
+	}
+
+	data := map[string]interface{}{}
+	res = data
+	data["isTest"] = request.IsTest
+	data["start"] = time.Now().UnixMilli()
+	defer func() {
+		data["end"] = time.Now().UnixMilli()
+	}()
+
+	if !request.IsTest {
+		var messages []string
+		messages, err = formatMessages(request.Messages, nil)
+		if err != nil {
+			return
+		}
+		var conn *grpc.ClientConn
+		conn, err = dial(request)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		var result *InvokeResult
+		startTime := time.Now()
+		result, err = invokeWithMessages(request, conn, method, messages)
+		data["useTime"] = time.Since(startTime).Milliseconds()
+		if err != nil {
+			data["error"] = err.Error()
+			err = nil
+		}
+		if result != nil {
+			bs, e := json.MarshalIndent(result, "", "  ")
+			if e == nil {
+				data["result"] = string(bs)
+			}
+		}
+		return
+	}
+
+	parentDir, err := this_.report.GetTaskParentDir(this_.getTaskParentDirRelativePath(request))
+	if err != nil {
+		return
+	}
+	executor := &invokeExecutor{
+		BaseRequest: request,
+		method:      method,
+		workerConn:  make(map[int]*grpc.ClientConn),
+	}
+	t, err := task.New(&task.Options{
+		Key:       fmt.Sprintf("%d", time.Now().UnixNano()),
+		Worker:    request.Worker,
+		Frequency: request.Frequency,
+		Duration:  request.Duration,
+		Executor:  executor,
+	})
+	if err != nil {
+		return
+	}
+	executor.taskDir = parentDir + "" + t.Key
+	_ = this_.saveTaskInfo(executor, request, t)
+	go func() {
+		defer func() {
+			this_.report.RemoveTask(t.Key)
+			_ = this_.saveTaskInfo(executor, request, t)
+			executor.stop()
+		}()
+		for !t.IsEnd {
+			_ = this_.saveTaskInfo(executor, request, t)
+			time.Sleep(time.Second * 1)
+		}
+	}()
+	go t.Run()
+	this_.report.AddTask(t)
+	data["taskKey"] = t.Key
+	return
+}
+
+func invokeWithMessages(request *BaseRequest, conn *grpc.ClientConn, method *desc.MethodDescriptor, messages []string) (result *InvokeResult, err error) {
+	list, err := newMessages(method, messages)
+	if err != nil {
+		return
+	}
+	ctx, cancel := contextWithTimeout(request)
+	defer cancel()
+	result, err = invoke(ctx, conn, method, list)
+	return
+}
+
+func (this_ *api) getTaskParentDirRelativePath(request *BaseRequest) (taskDir string) {
+	taskDir = fmt.Sprintf("%s/toolbox-%d/", "grpc-tasks", request.ToolboxId) + request.ServiceName + "/" + request.MethodName + "/"
+
+	return
+}
+
+func (this_ *api) saveTaskInfo(executor *invokeExecutor, request *BaseRequest, task *task.Task) (err error) {
+	var records []interface{}
+	for _, record := range executor.getAndCleanRecordList() {
+		records = append(records, record)
+	}
+	err = this_.report.SaveTaskInfo(executor.taskDir, request, task, nil, records)
+	return
+}
+
+func (this_ *api) loadTasks(requestBean *base.RequestBean, c *gin.Context) (taskList []map[string]interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	taskList, err = this_.report.LoadTaskList(this_.getTaskParentDirRelativePath(request))
+	return
+}
+
+func (this_ *api) invokeReports(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	res, err = this_.loadTasks(requestBean, c)
+
+	return
+}
+
+func (this_ *api) invokeMarkdown(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	taskList, err := this_.loadTasks(requestBean, c)
+	if err != nil {
+		return
+	}
+	res = toMarkdown(taskList)
+	return
+}
+
+func (this_ *api) invokeReportDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	err = this_.report.DeleteTask(this_.getTaskParentDirRelativePath(request), request.TaskKey)
+	return
+}
+
+func (this_ *api) invokeStop(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	this_.report.StopTask(request.TaskKey)
+	return
+}
+
+func (this_ *api) invokeInfo(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	taskParentDir, err := this_.report.GetTaskParentDir(this_.getTaskParentDirRelativePath(request))
+	if err != nil {
+		return
+	}
+
+	res, err = this_.report.LoadTask(taskParentDir + request.TaskKey)
+
+	return
+}
+
+func (this_ *api) invokeMetric(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	taskParentDir, err := this_.report.GetTaskParentDir(this_.getTaskParentDirRelativePath(request))
+	if err != nil {
+		return
+	}
+
+	res, err = this_.report.LoadTaskMetric(taskParentDir+request.TaskKey, request.Minute, request.Second)
+	return
+}
+
+func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	removeWorkspace(config.ProtoDir)
+	return
+}
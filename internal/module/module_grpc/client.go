@@ -0,0 +1,272 @@
+package module_grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"io"
+	"strings"
+	"teamide/internal/module/module_toolbox"
+)
+
+// InvokeResult 一次调用的结果，流式方法可能有多个响应
+type InvokeResult struct {
+	Responses []json.RawMessage   `json:"responses,omitempty"`
+	Header    map[string][]string `json:"header,omitempty"`
+	Trailer   map[string][]string `json:"trailer,omitempty"`
+}
+
+func dial(request *BaseRequest) (conn *grpc.ClientConn, err error) {
+	var opts []grpc.DialOption
+	if request.TLS {
+		if request.tlsConfig == nil {
+			err = errors.New("tls config not init")
+			return
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(request.tlsConfig)))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	opts = append(opts, grpc.WithBlock())
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout(request))
+	defer cancel()
+
+	conn, err = grpc.DialContext(ctx, request.ServerAddress, opts...)
+	if err != nil {
+		err = errors.New("dial " + request.ServerAddress + " error:" + err.Error())
+		return
+	}
+	return
+}
+
+// initTLSConfig 使用 TLS 时创建证书配置
+func (this_ *api) initTLSConfig(request *BaseRequest) (err error) {
+	if !request.TLS {
+		return
+	}
+	request.tlsConfig, err = this_.toolboxService.NewTLSConfig(&module_toolbox.TLSOptions{
+		CaCert:             request.TLSCaCert,
+		ClientCert:         request.TLSClientCert,
+		ClientKey:          request.TLSClientKey,
+		ServerName:         request.TLSServerName,
+		InsecureSkipVerify: request.TLSInsecureSkipVerify,
+	})
+	return
+}
+
+// requestContext 调用上下文，附带请求 Metadata
+func requestContext(request *BaseRequest) (ctx context.Context) {
+	ctx = context.Background()
+	if len(request.Metadata) == 0 {
+		return
+	}
+	md := metadata.MD{}
+	for key, value := range request.Metadata {
+		md.Append(key, value)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	return
+}
+
+func contextWithTimeout(request *BaseRequest) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(requestContext(request), requestTimeout(request))
+}
+
+// newMessages 将 JSON 请求转为方法入参，非客户端流方法只使用第一个
+func newMessages(method *desc.MethodDescriptor, messages []string) (list []proto.Message, err error) {
+	if len(messages) == 0 {
+		messages = []string{"{}"}
+	}
+	for i, one := range messages {
+		msg := dynamic.NewMessage(method.GetInputType())
+		if strings.TrimSpace(one) != "" {
+			if err = msg.UnmarshalJSON([]byte(one)); err != nil {
+				err = errors.New("message [" + method.GetInputType().GetFullyQualifiedName() + "] json to message error:" + err.Error())
+				return
+			}
+		}
+		list = append(list, msg)
+		if i == 0 && !method.IsClientStreaming() {
+			break
+		}
+	}
+	return
+}
+
+func invoke(ctx context.Context, conn *grpc.ClientConn, method *desc.MethodDescriptor, messages []proto.Message) (result *InvokeResult, err error) {
+	stub := grpcdynamic.NewStub(conn)
+	result = &InvokeResult{}
+	var header, trailer metadata.MD
+	defer func() {
+		result.Header = header
+		result.Trailer = trailer
+	}()
+
+	var responses []proto.Message
+	switch {
+	case method.IsClientStreaming() && method.IsServerStreaming():
+		var stream *grpcdynamic.BidiStream
+		stream, err = stub.InvokeRpcBidiStream(ctx, method)
+		if err != nil {
+			return
+		}
+		for _, one := range messages {
+			if err = stream.SendMsg(one); err != nil {
+				return
+			}
+		}
+		if err = stream.CloseSend(); err != nil {
+			return
+		}
+		responses, err = receiveAll(stream.RecvMsg)
+		header, _ = stream.Header()
+		trailer = stream.Trailer()
+	case method.IsClientStreaming():
+		var stream *grpcdynamic.ClientStream
+		stream, err = stub.InvokeRpcClientStream(ctx, method)
+		if err != nil {
+			return
+		}
+		for _, one := range messages {
+			if err = stream.SendMsg(one); err != nil {
+				return
+			}
+		}
+		var response proto.Message
+		response, err = stream.CloseAndReceive()
+		header, _ = stream.Header()
+		trailer = stream.Trailer()
+		if err != nil {
+			return
+		}
+		responses = append(responses, response)
+	case method.IsServerStreaming():
+		var stream *grpcdynamic.ServerStream
+		stream, err = stub.InvokeRpcServerStream(ctx, method, messages[0])
+		if err != nil {
+			return
+		}
+		responses, err = receiveAll(stream.RecvMsg)
+		header, _ = stream.Header()
+		trailer = stream.Trailer()
+	default:
+		var response proto.Message
+		response, err = stub.InvokeRpc(ctx, method, messages[0], grpc.Header(&header), grpc.Trailer(&trailer))
+		if err != nil {
+			return
+		}
+		responses = append(responses, response)
+	}
+	// 流式方法出错时保留已收到的响应
+	for _, response := range responses {
+		bs, e := messageToJSON(response)
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			return
+		}
+		result.Responses = append(result.Responses, bs)
+	}
+	return
+}
+
+func receiveAll(recv func() (proto.Message, error)) (responses []proto.Message, err error) {
+	for {
+		var response proto.Message
+		response, err = recv()
+		if err == io.EOF {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+		responses = append(responses, response)
+	}
+}
+
+func messageToJSON(msg proto.Message) (bs []byte, err error) {
+	dynamicMessage, err := dynamic.AsDynamicMessage(msg)
+	if err != nil {
+		return
+	}
+	bs, err = dynamicMessage.MarshalJSON()
+	return
+}
+
+// getMessageTemplate 根据消息定义生成 JSON 模板，嵌套层级过深或循环引用时不再展开
+func getMessageTemplate(message *desc.MessageDescriptor, depth int) map[string]interface{} {
+	data := map[string]interface{}{}
+	if depth > 5 {
+		return data
+	}
+	for _, field := range message.GetFields() {
+		name := field.GetJSONName()
+		if field.IsMap() {
+			data[name] = map[string]interface{}{
+				mapKeyTemplate(getFieldTemplate(field.GetMapKeyType(), depth+1)): getFieldTemplate(field.GetMapValueType(), depth+1),
+			}
+			continue
+		}
+		value := getFieldTemplate(field, depth+1)
+		if field.IsRepeated() {
+			data[name] = []interface{}{value}
+		} else {
+			data[name] = value
+		}
+	}
+	return data
+}
+
+func getFieldTemplate(field *desc.FieldDescriptor, depth int) interface{} {
+	switch field.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		return getMessageTemplate(field.GetMessageType(), depth)
+	case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		values := field.GetEnumType().GetValues()
+		if len(values) > 0 {
+			return values[0].GetName()
+		}
+		return ""
+	case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+		return false
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING:
+		return ""
+	case descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		// bytes 使用 base64 编码
+		return ""
+	case descriptorpb.FieldDescriptorProto_TYPE_INT64, descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+		descriptorpb.FieldDescriptorProto_TYPE_SINT64, descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+		descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
+		// 64 位整数在 JSON 中使用字符串
+		return "0"
+	default:
+		return 0
+	}
+}
+
+// mapKeyTemplate JSON 中 map 的 key 均为字符串
+func mapKeyTemplate(v interface{}) string {
+	switch tV := v.(type) {
+	case string:
+		if tV == "" {
+			return "key"
+		}
+		return tV
+	case bool:
+		return "false"
+	default:
+		return "0"
+	}
+}
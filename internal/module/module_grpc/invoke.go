@@ -0,0 +1,199 @@
+package module_grpc
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/team-ide/go-tool/javascript"
+	"github.com/team-ide/go-tool/task"
+	"github.com/team-ide/go-tool/util"
+	"google.golang.org/grpc"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// InvokeRecord 压测执行记录
+type InvokeRecord struct {
+	Index       int               `json:"index"`
+	WorkerIndex int               `json:"workerIndex"`
+	StartTime   int64             `json:"startTime"`
+	EndTime     int64             `json:"endTime"`
+	UseTime     int64             `json:"useTime"`
+	Messages    []string          `json:"messages,omitempty"`
+	Responses   []json.RawMessage `json:"responses,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+type invokeExecutor struct {
+	*BaseRequest
+	method         *desc.MethodDescriptor
+	workerConn     map[int]*grpc.ClientConn
+	workerConnLock sync.Mutex
+	taskDir        string
+	recordList     []*InvokeRecord
+	recordListLock sync.Mutex
+}
+
+func (this_ *invokeExecutor) getAndCleanRecordList() (recordList []*InvokeRecord) {
+	this_.recordListLock.Lock()
+	defer this_.recordListLock.Unlock()
+	recordList = this_.recordList
+	this_.recordList = []*InvokeRecord{}
+	return
+}
+
+func (this_ *invokeExecutor) addRecord(record *InvokeRecord) {
+	this_.recordListLock.Lock()
+	defer this_.recordListLock.Unlock()
+
+	this_.recordList = append(this_.recordList, record)
+	return
+}
+
+func (this_ *invokeExecutor) stop() {
+	this_.workerConnLock.Lock()
+	defer this_.workerConnLock.Unlock()
+
+	for _, conn := range this_.workerConn {
+		_ = conn.Close()
+	}
+}
+
+// getConn 每个线程使用独立连接
+func (this_ *invokeExecutor) getConn(param *task.ExecutorParam) (conn *grpc.ClientConn, err error) {
+	this_.workerConnLock.Lock()
+	defer this_.workerConnLock.Unlock()
+
+	conn = this_.workerConn[param.WorkerIndex]
+	if conn != nil {
+		return
+	}
+
+	conn, err = dial(this_.BaseRequest)
+	if err != nil {
+		return
+	}
+
+	this_.workerConn[param.WorkerIndex] = conn
+	return
+}
+
+func scriptValue(script string, param *task.ExecutorParam) (res string, err error) {
+	if script == "" {
+		return
+	}
+	scriptContext := javascript.NewContext()
+	if param == nil {
+		param = &task.ExecutorParam{}
+	}
+	scriptContext["index"] = param.Index
+	scriptContext["workerIndex"] = param.WorkerIndex
+
+	v, err := javascript.Run(script, scriptContext)
+	if err != nil {
+		err = errors.New("get scriptValue error:" + err.Error())
+		return
+	}
+	res = util.GetStringValue(v)
+
+	return
+}
+
+// formatMessage 替换消息中的 ${脚本}，如 ${index}、${util.UUID()}
+func formatMessage(message string, param *task.ExecutorParam) (res string, err error) {
+	if message == "" {
+		return
+	}
+	text := ""
+	re, _ := regexp.Compile(`[$]+{(.+?)}`)
+	indexList := re.FindAllStringIndex(message, -1)
+	var lastIndex = 0
+	for _, indexes := range indexList {
+		text += message[lastIndex:indexes[0]]
+
+		lastIndex = indexes[1]
+
+		script := message[indexes[0]+2 : indexes[1]-1]
+		v := ""
+		v, err = scriptValue(script, param)
+		if err != nil {
+			return
+		}
+		text += v
+	}
+	text += message[lastIndex:]
+
+	res = text
+	return
+}
+
+func formatMessages(messages []string, param *task.ExecutorParam) (res []string, err error) {
+	for _, message := range messages {
+		var v string
+		v, err = formatMessage(message, param)
+		if err != nil {
+			return
+		}
+		res = append(res, v)
+	}
+	return
+}
+
+func (this_ *invokeExecutor) Before(param *task.ExecutorParam) (err error) {
+	record := &InvokeRecord{
+		Index:       param.Index,
+		WorkerIndex: param.WorkerIndex,
+	}
+	param.Extend = record
+
+	record.Messages, err = formatMessages(this_.Messages, param)
+	if err != nil {
+		record.Error = err.Error()
+		return
+	}
+
+	_, err = this_.getConn(param)
+	if err != nil {
+		record.Error = err.Error()
+		return
+	}
+	return
+}
+
+func (this_ *invokeExecutor) Execute(param *task.ExecutorParam) (err error) {
+	record := param.Extend.(*InvokeRecord)
+	defer this_.addRecord(record)
+
+	conn, err := this_.getConn(param)
+	if err != nil {
+		record.Error = err.Error()
+		return
+	}
+	var messages []proto.Message
+	messages, err = newMessages(this_.method, record.Messages)
+	if err != nil {
+		record.Error = err.Error()
+		return
+	}
+
+	startTime := time.Now()
+	record.StartTime = startTime.UnixMilli()
+	ctx, cancel := contextWithTimeout(this_.BaseRequest)
+	defer cancel()
+	result, err := invoke(ctx, conn, this_.method, messages)
+	endTime := time.Now()
+	record.EndTime = endTime.UnixMilli()
+	record.UseTime = endTime.Sub(startTime).Milliseconds()
+	if err != nil {
+		record.Error = err.Error()
+		return
+	}
+	record.Responses = result.Responses
+	return
+}
+
+func (this_ *invokeExecutor) After(param *task.ExecutorParam) (err error) {
+	return
+}
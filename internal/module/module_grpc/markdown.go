@@ -0,0 +1,53 @@
+package module_grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"teamide/internal/module/module_toolbox"
+)
+
+func toMarkdown(taskList []map[string]interface{}) (content string) {
+	content = module_toolbox.LoadTaskToMarkdown(taskList, groupToMarkdown)
+	return
+}
+
+func groupToMarkdown(index int, group []map[string]interface{}) (content string) {
+	if len(group) == 0 {
+		return
+	}
+	var bs []byte
+	bs, _ = json.Marshal(group[0]["request"])
+	request := &BaseRequest{}
+	_ = json.Unmarshal(bs, request)
+
+	content += fmt.Sprintf("## 测试组-%d  \n\n", index+1)
+	content += fmt.Sprintf("#### 接口信息  \n\n")
+	content += fmt.Sprintf("* 服务名称：%s  \n", request.ServiceName)
+	content += fmt.Sprintf("* 方法名称：%s  \n", request.MethodName)
+
+	content += fmt.Sprintf("\n")
+
+	content += fmt.Sprintf("#### 测试信息  \n\n")
+	content += fmt.Sprintf("* 线程数：%d  \n", request.Worker)
+	if request.Frequency > 0 {
+		content += fmt.Sprintf("* 执行次数：%d  \n", request.Frequency)
+	} else {
+		content += fmt.Sprintf("* 执行时长：%d  \n", request.Duration)
+	}
+	content += fmt.Sprintf("* 测试地址：%s  \n", request.ServerAddress)
+	content += fmt.Sprintf("* 超时时长：%d  \n", request.Timeout)
+	content += fmt.Sprintf("* TLS：%v  \n", request.TLS)
+
+	content += fmt.Sprintf("\n")
+	for i, message := range request.Messages {
+		content += fmt.Sprintf("* 消息-%d：  \n\n", i+1)
+		content += fmt.Sprintf("```json\n")
+		content += message
+		content += fmt.Sprintf("\n")
+		content += fmt.Sprintf("```\n\n")
+	}
+
+	content += fmt.Sprintf("\n\n")
+	content += module_toolbox.LoadTaskMetricToMarkdown(group)
+	return
+}
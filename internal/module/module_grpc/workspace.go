@@ -0,0 +1,232 @@
+package module_grpc
+
+import (
+	"errors"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Workspace proto 文件目录，解析目录下所有 .proto 文件
+type Workspace struct {
+	dir         string
+	ServiceList []*ServiceInfo    `json:"serviceList"`
+	Errors      map[string]string `json:"errors,omitempty"`
+	services    map[string]*desc.ServiceDescriptor
+	lock        sync.Mutex
+}
+
+type ServiceInfo struct {
+	Name         string        `json:"name"`
+	RelativePath string        `json:"relativePath,omitempty"`
+	Methods      []*MethodInfo `json:"methods"`
+}
+
+type MethodInfo struct {
+	Name            string `json:"name"`
+	InputType       string `json:"inputType"`
+	OutputType      string `json:"outputType"`
+	ClientStreaming bool   `json:"clientStreaming"`
+	ServerStreaming bool   `json:"serverStreaming"`
+}
+
+func NewWorkspace(dir string) *Workspace {
+	return &Workspace{
+		dir: dir,
+	}
+}
+
+// Load 逐个文件解析，单个文件错误不影响其它文件
+func (this_ *Workspace) Load() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.ServiceList = []*ServiceInfo{}
+	this_.Errors = map[string]string{}
+	this_.services = map[string]*desc.ServiceDescriptor{}
+
+	if this_.dir == "" {
+		return
+	}
+	var filenames []string
+	_ = filepath.WalkDir(this_.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".proto") {
+			return nil
+		}
+		rel, e := filepath.Rel(this_.dir, path)
+		if e == nil {
+			filenames = append(filenames, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(filenames)
+
+	parser := protoparse.Parser{
+		ImportPaths:           []string{this_.dir},
+		IncludeSourceCodeInfo: true,
+	}
+	for _, filename := range filenames {
+		files, err := parser.ParseFiles(filename)
+		if err != nil {
+			util.Logger.Warn("proto parse error", zap.Any("filename", filename), zap.Error(err))
+			this_.Errors[filename] = err.Error()
+			continue
+		}
+		for _, file := range files {
+			for _, service := range file.GetServices() {
+				this_.addService(filename, service)
+			}
+		}
+	}
+}
+
+func (this_ *Workspace) addService(relativePath string, service *desc.ServiceDescriptor) {
+	this_.services[service.GetFullyQualifiedName()] = service
+	this_.ServiceList = append(this_.ServiceList, toServiceInfo(relativePath, service))
+}
+
+func (this_ *Workspace) Reload() {
+	this_.Load()
+}
+
+func (this_ *Workspace) GetMethod(serviceName string, methodName string) (method *desc.MethodDescriptor, err error) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	method, err = findMethod(this_.services[serviceName], serviceName, methodName)
+	return
+}
+
+func toServiceInfo(relativePath string, service *desc.ServiceDescriptor) (info *ServiceInfo) {
+	info = &ServiceInfo{
+		Name:         service.GetFullyQualifiedName(),
+		RelativePath: relativePath,
+		Methods:      []*MethodInfo{},
+	}
+	for _, method := range service.GetMethods() {
+		info.Methods = append(info.Methods, &MethodInfo{
+			Name:            method.GetName(),
+			InputType:       method.GetInputType().GetFullyQualifiedName(),
+			OutputType:      method.GetOutputType().GetFullyQualifiedName(),
+			ClientStreaming: method.IsClientStreaming(),
+			ServerStreaming: method.IsServerStreaming(),
+		})
+	}
+	return
+}
+
+func findMethod(service *desc.ServiceDescriptor, serviceName string, methodName string) (method *desc.MethodDescriptor, err error) {
+	if service == nil {
+		err = errors.New("service [" + serviceName + "] not found")
+		return
+	}
+	method = service.FindMethodByName(methodName)
+	if method == nil {
+		err = errors.New("service [" + serviceName + "] method [" + methodName + "] not found")
+		return
+	}
+	return
+}
+
+// getReflectionServices 通过服务端反射获取服务列表，忽略反射服务本身
+func getReflectionServices(request *BaseRequest) (list []*ServiceInfo, err error) {
+	conn, err := dial(request)
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	ctx, cancel := contextWithTimeout(request)
+	defer cancel()
+
+	client := grpcreflect.NewClientAuto(ctx, conn)
+	defer client.Reset()
+
+	names, err := client.ListServices()
+	if err != nil {
+		err = errors.New("reflection list services error:" + err.Error())
+		return
+	}
+	sort.Strings(names)
+	list = []*ServiceInfo{}
+	for _, name := range names {
+		if strings.HasPrefix(name, "grpc.reflection.") {
+			continue
+		}
+		var service *desc.ServiceDescriptor
+		service, err = client.ResolveService(name)
+		if err != nil {
+			err = errors.New("reflection resolve service [" + name + "] error:" + err.Error())
+			return
+		}
+		list = append(list, toServiceInfo("", service))
+	}
+	return
+}
+
+// getReflectionMethod 通过服务端反射解析方法，描述信息在连接关闭后仍可使用
+func getReflectionMethod(request *BaseRequest) (method *desc.MethodDescriptor, err error) {
+	conn, err := dial(request)
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	ctx, cancel := contextWithTimeout(request)
+	defer cancel()
+
+	client := grpcreflect.NewClientAuto(ctx, conn)
+	defer client.Reset()
+
+	service, err := client.ResolveService(request.ServiceName)
+	if err != nil {
+		err = errors.New("reflection resolve service [" + request.ServiceName + "] error:" + err.Error())
+		return
+	}
+	method, err = findMethod(service, request.ServiceName, request.MethodName)
+	return
+}
+
+func requestTimeout(request *BaseRequest) time.Duration {
+	if request.Timeout <= 0 {
+		return time.Second * 10
+	}
+	return time.Millisecond * time.Duration(request.Timeout)
+}
+
+var (
+	workspaceCache     = map[string]*Workspace{}
+	workspaceCacheLock = &sync.Mutex{}
+)
+
+func getOrCreateWorkspace(config *Config) (res *Workspace, err error) {
+	workspaceCacheLock.Lock()
+	defer workspaceCacheLock.Unlock()
+
+	res = workspaceCache[config.ProtoDir]
+	if res != nil {
+		return
+	}
+	res = NewWorkspace(config.ProtoDir)
+	res.Load()
+
+	workspaceCache[config.ProtoDir] = res
+
+	return
+}
+
+func removeWorkspace(dir string) {
+	workspaceCacheLock.Lock()
+	defer workspaceCacheLock.Unlock()
+
+	delete(workspaceCache, dir)
+	return
+}
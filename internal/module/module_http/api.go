@@ -0,0 +1,320 @@
+package module_http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/util"
+	"strconv"
+	"strings"
+	"teamide/internal/module/module_node"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+)
+
+type api struct {
+	toolboxService *module_toolbox.ToolboxService
+	nodeService    *module_node.NodeService
+}
+
+func NewApi(toolboxService *module_toolbox.ToolboxService, nodeService *module_node.NodeService) *api {
+	return &api{
+		toolboxService: toolboxService,
+		nodeService:    nodeService,
+	}
+}
+
+var (
+	// HTTP 权限
+	Power              = base.AppendPower(&base.PowerAction{Action: "http", Text: "HTTP", ShouldLogin: true, StandAlone: true})
+	sendPower          = base.AppendPower(&base.PowerAction{Action: "send", Text: "发送请求", ShouldLogin: true, StandAlone: true, Parent: Power})
+	runCollectionPower = base.AppendPower(&base.PowerAction{Action: "runCollection", Text: "运行集合", ShouldLogin: true, StandAlone: true, Parent: Power})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+
+	apis = append(apis, &base.ApiWorker{Power: sendPower, Do: this_.send})
+	apis = append(apis, &base.ApiWorker{Power: runCollectionPower, Do: this_.runCollection})
+
+	return
+}
+
+type Config struct {
+	// 基础地址，请求地址不是完整地址时拼接
+	BaseUrl string `json:"baseUrl"`
+	// 节点网络代理，请求通过代理的入口地址转发
+	NetProxyId int64 `json:"netProxyId"`
+	// 超时时长 毫秒
+	Timeout            int  `json:"timeout"`
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+	FollowRedirect     bool `json:"followRedirect"`
+}
+
+type BaseRequest struct {
+	ToolboxId int64        `json:"toolboxId,omitempty"`
+	Request   *HttpRequest `json:"request,omitempty"`
+	// 集合快速指令ID，使用集合的变量、认证及脚本
+	CollectionId int64 `json:"collectionId,omitempty"`
+	// 环境快速指令ID
+	EnvironmentId int64 `json:"environmentId,omitempty"`
+	// 临时变量，优先级最高
+	Variables []*KeyValue `json:"variables,omitempty"`
+	// 将脚本修改后的变量保存到环境
+	SaveEnvironment bool `json:"saveEnvironment,omitempty"`
+	// 运行集合时只运行指定名称的请求，为空运行全部
+	RequestNames []string `json:"requestNames,omitempty"`
+}
+
+func (this_ *api) newClient(requestBean *base.RequestBean, c *gin.Context) (config *Config, client *httpClient, err error) {
+	// 表单中的值可能为字符串，使用 map 绑定后转换
+	option := map[string]interface{}{}
+	sshConfig, err := this_.toolboxService.BindConfig(requestBean, c, &option)
+	if err != nil {
+		return
+	}
+	config = &Config{
+		BaseUrl:            util.GetStringValue(option["baseUrl"]),
+		InsecureSkipVerify: toBool(option["insecureSkipVerify"]),
+		FollowRedirect:     toBool(option["followRedirect"]),
+	}
+	config.NetProxyId, _ = strconv.ParseInt(util.GetStringValue(option["netProxyId"]), 10, 64)
+	config.Timeout, _ = strconv.Atoi(util.GetStringValue(option["timeout"]))
+	var proxyAddress string
+	if config.NetProxyId != 0 {
+		proxyAddress, err = this_.getNetProxyAddress(requestBean, config.NetProxyId)
+		if err != nil {
+			return
+		}
+	}
+	var userId int64
+	if requestBean.JWT != nil {
+		userId = requestBean.JWT.UserId
+	}
+	client, err = newHttpClient(config, sshConfig, proxyAddress, userId)
+	return
+}
+
+// getNetProxyAddress 网络代理入口地址，只有端口时使用本机地址
+func (this_ *api) getNetProxyAddress(requestBean *base.RequestBean, netProxyId int64) (address string, err error) {
+	netProxy, err := this_.nodeService.GetNetProxy(netProxyId)
+	if err != nil {
+		return
+	}
+	if netProxy == nil {
+		err = errors.New(fmt.Sprint("网络代理[", netProxyId, "]不存在"))
+		return
+	}
+	if netProxy.UserId != 0 && (requestBean.JWT == nil || netProxy.UserId != requestBean.JWT.UserId) {
+		err = errors.New("网络代理[" + netProxy.Name + "]不属于当前用户，无法使用")
+		return
+	}
+	address = netProxy.InnerAddress
+	if strings.HasPrefix(address, ":") {
+		address = "127.0.0.1" + address
+	}
+	return
+}
+
+func (this_ *api) getQuickCommand(requestBean *base.RequestBean, quickCommandId int64, quickCommandType int, data interface{}) (find *module_toolbox.ToolboxQuickCommandModel, err error) {
+	find, err = this_.toolboxService.GetQuickCommand(quickCommandId)
+	if err != nil {
+		return
+	}
+	if find == nil || find.QuickCommandType != quickCommandType {
+		err = errors.New(fmt.Sprint("快速指令[", quickCommandId, "]不存在"))
+		return
+	}
+	if find.UserId != 0 && (requestBean.JWT == nil || find.UserId != requestBean.JWT.UserId) {
+		err = errors.New("快速指令[" + find.Name + "]不属于当前用户，无法操作")
+		return
+	}
+	if find.Option != "" {
+		if err = json.Unmarshal([]byte(find.Option), data); err != nil {
+			err = errors.New("快速指令[" + find.Name + "]配置解析失败:" + err.Error())
+			return
+		}
+	}
+	return
+}
+
+// loadContext 加载集合、环境，变量优先级 集合 < 环境 < 临时变量
+func (this_ *api) loadContext(requestBean *base.RequestBean, request *BaseRequest) (collection *Collection, environment *Environment, variables map[string]string, err error) {
+	collection = &Collection{}
+	environment = &Environment{}
+	if request.CollectionId != 0 {
+		_, err = this_.getQuickCommand(requestBean, request.CollectionId, module_toolbox.QuickCommandTypeHttpCollection, collection)
+		if err != nil {
+			return
+		}
+	}
+	if request.EnvironmentId != 0 {
+		_, err = this_.getQuickCommand(requestBean, request.EnvironmentId, module_toolbox.QuickCommandTypeHttpEnvironment, environment)
+		if err != nil {
+			return
+		}
+	}
+	variables = toMap(collection.Variables)
+	for key, value := range toMap(environment.Variables) {
+		variables[key] = value
+	}
+	for key, value := range toMap(request.Variables) {
+		variables[key] = value
+	}
+	return
+}
+
+// saveEnvironment 保存脚本修改后的变量，临时变量及集合变量不变的不写入环境
+func (this_ *api) saveEnvironment(request *BaseRequest, collection *Collection, environment *Environment, variables map[string]string) (err error) {
+	if !request.SaveEnvironment || request.EnvironmentId == 0 {
+		return
+	}
+	collectionVariables := toMap(collection.Variables)
+	requestVariables := toMap(request.Variables)
+	envVariables := toMap(environment.Variables)
+
+	var list []*KeyValue
+	for _, one := range environment.Variables {
+		if one == nil {
+			continue
+		}
+		if one.Disabled {
+			list = append(list, one)
+			continue
+		}
+		value, ok := variables[one.Key]
+		if !ok {
+			continue
+		}
+		list = append(list, &KeyValue{Key: one.Key, Value: value})
+	}
+	for key, value := range variables {
+		if _, ok := envVariables[key]; ok {
+			continue
+		}
+		if v, ok := requestVariables[key]; ok && v == value {
+			continue
+		}
+		if v, ok := collectionVariables[key]; ok && v == value {
+			continue
+		}
+		list = append(list, &KeyValue{Key: key, Value: value})
+	}
+	environment.Variables = list
+
+	bs, err := json.Marshal(environment)
+	if err != nil {
+		return
+	}
+	_, err = this_.toolboxService.UpdateQuickCommand(&module_toolbox.ToolboxQuickCommandModel{
+		QuickCommandId: request.EnvironmentId,
+		Option:         string(bs),
+	})
+	return
+}
+
+func (this_ *api) send(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, client, err := this_.newClient(requestBean, c)
+	if err != nil {
+		return
+	}
+	defer client.close()
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Request == nil {
+		err = errors.New("请求不能为空")
+		return
+	}
+
+	collection, environment, variables, err := this_.loadContext(requestBean, request)
+	if err != nil {
+		return
+	}
+
+	data := map[string]interface{}{}
+	res = data
+
+	data["response"] = client.send(config, collection, request.Request, variables)
+	data["variables"] = variables
+
+	err = this_.saveEnvironment(request, collection, environment, variables)
+	return
+}
+
+func (this_ *api) runCollection(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, client, err := this_.newClient(requestBean, c)
+	if err != nil {
+		return
+	}
+	defer client.close()
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.CollectionId == 0 {
+		err = errors.New("集合不能为空")
+		return
+	}
+
+	collection, environment, variables, err := this_.loadContext(requestBean, request)
+	if err != nil {
+		return
+	}
+
+	var responses []*HttpResponse
+	var passed, failed int
+	for _, one := range collection.Requests {
+		if one == nil {
+			continue
+		}
+		if len(request.RequestNames) > 0 && !containsName(request.RequestNames, one.Name) {
+			continue
+		}
+		// 按顺序执行，前面请求脚本设置的变量后续请求可使用
+		response := client.send(config, collection, one, variables)
+		responses = append(responses, response)
+		if response.Error != "" {
+			failed++
+			continue
+		}
+		for _, test := range response.Tests {
+			if test.Passed {
+				passed++
+			} else {
+				failed++
+			}
+		}
+	}
+
+	data := map[string]interface{}{}
+	res = data
+
+	data["responses"] = responses
+	data["variables"] = variables
+	data["passed"] = passed
+	data["failed"] = failed
+
+	err = this_.saveEnvironment(request, collection, environment, variables)
+	return
+}
+
+func containsName(names []string, name string) bool {
+	for _, one := range names {
+		if one == name {
+			return true
+		}
+	}
+	return false
+}
+
+func toBool(value interface{}) bool {
+	switch util.GetStringValue(value) {
+	case "1", "true":
+		return true
+	}
+	return false
+}
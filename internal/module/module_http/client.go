@@ -0,0 +1,151 @@
+package module_http
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	goSSH "golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+// httpClient 请求客户端，配置 SSH 隧道时通过 SSH 连接目标地址，配置节点代理时连接代理的入口地址
+type httpClient struct {
+	client    *http.Client
+	sshClient *goSSH.Client
+	// 使用该客户端的用户，令牌缓存按用户隔离
+	userId int64
+}
+
+func newHttpClient(config *Config, sshConfig *ssh.Config, proxyAddress string, userId int64) (res *httpClient, err error) {
+	res = &httpClient{userId: userId}
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify},
+	}
+	dialer := &net.Dialer{Timeout: time.Second * 10}
+	dial := dialer.DialContext
+	if proxyAddress != "" {
+		// 网络代理入口为本机地址，直接连接，不通过 SSH 隧道
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, proxyAddress)
+		}
+		transport.Proxy = nil
+	} else if sshConfig != nil {
+		// SSH 隧道只用于连接目标地址
+		res.sshClient, err = ssh.NewClient(*sshConfig)
+		if err != nil {
+			err = errors.New("ssh client error:" + err.Error())
+			return
+		}
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return res.sshClient.Dial(network, addr)
+		}
+		transport.Proxy = nil
+	}
+	transport.DialContext = dial
+	res.client = &http.Client{
+		Transport: transport,
+	}
+	if !config.FollowRedirect {
+		res.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	return
+}
+
+func (this_ *httpClient) close() {
+	this_.client.CloseIdleConnections()
+	if this_.sshClient != nil {
+		_ = this_.sshClient.Close()
+	}
+}
+
+type oauth2Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	expireTime  time.Time
+}
+
+var (
+	oauth2TokenCache     = map[string]*oauth2Token{}
+	oauth2TokenCacheLock = &sync.Mutex{}
+	// 获取令牌请求超时时间
+	oauth2TokenTimeout = time.Second * 30
+)
+
+// getOAuth2Token client credentials 模式获取令牌，过期前缓存复用，缓存按用户和客户端密钥区分
+func (this_ *httpClient) getOAuth2Token(auth *Auth) (token string, err error) {
+	key := strconv.FormatInt(this_.userId, 10) + "|" + auth.TokenUrl + "|" + auth.ClientId + "|" + base.GetMd5String(auth.ClientSecret) + "|" + auth.Scope
+	oauth2TokenCacheLock.Lock()
+	find := oauth2TokenCache[key]
+	oauth2TokenCacheLock.Unlock()
+	if find != nil && time.Now().Before(find.expireTime) {
+		token = find.AccessToken
+		return
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if auth.Scope != "" {
+		form.Set("scope", auth.Scope)
+	}
+	req, err := http.NewRequest("POST", auth.TokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(auth.ClientId), url.QueryEscape(auth.ClientSecret))
+	tokenClient := &http.Client{
+		Transport:     this_.client.Transport,
+		CheckRedirect: this_.client.CheckRedirect,
+		Timeout:       oauth2TokenTimeout,
+	}
+	res, err := tokenClient.Do(req)
+	if err != nil {
+		err = errors.New("oauth2 token request error:" + err.Error())
+		return
+	}
+	defer func() { _ = res.Body.Close() }()
+	bs, err := io.ReadAll(res.Body)
+	if err != nil {
+		return
+	}
+	if res.StatusCode != http.StatusOK {
+		err = errors.New("oauth2 token request status " + res.Status + ":" + string(bs))
+		return
+	}
+	find = &oauth2Token{}
+	if err = json.Unmarshal(bs, find); err != nil {
+		err = errors.New("oauth2 token response error:" + err.Error())
+		return
+	}
+	if find.AccessToken == "" {
+		err = errors.New("oauth2 token response access_token is empty")
+		return
+	}
+	// 提前 30 秒过期，避免使用时刚好过期
+	expiresIn := find.ExpiresIn - 30
+	if expiresIn < 0 {
+		expiresIn = 0
+	}
+	find.expireTime = time.Now().Add(time.Second * time.Duration(expiresIn))
+
+	oauth2TokenCacheLock.Lock()
+	oauth2TokenCache[key] = find
+	oauth2TokenCacheLock.Unlock()
+
+	token = find.AccessToken
+	return
+}
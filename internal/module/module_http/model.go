@@ -0,0 +1,108 @@
+package module_http
+
+import (
+	"regexp"
+	"strings"
+)
+
+// KeyValue 请求头、查询参数、表单及变量，Disabled 的不生效
+type KeyValue struct {
+	Key      string `json:"key,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// Auth 认证方式 none、inherit（使用集合认证）、basic、bearer、oauth2（client credentials）
+type Auth struct {
+	Type         string `json:"type,omitempty"`
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`
+	Token        string `json:"token,omitempty"`
+	TokenUrl     string `json:"tokenUrl,omitempty"`
+	ClientId     string `json:"clientId,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+type HttpRequest struct {
+	Name    string      `json:"name,omitempty"`
+	Method  string      `json:"method,omitempty"`
+	Url     string      `json:"url,omitempty"`
+	Params  []*KeyValue `json:"params,omitempty"`
+	Headers []*KeyValue `json:"headers,omitempty"`
+	// 请求体类型 none、json、text、xml、form、urlencoded
+	BodyType string      `json:"bodyType,omitempty"`
+	Body     string      `json:"body,omitempty"`
+	Form     []*KeyValue `json:"form,omitempty"`
+	Auth     *Auth       `json:"auth,omitempty"`
+	// 请求前脚本，可修改 request 和 env
+	PreScript string `json:"preScript,omitempty"`
+	// 测试脚本，可使用 response，通过 test(name, bool) 记录断言
+	TestScript string `json:"testScript,omitempty"`
+	// 超时时长 毫秒，为空使用工具配置
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// Collection 请求集合，保存在快速指令中，Option 为集合 JSON
+type Collection struct {
+	Variables  []*KeyValue    `json:"variables,omitempty"`
+	Auth       *Auth          `json:"auth,omitempty"`
+	PreScript  string         `json:"preScript,omitempty"`
+	TestScript string         `json:"testScript,omitempty"`
+	Requests   []*HttpRequest `json:"requests,omitempty"`
+}
+
+// Environment 环境变量，保存在快速指令中，Option 为环境 JSON
+type Environment struct {
+	Variables []*KeyValue `json:"variables,omitempty"`
+}
+
+type TestResult struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+type HttpResponse struct {
+	Name       string              `json:"name,omitempty"`
+	Method     string              `json:"method,omitempty"`
+	Url        string              `json:"url,omitempty"`
+	Status     int                 `json:"status,omitempty"`
+	StatusText string              `json:"statusText,omitempty"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       string              `json:"body,omitempty"`
+	// 响应体超过大小限制时截断
+	BodyTruncated bool          `json:"bodyTruncated,omitempty"`
+	Size          int64         `json:"size"`
+	UseTime       int64         `json:"useTime"`
+	Tests         []*TestResult `json:"tests,omitempty"`
+	Logs          []string      `json:"logs,omitempty"`
+	Error         string        `json:"error,omitempty"`
+}
+
+func toMap(list []*KeyValue) map[string]string {
+	res := map[string]string{}
+	for _, one := range list {
+		if one == nil || one.Disabled || one.Key == "" {
+			continue
+		}
+		res[one.Key] = one.Value
+	}
+	return res
+}
+
+var variableRegexp = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// replaceVariables 替换 {{name}}，未定义的变量保持原样
+func replaceVariables(text string, variables map[string]string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	return variableRegexp.ReplaceAllStringFunc(text, func(s string) string {
+		name := variableRegexp.FindStringSubmatch(s)[1]
+		if value, ok := variables[name]; ok {
+			return value
+		}
+		return s
+	})
+}
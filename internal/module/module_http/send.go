@@ -0,0 +1,292 @@
+package module_http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/javascript"
+	"github.com/team-ide/go-tool/util"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"teamide/internal/module/module_toolbox"
+	"time"
+)
+
+var (
+	// 响应体最大读取 10M
+	responseBodyMaxSize int64 = 10 * 1024 * 1024
+	// 前置、后置脚本最长执行时间，超时后中断脚本
+	scriptTimeout = time.Second * 10
+)
+
+// send 执行请求，脚本中修改的变量写回 variables，集合中后续请求可使用
+func (this_ *httpClient) send(config *Config, collection *Collection, request *HttpRequest, variables map[string]string) (response *HttpResponse) {
+	response = &HttpResponse{
+		Name: request.Name,
+	}
+	if collection == nil {
+		collection = &Collection{}
+	}
+
+	scriptRequest := map[string]interface{}{
+		"method":  request.Method,
+		"url":     request.Url,
+		"headers": toScriptMap(request.Headers),
+		"body":    request.Body,
+	}
+	for _, script := range []string{collection.PreScript, request.PreScript} {
+		if err := runScript(script, map[string]interface{}{"request": scriptRequest}, variables, response); err != nil {
+			response.Error = "pre script error:" + err.Error()
+			return
+		}
+	}
+	request = applyScriptRequest(request, scriptRequest)
+
+	req, err := this_.newRequest(config, collection, request, variables)
+	if err != nil {
+		response.Error = err.Error()
+		return
+	}
+	response.Method = req.Method
+	response.Url = req.URL.String()
+
+	timeout := request.Timeout
+	if timeout <= 0 {
+		timeout = config.Timeout
+	}
+	if timeout <= 0 {
+		timeout = 30000
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*time.Duration(timeout))
+	defer cancel()
+
+	startTime := time.Now()
+	res, err := this_.client.Do(req.WithContext(ctx))
+	if err != nil {
+		response.UseTime = time.Since(startTime).Milliseconds()
+		response.Error = err.Error()
+		return
+	}
+	defer func() { _ = res.Body.Close() }()
+	bs, err := io.ReadAll(io.LimitReader(res.Body, responseBodyMaxSize+1))
+	response.UseTime = time.Since(startTime).Milliseconds()
+	if err != nil {
+		response.Error = "read body error:" + err.Error()
+		return
+	}
+	if int64(len(bs)) > responseBodyMaxSize {
+		bs = bs[:responseBodyMaxSize]
+		response.BodyTruncated = true
+	}
+	response.Status = res.StatusCode
+	response.StatusText = res.Status
+	response.Headers = res.Header
+	response.Body = string(bs)
+	response.Size = int64(len(bs))
+
+	scriptResponse := map[string]interface{}{
+		"status":     res.StatusCode,
+		"statusText": res.Status,
+		"headers":    toScriptHeaders(res.Header),
+		"body":       response.Body,
+		"useTime":    response.UseTime,
+	}
+	var data interface{}
+	if json.Unmarshal(bs, &data) == nil {
+		scriptResponse["json"] = data
+	}
+	for _, script := range []string{collection.TestScript, request.TestScript} {
+		if err = runScript(script, map[string]interface{}{"response": scriptResponse}, variables, response); err != nil {
+			response.Error = "test script error:" + err.Error()
+			return
+		}
+	}
+	return
+}
+
+func (this_ *httpClient) newRequest(config *Config, collection *Collection, request *HttpRequest, variables map[string]string) (req *http.Request, err error) {
+	method := strings.ToUpper(request.Method)
+	if method == "" {
+		method = "GET"
+	}
+	rawUrl := replaceVariables(request.Url, variables)
+	if config.BaseUrl != "" && !strings.HasPrefix(rawUrl, "http://") && !strings.HasPrefix(rawUrl, "https://") {
+		rawUrl = strings.TrimSuffix(replaceVariables(config.BaseUrl, variables), "/") + "/" + strings.TrimPrefix(rawUrl, "/")
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		err = errors.New("url [" + rawUrl + "] error:" + err.Error())
+		return
+	}
+	query := u.Query()
+	for _, one := range request.Params {
+		if one == nil || one.Disabled || one.Key == "" {
+			continue
+		}
+		query.Add(replaceVariables(one.Key, variables), replaceVariables(one.Value, variables))
+	}
+	u.RawQuery = query.Encode()
+
+	var body io.Reader
+	var contentType string
+	switch request.BodyType {
+	case "json":
+		body = strings.NewReader(replaceVariables(request.Body, variables))
+		contentType = "application/json"
+	case "xml":
+		body = strings.NewReader(replaceVariables(request.Body, variables))
+		contentType = "application/xml"
+	case "text":
+		body = strings.NewReader(replaceVariables(request.Body, variables))
+		contentType = "text/plain"
+	case "urlencoded":
+		form := url.Values{}
+		for _, one := range request.Form {
+			if one == nil || one.Disabled || one.Key == "" {
+				continue
+			}
+			form.Add(replaceVariables(one.Key, variables), replaceVariables(one.Value, variables))
+		}
+		body = strings.NewReader(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	case "form":
+		buf := &bytes.Buffer{}
+		writer := multipart.NewWriter(buf)
+		for _, one := range request.Form {
+			if one == nil || one.Disabled || one.Key == "" {
+				continue
+			}
+			if err = writer.WriteField(replaceVariables(one.Key, variables), replaceVariables(one.Value, variables)); err != nil {
+				return
+			}
+		}
+		if err = writer.Close(); err != nil {
+			return
+		}
+		body = buf
+		contentType = writer.FormDataContentType()
+	}
+
+	req, err = http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, one := range request.Headers {
+		if one == nil || one.Disabled || one.Key == "" {
+			continue
+		}
+		req.Header.Set(replaceVariables(one.Key, variables), replaceVariables(one.Value, variables))
+	}
+
+	auth := request.Auth
+	if auth == nil || auth.Type == "" || auth.Type == "inherit" {
+		auth = collection.Auth
+	}
+	err = this_.setAuth(req, auth, variables)
+	return
+}
+
+func (this_ *httpClient) setAuth(req *http.Request, auth *Auth, variables map[string]string) (err error) {
+	if auth == nil {
+		return
+	}
+	switch auth.Type {
+	case "basic":
+		req.SetBasicAuth(replaceVariables(auth.Username, variables), replaceVariables(auth.Password, variables))
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+replaceVariables(auth.Token, variables))
+	case "oauth2":
+		var token string
+		token, err = this_.getOAuth2Token(&Auth{
+			TokenUrl:     replaceVariables(auth.TokenUrl, variables),
+			ClientId:     replaceVariables(auth.ClientId, variables),
+			ClientSecret: replaceVariables(auth.ClientSecret, variables),
+			Scope:        replaceVariables(auth.Scope, variables),
+		})
+		if err != nil {
+			return
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return
+}
+
+// runScript 执行脚本，env 为变量，console.log 输出记录到响应日志，test(name, bool) 记录断言结果
+func runScript(script string, data map[string]interface{}, variables map[string]string, response *HttpResponse) (err error) {
+	if strings.TrimSpace(script) == "" {
+		return
+	}
+	env := map[string]interface{}{}
+	for key, value := range variables {
+		env[key] = value
+	}
+	scriptContext := javascript.NewContext()
+	for key, value := range data {
+		scriptContext[key] = value
+	}
+	scriptContext["env"] = env
+	scriptContext["console"] = map[string]interface{}{
+		"log": func(args ...interface{}) {
+			response.Logs = append(response.Logs, strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+		},
+	}
+	scriptContext["test"] = func(name string, passed bool) {
+		response.Tests = append(response.Tests, &TestResult{Name: name, Passed: passed})
+	}
+	_, err = module_toolbox.RunScript(script, scriptContext, scriptTimeout)
+
+	// 脚本中新增、修改、删除的变量写回
+	for key := range variables {
+		if _, ok := env[key]; !ok {
+			delete(variables, key)
+		}
+	}
+	for key, value := range env {
+		if value == nil {
+			delete(variables, key)
+			continue
+		}
+		variables[key] = util.GetStringValue(value)
+	}
+	return
+}
+
+func toScriptMap(list []*KeyValue) map[string]interface{} {
+	res := map[string]interface{}{}
+	for key, value := range toMap(list) {
+		res[key] = value
+	}
+	return res
+}
+
+func toScriptHeaders(header http.Header) map[string]interface{} {
+	res := map[string]interface{}{}
+	for key := range header {
+		res[key] = header.Get(key)
+		res[strings.ToLower(key)] = header.Get(key)
+	}
+	return res
+}
+
+// applyScriptRequest 请求前脚本修改的 method、url、headers、body 生效到请求
+func applyScriptRequest(request *HttpRequest, scriptRequest map[string]interface{}) *HttpRequest {
+	res := *request
+	res.Method = util.GetStringValue(scriptRequest["method"])
+	res.Url = util.GetStringValue(scriptRequest["url"])
+	res.Body = util.GetStringValue(scriptRequest["body"])
+	if headers, ok := scriptRequest["headers"].(map[string]interface{}); ok {
+		res.Headers = []*KeyValue{}
+		for key, value := range headers {
+			res.Headers = append(res.Headers, &KeyValue{Key: key, Value: util.GetStringValue(value)})
+		}
+	}
+	return &res
+}
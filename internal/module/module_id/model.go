@@ -48,6 +48,10 @@ const (
 	IDTypeToolboxGroup = 5004
 	// IDTypeToolboxQuickCommand 工具箱快速命令ID类型
 	IDTypeToolboxQuickCommand = 5005
+	// IDTypeToolboxKnownHost 工具箱SSH主机公钥ID类型
+	IDTypeToolboxKnownHost = 5006
+	// IDTypeToolboxSSHForward 工具箱SSH端口转发ID类型
+	IDTypeToolboxSSHForward = 5007
 
 	// IDTypeNode 节点
 	IDTypeNode = 6001
@@ -59,4 +63,8 @@ const (
 
 	// IDTypeTerminalLog 控制台日志
 	IDTypeTerminalLog = 8001
+	// IDTypeTerminalRecord 终端录像
+	IDTypeTerminalRecord = 8002
+	// IDTypeTerminalCommandPolicy 终端命令策略
+	IDTypeTerminalCommandPolicy = 8003
 )
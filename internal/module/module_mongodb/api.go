@@ -0,0 +1,522 @@
+package module_mongodb
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+)
+
+type api struct {
+	toolboxService *module_toolbox.ToolboxService
+}
+
+func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
+	return &api{
+		toolboxService: toolboxService,
+	}
+}
+
+var (
+	// MongoDB 权限
+	Power                 = base.AppendPower(&base.PowerAction{Action: "mongodb", Text: "MongoDB", ShouldLogin: true, StandAlone: true})
+	databasesPower        = base.AppendPower(&base.PowerAction{Action: "databases", Text: "MongoDB数据库查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	dropDatabasePower     = base.AppendPower(&base.PowerAction{Action: "dropDatabase", Text: "MongoDB删除数据库", ShouldLogin: true, StandAlone: true, Parent: Power})
+	collectionsPower      = base.AppendPower(&base.PowerAction{Action: "collections", Text: "MongoDB集合查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	createCollectionPower = base.AppendPower(&base.PowerAction{Action: "createCollection", Text: "MongoDB创建集合", ShouldLogin: true, StandAlone: true, Parent: Power})
+	dropCollectionPower   = base.AppendPower(&base.PowerAction{Action: "dropCollection", Text: "MongoDB删除集合", ShouldLogin: true, StandAlone: true, Parent: Power})
+	findPower             = base.AppendPower(&base.PowerAction{Action: "find", Text: "MongoDB查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	aggregatePower        = base.AppendPower(&base.PowerAction{Action: "aggregate", Text: "MongoDB聚合", ShouldLogin: true, StandAlone: true, Parent: Power})
+	insertPower           = base.AppendPower(&base.PowerAction{Action: "insert", Text: "MongoDB插入数据", ShouldLogin: true, StandAlone: true, Parent: Power})
+	updatePower           = base.AppendPower(&base.PowerAction{Action: "update", Text: "MongoDB修改数据", ShouldLogin: true, StandAlone: true, Parent: Power})
+	deletePower           = base.AppendPower(&base.PowerAction{Action: "delete", Text: "MongoDB删除数据", ShouldLogin: true, StandAlone: true, Parent: Power})
+	indexesPower          = base.AppendPower(&base.PowerAction{Action: "indexes", Text: "MongoDB索引查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	createIndexPower      = base.AppendPower(&base.PowerAction{Action: "createIndex", Text: "MongoDB创建索引", ShouldLogin: true, StandAlone: true, Parent: Power})
+	dropIndexPower        = base.AppendPower(&base.PowerAction{Action: "dropIndex", Text: "MongoDB删除索引", ShouldLogin: true, StandAlone: true, Parent: Power})
+	importPower           = base.AppendPower(&base.PowerAction{Action: "import", Text: "MongoDB导入", ShouldLogin: true, StandAlone: true, Parent: Power})
+	exportPower           = base.AppendPower(&base.PowerAction{Action: "export", Text: "MongoDB导出", ShouldLogin: true, StandAlone: true, Parent: Power})
+	exportDownloadPower   = base.AppendPower(&base.PowerAction{Action: "exportDownload", Text: "MongoDB导出下载", ShouldLogin: true, StandAlone: true, Parent: Power})
+	taskListPower         = base.AppendPower(&base.PowerAction{Action: "taskList", Text: "MongoDB任务列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	taskStatusPower       = base.AppendPower(&base.PowerAction{Action: "taskStatus", Text: "MongoDB任务状态", ShouldLogin: true, StandAlone: true, Parent: Power})
+	taskStopPower         = base.AppendPower(&base.PowerAction{Action: "taskStop", Text: "MongoDB任务停止", ShouldLogin: true, StandAlone: true, Parent: Power})
+	taskCleanPower        = base.AppendPower(&base.PowerAction{Action: "taskClean", Text: "MongoDB任务清理", ShouldLogin: true, StandAlone: true, Parent: Power})
+	closePower            = base.AppendPower(&base.PowerAction{Action: "close", Text: "MongoDB关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+	apis = append(apis, &base.ApiWorker{Power: databasesPower, Do: this_.databases})
+	apis = append(apis, &base.ApiWorker{Power: dropDatabasePower, Do: this_.dropDatabase})
+	apis = append(apis, &base.ApiWorker{Power: collectionsPower, Do: this_.collections})
+	apis = append(apis, &base.ApiWorker{Power: createCollectionPower, Do: this_.createCollection})
+	apis = append(apis, &base.ApiWorker{Power: dropCollectionPower, Do: this_.dropCollection})
+	apis = append(apis, &base.ApiWorker{Power: findPower, Do: this_.find})
+	apis = append(apis, &base.ApiWorker{Power: aggregatePower, Do: this_.aggregate})
+	apis = append(apis, &base.ApiWorker{Power: insertPower, Do: this_.insert})
+	apis = append(apis, &base.ApiWorker{Power: updatePower, Do: this_.update})
+	apis = append(apis, &base.ApiWorker{Power: deletePower, Do: this_.delete})
+	apis = append(apis, &base.ApiWorker{Power: indexesPower, Do: this_.indexes})
+	apis = append(apis, &base.ApiWorker{Power: createIndexPower, Do: this_.createIndex})
+	apis = append(apis, &base.ApiWorker{Power: dropIndexPower, Do: this_.dropIndex})
+	apis = append(apis, &base.ApiWorker{Power: importPower, Do: this_._import})
+	apis = append(apis, &base.ApiWorker{Power: exportPower, Do: this_.export})
+	apis = append(apis, &base.ApiWorker{Power: exportDownloadPower, Do: this_.exportDownload, IsGet: true})
+	apis = append(apis, &base.ApiWorker{Power: taskListPower, Do: this_.taskList})
+	apis = append(apis, &base.ApiWorker{Power: taskStatusPower, Do: this_.taskStatus})
+	apis = append(apis, &base.ApiWorker{Power: taskStopPower, Do: this_.taskStop})
+	apis = append(apis, &base.ApiWorker{Power: taskCleanPower, Do: this_.taskClean})
+	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
+
+	return
+}
+
+func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (config *Config, sshConfig *ssh.Config, err error) {
+	config = &Config{}
+	sshConfig, err = this_.toolboxService.BindConfig(requestBean, c, config)
+	if err != nil {
+		return
+	}
+	config.Password = this_.toolboxService.DecryptOptionAttr(config.Password)
+	return
+}
+
+func getService(mongoConfig *Config, sshConfig *ssh.Config) (res *Service, err error) {
+	key := "mongodb-" + mongoConfig.Address
+	if mongoConfig.Username != "" {
+		key += "-" + base.GetMd5String(key+mongoConfig.Username)
+	}
+	if mongoConfig.Password != "" {
+		key += "-" + base.GetMd5String(key+mongoConfig.Password)
+	}
+	if mongoConfig.AuthSource != "" {
+		key += "-" + mongoConfig.AuthSource
+	}
+	if sshConfig != nil {
+		key += "-ssh-" + sshConfig.Address
+		key += "-ssh-" + sshConfig.Username
+	}
+	var serviceInfo *base.ServiceInfo
+	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
+		var s *Service
+		s, err = NewService(mongoConfig, sshConfig)
+		if err != nil {
+			util.Logger.Error("getMongoDBService error", zap.Any("key", key), zap.Error(err))
+			return
+		}
+		err = s.Ping()
+		if err != nil {
+			util.Logger.Error("getMongoDBService error", zap.Any("key", key), zap.Error(err))
+			s.Close()
+			return
+		}
+		res = &base.ServiceInfo{
+			WaitTime:    10 * 60 * 1000,
+			LastUseTime: util.GetNowMilli(),
+			Service:     s,
+			Stop:        s.Close,
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = serviceInfo.Service.(*Service)
+	serviceInfo.SetLastUseTime()
+	return
+}
+
+type BaseRequest struct {
+	WorkerId   string `json:"workerId"`
+	Database   string `json:"database"`
+	Collection string `json:"collection"`
+	Filter     string `json:"filter"`
+	Projection string `json:"projection"`
+	Sort       string `json:"sort"`
+	PageIndex  int64  `json:"pageIndex"`
+	PageSize   int64  `json:"pageSize"`
+	Pipeline   string `json:"pipeline"`
+	// 聚合最多返回条数，默认 1000
+	Limit     int         `json:"limit"`
+	Documents string      `json:"documents"`
+	Update    string      `json:"update"`
+	Multi     bool        `json:"multi"`
+	Upsert    bool        `json:"upsert"`
+	Index     *IndexParam `json:"index"`
+	IndexName string      `json:"indexName"`
+	TaskId    string      `json:"taskId"`
+}
+
+func (this_ *api) getServiceAndRequest(requestBean *base.RequestBean, c *gin.Context) (service *Service, request *BaseRequest, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err = getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+	request = &BaseRequest{}
+	err = c.ShouldBindBodyWith(request, binding.JSON)
+	return
+}
+
+func (this_ *api) databases(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, _, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	res, err = service.Databases()
+	return
+}
+
+func (this_ *api) dropDatabase(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	err = service.DropDatabase(request.Database)
+	return
+}
+
+func (this_ *api) collections(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	res, err = service.Collections(request.Database)
+	return
+}
+
+func (this_ *api) createCollection(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	err = service.CreateCollection(request.Database, request.Collection)
+	return
+}
+
+func (this_ *api) dropCollection(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	err = service.DropCollection(request.Database, request.Collection)
+	return
+}
+
+func (this_ *api) find(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	res, err = service.Find(&FindParam{
+		Database:   request.Database,
+		Collection: request.Collection,
+		Filter:     request.Filter,
+		Projection: request.Projection,
+		Sort:       request.Sort,
+		PageIndex:  request.PageIndex,
+		PageSize:   request.PageSize,
+	})
+	return
+}
+
+func (this_ *api) aggregate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+	limit := request.Limit
+	if limit <= 0 {
+		limit = 1000
+	}
+
+	res, err = service.Aggregate(request.Database, request.Collection, request.Pipeline, limit)
+	return
+}
+
+func (this_ *api) insert(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	res, err = service.Insert(request.Database, request.Collection, request.Documents)
+	return
+}
+
+func (this_ *api) update(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	res, err = service.Update(request.Database, request.Collection, request.Filter, request.Update, request.Multi, request.Upsert)
+	return
+}
+
+func (this_ *api) delete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	res, err = service.Delete(request.Database, request.Collection, request.Filter, request.Multi)
+	return
+}
+
+func (this_ *api) indexes(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	res, err = service.Indexes(request.Database, request.Collection)
+	return
+}
+
+func (this_ *api) createIndex(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+	if request.Index == nil {
+		err = errors.New("索引信息不能为空")
+		return
+	}
+
+	res, err = service.CreateIndex(request.Database, request.Collection, request.Index)
+	return
+}
+
+func (this_ *api) dropIndex(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	err = service.DropIndex(request.Database, request.Collection, request.IndexName)
+	return
+}
+
+func (this_ *api) _import(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	var task = &Task{}
+	if !base.RequestJSON(task, c) {
+		return
+	}
+	if task.FilePath == "" {
+		err = errors.New("请上传导入文件")
+		return
+	}
+	task.TaskType = "import"
+	task.TaskId = util.GetUUID()
+	task.service = service
+	task.filePath, err = this_.toolboxService.GetFilesFileInDir(task.FilePath)
+	if err != nil {
+		return
+	}
+
+	task.userId = requestBean.JWT.UserId
+	StartTask(task)
+	addWorkerTask(request.WorkerId, task.TaskId)
+	res = GetTask(task.TaskId, task.userId)
+	return
+}
+
+func (this_ *api) export(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	service, request, err := this_.getServiceAndRequest(requestBean, c)
+	if err != nil {
+		return
+	}
+
+	var task = &Task{}
+	if !base.RequestJSON(task, c) {
+		return
+	}
+	task.TaskType = "export"
+	task.TaskId = util.GetUUID()
+	task.service = service
+	task.FilePath = "mongodb-export/" + task.TaskId + ".json"
+	task.filePath = this_.toolboxService.GetFilesDir() + task.FilePath
+
+	task.userId = requestBean.JWT.UserId
+	StartTask(task)
+	addWorkerTask(request.WorkerId, task.TaskId)
+	res = GetTask(task.TaskId, task.userId)
+	return
+}
+
+func (this_ *api) exportDownload(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	res = base.HttpNotResponse
+	defer func() {
+		if err != nil {
+			_, _ = c.Writer.WriteString(err.Error())
+		}
+	}()
+
+	request := map[string]string{}
+
+	err = c.Bind(&request)
+	if err != nil {
+		return
+	}
+
+	task := GetTask(request["taskId"], requestBean.JWT.UserId)
+	if task == nil || task.TaskType != "export" {
+		err = errors.New("导出任务[" + request["taskId"] + "]不存在")
+		return
+	}
+	if !task.IsEnd {
+		err = errors.New("导出任务[" + request["taskId"] + "]未完成")
+		return
+	}
+
+	fileName := task.Database + "." + task.Collection + ".json"
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=utf-8''%s", url.QueryEscape(fileName)))
+	c.Header("download-file-name", fileName)
+
+	f, err := os.Open(task.filePath)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(c.Writer, f)
+	c.Status(http.StatusOK)
+	return
+}
+
+func (this_ *api) taskStatus(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	var request = &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res = GetTask(request.TaskId, requestBean.JWT.UserId)
+	return
+}
+
+func (this_ *api) taskStop(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	var request = &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	StopTask(request.TaskId, requestBean.JWT.UserId)
+	return
+}
+
+func (this_ *api) taskClean(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	var request = &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	removeWorkerTask(request.WorkerId, request.TaskId, requestBean.JWT.UserId)
+	return
+}
+
+func (this_ *api) taskList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	var request = &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res = getWorkerTasks(request.WorkerId, requestBean.JWT.UserId)
+	return
+}
+
+func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	var request = &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	removeWorkerTasks(request.WorkerId, requestBean.JWT.UserId)
+	return
+}
+
+var (
+	workerTasksCache     = map[string][]string{}
+	workerTasksCacheLock = &sync.Mutex{}
+)
+
+func addWorkerTask(workerId string, taskId string) {
+	workerTasksCacheLock.Lock()
+	defer workerTasksCacheLock.Unlock()
+	taskIds := workerTasksCache[workerId]
+	if util.StringIndexOf(taskIds, taskId) < 0 {
+		taskIds = append(taskIds, taskId)
+		workerTasksCache[workerId] = taskIds
+	}
+	return
+}
+
+func getWorkerTasks(workerId string, userId int64) (taskList []*Task) {
+	workerTasksCacheLock.Lock()
+	defer workerTasksCacheLock.Unlock()
+	taskIds := workerTasksCache[workerId]
+	for _, id := range taskIds {
+		task := GetTask(id, userId)
+		if task != nil {
+			taskList = append(taskList, task)
+		}
+	}
+	return
+}
+
+func removeWorkerTasks(workerId string, userId int64) {
+	workerTasksCacheLock.Lock()
+	defer workerTasksCacheLock.Unlock()
+	taskIds := workerTasksCache[workerId]
+	for _, taskId := range taskIds {
+		CleanTask(taskId, userId)
+	}
+	delete(workerTasksCache, workerId)
+	return
+}
+
+func removeWorkerTask(workerId string, taskId string, userId int64) {
+	workerTasksCacheLock.Lock()
+	defer workerTasksCacheLock.Unlock()
+
+	if CleanTask(taskId, userId) == nil {
+		return
+	}
+
+	taskIds := workerTasksCache[workerId]
+	var newIds []string
+	for _, id := range taskIds {
+		if id != taskId {
+			newIds = append(newIds, id)
+		}
+	}
+	workerTasksCache[workerId] = newIds
+	return
+}
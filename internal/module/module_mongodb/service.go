@@ -0,0 +1,516 @@
+package module_mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	goSSH "golang.org/x/crypto/ssh"
+	"net"
+	"strings"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+type Config struct {
+	// 连接地址 127.0.0.1:27017，多个地址逗号分隔，也可以是完整连接串 mongodb://...
+	Address  string `json:"address"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// 认证数据库，为空使用 admin
+	AuthSource string `json:"authSource"`
+	// 默认数据库，账号没有 listDatabases 权限时使用
+	Database string `json:"database"`
+	// 超时时长 毫秒
+	Timeout int `json:"timeout"`
+}
+
+func (this_ *Config) getUri() string {
+	if strings.HasPrefix(this_.Address, "mongodb://") || strings.HasPrefix(this_.Address, "mongodb+srv://") {
+		return this_.Address
+	}
+	return "mongodb://" + this_.Address
+}
+
+func (this_ *Config) getTimeout() time.Duration {
+	if this_.Timeout > 0 {
+		return time.Millisecond * time.Duration(this_.Timeout)
+	}
+	return time.Second * 60
+}
+
+// sshDialer 通过 SSH 隧道连接 MongoDB
+type sshDialer struct {
+	sshClient *goSSH.Client
+}
+
+func (this_ *sshDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return this_.sshClient.Dial(network, address)
+}
+
+type Service struct {
+	config    *Config
+	client    *mongo.Client
+	sshClient *goSSH.Client
+}
+
+func NewService(config *Config, sshConfig *ssh.Config) (res *Service, err error) {
+	res = &Service{
+		config: config,
+	}
+	clientOptions := options.Client().ApplyURI(config.getUri())
+	clientOptions.SetConnectTimeout(time.Second * 10)
+	clientOptions.SetServerSelectionTimeout(time.Second * 10)
+	if config.Username != "" {
+		authSource := config.AuthSource
+		if authSource == "" {
+			authSource = "admin"
+		}
+		clientOptions.SetAuth(options.Credential{
+			Username:   config.Username,
+			Password:   config.Password,
+			AuthSource: authSource,
+		})
+	}
+	if sshConfig != nil {
+		res.sshClient, err = ssh.NewClient(*sshConfig)
+		if err != nil {
+			return
+		}
+		clientOptions.SetDialer(&sshDialer{sshClient: res.sshClient})
+	}
+	if err = clientOptions.Validate(); err != nil {
+		res.Close()
+		return
+	}
+	res.client, err = mongo.Connect(context.Background(), clientOptions)
+	if err != nil {
+		res.Close()
+		return
+	}
+	return
+}
+
+func (this_ *Service) Close() {
+	if this_.client != nil {
+		_ = this_.client.Disconnect(context.Background())
+	}
+	if this_.sshClient != nil {
+		_ = this_.sshClient.Close()
+	}
+}
+
+func (this_ *Service) newContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), this_.config.getTimeout())
+}
+
+func (this_ *Service) Ping() (err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	err = this_.client.Ping(ctx, nil)
+	return
+}
+
+type DatabaseInfo struct {
+	Name       string `json:"name"`
+	SizeOnDisk int64  `json:"sizeOnDisk"`
+	Empty      bool   `json:"empty"`
+}
+
+// Databases 数据库列表，没有权限时返回配置的默认数据库
+func (this_ *Service) Databases() (list []*DatabaseInfo, err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	result, err := this_.client.ListDatabases(ctx, bson.D{})
+	if err != nil {
+		if this_.config.Database == "" {
+			return
+		}
+		err = nil
+		list = append(list, &DatabaseInfo{Name: this_.config.Database})
+		return
+	}
+	for _, one := range result.Databases {
+		list = append(list, &DatabaseInfo{Name: one.Name, SizeOnDisk: one.SizeOnDisk, Empty: one.Empty})
+	}
+	return
+}
+
+type CollectionInfo struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func (this_ *Service) Collections(database string) (list []*CollectionInfo, err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	cursor, err := this_.client.Database(database).ListCollections(ctx, bson.D{}, options.ListCollections().SetNameOnly(true))
+	if err != nil {
+		return
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+	for cursor.Next(ctx) {
+		list = append(list, &CollectionInfo{
+			Name: cursor.Current.Lookup("name").StringValue(),
+			Type: cursor.Current.Lookup("type").StringValue(),
+		})
+	}
+	err = cursor.Err()
+	return
+}
+
+func (this_ *Service) CreateCollection(database string, collection string) (err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	err = this_.client.Database(database).CreateCollection(ctx, collection)
+	return
+}
+
+func (this_ *Service) DropCollection(database string, collection string) (err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	err = this_.client.Database(database).Collection(collection).Drop(ctx)
+	return
+}
+
+func (this_ *Service) DropDatabase(database string) (err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	err = this_.client.Database(database).Drop(ctx)
+	return
+}
+
+// FindParam 查询条件、投影、排序均为 Extended JSON，如 {"_id": {"$oid": "..."}}
+type FindParam struct {
+	Database   string `json:"database"`
+	Collection string `json:"collection"`
+	Filter     string `json:"filter"`
+	Projection string `json:"projection"`
+	Sort       string `json:"sort"`
+	PageIndex  int64  `json:"pageIndex"`
+	PageSize   int64  `json:"pageSize"`
+}
+
+type FindResult struct {
+	Total     int64             `json:"total"`
+	PageIndex int64             `json:"pageIndex"`
+	PageSize  int64             `json:"pageSize"`
+	Documents []json.RawMessage `json:"documents"`
+}
+
+func (this_ *Service) Find(param *FindParam) (res *FindResult, err error) {
+	filter, err := parseDocument(param.Filter)
+	if err != nil {
+		err = errors.New("filter error:" + err.Error())
+		return
+	}
+	findOptions := options.Find()
+	if strings.TrimSpace(param.Projection) != "" {
+		var projection bson.D
+		if projection, err = parseDocument(param.Projection); err != nil {
+			err = errors.New("projection error:" + err.Error())
+			return
+		}
+		findOptions.SetProjection(projection)
+	}
+	if strings.TrimSpace(param.Sort) != "" {
+		var sort bson.D
+		if sort, err = parseDocument(param.Sort); err != nil {
+			err = errors.New("sort error:" + err.Error())
+			return
+		}
+		findOptions.SetSort(sort)
+	}
+	res = &FindResult{
+		PageIndex: param.PageIndex,
+		PageSize:  param.PageSize,
+	}
+	if res.PageIndex <= 0 {
+		res.PageIndex = 1
+	}
+	if res.PageSize <= 0 {
+		res.PageSize = 20
+	}
+	findOptions.SetSkip((res.PageIndex - 1) * res.PageSize)
+	findOptions.SetLimit(res.PageSize)
+
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	coll := this_.client.Database(param.Database).Collection(param.Collection)
+	res.Total, err = coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return
+	}
+	cursor, err := coll.Find(ctx, filter, findOptions)
+	if err != nil {
+		return
+	}
+	res.Documents, err = readCursor(ctx, cursor, 0)
+	return
+}
+
+// Aggregate 执行聚合管道，limit 大于 0 时最多返回 limit 条
+func (this_ *Service) Aggregate(database string, collection string, pipeline string, limit int) (documents []json.RawMessage, err error) {
+	stages, err := parseArray(pipeline)
+	if err != nil {
+		err = errors.New("pipeline error:" + err.Error())
+		return
+	}
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	cursor, err := this_.client.Database(database).Collection(collection).Aggregate(ctx, stages)
+	if err != nil {
+		return
+	}
+	documents, err = readCursor(ctx, cursor, limit)
+	return
+}
+
+// Insert 插入文档，documents 可以是单个文档或文档数组
+func (this_ *Service) Insert(database string, collection string, documents string) (insertedIds []json.RawMessage, err error) {
+	list, err := parseDocuments(documents)
+	if err != nil {
+		err = errors.New("documents error:" + err.Error())
+		return
+	}
+	if len(list) == 0 {
+		err = errors.New("documents is empty")
+		return
+	}
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	result, err := this_.client.Database(database).Collection(collection).InsertMany(ctx, list)
+	if result != nil {
+		for _, id := range result.InsertedIDs {
+			bs, e := marshalValue(id)
+			if e == nil {
+				insertedIds = append(insertedIds, bs)
+			}
+		}
+	}
+	return
+}
+
+type UpdateResult struct {
+	MatchedCount  int64           `json:"matchedCount"`
+	ModifiedCount int64           `json:"modifiedCount"`
+	UpsertedCount int64           `json:"upsertedCount"`
+	UpsertedId    json.RawMessage `json:"upsertedId,omitempty"`
+}
+
+// Update 修改文档，update 为更新操作符文档如 {"$set": {...}}，或更新管道数组
+func (this_ *Service) Update(database string, collection string, filter string, update string, multi bool, upsert bool) (res *UpdateResult, err error) {
+	filterDoc, err := parseDocument(filter)
+	if err != nil {
+		err = errors.New("filter error:" + err.Error())
+		return
+	}
+	var updateValue interface{}
+	if strings.HasPrefix(strings.TrimSpace(update), "[") {
+		updateValue, err = parseArray(update)
+	} else {
+		updateValue, err = parseDocument(update)
+	}
+	if err != nil {
+		err = errors.New("update error:" + err.Error())
+		return
+	}
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	coll := this_.client.Database(database).Collection(collection)
+	updateOptions := options.Update().SetUpsert(upsert)
+	var result *mongo.UpdateResult
+	if multi {
+		result, err = coll.UpdateMany(ctx, filterDoc, updateValue, updateOptions)
+	} else {
+		result, err = coll.UpdateOne(ctx, filterDoc, updateValue, updateOptions)
+	}
+	if err != nil {
+		return
+	}
+	res = &UpdateResult{
+		MatchedCount:  result.MatchedCount,
+		ModifiedCount: result.ModifiedCount,
+		UpsertedCount: result.UpsertedCount,
+	}
+	if result.UpsertedID != nil {
+		res.UpsertedId, _ = marshalValue(result.UpsertedID)
+	}
+	return
+}
+
+// Delete 删除文档，multi 为 false 时只删除第一条匹配的
+func (this_ *Service) Delete(database string, collection string, filter string, multi bool) (deletedCount int64, err error) {
+	filterDoc, err := parseDocument(filter)
+	if err != nil {
+		err = errors.New("filter error:" + err.Error())
+		return
+	}
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	coll := this_.client.Database(database).Collection(collection)
+	var result *mongo.DeleteResult
+	if multi {
+		result, err = coll.DeleteMany(ctx, filterDoc)
+	} else {
+		result, err = coll.DeleteOne(ctx, filterDoc)
+	}
+	if err != nil {
+		return
+	}
+	deletedCount = result.DeletedCount
+	return
+}
+
+func (this_ *Service) Indexes(database string, collection string) (indexes []json.RawMessage, err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	cursor, err := this_.client.Database(database).Collection(collection).Indexes().List(ctx)
+	if err != nil {
+		return
+	}
+	indexes, err = readCursor(ctx, cursor, 0)
+	return
+}
+
+type IndexParam struct {
+	// 索引字段 Extended JSON，如 {"name": 1, "age": -1}
+	Keys   string `json:"keys"`
+	Name   string `json:"name"`
+	Unique bool   `json:"unique"`
+	Sparse bool   `json:"sparse"`
+	// TTL 索引过期秒数，小于等于 0 不设置
+	ExpireAfterSeconds int32 `json:"expireAfterSeconds"`
+}
+
+func (this_ *Service) CreateIndex(database string, collection string, param *IndexParam) (name string, err error) {
+	keys, err := parseDocument(param.Keys)
+	if err != nil {
+		err = errors.New("keys error:" + err.Error())
+		return
+	}
+	if len(keys) == 0 {
+		err = errors.New("index keys is empty")
+		return
+	}
+	indexOptions := options.Index()
+	if param.Name != "" {
+		indexOptions.SetName(param.Name)
+	}
+	if param.Unique {
+		indexOptions.SetUnique(true)
+	}
+	if param.Sparse {
+		indexOptions.SetSparse(true)
+	}
+	if param.ExpireAfterSeconds > 0 {
+		indexOptions.SetExpireAfterSeconds(param.ExpireAfterSeconds)
+	}
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	name, err = this_.client.Database(database).Collection(collection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: indexOptions,
+	})
+	return
+}
+
+func (this_ *Service) DropIndex(database string, collection string, name string) (err error) {
+	ctx, cancel := this_.newContext()
+	defer cancel()
+	_, err = this_.client.Database(database).Collection(collection).Indexes().DropOne(ctx, name)
+	return
+}
+
+func readCursor(ctx context.Context, cursor *mongo.Cursor, limit int) (documents []json.RawMessage, err error) {
+	defer func() { _ = cursor.Close(ctx) }()
+	documents = []json.RawMessage{}
+	for cursor.Next(ctx) {
+		var bs []byte
+		bs, err = bson.MarshalExtJSON(cursor.Current, false, false)
+		if err != nil {
+			return
+		}
+		documents = append(documents, bs)
+		if limit > 0 && len(documents) >= limit {
+			break
+		}
+	}
+	err = cursor.Err()
+	return
+}
+
+// parseDocument 解析 Extended JSON 文档，为空时返回空文档
+func parseDocument(text string) (doc bson.D, err error) {
+	doc = bson.D{}
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	err = bson.UnmarshalExtJSON([]byte(text), false, &doc)
+	return
+}
+
+// parseValue 顶层只能解析文档，数组等值包装后解析
+func parseValue(text string) (value bson.RawValue, err error) {
+	var raw bson.Raw
+	err = bson.UnmarshalExtJSON([]byte(`{"v":`+text+`}`), false, &raw)
+	if err != nil {
+		return
+	}
+	value, err = raw.LookupErr("v")
+	return
+}
+
+func parseArray(text string) (list bson.A, err error) {
+	list = bson.A{}
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	value, err := parseValue(text)
+	if err != nil {
+		return
+	}
+	if value.Type != bson.TypeArray {
+		err = errors.New("must be an array")
+		return
+	}
+	err = value.Unmarshal(&list)
+	return
+}
+
+func parseDocuments(text string) (list []interface{}, err error) {
+	if !strings.HasPrefix(strings.TrimSpace(text), "[") {
+		var doc bson.D
+		doc, err = parseDocument(text)
+		if err != nil {
+			return
+		}
+		if len(doc) > 0 {
+			list = append(list, doc)
+		}
+		return
+	}
+	array, err := parseArray(text)
+	if err != nil {
+		return
+	}
+	for _, one := range array {
+		list = append(list, one)
+	}
+	return
+}
+
+func marshalValue(value interface{}) (bs json.RawMessage, err error) {
+	bs, err = bson.MarshalExtJSON(bson.D{{Key: "v", Value: value}}, false, false)
+	if err != nil {
+		return
+	}
+	var data map[string]json.RawMessage
+	if err = json.Unmarshal(bs, &data); err != nil {
+		return
+	}
+	bs = data["v"]
+	return
+}
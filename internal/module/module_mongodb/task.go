@@ -0,0 +1,420 @@
+package module_mongodb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	taskCache     = map[string]*Task{}
+	taskCacheLock = &sync.Mutex{}
+)
+
+// Task 导入、导出任务，文件格式为每行一个 Extended JSON 文档，导入同时支持 JSON 数组
+type Task struct {
+	TaskId     string `json:"taskId,omitempty"`
+	TaskType   string `json:"taskType,omitempty"`
+	Database   string `json:"database,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	// 导出条件
+	Filter     string `json:"filter,omitempty"`
+	Projection string `json:"projection,omitempty"`
+	Sort       string `json:"sort,omitempty"`
+	// 导入文件为上传文件的相对路径，导出文件为生成文件的相对路径
+	FilePath    string `json:"filePath,omitempty"`
+	BatchNumber int    `json:"batchNumber,omitempty"`
+	// 导入时 _id 已存在则覆盖
+	Upsert        bool `json:"upsert"`
+	ErrorContinue bool `json:"errorContinue"`
+
+	Total        int64  `json:"total"`
+	ReadCount    int64  `json:"readCount"`
+	SuccessCount int64  `json:"successCount"`
+	ErrorCount   int64  `json:"errorCount"`
+	Percent      string `json:"percent,omitempty"`
+
+	IsEnd     bool      `json:"isEnd"`
+	IsStop    bool      `json:"isStop"`
+	StartTime time.Time `json:"startTime,omitempty"`
+	NowTime   time.Time `json:"nowTime,omitempty"`
+	EndTime   time.Time `json:"endTime,omitempty"`
+	UseTime   int64     `json:"useTime"`
+	Error     string    `json:"error,omitempty"`
+
+	// 创建任务的用户，只能查看、停止自己的任务
+	userId   int64
+	service  *Service
+	filePath string
+	lock     *sync.Mutex
+}
+
+func StartTask(task *Task) {
+	taskCacheLock.Lock()
+	defer taskCacheLock.Unlock()
+
+	if task.TaskId == "" {
+		task.TaskId = util.GetUUID()
+	}
+	task.lock = &sync.Mutex{}
+	taskCache[task.TaskId] = task
+	go task.Start()
+}
+
+// GetTask 查询用户的任务，返回任务当前状态的副本
+func GetTask(taskId string, userId int64) *Task {
+	taskCacheLock.Lock()
+	defer taskCacheLock.Unlock()
+
+	task := taskCache[taskId]
+	if task == nil || task.userId != userId {
+		return nil
+	}
+	return task.info()
+}
+
+func StopTask(taskId string, userId int64) *Task {
+	taskCacheLock.Lock()
+	defer taskCacheLock.Unlock()
+
+	task := taskCache[taskId]
+	if task == nil || task.userId != userId {
+		return nil
+	}
+	task.Stop()
+	return task.info()
+}
+
+// CleanTask 停止并移除任务，导出文件一并删除
+func CleanTask(taskId string, userId int64) *Task {
+	taskCacheLock.Lock()
+	defer taskCacheLock.Unlock()
+
+	task := taskCache[taskId]
+	if task == nil || task.userId != userId {
+		return nil
+	}
+	task.Stop()
+	if task.TaskType == "export" && task.filePath != "" {
+		_ = os.Remove(task.filePath)
+	}
+	delete(taskCache, taskId)
+	return task.info()
+}
+
+// info 任务当前状态的副本
+func (this_ *Task) info() *Task {
+	this_.Statistics()
+
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	res := *this_
+	res.service = nil
+	return &res
+}
+
+func (this_ *Task) Statistics() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.Total > 0 {
+		this_.Percent = fmt.Sprintf("%.2f", float64(this_.ReadCount*100)/float64(this_.Total))
+	}
+	if this_.StartTime.IsZero() {
+		return
+	}
+	if this_.IsEnd {
+		this_.NowTime = this_.EndTime
+	} else {
+		this_.NowTime = time.Now()
+	}
+	this_.UseTime = util.GetMilliByTime(this_.NowTime) - util.GetMilliByTime(this_.StartTime)
+}
+
+func (this_ *Task) Stop() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.IsStop = true
+}
+
+func (this_ *Task) isStopped() bool {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	return this_.IsStop
+}
+
+func (this_ *Task) Start() {
+	this_.lock.Lock()
+	this_.StartTime = time.Now()
+	this_.lock.Unlock()
+
+	var err error
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New(fmt.Sprint(e))
+		}
+		if err != nil {
+			util.Logger.Error("MongoDB任务执行异常", zap.Any("taskId", this_.TaskId), zap.Any("taskType", this_.TaskType), zap.Error(err))
+		}
+		this_.lock.Lock()
+		if err != nil {
+			this_.Error = err.Error()
+		}
+		this_.EndTime = time.Now()
+		this_.IsEnd = true
+		this_.lock.Unlock()
+
+		this_.Statistics()
+	}()
+
+	if this_.Database == "" || this_.Collection == "" {
+		err = errors.New("必须配置database和collection")
+		return
+	}
+	if this_.BatchNumber <= 0 {
+		this_.BatchNumber = 1000
+	}
+	switch this_.TaskType {
+	case "import":
+		err = this_.doImport()
+	case "export":
+		err = this_.doExport()
+	default:
+		err = errors.New("task type [" + this_.TaskType + "] not support")
+	}
+}
+
+func (this_ *Task) addCount(read int64, success int64, errorCount int64) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.ReadCount += read
+	this_.SuccessCount += success
+	this_.ErrorCount += errorCount
+}
+
+func (this_ *Task) doExport() (err error) {
+	filter, err := parseDocument(this_.Filter)
+	if err != nil {
+		err = errors.New("filter error:" + err.Error())
+		return
+	}
+	findOptions := options.Find().SetBatchSize(int32(this_.BatchNumber))
+	if this_.Projection != "" {
+		var projection bson.D
+		if projection, err = parseDocument(this_.Projection); err != nil {
+			err = errors.New("projection error:" + err.Error())
+			return
+		}
+		findOptions.SetProjection(projection)
+	}
+	if this_.Sort != "" {
+		var sort bson.D
+		if sort, err = parseDocument(this_.Sort); err != nil {
+			err = errors.New("sort error:" + err.Error())
+			return
+		}
+		findOptions.SetSort(sort)
+	}
+
+	ctx := context.Background()
+	coll := this_.service.client.Database(this_.Database).Collection(this_.Collection)
+	total, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return
+	}
+	this_.lock.Lock()
+	this_.Total = total
+	this_.lock.Unlock()
+
+	if err = os.MkdirAll(filepath.Dir(this_.filePath), 0777); err != nil {
+		return
+	}
+	f, err := os.Create(this_.filePath)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	writer := bufio.NewWriter(f)
+	defer func() { _ = writer.Flush() }()
+
+	cursor, err := coll.Find(ctx, filter, findOptions)
+	if err != nil {
+		return
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+	for cursor.Next(ctx) {
+		if this_.isStopped() {
+			return
+		}
+		var bs []byte
+		bs, err = bson.MarshalExtJSON(cursor.Current, false, false)
+		if err != nil {
+			this_.addCount(1, 0, 1)
+			if !this_.ErrorContinue {
+				return
+			}
+			err = nil
+			continue
+		}
+		if _, err = writer.Write(bs); err != nil {
+			return
+		}
+		if err = writer.WriteByte('\n'); err != nil {
+			return
+		}
+		this_.addCount(1, 1, 0)
+	}
+	err = cursor.Err()
+	return
+}
+
+func (this_ *Task) doImport() (err error) {
+	f, err := os.Open(this_.filePath)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	reader := bufio.NewReader(f)
+	isArray, err := isJSONArray(reader)
+	if err != nil {
+		return
+	}
+	decoder := json.NewDecoder(reader)
+	if isArray {
+		// 读取数组开始符号
+		if _, err = decoder.Token(); err != nil {
+			return
+		}
+	}
+
+	var batch []interface{}
+	for {
+		if this_.isStopped() {
+			return
+		}
+		if isArray && !decoder.More() {
+			break
+		}
+		var raw json.RawMessage
+		if err = decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				err = nil
+				break
+			}
+			return
+		}
+		var doc bson.D
+		if err = bson.UnmarshalExtJSON(raw, false, &doc); err != nil {
+			this_.addCount(1, 0, 1)
+			if !this_.ErrorContinue {
+				return
+			}
+			err = nil
+			continue
+		}
+		this_.addCount(1, 0, 0)
+		batch = append(batch, doc)
+		if len(batch) >= this_.BatchNumber {
+			if err = this_.writeBatch(batch); err != nil {
+				return
+			}
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		err = this_.writeBatch(batch)
+	}
+	return
+}
+
+func (this_ *Task) writeBatch(batch []interface{}) (err error) {
+	ctx := context.Background()
+	coll := this_.service.client.Database(this_.Database).Collection(this_.Collection)
+
+	var models []mongo.WriteModel
+	for _, one := range batch {
+		doc := one.(bson.D)
+		id, hasId := findId(doc)
+		if this_.Upsert && hasId {
+			models = append(models, mongo.NewReplaceOneModel().SetFilter(bson.D{{Key: "_id", Value: id}}).SetReplacement(doc).SetUpsert(true))
+		} else {
+			models = append(models, mongo.NewInsertOneModel().SetDocument(doc))
+		}
+	}
+	_, err = coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(!this_.ErrorContinue))
+	if err == nil {
+		this_.addCount(0, int64(len(batch)), 0)
+		return
+	}
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) {
+		this_.addCount(0, 0, int64(len(batch)))
+		return
+	}
+	errorCount := int64(len(bulkErr.WriteErrors))
+	if this_.ErrorContinue {
+		this_.addCount(0, int64(len(batch))-errorCount, errorCount)
+		err = nil
+		return
+	}
+	// 有序写入遇到错误时停止，之前的已写入成功
+	success := int64(0)
+	if errorCount > 0 {
+		success = int64(bulkErr.WriteErrors[0].Index)
+	}
+	this_.addCount(0, success, errorCount)
+	return
+}
+
+func findId(doc bson.D) (id interface{}, find bool) {
+	for _, e := range doc {
+		if e.Key == "_id" {
+			return e.Value, true
+		}
+	}
+	return
+}
+
+// isJSONArray 判断文件第一个非空字符是否为 [
+func isJSONArray(reader *bufio.Reader) (res bool, err error) {
+	for {
+		var bs []byte
+		bs, err = reader.Peek(1)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		switch bs[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = reader.ReadByte()
+			continue
+		case 0xEF:
+			// UTF-8 BOM
+			if bom, _ := reader.Peek(3); len(bom) == 3 && bom[1] == 0xBB && bom[2] == 0xBF {
+				_, _ = reader.Discard(3)
+				continue
+			}
+		}
+		res = bs[0] == '['
+		return
+	}
+}
@@ -8,10 +8,12 @@ import (
 	"teamide/pkg/terminal"
 )
 
-func NewTerminalService(nodeId string, nodeService *NodeService) (res *terminalService) {
+// NewTerminalService 创建节点终端服务，localConfig 为节点上本地终端的配置
+func NewTerminalService(nodeId string, nodeService *NodeService, localConfig *terminal.LocalConfig) (res *terminalService) {
 	res = &terminalService{
 		nodeId:      nodeId,
 		nodeService: nodeService,
+		localConfig: localConfig,
 		bytesChan:   make(chan []byte),
 	}
 	return
@@ -22,6 +24,7 @@ type terminalService struct {
 	key         string
 	nodeLine    []string
 	nodeService *NodeService
+	localConfig *terminal.LocalConfig
 	bytesChan   chan []byte
 }
 
@@ -93,7 +96,7 @@ func (this_ *terminalService) Start(size *terminal.Size) (err error) {
 		return
 	}
 
-	this_.key, err = server.TerminalStart(this_.nodeLine, size,
+	this_.key, err = server.TerminalStart(this_.nodeLine, size, this_.localConfig,
 		func(buf []byte) (err error) {
 			this_.bytesChan <- buf
 			return
@@ -1 +1,89 @@
 package module_preferences
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"teamide/internal/context"
+	"teamide/pkg/base"
+)
+
+type Api struct {
+	*context.ServerContext
+	PreferencesService *PreferencesService
+}
+
+func NewApi(PreferencesService *PreferencesService) *Api {
+	return &Api{
+		ServerContext:      PreferencesService.ServerContext,
+		PreferencesService: PreferencesService,
+	}
+}
+
+var (
+
+	// Power 偏好 基本 权限
+	Power     = base.AppendPower(&base.PowerAction{Action: "preferences", Text: "偏好", ShouldLogin: true, StandAlone: true})
+	getPower  = base.AppendPower(&base.PowerAction{Action: "get", Text: "偏好查询", Parent: Power, ShouldLogin: true, StandAlone: true})
+	savePower = base.AppendPower(&base.PowerAction{Action: "save", Text: "偏好保存", Parent: Power, ShouldLogin: true, StandAlone: true})
+)
+
+func (this_ *Api) GetApis() (apis []*base.ApiWorker) {
+	apis = append(apis, &base.ApiWorker{Power: getPower, Do: this_.get})
+	apis = append(apis, &base.ApiWorker{Power: savePower, Do: this_.save})
+
+	return
+}
+
+type GetRequest struct {
+	Name string `json:"name,omitempty"`
+}
+
+type GetResponse struct {
+	Preferences *PreferencesModel `json:"preferences,omitempty"`
+}
+
+func (this_ *Api) get(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &GetRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &GetResponse{}
+
+	if request.Name == "" {
+		err = errors.New("偏好名称不能为空")
+		return
+	}
+	response.Preferences, err = this_.PreferencesService.Get(requestBean.JWT.UserId, request.Name)
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
+
+type SaveRequest struct {
+	*PreferencesModel
+}
+
+func (this_ *Api) save(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &SaveRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	preferences := request.PreferencesModel
+	if preferences == nil || preferences.Name == "" {
+		err = errors.New("偏好名称不能为空")
+		return
+	}
+	preferences.UserId = requestBean.JWT.UserId
+	err = this_.PreferencesService.Save(preferences)
+	if err != nil {
+		return
+	}
+
+	return
+}
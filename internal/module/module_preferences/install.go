@@ -1 +1,42 @@
 package module_preferences
+
+import (
+	"teamide/internal/install"
+)
+
+func GetInstallStages() []*install.StageModel {
+
+	return []*install.StageModel{
+
+		// 创建偏好表
+		{
+			Version: "1.0",
+			Module:  ModulePreferences,
+			Stage:   `创建表[` + TablePreferences + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TablePreferences + ` (
+	userId bigint(20) NOT NULL COMMENT '用户ID',
+	name varchar(100) NOT NULL COMMENT '名称',
+	comment varchar(200) DEFAULT NULL COMMENT '说明',
+	option text DEFAULT NULL COMMENT '偏好内容',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	updateTime datetime DEFAULT NULL COMMENT '修改时间',
+	PRIMARY KEY (userId, name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TablePreferencesComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TablePreferences + ` (
+	userId bigint(20) NOT NULL,
+	name varchar(100) NOT NULL,
+	comment varchar(200) DEFAULT NULL,
+	option text DEFAULT NULL,
+	createTime datetime NOT NULL,
+	updateTime datetime DEFAULT NULL,
+	PRIMARY KEY (userId, name)
+);
+`},
+			},
+		},
+	}
+}
@@ -6,10 +6,11 @@ const (
 	// ModulePreferences 偏好模块
 	ModulePreferences = "preferences"
 	// TablePreferences 偏好表
-	TablePreferences = "TM_PREFERENCES"
+	TablePreferences        = "TM_PREFERENCES"
+	TablePreferencesComment = "偏好"
 )
 
-// PreferencesModel 偏好模型
+// PreferencesModel 偏好模型，每个用户每个偏好名称一条，Option 为偏好内容 JSON
 type PreferencesModel struct {
 	UserId     int64     `json:"userId,omitempty"`
 	Name       string    `json:"name,omitempty"`
@@ -1 +1,75 @@
 package module_preferences
+
+import (
+	"encoding/json"
+	"teamide/internal/context"
+	"time"
+)
+
+// NewPreferencesService 根据库配置创建PreferencesService
+func NewPreferencesService(ServerContext *context.ServerContext) (res *PreferencesService) {
+
+	res = &PreferencesService{
+		ServerContext: ServerContext,
+	}
+	return
+}
+
+// PreferencesService 用户偏好服务
+type PreferencesService struct {
+	*context.ServerContext
+}
+
+// Get 查询用户偏好，不存在时返回 nil
+func (this_ *PreferencesService) Get(userId int64, name string) (res *PreferencesModel, err error) {
+	res = &PreferencesModel{}
+
+	sql := `SELECT * FROM ` + TablePreferences + ` WHERE userId=? AND name=? `
+	find, err := this_.DatabaseWorker.QueryOne(sql, []interface{}{userId, name}, res)
+	if err != nil {
+		return
+	}
+
+	if !find {
+		res = nil
+	}
+	return
+}
+
+// GetOption 查询用户偏好并将偏好内容解析到 option，不存在时 option 不变
+func (this_ *PreferencesService) GetOption(userId int64, name string, option interface{}) (err error) {
+	preferences, err := this_.Get(userId, name)
+	if err != nil {
+		return
+	}
+	if preferences == nil || preferences.Option == "" {
+		return
+	}
+	err = json.Unmarshal([]byte(preferences.Option), option)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// Save 保存用户偏好，不存在时新增
+func (this_ *PreferencesService) Save(preferences *PreferencesModel) (err error) {
+	find, err := this_.Get(preferences.UserId, preferences.Name)
+	if err != nil {
+		return
+	}
+
+	if find == nil {
+		preferences.CreateTime = time.Now()
+		sql := `INSERT INTO ` + TablePreferences + `(userId, name, comment, option, createTime) VALUES (?, ?, ?, ?, ?) `
+		_, err = this_.DatabaseWorker.Exec(sql, []interface{}{preferences.UserId, preferences.Name, preferences.Comment, preferences.Option, preferences.CreateTime})
+	} else {
+		preferences.UpdateTime = time.Now()
+		sql := `UPDATE ` + TablePreferences + ` SET comment=?,option=?,updateTime=? WHERE userId=? AND name=? `
+		_, err = this_.DatabaseWorker.Exec(sql, []interface{}{preferences.Comment, preferences.Option, preferences.UpdateTime, preferences.UserId, preferences.Name})
+	}
+	if err != nil {
+		return
+	}
+	return
+}
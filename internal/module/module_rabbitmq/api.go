@@ -0,0 +1,257 @@
+package module_rabbitmq
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+)
+
+type api struct {
+	toolboxService *module_toolbox.ToolboxService
+}
+
+func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
+	return &api{
+		toolboxService: toolboxService,
+	}
+}
+
+var (
+	// RabbitMQ 权限
+	Power          = base.AppendPower(&base.PowerAction{Action: "rabbitmq", Text: "RabbitMQ", ShouldLogin: true, StandAlone: true})
+	overviewPower  = base.AppendPower(&base.PowerAction{Action: "overview", Text: "RabbitMQ概览", ShouldLogin: true, StandAlone: true, Parent: Power})
+	exchangesPower = base.AppendPower(&base.PowerAction{Action: "exchanges", Text: "RabbitMQ交换机查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	queuesPower    = base.AppendPower(&base.PowerAction{Action: "queues", Text: "RabbitMQ队列查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	bindingsPower  = base.AppendPower(&base.PowerAction{Action: "bindings", Text: "RabbitMQ绑定查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	queueInfoPower = base.AppendPower(&base.PowerAction{Action: "queueInfo", Text: "RabbitMQ队列深度", ShouldLogin: true, StandAlone: true, Parent: Power})
+	publishPower   = base.AppendPower(&base.PowerAction{Action: "publish", Text: "RabbitMQ发送消息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	getPower       = base.AppendPower(&base.PowerAction{Action: "get", Text: "RabbitMQ获取消息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	purgePower     = base.AppendPower(&base.PowerAction{Action: "purge", Text: "RabbitMQ清空队列", ShouldLogin: true, StandAlone: true, Parent: Power})
+	closePower     = base.AppendPower(&base.PowerAction{Action: "close", Text: "RabbitMQ关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+	apis = append(apis, &base.ApiWorker{Power: overviewPower, Do: this_.overview})
+	apis = append(apis, &base.ApiWorker{Power: exchangesPower, Do: this_.exchanges})
+	apis = append(apis, &base.ApiWorker{Power: queuesPower, Do: this_.queues})
+	apis = append(apis, &base.ApiWorker{Power: bindingsPower, Do: this_.bindings})
+	apis = append(apis, &base.ApiWorker{Power: queueInfoPower, Do: this_.queueInfo})
+	apis = append(apis, &base.ApiWorker{Power: publishPower, Do: this_.publish})
+	apis = append(apis, &base.ApiWorker{Power: getPower, Do: this_.get})
+	apis = append(apis, &base.ApiWorker{Power: purgePower, Do: this_.purge})
+	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
+
+	return
+}
+
+func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (config *Config, sshConfig *ssh.Config, err error) {
+	config = &Config{}
+	sshConfig, err = this_.toolboxService.BindConfig(requestBean, c, config)
+	if err != nil {
+		return
+	}
+	config.Password = this_.toolboxService.DecryptOptionAttr(config.Password)
+	return
+}
+
+func getService(rabbitmqConfig *Config, sshConfig *ssh.Config) (res *Service, err error) {
+	key := "rabbitmq-" + rabbitmqConfig.Address + "-" + rabbitmqConfig.getVhost()
+	if rabbitmqConfig.Username != "" {
+		key += "-" + base.GetMd5String(key+rabbitmqConfig.Username)
+	}
+	if rabbitmqConfig.Password != "" {
+		key += "-" + base.GetMd5String(key+rabbitmqConfig.Password)
+	}
+	if rabbitmqConfig.ManagementUrl != "" {
+		key += "-" + rabbitmqConfig.ManagementUrl
+	}
+	if sshConfig != nil {
+		key += "-ssh-" + sshConfig.Address
+		key += "-ssh-" + sshConfig.Username
+	}
+	var serviceInfo *base.ServiceInfo
+	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
+		var s *Service
+		s, err = NewService(rabbitmqConfig, sshConfig)
+		if err != nil {
+			util.Logger.Error("getRabbitMQService error", zap.Any("key", key), zap.Error(err))
+			return
+		}
+		err = s.Ping()
+		if err != nil {
+			util.Logger.Error("getRabbitMQService error", zap.Any("key", key), zap.Error(err))
+			s.Close()
+			return
+		}
+		res = &base.ServiceInfo{
+			WaitTime:    10 * 60 * 1000,
+			LastUseTime: util.GetNowMilli(),
+			Service:     s,
+			Stop:        s.Close,
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	res = serviceInfo.Service.(*Service)
+	serviceInfo.SetLastUseTime()
+	return
+}
+
+type BaseRequest struct {
+	Queue   string        `json:"queue"`
+	Publish *PublishParam `json:"publish"`
+	Get     *GetParam     `json:"get"`
+}
+
+func (this_ *api) overview(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	res, err = service.Overview()
+	return
+}
+
+func (this_ *api) exchanges(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	res, err = service.Exchanges()
+	return
+}
+
+func (this_ *api) queues(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	res, err = service.Queues()
+	return
+}
+
+func (this_ *api) bindings(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = service.Bindings(request.Queue)
+	return
+}
+
+func (this_ *api) queueInfo(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = service.QueueInfo(request.Queue)
+	return
+}
+
+func (this_ *api) publish(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Publish == nil {
+		request.Publish = &PublishParam{}
+	}
+
+	err = service.Publish(request.Publish)
+	return
+}
+
+func (this_ *api) get(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.Get == nil {
+		request.Get = &GetParam{Queue: request.Queue}
+	}
+
+	res, err = service.Get(request.Get)
+	return
+}
+
+func (this_ *api) purge(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res, err = service.Purge(request.Queue)
+	return
+}
+
+func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	return
+}
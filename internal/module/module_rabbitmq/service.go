@@ -0,0 +1,492 @@
+package module_rabbitmq
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	amqp "github.com/rabbitmq/amqp091-go"
+	goSSH "golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"teamide/pkg/ssh"
+	"time"
+	"unicode/utf8"
+)
+
+type Config struct {
+	// 连接地址 127.0.0.1:5672，也可以是完整连接串 amqp://...
+	Address  string `json:"address"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Vhost    string `json:"vhost"`
+	// 管理插件地址 http://127.0.0.1:15672，交换机、队列、绑定列表需要
+	ManagementUrl string `json:"managementUrl"`
+}
+
+func (this_ *Config) getVhost() string {
+	if this_.Vhost == "" {
+		return "/"
+	}
+	return this_.Vhost
+}
+
+func (this_ *Config) getUrl() (res string, err error) {
+	if strings.HasPrefix(this_.Address, "amqp://") || strings.HasPrefix(this_.Address, "amqps://") {
+		res = this_.Address
+		return
+	}
+	host, portStr, err := net.SplitHostPort(this_.Address)
+	if err != nil {
+		host = this_.Address
+		portStr = "5672"
+		err = nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		err = errors.New("address [" + this_.Address + "] port error")
+		return
+	}
+	uri := amqp.URI{
+		Scheme:   "amqp",
+		Host:     host,
+		Port:     port,
+		Username: this_.Username,
+		Password: this_.Password,
+		Vhost:    this_.getVhost(),
+	}
+	if uri.Username == "" {
+		uri.Username = "guest"
+		uri.Password = "guest"
+	}
+	res = uri.String()
+	return
+}
+
+type Service struct {
+	config     *Config
+	sshClient  *goSSH.Client
+	httpClient *http.Client
+	conn       *amqp.Connection
+	connLock   sync.Mutex
+}
+
+func NewService(config *Config, sshConfig *ssh.Config) (res *Service, err error) {
+	res = &Service{
+		config: config,
+	}
+	dialer := &net.Dialer{Timeout: time.Second * 10}
+	dial := dialer.DialContext
+	if sshConfig != nil {
+		res.sshClient, err = ssh.NewClient(*sshConfig)
+		if err != nil {
+			return
+		}
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return res.sshClient.Dial(network, addr)
+		}
+	}
+	res.httpClient = &http.Client{
+		Timeout: time.Second * 30,
+		Transport: &http.Transport{
+			DialContext: dial,
+		},
+	}
+	return
+}
+
+func (this_ *Service) Close() {
+	this_.connLock.Lock()
+	defer this_.connLock.Unlock()
+
+	if this_.conn != nil {
+		_ = this_.conn.Close()
+		this_.conn = nil
+	}
+	if this_.httpClient != nil {
+		this_.httpClient.CloseIdleConnections()
+	}
+	if this_.sshClient != nil {
+		_ = this_.sshClient.Close()
+	}
+}
+
+// getConn 连接断开后重新连接
+func (this_ *Service) getConn() (conn *amqp.Connection, err error) {
+	this_.connLock.Lock()
+	defer this_.connLock.Unlock()
+
+	if this_.conn != nil && !this_.conn.IsClosed() {
+		conn = this_.conn
+		return
+	}
+	address, err := this_.config.getUrl()
+	if err != nil {
+		return
+	}
+	amqpConfig := amqp.Config{
+		Heartbeat: time.Second * 10,
+		Locale:    "en_US",
+		Dial:      amqp.DefaultDial(time.Second * 10),
+	}
+	if this_.sshClient != nil {
+		amqpConfig.Dial = func(network, addr string) (net.Conn, error) {
+			return this_.sshClient.Dial(network, addr)
+		}
+	}
+	this_.conn, err = amqp.DialConfig(address, amqpConfig)
+	if err != nil {
+		return
+	}
+	conn = this_.conn
+	return
+}
+
+// channel 每个操作使用独立通道，通道异常关闭不影响其它操作
+func (this_ *Service) channel(do func(ch *amqp.Channel) error) (err error) {
+	conn, err := this_.getConn()
+	if err != nil {
+		return
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return
+	}
+	defer func() { _ = ch.Close() }()
+	err = do(ch)
+	return
+}
+
+func (this_ *Service) Ping() (err error) {
+	_, err = this_.getConn()
+	return
+}
+
+// management 调用管理插件接口
+func (this_ *Service) management(path string, res interface{}) (err error) {
+	if this_.config.ManagementUrl == "" {
+		err = errors.New("未配置管理插件地址，无法查询列表")
+		return
+	}
+	req, err := http.NewRequest("GET", strings.TrimSuffix(this_.config.ManagementUrl, "/")+path, nil)
+	if err != nil {
+		return
+	}
+	username, password := this_.config.Username, this_.config.Password
+	if username == "" {
+		username, password = "guest", "guest"
+	}
+	req.SetBasicAuth(username, password)
+	resp, err := this_.httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	bs, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = errors.New("management api [" + path + "] status " + resp.Status + ":" + string(bs))
+		return
+	}
+	err = json.Unmarshal(bs, res)
+	return
+}
+
+func (this_ *Service) vhostPath() string {
+	return url.PathEscape(this_.config.getVhost())
+}
+
+func (this_ *Service) Overview() (res map[string]interface{}, err error) {
+	res = map[string]interface{}{}
+	err = this_.management("/api/overview", &res)
+	return
+}
+
+func (this_ *Service) Exchanges() (res []map[string]interface{}, err error) {
+	err = this_.management("/api/exchanges/"+this_.vhostPath(), &res)
+	return
+}
+
+func (this_ *Service) Queues() (res []map[string]interface{}, err error) {
+	err = this_.management("/api/queues/"+this_.vhostPath(), &res)
+	return
+}
+
+// Bindings 队列名称不为空时只查询该队列的绑定
+func (this_ *Service) Bindings(queue string) (res []map[string]interface{}, err error) {
+	path := "/api/bindings/" + this_.vhostPath()
+	if queue != "" {
+		path = "/api/queues/" + this_.vhostPath() + "/" + url.PathEscape(queue) + "/bindings"
+	}
+	err = this_.management(path, &res)
+	return
+}
+
+type QueueInfo struct {
+	Name      string `json:"name"`
+	Messages  int    `json:"messages"`
+	Consumers int    `json:"consumers"`
+}
+
+// QueueInfo 通过 AMQP 查询队列深度，不依赖管理插件
+func (this_ *Service) QueueInfo(queue string) (res *QueueInfo, err error) {
+	err = this_.channel(func(ch *amqp.Channel) (e error) {
+		q, e := ch.QueueDeclarePassive(queue, false, false, false, false, nil)
+		if e != nil {
+			return
+		}
+		res = &QueueInfo{Name: q.Name, Messages: q.Messages, Consumers: q.Consumers}
+		return
+	})
+	return
+}
+
+func (this_ *Service) Purge(queue string) (count int, err error) {
+	err = this_.channel(func(ch *amqp.Channel) (e error) {
+		count, e = ch.QueuePurge(queue, false)
+		return
+	})
+	return
+}
+
+type PublishParam struct {
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routingKey"`
+	Mandatory  bool   `json:"mandatory"`
+	Body       string `json:"body"`
+	// 消息体为 base64 编码的二进制数据
+	BodyBase64      bool                   `json:"bodyBase64"`
+	ContentType     string                 `json:"contentType"`
+	ContentEncoding string                 `json:"contentEncoding"`
+	DeliveryMode    uint8                  `json:"deliveryMode"`
+	Priority        uint8                  `json:"priority"`
+	CorrelationId   string                 `json:"correlationId"`
+	ReplyTo         string                 `json:"replyTo"`
+	Expiration      string                 `json:"expiration"`
+	MessageId       string                 `json:"messageId"`
+	Type            string                 `json:"type"`
+	UserId          string                 `json:"userId"`
+	AppId           string                 `json:"appId"`
+	Headers         map[string]interface{} `json:"headers"`
+	// 发送次数，默认 1
+	Count int `json:"count"`
+}
+
+func (this_ *Service) Publish(param *PublishParam) (err error) {
+	body := []byte(param.Body)
+	if param.BodyBase64 {
+		body, err = base64.StdEncoding.DecodeString(param.Body)
+		if err != nil {
+			err = errors.New("body base64 decode error:" + err.Error())
+			return
+		}
+	}
+	msg := amqp.Publishing{
+		Headers:         toTable(param.Headers),
+		ContentType:     param.ContentType,
+		ContentEncoding: param.ContentEncoding,
+		DeliveryMode:    param.DeliveryMode,
+		Priority:        param.Priority,
+		CorrelationId:   param.CorrelationId,
+		ReplyTo:         param.ReplyTo,
+		Expiration:      param.Expiration,
+		MessageId:       param.MessageId,
+		Timestamp:       time.Now(),
+		Type:            param.Type,
+		UserId:          param.UserId,
+		AppId:           param.AppId,
+		Body:            body,
+	}
+	count := param.Count
+	if count <= 0 {
+		count = 1
+	}
+	err = this_.channel(func(ch *amqp.Channel) (e error) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+		for i := 0; i < count; i++ {
+			if e = ch.PublishWithContext(ctx, param.Exchange, param.RoutingKey, param.Mandatory, false, msg); e != nil {
+				return
+			}
+		}
+		return
+	})
+	return
+}
+
+// toTable JSON 数字为 float64，整数转为 int64 便于服务端识别
+func toTable(data map[string]interface{}) amqp.Table {
+	if len(data) == 0 {
+		return nil
+	}
+	res := amqp.Table{}
+	for key, value := range data {
+		res[key] = toTableValue(value)
+	}
+	return res
+}
+
+func toTableValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v)
+		}
+		return v
+	case map[string]interface{}:
+		return toTable(v)
+	case []interface{}:
+		var list []interface{}
+		for _, one := range v {
+			list = append(list, toTableValue(one))
+		}
+		return list
+	}
+	return value
+}
+
+type GetParam struct {
+	Queue string `json:"queue"`
+	// 获取条数，默认 1
+	Count int `json:"count"`
+	// 处理方式 requeue（查看后放回队列）、ack（确认消费）、reject（丢弃，有死信队列时进入死信队列）
+	AckMode string `json:"ackMode"`
+}
+
+type Message struct {
+	DeliveryTag     uint64                 `json:"deliveryTag"`
+	Exchange        string                 `json:"exchange"`
+	RoutingKey      string                 `json:"routingKey"`
+	Redelivered     bool                   `json:"redelivered"`
+	MessageCount    uint32                 `json:"messageCount"`
+	ContentType     string                 `json:"contentType,omitempty"`
+	ContentEncoding string                 `json:"contentEncoding,omitempty"`
+	DeliveryMode    uint8                  `json:"deliveryMode,omitempty"`
+	Priority        uint8                  `json:"priority,omitempty"`
+	CorrelationId   string                 `json:"correlationId,omitempty"`
+	ReplyTo         string                 `json:"replyTo,omitempty"`
+	Expiration      string                 `json:"expiration,omitempty"`
+	MessageId       string                 `json:"messageId,omitempty"`
+	Timestamp       int64                  `json:"timestamp,omitempty"`
+	Type            string                 `json:"type,omitempty"`
+	UserId          string                 `json:"userId,omitempty"`
+	AppId           string                 `json:"appId,omitempty"`
+	Headers         map[string]interface{} `json:"headers,omitempty"`
+	Body            string                 `json:"body"`
+	// 消息体不是 UTF-8 文本时使用 base64 编码
+	BodyBase64 bool `json:"bodyBase64,omitempty"`
+}
+
+// Get 拉取消息，全部拉取后再统一处理，避免查看时重复拉取到放回的消息
+func (this_ *Service) Get(param *GetParam) (list []*Message, err error) {
+	count := param.Count
+	if count <= 0 {
+		count = 1
+	}
+	switch param.AckMode {
+	case "", "requeue", "ack", "reject":
+	default:
+		err = errors.New("ack mode [" + param.AckMode + "] not support")
+		return
+	}
+	err = this_.channel(func(ch *amqp.Channel) (e error) {
+		var lastTag uint64
+		for i := 0; i < count; i++ {
+			var delivery amqp.Delivery
+			var ok bool
+			delivery, ok, e = ch.Get(param.Queue, false)
+			if e != nil || !ok {
+				break
+			}
+			lastTag = delivery.DeliveryTag
+			list = append(list, toMessage(delivery))
+		}
+		if lastTag == 0 {
+			return
+		}
+		var ackErr error
+		switch param.AckMode {
+		case "ack":
+			ackErr = ch.Ack(lastTag, true)
+		case "reject":
+			ackErr = ch.Nack(lastTag, true, false)
+		default:
+			ackErr = ch.Nack(lastTag, true, true)
+		}
+		if e == nil {
+			e = ackErr
+		}
+		return
+	})
+	return
+}
+
+func toMessage(delivery amqp.Delivery) (res *Message) {
+	res = &Message{
+		DeliveryTag:     delivery.DeliveryTag,
+		Exchange:        delivery.Exchange,
+		RoutingKey:      delivery.RoutingKey,
+		Redelivered:     delivery.Redelivered,
+		MessageCount:    delivery.MessageCount,
+		ContentType:     delivery.ContentType,
+		ContentEncoding: delivery.ContentEncoding,
+		DeliveryMode:    delivery.DeliveryMode,
+		Priority:        delivery.Priority,
+		CorrelationId:   delivery.CorrelationId,
+		ReplyTo:         delivery.ReplyTo,
+		Expiration:      delivery.Expiration,
+		MessageId:       delivery.MessageId,
+		Type:            delivery.Type,
+		UserId:          delivery.UserId,
+		AppId:           delivery.AppId,
+	}
+	if !delivery.Timestamp.IsZero() {
+		res.Timestamp = delivery.Timestamp.UnixMilli()
+	}
+	if len(delivery.Headers) > 0 {
+		res.Headers = map[string]interface{}{}
+		for key, value := range delivery.Headers {
+			res.Headers[key] = fromTableValue(value)
+		}
+	}
+	if utf8.Valid(delivery.Body) {
+		res.Body = string(delivery.Body)
+	} else {
+		res.Body = base64.StdEncoding.EncodeToString(delivery.Body)
+		res.BodyBase64 = true
+	}
+	return
+}
+
+// fromTableValue 转换为可 JSON 序列化的值
+func fromTableValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case amqp.Table:
+		res := map[string]interface{}{}
+		for key, one := range v {
+			res[key] = fromTableValue(one)
+		}
+		return res
+	case []interface{}:
+		var list []interface{}
+		for _, one := range v {
+			list = append(list, fromTableValue(one))
+		}
+		return list
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case amqp.Decimal:
+		return fmt.Sprintf("%de-%d", v.Value, v.Scale)
+	case time.Time:
+		return v.UnixMilli()
+	}
+	return value
+}
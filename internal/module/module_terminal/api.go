@@ -36,6 +36,34 @@ var (
 	keyPower             = base.AppendPower(&base.PowerAction{Action: "key", Text: "终端Key", ShouldLogin: true, StandAlone: true, Parent: Power})
 	changeSizePower      = base.AppendPower(&base.PowerAction{Action: "changeSize", Text: "终端窗口大小变更", ShouldLogin: true, StandAlone: true, Parent: Power})
 	uploadWebsocketPower = base.AppendPower(&base.PowerAction{Action: "uploadWebsocket", Text: "终端上传WebSocket", ShouldLogin: true, StandAlone: true, Parent: Power})
+	sessionsPower        = base.AppendPower(&base.PowerAction{Action: "sessions", Text: "终端会话查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	recordPower          = base.AppendPower(&base.PowerAction{Action: "record", Text: "终端录像", ShouldLogin: true, StandAlone: true, ShouldPower: true, Parent: Power})
+	recordQueryPagePower = base.AppendPower(&base.PowerAction{Action: "queryPage", Text: "终端录像查询", ShouldLogin: true, StandAlone: true, ShouldPower: true, Parent: recordPower})
+	recordDataPower      = base.AppendPower(&base.PowerAction{Action: "data", Text: "终端录像回放", ShouldLogin: true, StandAlone: true, ShouldPower: true, Parent: recordPower})
+	recordDownloadPower  = base.AppendPower(&base.PowerAction{Action: "download", Text: "终端录像下载", ShouldLogin: true, StandAlone: true, ShouldPower: true, Parent: recordPower})
+	recordDeletePower    = base.AppendPower(&base.PowerAction{Action: "delete", Text: "终端录像删除", ShouldLogin: true, StandAlone: true, ShouldPower: true, Parent: recordPower})
+
+	commandPolicyPower       = base.AppendPower(&base.PowerAction{Action: "commandPolicy", Text: "终端命令策略", ShouldLogin: true, StandAlone: true, ShouldPower: true, Parent: Power})
+	commandPolicyQueryPower  = base.AppendPower(&base.PowerAction{Action: "query", Text: "终端命令策略查询", ShouldLogin: true, StandAlone: true, ShouldPower: true, Parent: commandPolicyPower})
+	commandPolicyInsertPower = base.AppendPower(&base.PowerAction{Action: "insert", Text: "终端命令策略新增", ShouldLogin: true, StandAlone: true, ShouldPower: true, Parent: commandPolicyPower})
+	commandPolicyUpdatePower = base.AppendPower(&base.PowerAction{Action: "update", Text: "终端命令策略修改", ShouldLogin: true, StandAlone: true, ShouldPower: true, Parent: commandPolicyPower})
+	commandPolicyDeletePower = base.AppendPower(&base.PowerAction{Action: "delete", Text: "终端命令策略删除", ShouldLogin: true, StandAlone: true, ShouldPower: true, Parent: commandPolicyPower})
+
+	commandConfirmPower      = base.AppendPower(&base.PowerAction{Action: "commandConfirm", Text: "终端命令确认", ShouldLogin: true, StandAlone: true, Parent: Power})
+	commandConfirmQueryPower = base.AppendPower(&base.PowerAction{Action: "query", Text: "终端命令确认查询", ShouldLogin: true, StandAlone: true, Parent: commandConfirmPower})
+
+	sharePower          = base.AppendPower(&base.PowerAction{Action: "share", Text: "终端共享", ShouldLogin: true, StandAlone: true, Parent: Power})
+	shareCreatePower    = base.AppendPower(&base.PowerAction{Action: "create", Text: "终端共享创建", ShouldLogin: true, StandAlone: true, Parent: sharePower})
+	shareQueryPower     = base.AppendPower(&base.PowerAction{Action: "query", Text: "终端共享查询", ShouldLogin: true, StandAlone: true, Parent: sharePower})
+	shareRevokePower    = base.AppendPower(&base.PowerAction{Action: "revoke", Text: "终端共享撤销", ShouldLogin: true, StandAlone: true, Parent: sharePower})
+	shareInfoPower      = base.AppendPower(&base.PowerAction{Action: "info", Text: "终端共享信息", ShouldLogin: true, StandAlone: true, Parent: sharePower})
+	shareWebsocketPower = base.AppendPower(&base.PowerAction{Action: "websocket", Text: "终端共享WebSocket", ShouldLogin: true, StandAlone: true, Parent: sharePower})
+
+	broadcastPower       = base.AppendPower(&base.PowerAction{Action: "broadcast", Text: "终端广播", ShouldLogin: true, StandAlone: true, Parent: Power})
+	broadcastQueryPower  = base.AppendPower(&base.PowerAction{Action: "query", Text: "终端广播组查询", ShouldLogin: true, StandAlone: true, Parent: broadcastPower})
+	broadcastSavePower   = base.AppendPower(&base.PowerAction{Action: "save", Text: "终端广播组保存", ShouldLogin: true, StandAlone: true, Parent: broadcastPower})
+	broadcastDeletePower = base.AppendPower(&base.PowerAction{Action: "delete", Text: "终端广播组删除", ShouldLogin: true, StandAlone: true, Parent: broadcastPower})
 )
 
 func (this_ *api) GetApis() (apis []*base.ApiWorker) {
@@ -43,18 +71,42 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: websocketPower, Do: this_.websocket, IsWebSocket: true})
 	apis = append(apis, &base.ApiWorker{Power: changeSizePower, Do: this_.changeSize})
 	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
+	apis = append(apis, &base.ApiWorker{Power: sessionsPower, Do: this_.sessions})
 	apis = append(apis, &base.ApiWorker{Power: uploadWebsocketPower, Do: this_.uploadWebsocket, IsWebSocket: true})
 
+	apis = append(apis, &base.ApiWorker{Power: recordQueryPagePower, Do: this_.recordQueryPage})
+	apis = append(apis, &base.ApiWorker{Power: recordDataPower, Do: this_.recordData})
+	apis = append(apis, &base.ApiWorker{Power: recordDownloadPower, Do: this_.recordDownload, IsGet: true})
+	apis = append(apis, &base.ApiWorker{Power: recordDeletePower, Do: this_.recordDelete})
+
+	apis = append(apis, &base.ApiWorker{Power: commandPolicyQueryPower, Do: this_.commandPolicyQuery})
+	apis = append(apis, &base.ApiWorker{Power: commandPolicyInsertPower, Do: this_.commandPolicyInsert})
+	apis = append(apis, &base.ApiWorker{Power: commandPolicyUpdatePower, Do: this_.commandPolicyUpdate})
+	apis = append(apis, &base.ApiWorker{Power: commandPolicyDeletePower, Do: this_.commandPolicyDelete})
+
+	apis = append(apis, &base.ApiWorker{Power: commandConfirmPower, Do: this_.commandConfirm})
+	apis = append(apis, &base.ApiWorker{Power: commandConfirmQueryPower, Do: this_.commandConfirmQuery})
+
+	apis = append(apis, &base.ApiWorker{Power: shareCreatePower, Do: this_.shareCreate})
+	apis = append(apis, &base.ApiWorker{Power: shareQueryPower, Do: this_.shareQuery})
+	apis = append(apis, &base.ApiWorker{Power: shareRevokePower, Do: this_.shareRevoke})
+	apis = append(apis, &base.ApiWorker{Power: shareInfoPower, Do: this_.shareInfo})
+	apis = append(apis, &base.ApiWorker{Power: shareWebsocketPower, Do: this_.shareWebsocket, IsWebSocket: true})
+
+	apis = append(apis, &base.ApiWorker{Power: broadcastQueryPower, Do: this_.broadcastQuery})
+	apis = append(apis, &base.ApiWorker{Power: broadcastSavePower, Do: this_.broadcastSave})
+	apis = append(apis, &base.ApiWorker{Power: broadcastDeletePower, Do: this_.broadcastDelete})
+
 	return
 }
 
-func (this_ *api) key(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+func (this_ *api) key(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	request := &Request{}
 	if !base.RequestJSON(request, c) {
 		return
 	}
 
-	service, _, err := this_.createService(request.Place, request.PlaceId)
+	service, _, err := this_.createService(request.Place, request.PlaceId, requestBean.JWT.UserId, nil)
 	if err != nil {
 		return
 	}
@@ -113,16 +165,6 @@ func (this_ *api) websocket(request *base.RequestBean, c *gin.Context) (res inte
 		return
 	}
 
-	service := this_.GetService(key)
-	if service != nil {
-		err = errors.New("会话[" + key + "]已存在")
-
-		_ = ws.WriteMessage(websocket.BinaryMessage, []byte("service create error:"+err.Error()))
-		this_.Logger.Error("websocket start error", zap.Error(err))
-		_ = ws.Close()
-		return
-	}
-
 	userAgentStr := c.Request.UserAgent()
 	baseLog := &TerminalLogModel{
 		Ip:        c.ClientIP(),
@@ -136,10 +178,30 @@ func (this_ *api) websocket(request *base.RequestBean, c *gin.Context) (res inte
 	baseLog.UserName = request.JWT.Name
 	baseLog.UserAccount = request.JWT.Account
 	baseLog.LoginId = request.JWT.LoginId
-	err = this_.Start(key, place, placeId, &terminal.Size{
+	size := &terminal.Size{
 		Cols: cols,
 		Rows: rows,
-	}, ws, baseLog)
+	}
+
+	// 会话已存在时为重新连接
+	session_ := this_.getSession(key)
+	if session_ != nil {
+		if session_.place != place || session_.placeId != placeId {
+			err = errors.New("会话[" + key + "]已存在")
+		} else {
+			err = this_.Attach(session_, size, ws, baseLog)
+		}
+		if err != nil {
+			_ = ws.WriteMessage(websocket.BinaryMessage, []byte("service create error:"+err.Error()))
+			this_.Logger.Error("websocket attach error", zap.Error(err))
+			_ = ws.Close()
+			return
+		}
+		res = base.HttpNotResponse
+		return
+	}
+
+	err = this_.Start(key, place, placeId, size, ws, baseLog)
 	if err != nil {
 		_ = ws.WriteMessage(websocket.BinaryMessage, []byte("start error:"+err.Error()))
 		this_.Logger.Error("websocket start error", zap.Error(err))
@@ -162,18 +224,14 @@ func (this_ *api) uploadWebsocket(request *base.RequestBean, c *gin.Context) (re
 		err = errors.New("key获取失败")
 		return
 	}
-	//升级get请求为webSocket协议
-	ws, err := upGrader.Upgrade(c.Writer, c.Request, nil)
+	session_, err := this_.getOwnerSession(request, key)
 	if err != nil {
 		return
 	}
-
-	service := this_.GetService(key)
-	if service == nil {
-		err = errors.New("会话[" + key + "]不存在")
-
-		this_.Logger.Error("uploadWebsocket start error", zap.Error(err))
-		_ = ws.Close()
+	service := session_.service
+	//升级get请求为webSocket协议
+	ws, err := upGrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
 		return
 	}
 
@@ -194,6 +252,11 @@ func (this_ *api) uploadWebsocket(request *base.RequestBean, c *gin.Context) (re
 				break
 			}
 			//this_.Logger.Info("ws on read", zap.Any("bs", string(buf)))
+			// 上传数据不经过命令策略，只允许在 rz、sz 文件传输中写入
+			if len(buf) > 0 && !session_.isFileTransfer() {
+				writeErr = errors.New("会话[" + key + "]未处于文件传输中，上传数据已忽略")
+				break
+			}
 			n, writeErr = service.Write(buf)
 			if writeErr != nil {
 				break
@@ -230,23 +293,54 @@ type Request struct {
 	*terminal.Size
 }
 
-func (this_ *api) close(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	request := &Request{}
 	if !base.RequestJSON(request, c) {
 		return
 	}
+	// 会话未创建时可能正在启动，取消启动
+	if this_.getSession(request.Key) == nil {
+		this_.stopStartingService(request.Key, requestBean.JWT.UserId)
+		return
+	}
+	_, err = this_.getOwnerSession(requestBean, request.Key)
+	if err != nil {
+		return
+	}
 	this_.stopService(request.Key)
 	return
 }
 
-func (this_ *api) changeSize(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+type SessionsResponse struct {
+	Sessions []*SessionInfo `json:"sessions,omitempty"`
+}
+
+// sessions 查询当前用户的会话，页面刷新后可根据 key 重新连接
+func (this_ *api) sessions(requestBean *base.RequestBean, _ *gin.Context) (res interface{}, err error) {
+	response := &SessionsResponse{}
+
+	response.Sessions = this_.GetUserSessions(requestBean.JWT.UserId)
+
+	res = response
+	return
+}
+
+func (this_ *api) changeSize(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	request := &Request{}
 	if !base.RequestJSON(request, c) {
 		return
 	}
-	service := this_.GetService(request.Key)
-	if service != nil {
-		err = service.ChangeSize(request.Size)
+	if this_.getSession(request.Key) == nil {
+		return
+	}
+	session_, err := this_.getOwnerSession(requestBean, request.Key)
+	if err != nil {
+		return
+	}
+	err = session_.service.ChangeSize(request.Size)
+	if err != nil {
+		return
 	}
+	session_.recorder.Resize(request.Size)
 	return
 }
@@ -0,0 +1,75 @@
+package module_terminal
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"teamide/pkg/base"
+)
+
+type BroadcastQueryResponse struct {
+	Groups []*BroadcastGroup `json:"groups,omitempty"`
+}
+
+func (this_ *api) broadcastQuery(requestBean *base.RequestBean, _ *gin.Context) (res interface{}, err error) {
+
+	response := &BroadcastQueryResponse{}
+
+	response.Groups = this_.GetUserBroadcastGroups(requestBean.JWT.UserId)
+
+	res = response
+	return
+}
+
+type BroadcastSaveRequest struct {
+	*BroadcastGroup
+}
+
+type BroadcastSaveResponse struct {
+	Group *BroadcastGroup `json:"group,omitempty"`
+}
+
+// broadcastSave 新增或修改广播组，广播组ID为空时新增
+func (this_ *api) broadcastSave(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &BroadcastSaveRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &BroadcastSaveResponse{}
+
+	group := request.BroadcastGroup
+	if group == nil {
+		err = errors.New("广播组不能为空")
+		return
+	}
+	group.UserId = requestBean.JWT.UserId
+	err = this_.SaveBroadcastGroup(group)
+	if err != nil {
+		return
+	}
+	response.Group = group
+
+	res = response
+	return
+}
+
+type BroadcastDeleteRequest struct {
+	GroupId string `json:"groupId,omitempty"`
+}
+
+type BroadcastDeleteResponse struct {
+}
+
+func (this_ *api) broadcastDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &BroadcastDeleteRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &BroadcastDeleteResponse{}
+
+	this_.DeleteBroadcastGroup(requestBean.JWT.UserId, request.GroupId)
+
+	res = response
+	return
+}
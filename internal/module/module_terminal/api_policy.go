@@ -0,0 +1,148 @@
+package module_terminal
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"teamide/pkg/base"
+)
+
+type CommandPolicyQueryResponse struct {
+	Policies []*TerminalCommandPolicyModel `json:"policies,omitempty"`
+}
+
+func (this_ *api) commandPolicyQuery(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	response := &CommandPolicyQueryResponse{}
+
+	response.Policies, err = this_.policyService.Query()
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
+
+type CommandPolicyRequest struct {
+	*TerminalCommandPolicyModel
+}
+
+type CommandPolicyResponse struct {
+	PolicyId int64 `json:"policyId,omitempty"`
+}
+
+func (this_ *api) commandPolicyInsert(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &CommandPolicyRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &CommandPolicyResponse{}
+
+	policy := request.TerminalCommandPolicyModel
+	if policy == nil {
+		err = errors.New("命令策略不能为空")
+		return
+	}
+	policy.PolicyId = 0
+	policy.UserId = requestBean.JWT.UserId
+	err = this_.policyService.Insert(policy)
+	if err != nil {
+		return
+	}
+	response.PolicyId = policy.PolicyId
+
+	res = response
+	return
+}
+
+func (this_ *api) commandPolicyUpdate(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &CommandPolicyRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &CommandPolicyResponse{}
+
+	policy := request.TerminalCommandPolicyModel
+	if policy == nil || policy.PolicyId == 0 {
+		err = errors.New("命令策略ID不能为空")
+		return
+	}
+	err = this_.policyService.Update(policy)
+	if err != nil {
+		return
+	}
+	response.PolicyId = policy.PolicyId
+
+	res = response
+	return
+}
+
+func (this_ *api) commandPolicyDelete(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &CommandPolicyRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &CommandPolicyResponse{}
+
+	if request.TerminalCommandPolicyModel == nil || request.PolicyId == 0 {
+		err = errors.New("命令策略ID不能为空")
+		return
+	}
+	err = this_.policyService.Delete(request.PolicyId)
+	if err != nil {
+		return
+	}
+	response.PolicyId = request.PolicyId
+
+	res = response
+	return
+}
+
+type CommandConfirmQueryResponse struct {
+	Confirms []*CommandConfirm `json:"confirms,omitempty"`
+}
+
+// commandConfirmQuery 查询当前用户等待确认的命令，用于页面刷新后恢复确认框
+func (this_ *api) commandConfirmQuery(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	response := &CommandConfirmQueryResponse{}
+
+	response.Confirms = QueryCommandConfirms(requestBean.JWT.UserId)
+
+	res = response
+	return
+}
+
+type CommandConfirmRequest struct {
+	ConfirmId string `json:"confirmId,omitempty"`
+	Accepted  bool   `json:"accepted,omitempty"`
+}
+
+type CommandConfirmResponse struct {
+}
+
+func (this_ *api) commandConfirm(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &CommandConfirmRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &CommandConfirmResponse{}
+
+	confirm := GetCommandConfirm(request.ConfirmId)
+	if confirm == nil {
+		err = errors.New("命令确认已过期")
+		return
+	}
+	if confirm.userId != requestBean.JWT.UserId {
+		err = errors.New("命令确认不属于当前用户，无法操作")
+		return
+	}
+	confirm.finish(request.Accepted)
+
+	res = response
+	return
+}
@@ -0,0 +1,174 @@
+package module_terminal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"teamide/pkg/base"
+	"time"
+)
+
+type RecordQueryPageRequest struct {
+	*TerminalRecordPage
+	UserId    int64  `json:"userId,omitempty"`
+	Place     string `json:"place,omitempty"`
+	PlaceId   string `json:"placeId,omitempty"`
+	StartTime int64  `json:"startTime,omitempty"`
+	EndTime   int64  `json:"endTime,omitempty"`
+}
+
+type RecordQueryPageResponse struct {
+	*TerminalRecordPage
+}
+
+func (this_ *api) recordQueryPage(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &RecordQueryPageRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &RecordQueryPageResponse{}
+
+	record := &TerminalRecordModel{
+		UserId:  request.UserId,
+		Place:   request.Place,
+		PlaceId: request.PlaceId,
+	}
+	if request.StartTime > 0 {
+		record.StartTime = time.Unix(request.StartTime, 0)
+	}
+	if request.EndTime > 0 {
+		record.EndTime = time.Unix(request.EndTime, 0)
+	}
+	if request.TerminalRecordPage == nil {
+		err = errors.New("分页参数不能为空")
+		return
+	}
+	err = this_.recordService.QueryPage(record, request.TerminalRecordPage)
+	if err != nil {
+		return
+	}
+	response.TerminalRecordPage = request.TerminalRecordPage
+	res = response
+	return
+}
+
+type RecordRequest struct {
+	RecordId int64 `json:"recordId,omitempty"`
+}
+
+func (this_ *api) getRecord(recordId int64) (record *TerminalRecordModel, filePath string, err error) {
+	record, err = this_.recordService.Get(recordId)
+	if err != nil {
+		return
+	}
+	if record == nil {
+		err = errors.New("终端录像[" + strconv.FormatInt(recordId, 10) + "]不存在")
+		return
+	}
+	filePath, err = this_.recordService.GetRecordFile(record)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// recordData 回放数据，直接输出 asciicast v2 文件内容，录像信息在文件头中
+func (this_ *api) recordData(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &RecordRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	_, filePath, err := this_.getRecord(request.RecordId)
+	if err != nil {
+		return
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	res = base.HttpNotResponse
+	c.Header("Content-Type", "application/x-asciicast")
+	c.Status(http.StatusOK)
+	_, err = io.Copy(c.Writer, file)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) recordDownload(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	res = base.HttpNotResponse
+	defer func() {
+		if err != nil {
+			_, _ = c.Writer.WriteString(err.Error())
+		}
+	}()
+
+	data := map[string]string{}
+
+	err = c.Bind(&data)
+	if err != nil {
+		return
+	}
+	recordId, err := strconv.ParseInt(data["recordId"], 10, 64)
+	if err != nil {
+		return
+	}
+
+	record, filePath, err := this_.getRecord(recordId)
+	if err != nil {
+		return
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	fileName := record.StartTime.Format("20060102150405") + "-" + record.UserName + "-" + record.Place + "-" + record.PlaceId + ".cast"
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=utf-8''%s", url.QueryEscape(fileName)))
+	c.Header("download-file-name", fileName)
+
+	c.Status(http.StatusOK)
+	_, err = io.Copy(c.Writer, file)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) recordDelete(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &RecordRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	record, _, err := this_.getRecord(request.RecordId)
+	if err != nil {
+		return
+	}
+	// 服务异常退出时录像没有结束时间，只要没有运行中的会话就可以删除
+	if isRecording(record.RecordId) {
+		err = errors.New("终端录像正在录制，无法删除")
+		return
+	}
+	err = this_.recordService.Delete(record)
+	if err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,231 @@
+package module_terminal
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"teamide/pkg/base"
+)
+
+// getOwnerSession 查询会话并校验当前用户为会话所有者
+func (this_ *api) getOwnerSession(requestBean *base.RequestBean, key string) (res *session, err error) {
+	res = this_.getSession(key)
+	if res == nil {
+		err = errors.New("会话[" + key + "]不存在")
+		return
+	}
+	if res.ownerId != requestBean.JWT.UserId {
+		err = errors.New("会话[" + key + "]不属于当前用户，无法操作")
+		return
+	}
+	return
+}
+
+// getJoinSession 根据共享ID查询会话并校验当前用户可以加入
+func (this_ *api) getJoinSession(requestBean *base.RequestBean, shareId string) (res *session, share *SessionShare, err error) {
+	if shareId == "" {
+		err = errors.New("共享ID不能为空")
+		return
+	}
+	res, share = this_.getShareSession(shareId)
+	if res == nil {
+		err = errors.New("共享会话不存在或已撤销")
+		return
+	}
+	if share.UserId != 0 && share.UserId != requestBean.JWT.UserId {
+		err = errors.New("共享会话不属于当前用户，无法加入")
+		return
+	}
+	return
+}
+
+type ShareCreateRequest struct {
+	Key       string `json:"key,omitempty"`
+	UserId    int64  `json:"userId,omitempty"`
+	ReadWrite bool   `json:"readWrite,omitempty"`
+}
+
+type ShareCreateResponse struct {
+	Share *SessionShare `json:"share,omitempty"`
+}
+
+func (this_ *api) shareCreate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &ShareCreateRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &ShareCreateResponse{}
+
+	// 读写共享可以使用所有者的 SSH 凭证执行命令，必须指定用户
+	if request.ReadWrite && request.UserId == 0 {
+		err = errors.New("读写共享需要指定共享用户")
+		return
+	}
+	session_, err := this_.getOwnerSession(requestBean, request.Key)
+	if err != nil {
+		return
+	}
+	response.Share = session_.addShare(request.UserId, request.ReadWrite)
+	if response.Share == nil {
+		err = errors.New("会话[" + request.Key + "]已结束")
+		return
+	}
+
+	res = response
+	return
+}
+
+type ShareQueryRequest struct {
+	Key string `json:"key,omitempty"`
+}
+
+type ShareQueryResponse struct {
+	Shares  []*SessionShare  `json:"shares,omitempty"`
+	Viewers []*SessionViewer `json:"viewers,omitempty"`
+}
+
+func (this_ *api) shareQuery(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &ShareQueryRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &ShareQueryResponse{}
+
+	session_, err := this_.getOwnerSession(requestBean, request.Key)
+	if err != nil {
+		return
+	}
+	response.Shares = session_.getShares()
+	response.Viewers = session_.getViewers()
+
+	res = response
+	return
+}
+
+type ShareRevokeRequest struct {
+	Key      string `json:"key,omitempty"`
+	ShareId  string `json:"shareId,omitempty"`
+	ViewerId string `json:"viewerId,omitempty"`
+}
+
+type ShareRevokeResponse struct {
+}
+
+// shareRevoke 撤销共享并断开通过该共享加入的查看者，或只断开某个查看者
+func (this_ *api) shareRevoke(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &ShareRevokeRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &ShareRevokeResponse{}
+
+	session_, err := this_.getOwnerSession(requestBean, request.Key)
+	if err != nil {
+		return
+	}
+	if request.ShareId != "" {
+		session_.revokeShare(request.ShareId)
+	}
+	if request.ViewerId != "" {
+		for _, one := range session_.getViewers() {
+			if one.ViewerId == request.ViewerId && !one.IsOwner {
+				session_.removeViewer(request.ViewerId)
+			}
+		}
+	}
+
+	res = response
+	return
+}
+
+type ShareInfoRequest struct {
+	ShareId string `json:"shareId,omitempty"`
+}
+
+// ShareInfoResponse 共享会话信息，不返回会话 key，共享用户只能通过共享ID加入
+type ShareInfoResponse struct {
+	Place     string `json:"place,omitempty"`
+	PlaceId   string `json:"placeId,omitempty"`
+	OwnerName string `json:"ownerName,omitempty"`
+	ReadWrite bool   `json:"readWrite,omitempty"`
+	IsWindows bool   `json:"isWindows,omitempty"`
+}
+
+// shareInfo 加入共享会话前查询会话信息
+func (this_ *api) shareInfo(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &ShareInfoRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &ShareInfoResponse{}
+
+	session_, share, err := this_.getJoinSession(requestBean, request.ShareId)
+	if err != nil {
+		return
+	}
+	response.Place = session_.place
+	response.PlaceId = session_.placeId
+	response.OwnerName = session_.ownerName
+	response.ReadWrite = share.ReadWrite
+	response.IsWindows = session_.isWindow
+
+	res = response
+	return
+}
+
+// shareWebsocket 通过共享加入会话
+func (this_ *api) shareWebsocket(request *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	if request.JWT == nil || request.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	session_, share, err := this_.getJoinSession(request, c.Query("shareId"))
+	if err != nil {
+		return
+	}
+	powerRoleIds, err := this_.getPowerRoleIds(request.JWT.UserId)
+	if err != nil {
+		return
+	}
+	//升级get请求为webSocket协议
+	ws, err := upGrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+
+	baseLog := &TerminalLogModel{
+		Ip:          c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		Place:       session_.place,
+		PlaceId:     session_.placeId,
+		WorkerId:    c.Query("workerId"),
+		UserId:      request.JWT.UserId,
+		UserName:    request.JWT.Name,
+		UserAccount: request.JWT.Account,
+		LoginId:     request.JWT.LoginId,
+	}
+	viewer_ := &viewer{
+		wsWriter:     &wsWriter{ws: ws},
+		shareId:      share.ShareId,
+		readWrite:    share.ReadWrite,
+		baseLog:      baseLog,
+		powerRoleIds: powerRoleIds,
+	}
+	if !session_.addViewer(viewer_) {
+		_ = ws.WriteMessage(websocket.BinaryMessage, []byte("join error:会话已结束或共享已撤销"))
+		this_.Logger.Error("share websocket join error", zap.Any("shareId", share.ShareId))
+		_ = ws.Close()
+		res = base.HttpNotResponse
+		return
+	}
+	go this_.startReadWS(session_, viewer_)
+
+	res = base.HttpNotResponse
+	return
+}
@@ -0,0 +1,196 @@
+package module_terminal
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"sync"
+)
+
+var (
+	broadcastGroupCache     = map[string]*BroadcastGroup{}
+	broadcastGroupCacheLock = &sync.Mutex{}
+)
+
+// BroadcastGroup 广播组，组内任一终端的输入会同时写入组内其它终端，输出各自独立
+type BroadcastGroup struct {
+	GroupId    string   `json:"groupId,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	Keys       []string `json:"keys,omitempty"`
+	Enable     bool     `json:"enable,omitempty"`
+	UserId     int64    `json:"userId,omitempty"`
+	CreateTime int64    `json:"createTime,omitempty"`
+}
+
+// checkBroadcastKeys 校验终端会话都属于当前用户，去除重复
+func (this_ *worker) checkBroadcastKeys(userId int64, keys []string) (res []string, err error) {
+	for _, key := range keys {
+		if util.ArrayIndexOf(res, key) >= 0 {
+			continue
+		}
+		session_ := this_.getSession(key)
+		if session_ == nil {
+			err = errors.New("会话[" + key + "]不存在")
+			return
+		}
+		if session_.ownerId != userId {
+			err = errors.New("会话[" + key + "]不属于当前用户，无法广播")
+			return
+		}
+		res = append(res, key)
+	}
+	if len(res) < 2 {
+		err = errors.New("广播组至少需要两个终端")
+		return
+	}
+	return
+}
+
+// SaveBroadcastGroup 新增或修改广播组
+func (this_ *worker) SaveBroadcastGroup(group *BroadcastGroup) (err error) {
+	group.Keys, err = this_.checkBroadcastKeys(group.UserId, group.Keys)
+	if err != nil {
+		return
+	}
+
+	broadcastGroupCacheLock.Lock()
+	defer broadcastGroupCacheLock.Unlock()
+
+	if group.GroupId == "" {
+		group.GroupId = util.GetUUID()
+		group.CreateTime = util.GetNowMilli()
+	} else {
+		old := broadcastGroupCache[group.GroupId]
+		if old == nil || old.UserId != group.UserId {
+			err = errors.New("广播组[" + group.GroupId + "]不存在")
+			return
+		}
+		group.CreateTime = old.CreateTime
+	}
+	broadcastGroupCache[group.GroupId] = group
+	return
+}
+
+// DeleteBroadcastGroup 删除广播组
+func (this_ *worker) DeleteBroadcastGroup(userId int64, groupId string) {
+	broadcastGroupCacheLock.Lock()
+	defer broadcastGroupCacheLock.Unlock()
+
+	group := broadcastGroupCache[groupId]
+	if group == nil || group.UserId != userId {
+		return
+	}
+	delete(broadcastGroupCache, groupId)
+}
+
+// GetUserBroadcastGroups 查询用户的广播组
+func (this_ *worker) GetUserBroadcastGroups(userId int64) (res []*BroadcastGroup) {
+	broadcastGroupCacheLock.Lock()
+	defer broadcastGroupCacheLock.Unlock()
+
+	for _, one := range broadcastGroupCache {
+		if one.UserId == userId {
+			res = append(res, one)
+		}
+	}
+	return
+}
+
+// removeBroadcastKey 会话结束时从广播组中移除，组内不足两个终端时删除广播组
+func (this_ *worker) removeBroadcastKey(key string) {
+	broadcastGroupCacheLock.Lock()
+	defer broadcastGroupCacheLock.Unlock()
+
+	for groupId, one := range broadcastGroupCache {
+		index := util.ArrayIndexOf(one.Keys, key)
+		if index < 0 {
+			continue
+		}
+		var keys []string
+		keys = append(keys, one.Keys[:index]...)
+		keys = append(keys, one.Keys[index+1:]...)
+		if len(keys) < 2 {
+			delete(broadcastGroupCache, groupId)
+			continue
+		}
+		one.Keys = keys
+	}
+}
+
+// getBroadcastKeys 查询需要广播的其它终端，只广播广播组所属用户的输入
+func (this_ *worker) getBroadcastKeys(key string, userId int64) (res []string) {
+	broadcastGroupCacheLock.Lock()
+	defer broadcastGroupCacheLock.Unlock()
+
+	for _, one := range broadcastGroupCache {
+		if !one.Enable || one.UserId != userId || util.ArrayIndexOf(one.Keys, key) < 0 {
+			continue
+		}
+		for _, k := range one.Keys {
+			if k != key && util.ArrayIndexOf(res, k) < 0 {
+				res = append(res, k)
+			}
+		}
+	}
+	return
+}
+
+// queuedInput 广播组其它终端的输入，viewer 为写入该终端时使用的查看者信息
+type queuedInput struct {
+	viewer *viewer
+	buf    []byte
+}
+
+// broadcastInput 将输入放入广播组内其它终端的输入队列，不等待写入，
+// 某个终端等待命令确认时不会阻塞当前终端和组内其它终端
+func (this_ *worker) broadcastInput(session_ *session, viewer_ *viewer, buf []byte) {
+	keys := this_.getBroadcastKeys(session_.key, viewer_.baseLog.UserId)
+	for _, key := range keys {
+		member := this_.getSession(key)
+		if member == nil {
+			continue
+		}
+		baseLog := *viewer_.baseLog
+		baseLog.Place = member.place
+		baseLog.PlaceId = member.placeId
+		input := &queuedInput{
+			viewer: &viewer{
+				readWrite:    true,
+				baseLog:      &baseLog,
+				powerRoleIds: viewer_.powerRoleIds,
+			},
+			buf: append([]byte{}, buf...),
+		}
+		select {
+		case member.broadcastQueue <- input:
+		case <-member.closed:
+		default:
+			this_.Logger.Warn("broadcast input queue is full", zap.Any("key", key))
+			viewer_.writeNotice("终端[" + key + "]输入队列已满，广播输入已丢弃")
+		}
+	}
+}
+
+// startBroadcastInput 按顺序处理会话的广播输入，每个终端各自还原命令行、记录命令和校验命令策略，会话结束时退出
+func (this_ *worker) startBroadcastInput(session_ *session) {
+	defer func() {
+		if e := recover(); e != nil {
+			this_.Logger.Error("startBroadcastInput error", zap.Any("error", e))
+		}
+	}()
+
+	for {
+		select {
+		case <-session_.closed:
+			return
+		case input := <-session_.broadcastQueue:
+			// 命令提示写入执行广播时该终端所有者的 WebSocket
+			input.viewer.wsWriter = session_.getOwnerWriter()
+			session_.recorder.Input(input.buf)
+			err := this_.writeInput(session_, input.viewer, input.buf)
+			if err != nil {
+				this_.Logger.Error("broadcast input error", zap.Any("key", session_.key), zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package module_terminal
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// commandLine 根据终端输入还原用户执行的命令行
+// 支持退格、左右移动、Home/End、Delete 和常用 Ctrl 快捷键，
+// Tab 补全和上下键历史命令由 Shell 完成，根据之后的终端回显同步命令行，
+// 全屏程序（vim、top 等）运行期间不根据回显同步。
+// 全屏状态来自终端输出，可以被用户伪造，所以不影响输入解析，全屏期间回车同样返回命令行用于审计和命令策略
+type commandLine struct {
+	line   []rune
+	cursor int
+	// 当前行的提示符，回显中回车重绘提示符时跳过
+	prompt     []rune
+	outputLine []rune
+	// Tab、上下键后根据回显同步命令行
+	syncEcho   bool
+	skipPrompt []rune
+	// 全屏程序运行中，只用于停止回显同步
+	alternate bool
+
+	inputEscape  []byte
+	inputPending []byte
+	outputEscape []byte
+	outPending   []byte
+	lock         sync.Mutex
+}
+
+func newCommandLine() *commandLine {
+	return &commandLine{}
+}
+
+// Input 处理一个输入字节，回车时返回还原的命令行
+func (this_ *commandLine) Input(b byte) (command string, enter bool) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if len(this_.inputEscape) > 0 {
+		this_.inputEscape = append(this_.inputEscape, b)
+		if isEscapeEnd(this_.inputEscape) {
+			this_.onInputEscape(string(this_.inputEscape[1:]))
+			this_.inputEscape = nil
+		}
+		return
+	}
+	if b != '\t' {
+		this_.syncEcho = false
+	}
+	switch b {
+	case 0x1b:
+		this_.inputEscape = []byte{b}
+	case '\r', '\n':
+		command = string(this_.line)
+		enter = true
+		this_.reset()
+	case 0x7f, 0x08:
+		if this_.cursor > 0 {
+			this_.line = append(this_.line[:this_.cursor-1], this_.line[this_.cursor:]...)
+			this_.cursor--
+		}
+	case 0x01:
+		this_.cursor = 0
+	case 0x05:
+		this_.cursor = len(this_.line)
+	case 0x02:
+		if this_.cursor > 0 {
+			this_.cursor--
+		}
+	case 0x06:
+		if this_.cursor < len(this_.line) {
+			this_.cursor++
+		}
+	case 0x04:
+		if this_.cursor < len(this_.line) {
+			this_.line = append(this_.line[:this_.cursor], this_.line[this_.cursor+1:]...)
+		}
+	case 0x0b:
+		this_.line = this_.line[:this_.cursor]
+	case 0x15:
+		this_.line = append([]rune{}, this_.line[this_.cursor:]...)
+		this_.cursor = 0
+	case 0x17:
+		start := this_.cursor
+		for start > 0 && this_.line[start-1] == ' ' {
+			start--
+		}
+		for start > 0 && this_.line[start-1] != ' ' {
+			start--
+		}
+		this_.line = append(this_.line[:start], this_.line[this_.cursor:]...)
+		this_.cursor = start
+	case 0x03:
+		this_.reset()
+	case '\t', 0x12:
+		// Tab 补全、Ctrl+R 历史搜索由 Shell 完成，根据回显同步
+		this_.startSyncEcho()
+	default:
+		if b < 0x20 {
+			return
+		}
+		this_.inputPending = append(this_.inputPending, b)
+		if !utf8.FullRune(this_.inputPending) {
+			return
+		}
+		r, _ := utf8.DecodeRune(this_.inputPending)
+		this_.inputPending = nil
+		if len(this_.line) == 0 {
+			// 开始输入时的输出行为提示符
+			this_.prompt = append([]rune{}, this_.outputLine...)
+		}
+		this_.insert(r)
+	}
+	return
+}
+
+func (this_ *commandLine) reset() {
+	this_.line = nil
+	this_.cursor = 0
+	this_.syncEcho = false
+	this_.inputPending = nil
+}
+
+func (this_ *commandLine) insert(r rune) {
+	this_.line = append(this_.line, 0)
+	copy(this_.line[this_.cursor+1:], this_.line[this_.cursor:])
+	this_.line[this_.cursor] = r
+	this_.cursor++
+}
+
+func (this_ *commandLine) startSyncEcho() {
+	this_.syncEcho = true
+	this_.skipPrompt = nil
+	if len(this_.line) == 0 {
+		this_.prompt = append([]rune{}, this_.outputLine...)
+	}
+}
+
+func (this_ *commandLine) onInputEscape(seq string) {
+	switch seq {
+	case "[C", "OC":
+		if this_.cursor < len(this_.line) {
+			this_.cursor++
+		}
+	case "[D", "OD":
+		if this_.cursor > 0 {
+			this_.cursor--
+		}
+	case "[H", "OH", "[1~", "[7~":
+		this_.cursor = 0
+	case "[F", "OF", "[4~", "[8~":
+		this_.cursor = len(this_.line)
+	case "[3~":
+		if this_.cursor < len(this_.line) {
+			this_.line = append(this_.line[:this_.cursor], this_.line[this_.cursor+1:]...)
+		}
+	case "[A", "[B", "OA", "OB":
+		// 历史命令由 Shell 完成，根据回显同步
+		this_.startSyncEcho()
+	}
+}
+
+// isEscapeEnd 转义序列是否完整：ESC [ 参数 结束字符、ESC O 字符、ESC 字符
+func isEscapeEnd(seq []byte) bool {
+	if len(seq) < 2 {
+		return false
+	}
+	switch seq[1] {
+	case '[':
+		if len(seq) < 3 {
+			return false
+		}
+		last := seq[len(seq)-1]
+		return last >= 0x40 && last <= 0x7e
+	case 'O':
+		return len(seq) >= 3
+	case ']':
+		// OSC 以 BEL 或 ESC \ 结束
+		last := seq[len(seq)-1]
+		return last == 0x07 || (last == '\\' && seq[len(seq)-2] == 0x1b)
+	}
+	return true
+}
+
+// Output 处理终端输出，识别全屏程序并在 Tab、历史命令后同步命令行
+func (this_ *commandLine) Output(bs []byte) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	for _, b := range bs {
+		if len(this_.outputEscape) > 0 {
+			this_.outputEscape = append(this_.outputEscape, b)
+			if isEscapeEnd(this_.outputEscape) {
+				this_.onOutputEscape(string(this_.outputEscape[1:]))
+				this_.outputEscape = nil
+			}
+			continue
+		}
+		switch b {
+		case 0x1b:
+			this_.outputEscape = []byte{b}
+		case '\n':
+			this_.outputLine = nil
+			this_.syncEcho = false
+		case '\r':
+			this_.outputLine = nil
+			if this_.syncEcho {
+				// 回车后 Shell 重绘提示符和命令行
+				this_.cursor = 0
+				this_.skipPrompt = append([]rune{}, this_.prompt...)
+			}
+		case 0x08:
+			if len(this_.outputLine) > 0 {
+				this_.outputLine = this_.outputLine[:len(this_.outputLine)-1]
+			}
+			if this_.syncEcho && this_.cursor > 0 {
+				this_.cursor--
+			}
+		default:
+			if b < 0x20 {
+				continue
+			}
+			this_.outPending = append(this_.outPending, b)
+			if !utf8.FullRune(this_.outPending) {
+				continue
+			}
+			r, _ := utf8.DecodeRune(this_.outPending)
+			this_.outPending = nil
+			this_.outputLine = append(this_.outputLine, r)
+			if this_.alternate || !this_.syncEcho {
+				continue
+			}
+			if len(this_.skipPrompt) > 0 && this_.skipPrompt[0] == r {
+				this_.skipPrompt = this_.skipPrompt[1:]
+				continue
+			}
+			this_.skipPrompt = nil
+			// 终端输出为覆盖写入
+			if this_.cursor < len(this_.line) {
+				this_.line[this_.cursor] = r
+			} else {
+				this_.line = append(this_.line, r)
+			}
+			this_.cursor++
+		}
+	}
+}
+
+func (this_ *commandLine) onOutputEscape(seq string) {
+	if !strings.HasPrefix(seq, "[") {
+		return
+	}
+	// 切换全屏不清空已输入的命令行，避免输出切换全屏后回车执行未校验的命令
+	switch seq {
+	case "[?1049h", "[?1047h", "[?47h":
+		this_.alternate = true
+		this_.syncEcho = false
+		return
+	case "[?1049l", "[?1047l", "[?47l":
+		this_.alternate = false
+		this_.syncEcho = false
+		return
+	}
+	if this_.alternate || !this_.syncEcho {
+		return
+	}
+	final := seq[len(seq)-1]
+	n, e := strconv.Atoi(seq[1 : len(seq)-1])
+	if e != nil || n <= 0 {
+		n = 1
+	}
+	switch final {
+	case 'K':
+		// 清除光标到行尾
+		if this_.cursor < len(this_.line) {
+			this_.line = this_.line[:this_.cursor]
+		}
+	case 'C':
+		this_.cursor += n
+		if this_.cursor > len(this_.line) {
+			this_.cursor = len(this_.line)
+		}
+	case 'D':
+		this_.cursor -= n
+		if this_.cursor < 0 {
+			this_.cursor = 0
+		}
+	case 'P':
+		// 删除光标处字符
+		end := this_.cursor + n
+		if end > len(this_.line) {
+			end = len(this_.line)
+		}
+		if this_.cursor < end {
+			this_.line = append(this_.line[:this_.cursor], this_.line[end:]...)
+		}
+	case '@':
+		// 插入空白，之后的输出会覆盖
+		for i := 0; i < n && this_.cursor <= len(this_.line); i++ {
+			this_.line = append(this_.line, 0)
+			copy(this_.line[this_.cursor+1:], this_.line[this_.cursor:])
+			this_.line[this_.cursor] = ' '
+		}
+	}
+}
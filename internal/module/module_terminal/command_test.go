@@ -0,0 +1,323 @@
+package module_terminal
+
+import (
+	"testing"
+)
+
+// commandLineStep 终端输出或用户输入，按顺序处理
+type commandLineStep struct {
+	output string
+	input  string
+}
+
+func TestCommandLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []commandLineStep
+		want  []string
+	}{
+		{
+			name:  "plain",
+			steps: []commandLineStep{{input: "ls -l\r"}},
+			want:  []string{"ls -l"},
+		},
+		{
+			name:  "empty enter",
+			steps: []commandLineStep{{input: "\r"}},
+			want:  []string{""},
+		},
+		{
+			name:  "multiple commands",
+			steps: []commandLineStep{{input: "cd /tmp\rpwd\n"}},
+			want:  []string{"cd /tmp", "pwd"},
+		},
+		{
+			name:  "backspace",
+			steps: []commandLineStep{{input: "lss\x7f -l\r"}},
+			want:  []string{"ls -l"},
+		},
+		{
+			name:  "backspace at start",
+			steps: []commandLineStep{{input: "\x7f\x08ls\r"}},
+			want:  []string{"ls"},
+		},
+		{
+			name:  "left arrow insert",
+			steps: []commandLineStep{{input: "l -l\x1b[D\x1b[D\x1b[Ds\r"}},
+			want:  []string{"ls -l"},
+		},
+		{
+			name:  "application cursor keys",
+			steps: []commandLineStep{{input: "l -l\x1bOD\x1bOD\x1bODs\x1bOC\x1bOCa\r"}},
+			want:  []string{"ls -al"},
+		},
+		{
+			name:  "right arrow at end",
+			steps: []commandLineStep{{input: "ls\x1b[C\x1b[C -l\r"}},
+			want:  []string{"ls -l"},
+		},
+		{
+			name:  "ctrl a ctrl e",
+			steps: []commandLineStep{{input: "s -l\x01l\x05a\r"}},
+			want:  []string{"ls -la"},
+		},
+		{
+			name:  "home end keys",
+			steps: []commandLineStep{{input: "s -l\x1b[Hl\x1b[Fa\x1b[1~#\x1b[4~!\r"}},
+			want:  []string{"#ls -la!"},
+		},
+		{
+			name:  "ctrl b ctrl f",
+			steps: []commandLineStep{{input: "l -l\x02\x02\x02s\x06\x06a\r"}},
+			want:  []string{"ls -al"},
+		},
+		{
+			name:  "delete key",
+			steps: []commandLineStep{{input: "lxs\x1b[D\x1b[D\x1b[3~\r"}},
+			want:  []string{"ls"},
+		},
+		{
+			name:  "ctrl d deletes under cursor",
+			steps: []commandLineStep{{input: "lxs\x02\x02\x04\r"}},
+			want:  []string{"ls"},
+		},
+		{
+			name:  "ctrl k",
+			steps: []commandLineStep{{input: "ls -la\x01\x06\x06\x0b\r"}},
+			want:  []string{"ls"},
+		},
+		{
+			name:  "ctrl u",
+			steps: []commandLineStep{{input: "abc\x15ls\r"}},
+			want:  []string{"ls"},
+		},
+		{
+			name:  "ctrl u keeps text after cursor",
+			steps: []commandLineStep{{input: "abcls\x02\x02\x15\r"}},
+			want:  []string{"ls"},
+		},
+		{
+			name:  "ctrl w",
+			steps: []commandLineStep{{input: "echo foo bar\x17baz\r"}},
+			want:  []string{"echo foo baz"},
+		},
+		{
+			name:  "ctrl w trailing spaces",
+			steps: []commandLineStep{{input: "echo foo bar  \x17\r"}},
+			want:  []string{"echo foo "},
+		},
+		{
+			name:  "ctrl c resets line",
+			steps: []commandLineStep{{input: "rm -rf /\x03ls\r"}},
+			want:  []string{"ls"},
+		},
+		{
+			name:  "utf8",
+			steps: []commandLineStep{{input: "echo 你好\x7f\x7f世界\r"}},
+			want:  []string{"echo 世界"},
+		},
+		{
+			name:  "other control bytes ignored",
+			steps: []commandLineStep{{input: "l\x00\x1fs\r"}},
+			want:  []string{"ls"},
+		},
+		{
+			name: "tab completion",
+			steps: []commandLineStep{
+				{output: "user@host:~$ "},
+				{input: "ec"},
+				{output: "ec"},
+				{input: "\t"},
+				{output: "ho "},
+				{input: "hi\r"},
+			},
+			want: []string{"echo hi"},
+		},
+		{
+			name: "tab completion with bell",
+			steps: []commandLineStep{
+				{output: "$ "},
+				{input: "ls /us"},
+				{output: "ls /us"},
+				{input: "\t"},
+				{output: "\x07r/"},
+				{input: "\r"},
+			},
+			want: []string{"ls /usr/"},
+		},
+		{
+			name: "history up",
+			steps: []commandLineStep{
+				{output: "$ "},
+				{input: "\x1b[A"},
+				{output: "ls -la"},
+				{input: "\r"},
+			},
+			want: []string{"ls -la"},
+		},
+		{
+			name: "history redraw after carriage return",
+			steps: []commandLineStep{
+				{output: "$ "},
+				{input: "\x1b[A"},
+				{output: "ls -la"},
+				{input: "\x1b[A"},
+				{output: "\r$ pwd\x1b[K"},
+				{input: "\r"},
+			},
+			want: []string{"pwd"},
+		},
+		{
+			name: "history shorter entry with backspaces",
+			steps: []commandLineStep{
+				{output: "$ "},
+				{input: "\x1b[A"},
+				{output: "ls -la"},
+				{input: "\x1b[B"},
+				{output: "\b\b\b\b\b\bpwd\x1b[K"},
+				{input: "\r"},
+			},
+			want: []string{"pwd"},
+		},
+		{
+			name: "history then edit",
+			steps: []commandLineStep{
+				{output: "$ "},
+				{input: "\x1b[A"},
+				{output: "ls"},
+				{input: " -l\r"},
+			},
+			want: []string{"ls -l"},
+		},
+		{
+			name: "output ignored without tab or history",
+			steps: []commandLineStep{
+				{output: "$ "},
+				{input: "ls"},
+				{output: "ls fake"},
+				{input: "\r"},
+			},
+			want: []string{"ls"},
+		},
+		{
+			name: "alternate screen input still parsed",
+			steps: []commandLineStep{
+				{output: "\x1b[?1049h"},
+				{input: ":wq\r"},
+				{output: "\x1b[?1049l"},
+				{input: "ls\r"},
+			},
+			want: []string{":wq", "ls"},
+		},
+		{
+			name: "alternate screen from shell output",
+			steps: []commandLineStep{
+				{output: "\x1b[?1049h"},
+				{input: "rm -rf /tmp/x\r"},
+			},
+			want: []string{"rm -rf /tmp/x"},
+		},
+		{
+			name: "alternate screen before enter keeps line",
+			steps: []commandLineStep{
+				{input: "rm -rf /tmp/x"},
+				{output: "\x1b[?47h"},
+				{input: "\r"},
+			},
+			want: []string{"rm -rf /tmp/x"},
+		},
+		{
+			name: "alternate screen stops echo sync",
+			steps: []commandLineStep{
+				{output: "$ "},
+				{input: "l"},
+				{input: "\t"},
+				{output: "\x1b[?1049hs -la"},
+				{input: "s\r"},
+			},
+			want: []string{"ls"},
+		},
+		{
+			name: "escape sequence split across inputs",
+			steps: []commandLineStep{
+				{input: "l -l\x1b"},
+				{input: "[D\x1b["},
+				{input: "D\x1b[Ds\r"},
+			},
+			want: []string{"ls -l"},
+		},
+		{
+			name: "escape sequence split across outputs",
+			steps: []commandLineStep{
+				{output: "$ "},
+				{input: "\x1b[A"},
+				{output: "ls -la\x1b["},
+				{output: "D\x1b[K"},
+				{input: "\r"},
+			},
+			want: []string{"ls -l"},
+		},
+		{
+			name: "osc title in output",
+			steps: []commandLineStep{
+				{output: "\x1b]0;user@host\x07$ "},
+				{input: "\x1b[A"},
+				{output: "pwd"},
+				{input: "\r"},
+			},
+			want: []string{"pwd"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCommandLine()
+			var got []string
+			for _, step := range tt.steps {
+				if step.output != "" {
+					c.Output([]byte(step.output))
+				}
+				for _, b := range []byte(step.input) {
+					command, enter := c.Input(b)
+					if enter {
+						got = append(got, command)
+					}
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("commands = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("commands = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsEscapeEnd(t *testing.T) {
+	tests := []struct {
+		seq  string
+		want bool
+	}{
+		{"\x1b", false},
+		{"\x1b[", false},
+		{"\x1b[1", false},
+		{"\x1b[1;5", false},
+		{"\x1b[A", true},
+		{"\x1b[3~", true},
+		{"\x1b[?1049h", true},
+		{"\x1bO", false},
+		{"\x1bOA", true},
+		{"\x1b]0;title", false},
+		{"\x1b]0;title\x07", true},
+		{"\x1b]0;title\x1b", false},
+		{"\x1b]0;title\x1b\\", true},
+		{"\x1bc", true},
+	}
+	for _, tt := range tests {
+		if got := isEscapeEnd([]byte(tt.seq)); got != tt.want {
+			t.Errorf("isEscapeEnd(%q) = %v, want %v", tt.seq, got, tt.want)
+		}
+	}
+}
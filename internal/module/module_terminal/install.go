@@ -69,5 +69,119 @@ CREATE TABLE ` + TableTerminalLog + ` (
 				},
 			},
 		},
+
+		// 创建终端录像表
+		{
+			Version: "1.0.1",
+			Module:  ModuleTerminalLog,
+			Stage:   `创建表[` + TableTerminalRecord + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableTerminalRecord + ` (
+	recordId bigint(20) NOT NULL COMMENT '录像ID',
+	loginId bigint(20) DEFAULT NULL COMMENT '登录ID',
+	workerId varchar(50) DEFAULT NULL COMMENT '工作ID',
+	userId bigint(20) DEFAULT NULL COMMENT '用户ID',
+	userName varchar(50) DEFAULT NULL COMMENT '用户名称',
+	userAccount varchar(50) DEFAULT NULL COMMENT '用户账号',
+	ip varchar(50) DEFAULT NULL COMMENT 'IP',
+	place varchar(20) DEFAULT NULL COMMENT '位置',
+	placeId varchar(20) DEFAULT NULL COMMENT '位置ID',
+	path varchar(200) NOT NULL COMMENT '录像文件',
+	width int(10) DEFAULT NULL COMMENT '终端宽度（列数）',
+	height int(10) DEFAULT NULL COMMENT '终端高度（行数）',
+	size bigint(20) DEFAULT NULL COMMENT '文件大小',
+	startTime datetime NOT NULL COMMENT '开始时间',
+	endTime datetime DEFAULT NULL COMMENT '结束时间',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	PRIMARY KEY (recordId),
+	KEY index_userId (userId),
+	KEY index_place (place),
+	KEY index_placeId (placeId),
+	KEY index_startTime (startTime)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableTerminalRecordComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableTerminalRecord + ` (
+	recordId bigint(20) NOT NULL,
+	loginId bigint(20) DEFAULT NULL,
+	workerId varchar(50) DEFAULT NULL,
+	userId bigint(20) DEFAULT NULL,
+	userName varchar(50) DEFAULT NULL,
+	userAccount varchar(50) DEFAULT NULL,
+	ip varchar(50) DEFAULT NULL,
+	place varchar(20) DEFAULT NULL,
+	placeId varchar(20) DEFAULT NULL,
+	path varchar(200) NOT NULL,
+	width int(10) DEFAULT NULL,
+	height int(10) DEFAULT NULL,
+	size bigint(20) DEFAULT NULL,
+	startTime datetime NOT NULL,
+	endTime datetime DEFAULT NULL,
+	createTime datetime NOT NULL,
+	PRIMARY KEY (recordId)
+);
+`,
+					`CREATE INDEX ` + TableTerminalRecord + `_index_userId on ` + TableTerminalRecord + ` (userId);`,
+					`CREATE INDEX ` + TableTerminalRecord + `_index_place on ` + TableTerminalRecord + ` (place);`,
+					`CREATE INDEX ` + TableTerminalRecord + `_index_placeId on ` + TableTerminalRecord + ` (placeId);`,
+					`CREATE INDEX ` + TableTerminalRecord + `_index_startTime on ` + TableTerminalRecord + ` (startTime);`,
+				},
+			},
+		},
+
+		// 创建终端命令策略表
+		{
+			Version: "1.0.2",
+			Module:  ModuleTerminalLog,
+			Stage:   `创建表[` + TableTerminalCommandPolicy + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableTerminalCommandPolicy + ` (
+	policyId bigint(20) NOT NULL COMMENT '策略ID',
+	name varchar(50) NOT NULL COMMENT '名称',
+	action varchar(20) NOT NULL COMMENT '动作',
+	pattern varchar(500) NOT NULL COMMENT '命令正则',
+	toolboxId bigint(20) DEFAULT NULL COMMENT '工具箱ID',
+	powerRoleId bigint(20) DEFAULT NULL COMMENT '角色ID',
+	comment varchar(200) DEFAULT NULL COMMENT '说明',
+	userId bigint(20) DEFAULT NULL COMMENT '用户ID',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	updateTime datetime DEFAULT NULL COMMENT '修改时间',
+	PRIMARY KEY (policyId)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableTerminalCommandPolicyComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableTerminalCommandPolicy + ` (
+	policyId bigint(20) NOT NULL,
+	name varchar(50) NOT NULL,
+	action varchar(20) NOT NULL,
+	pattern varchar(500) NOT NULL,
+	toolboxId bigint(20) DEFAULT NULL,
+	powerRoleId bigint(20) DEFAULT NULL,
+	comment varchar(200) DEFAULT NULL,
+	userId bigint(20) DEFAULT NULL,
+	createTime datetime NOT NULL,
+	updateTime datetime DEFAULT NULL,
+	PRIMARY KEY (policyId)
+);
+`},
+			},
+		},
+
+		// 终端日志 添加命令状态
+		{
+			Version: "1.0.3",
+			Module:  ModuleTerminalLog,
+			Stage:   `终端日志[` + TableTerminalLog + `]添加命令状态[status]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{
+					`ALTER TABLE ` + TableTerminalLog + ` ADD COLUMN status varchar(20) DEFAULT NULL COMMENT '命令状态' AFTER command;`,
+				},
+				Sqlite: []string{
+					`ALTER TABLE ` + TableTerminalLog + ` ADD status varchar(20);`,
+				},
+			},
+		},
 	}
 }
@@ -8,6 +8,31 @@ const (
 	// TableTerminalLog 控制台日志表
 	TableTerminalLog        = "TM_TERMINAL_LOG"
 	TableTerminalLogComment = "控制台日志"
+	// TableTerminalRecord 终端录像表
+	TableTerminalRecord        = "TM_TERMINAL_RECORD"
+	TableTerminalRecordComment = "终端录像"
+	// TableTerminalCommandPolicy 终端命令策略表
+	TableTerminalCommandPolicy        = "TM_TERMINAL_COMMAND_POLICY"
+	TableTerminalCommandPolicyComment = "终端命令策略"
+)
+
+const (
+	// CommandPolicyActionDeny 拒绝执行
+	CommandPolicyActionDeny = "deny"
+	// CommandPolicyActionConfirm 需要用户确认后执行
+	CommandPolicyActionConfirm = "confirm"
+
+	// CommandStatusDenied 命令被策略拒绝
+	CommandStatusDenied = "denied"
+	// CommandStatusConfirmed 命令经用户确认后执行
+	CommandStatusConfirmed = "confirmed"
+	// CommandStatusCanceled 命令需要确认，用户取消或超时
+	CommandStatusCanceled = "canceled"
+)
+
+const (
+	// PreferencesNameTerminal 终端偏好名称，偏好内容为本地终端的 Shell、参数、环境变量和启动目录
+	PreferencesNameTerminal = "terminal"
 )
 
 // TerminalLogModel 控制台日志模型，和控制台日志表对应
@@ -23,5 +48,45 @@ type TerminalLogModel struct {
 	Place         string    `json:"place,omitempty"`
 	PlaceId       string    `json:"placeId,omitempty"`
 	Command       string    `json:"command,omitempty"`
+	Status        string    `json:"status,omitempty"` // 命令策略处理结果，为空表示直接执行
 	CreateTime    time.Time `json:"createTime,omitempty"`
 }
+
+// TerminalRecordModel 终端录像模型，录像文件为 asciicast v2 格式
+type TerminalRecordModel struct {
+	RecordId    int64  `json:"recordId,omitempty"`
+	LoginId     int64  `json:"loginId,omitempty"`
+	WorkerId    string `json:"workerId,omitempty"`
+	UserId      int64  `json:"userId,omitempty"`
+	UserName    string `json:"userName,omitempty"`
+	UserAccount string `json:"userAccount,omitempty"`
+	Ip          string `json:"ip,omitempty"`
+	Place       string `json:"place,omitempty"`
+	PlaceId     string `json:"placeId,omitempty"`
+	// Path 录像文件相对录像目录的路径
+	Path       string    `json:"path,omitempty"`
+	Width      int       `json:"width,omitempty"`
+	Height     int       `json:"height,omitempty"`
+	Size       int64     `json:"size,omitempty"`
+	StartTime  time.Time `json:"startTime,omitempty"`
+	EndTime    time.Time `json:"endTime,omitempty"`
+	CreateTime time.Time `json:"createTime,omitempty"`
+}
+
+// TerminalCommandPolicyModel 终端命令策略，命令行匹配正则时拒绝执行或需要确认
+type TerminalCommandPolicyModel struct {
+	PolicyId int64  `json:"policyId,omitempty"`
+	Name     string `json:"name,omitempty"`
+	// Action deny 拒绝执行，confirm 需要确认后执行
+	Action string `json:"action,omitempty"`
+	// Pattern 正则表达式，匹配还原后的命令行
+	Pattern string `json:"pattern,omitempty"`
+	// ToolboxId 生效的SSH工具，为 0 时所有终端生效
+	ToolboxId int64 `json:"toolboxId,omitempty"`
+	// PowerRoleId 生效的角色，为 0 时所有用户生效
+	PowerRoleId int64     `json:"powerRoleId,omitempty"`
+	Comment     string    `json:"comment,omitempty"`
+	UserId      int64     `json:"userId,omitempty"`
+	CreateTime  time.Time `json:"createTime,omitempty"`
+	UpdateTime  time.Time `json:"updateTime,omitempty"`
+}
@@ -0,0 +1,266 @@
+package module_terminal
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"teamide/internal/context"
+	"teamide/internal/module/module_id"
+	"time"
+)
+
+// NewCommandPolicyService 根据库配置创建CommandPolicyService
+func NewCommandPolicyService(ServerContext *context.ServerContext) (res *CommandPolicyService) {
+
+	idService := module_id.NewIDService(ServerContext)
+
+	res = &CommandPolicyService{
+		ServerContext: ServerContext,
+		idService:     idService,
+	}
+	return
+}
+
+// CommandPolicyService 终端命令策略服务
+type CommandPolicyService struct {
+	*context.ServerContext
+	idService *module_id.IDService
+	// 已编译的策略，修改策略后清空，下次匹配时重新加载
+	policyCache     []*commandPolicy
+	policyCacheLoad bool
+	policyCacheLock sync.Mutex
+}
+
+type commandPolicy struct {
+	*TerminalCommandPolicyModel
+	regexp *regexp.Regexp
+}
+
+// Query 查询所有策略
+func (this_ *CommandPolicyService) Query() (res []*TerminalCommandPolicyModel, err error) {
+
+	sql := `SELECT * FROM ` + TableTerminalCommandPolicy + ` ORDER BY createTime ASC `
+	err = this_.DatabaseWorker.Query(sql, []interface{}{}, &res)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func checkCommandPolicy(policy *TerminalCommandPolicyModel) (err error) {
+	if policy.Action != CommandPolicyActionDeny && policy.Action != CommandPolicyActionConfirm {
+		err = errors.New("不支持的策略动作[" + policy.Action + "]")
+		return
+	}
+	if strings.TrimSpace(policy.Pattern) == "" {
+		err = errors.New("命令正则不能为空")
+		return
+	}
+	_, err = regexp.Compile(policy.Pattern)
+	if err != nil {
+		err = errors.New("命令正则[" + policy.Pattern + "]错误:" + err.Error())
+		return
+	}
+	return
+}
+
+// Insert 新增
+func (this_ *CommandPolicyService) Insert(policy *TerminalCommandPolicyModel) (err error) {
+
+	err = checkCommandPolicy(policy)
+	if err != nil {
+		return
+	}
+	if policy.PolicyId == 0 {
+		policy.PolicyId, err = this_.idService.GetNextID(module_id.IDTypeTerminalCommandPolicy)
+		if err != nil {
+			return
+		}
+	}
+	if policy.CreateTime.IsZero() {
+		policy.CreateTime = time.Now()
+	}
+
+	sql := `INSERT INTO ` + TableTerminalCommandPolicy + `(policyId, name, action, pattern, toolboxId, powerRoleId, comment, userId, createTime) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{policy.PolicyId, policy.Name, policy.Action, policy.Pattern, policy.ToolboxId, policy.PowerRoleId, policy.Comment, policy.UserId, policy.CreateTime})
+	if err != nil {
+		return
+	}
+	this_.cleanPolicyCache()
+	return
+}
+
+// Update 修改
+func (this_ *CommandPolicyService) Update(policy *TerminalCommandPolicyModel) (err error) {
+
+	err = checkCommandPolicy(policy)
+	if err != nil {
+		return
+	}
+
+	sql := `UPDATE ` + TableTerminalCommandPolicy + ` SET name=?,action=?,pattern=?,toolboxId=?,powerRoleId=?,comment=?,updateTime=? WHERE policyId=? `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{policy.Name, policy.Action, policy.Pattern, policy.ToolboxId, policy.PowerRoleId, policy.Comment, time.Now(), policy.PolicyId})
+	if err != nil {
+		return
+	}
+	this_.cleanPolicyCache()
+	return
+}
+
+// Delete 删除
+func (this_ *CommandPolicyService) Delete(policyId int64) (err error) {
+
+	sql := `DELETE FROM ` + TableTerminalCommandPolicy + ` WHERE policyId=? `
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{policyId})
+	if err != nil {
+		return
+	}
+	this_.cleanPolicyCache()
+	return
+}
+
+func (this_ *CommandPolicyService) cleanPolicyCache() {
+	this_.policyCacheLock.Lock()
+	defer this_.policyCacheLock.Unlock()
+
+	this_.policyCache = nil
+	this_.policyCacheLoad = false
+}
+
+func (this_ *CommandPolicyService) getPolicies() (res []*commandPolicy, err error) {
+	this_.policyCacheLock.Lock()
+	defer this_.policyCacheLock.Unlock()
+
+	if this_.policyCacheLoad {
+		res = this_.policyCache
+		return
+	}
+	list, err := this_.Query()
+	if err != nil {
+		return
+	}
+	for _, one := range list {
+		r, e := regexp.Compile(one.Pattern)
+		if e != nil {
+			this_.Logger.Error("command policy pattern error", zap.Any("policyId", one.PolicyId), zap.Error(e))
+			continue
+		}
+		res = append(res, &commandPolicy{
+			TerminalCommandPolicyModel: one,
+			regexp:                     r,
+		})
+	}
+	this_.policyCache = res
+	this_.policyCacheLoad = true
+	return
+}
+
+// Match 查询命令匹配的策略，拒绝优先于确认
+func (this_ *CommandPolicyService) Match(place string, placeId string, powerRoleIds []int64, command string) (res *TerminalCommandPolicyModel, err error) {
+	policies, err := this_.getPolicies()
+	if err != nil {
+		return
+	}
+	command = strings.TrimSpace(command)
+	for _, one := range policies {
+		if one.ToolboxId != 0 {
+			if place != "ssh" || placeId != strconv.FormatInt(one.ToolboxId, 10) {
+				continue
+			}
+		}
+		if one.PowerRoleId != 0 && util.ArrayIndexOf(powerRoleIds, one.PowerRoleId) < 0 {
+			continue
+		}
+		if !one.regexp.MatchString(command) {
+			continue
+		}
+		res = one.TerminalCommandPolicyModel
+		if one.Action == CommandPolicyActionDeny {
+			return
+		}
+	}
+	return
+}
+
+var (
+	commandConfirmCache     = map[string]*CommandConfirm{}
+	commandConfirmCacheLock = &sync.Mutex{}
+	// 等待用户确认命令的超时时间
+	commandConfirmTimeout = time.Second * 60
+
+	commandConfirmEventName = "terminal-command-confirm"
+)
+
+// CommandConfirm 命令匹配确认策略时等待用户确认
+type CommandConfirm struct {
+	ConfirmId  string `json:"confirmId"`
+	Key        string `json:"key"`
+	Place      string `json:"place"`
+	PlaceId    string `json:"placeId"`
+	Command    string `json:"command"`
+	PolicyName string `json:"policyName"`
+	userId     int64
+	accepted   bool
+	done       chan struct{}
+	doneOnce   sync.Once
+}
+
+func (this_ *CommandConfirm) finish(accepted bool) {
+	this_.doneOnce.Do(func() {
+		this_.accepted = accepted
+		close(this_.done)
+	})
+}
+
+// waitCommandConfirm 推送确认事件给用户并等待确认，超时视为取消
+func waitCommandConfirm(confirm *CommandConfirm) (accepted bool) {
+	confirm.ConfirmId = util.GetUUID()
+	confirm.done = make(chan struct{})
+
+	commandConfirmCacheLock.Lock()
+	commandConfirmCache[confirm.ConfirmId] = confirm
+	commandConfirmCacheLock.Unlock()
+	defer func() {
+		commandConfirmCacheLock.Lock()
+		delete(commandConfirmCache, confirm.ConfirmId)
+		commandConfirmCacheLock.Unlock()
+	}()
+
+	context.CallUserEvent(confirm.userId, context.NewListenEvent(commandConfirmEventName, confirm))
+
+	select {
+	case <-confirm.done:
+	case <-time.After(commandConfirmTimeout):
+		confirm.finish(false)
+	}
+	accepted = confirm.accepted
+	return
+}
+
+// GetCommandConfirm 查询等待确认的命令
+func GetCommandConfirm(confirmId string) (res *CommandConfirm) {
+	commandConfirmCacheLock.Lock()
+	defer commandConfirmCacheLock.Unlock()
+
+	res = commandConfirmCache[confirmId]
+	return
+}
+
+// QueryCommandConfirms 查询用户等待确认的命令
+func QueryCommandConfirms(userId int64) (res []*CommandConfirm) {
+	commandConfirmCacheLock.Lock()
+	defer commandConfirmCacheLock.Unlock()
+
+	for _, one := range commandConfirmCache {
+		if one.userId == userId {
+			res = append(res, one)
+		}
+	}
+	return
+}
@@ -0,0 +1,418 @@
+package module_terminal
+
+import (
+	"encoding/json"
+	"errors"
+	dialectWorker "github.com/team-ide/go-dialect/worker"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"teamide/internal/context"
+	"teamide/internal/module/module_id"
+	"teamide/pkg/task"
+	"teamide/pkg/terminal"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// 正在录制的录像，录像结束时移除
+	recordingIds     = map[int64]bool{}
+	recordingIdsLock = &sync.Mutex{}
+)
+
+// isRecording 录像是否正在录制
+func isRecording(recordId int64) bool {
+	recordingIdsLock.Lock()
+	defer recordingIdsLock.Unlock()
+
+	return recordingIds[recordId]
+}
+
+func setRecording(recordId int64, recording bool) {
+	recordingIdsLock.Lock()
+	defer recordingIdsLock.Unlock()
+
+	if recording {
+		recordingIds[recordId] = true
+	} else {
+		delete(recordingIds, recordId)
+	}
+}
+
+// NewTerminalRecordService 根据库配置创建TerminalRecordService
+func NewTerminalRecordService(ServerContext *context.ServerContext) (res *TerminalRecordService) {
+
+	idService := module_id.NewIDService(ServerContext)
+
+	res = &TerminalRecordService{
+		ServerContext: ServerContext,
+		idService:     idService,
+	}
+	return
+}
+
+// TerminalRecordService 终端录像服务
+type TerminalRecordService struct {
+	*context.ServerContext
+	idService *module_id.IDService
+}
+
+// GetRecordDir 录像文件目录
+func (this_ *TerminalRecordService) GetRecordDir() string {
+	return this_.ServerConfig.Server.Data + "terminal_record/"
+}
+
+// GetRecordFile 录像文件路径，校验路径不能跳出录像目录
+func (this_ *TerminalRecordService) GetRecordFile(record *TerminalRecordModel) (res string, err error) {
+	dir, err := filepath.Abs(this_.GetRecordDir())
+	if err != nil {
+		return
+	}
+	res, err = filepath.Abs(filepath.Join(dir, record.Path))
+	if err != nil {
+		return
+	}
+	if !strings.HasPrefix(res, dir+string(filepath.Separator)) {
+		err = errors.New("录像文件路径[" + record.Path + "]错误")
+		return
+	}
+	return
+}
+
+// Insert 新增
+func (this_ *TerminalRecordService) Insert(record *TerminalRecordModel) (err error) {
+
+	if record.RecordId == 0 {
+		record.RecordId, err = this_.idService.GetNextID(module_id.IDTypeTerminalRecord)
+		if err != nil {
+			return
+		}
+	}
+	if record.CreateTime.IsZero() {
+		record.CreateTime = time.Now()
+	}
+
+	sql := `INSERT INTO ` + TableTerminalRecord + `(recordId, loginId, workerId, userId, userName, userAccount, ip, place, placeId, path, width, height, size, startTime, createTime) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{record.RecordId, record.LoginId, record.WorkerId, record.UserId, record.UserName, record.UserAccount, record.Ip, record.Place, record.PlaceId, record.Path, record.Width, record.Height, record.Size, record.StartTime, record.CreateTime})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// UpdateEnd 录像结束，记录结束时间和文件大小
+func (this_ *TerminalRecordService) UpdateEnd(recordId int64, size int64, endTime time.Time) (err error) {
+
+	sql := `UPDATE ` + TableTerminalRecord + ` SET size=?,endTime=? WHERE recordId=? `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{size, endTime, recordId})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// Get 查询单个
+func (this_ *TerminalRecordService) Get(recordId int64) (res *TerminalRecordModel, err error) {
+	res = &TerminalRecordModel{}
+
+	sql := `SELECT * FROM ` + TableTerminalRecord + ` WHERE recordId=? `
+	find, err := this_.DatabaseWorker.QueryOne(sql, []interface{}{recordId}, res)
+	if err != nil {
+		return
+	}
+
+	if !find {
+		res = nil
+	}
+	return
+}
+
+type TerminalRecordPage struct {
+	*dialectWorker.Page
+	DataList []*TerminalRecordModel `json:"dataList"`
+}
+
+// QueryPage 分页查询
+func (this_ *TerminalRecordService) QueryPage(record *TerminalRecordModel, page *TerminalRecordPage) (err error) {
+	var sql string
+	var values []interface{}
+
+	sql += "SELECT * FROM " + TableTerminalRecord + " WHERE 1=1"
+	if record.UserId != 0 {
+		sql += " AND userId=?"
+		values = append(values, record.UserId)
+	}
+	if record.Place != "" {
+		sql += " AND place=?"
+		values = append(values, record.Place)
+	}
+	if record.PlaceId != "" {
+		sql += " AND placeId=?"
+		values = append(values, record.PlaceId)
+	}
+	if !record.StartTime.IsZero() {
+		sql += " AND startTime>=?"
+		values = append(values, record.StartTime)
+	}
+	if !record.EndTime.IsZero() {
+		sql += " AND startTime<=?"
+		values = append(values, record.EndTime)
+	}
+	sql += " ORDER BY startTime DESC"
+	page.DataList = []*TerminalRecordModel{}
+	err = this_.DatabaseWorker.QueryPage(sql, values, &page.DataList, page.Page)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// Delete 删除录像记录和录像文件
+func (this_ *TerminalRecordService) Delete(record *TerminalRecordModel) (err error) {
+
+	filePath, err := this_.GetRecordFile(record)
+	if err != nil {
+		return
+	}
+	err = os.Remove(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	sql := `DELETE FROM ` + TableTerminalRecord + ` WHERE recordId=? `
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{record.RecordId})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// cleanExpired 删除超过保留天数的录像
+func (this_ *TerminalRecordService) cleanExpired() {
+	days := this_.Setting.TerminalRecordRetentionDays
+	if days <= 0 {
+		return
+	}
+	var list []*TerminalRecordModel
+	sql := `SELECT * FROM ` + TableTerminalRecord + ` WHERE startTime<? `
+	err := this_.DatabaseWorker.Query(sql, []interface{}{time.Now().AddDate(0, 0, -days)}, &list)
+	if err != nil {
+		this_.Logger.Error("terminal record clean query error", zap.Error(err))
+		return
+	}
+	var size int
+	for _, one := range list {
+		// 长时间运行的会话仍在录制，结束后再清理
+		if isRecording(one.RecordId) {
+			continue
+		}
+		size++
+		err = this_.Delete(one)
+		if err != nil {
+			this_.Logger.Error("terminal record clean error", zap.Any("recordId", one.RecordId), zap.Error(err))
+		}
+	}
+	if size > 0 {
+		this_.Logger.Info("terminal record clean", zap.Any("days", days), zap.Any("size", size))
+	}
+}
+
+const (
+	recordCleanTaskKey = "terminal-record-clean-task-key"
+)
+
+// StartClean 启动时清理一次，之后每小时清理一次超过保留天数的录像
+func (this_ *TerminalRecordService) StartClean() {
+	go this_.cleanExpired()
+
+	err := task.AddCronTask(&task.CronTask{
+		Spec: "0 0 * * * *",
+		Task: &task.Task{
+			Key: recordCleanTaskKey,
+			Do:  this_.cleanExpired,
+		},
+	})
+	if err != nil {
+		this_.Logger.Error("terminal record clean task add error", zap.Error(err))
+	}
+}
+
+// recorder 将终端会话写入 asciicast v2 文件，每行一个事件：[秒, 类型, 数据]
+type recorder struct {
+	record        *TerminalRecordModel
+	recordService *TerminalRecordService
+	file          *os.File
+	startTime     time.Time
+	size          int64
+	// 未写入的不完整 UTF-8 字节，按事件类型区分
+	pending  map[string][]byte
+	lock     sync.Mutex
+	isClosed bool
+}
+
+// newRecorder 创建录像文件并写入 asciicast 头信息
+func newRecorder(recordService *TerminalRecordService, baseLog *TerminalLogModel, size *terminal.Size) (res *recorder, err error) {
+	startTime := time.Now()
+	record := &TerminalRecordModel{
+		LoginId:     baseLog.LoginId,
+		WorkerId:    baseLog.WorkerId,
+		UserId:      baseLog.UserId,
+		UserName:    baseLog.UserName,
+		UserAccount: baseLog.UserAccount,
+		Ip:          baseLog.Ip,
+		Place:       baseLog.Place,
+		PlaceId:     baseLog.PlaceId,
+		StartTime:   startTime,
+	}
+	if size != nil {
+		record.Width = size.Cols
+		record.Height = size.Rows
+	}
+	record.RecordId, err = recordService.idService.GetNextID(module_id.IDTypeTerminalRecord)
+	if err != nil {
+		return
+	}
+	// 按日期分目录，文件名为录像ID
+	record.Path = startTime.Format("20060102") + "/" + strconv.FormatInt(record.RecordId, 10) + ".cast"
+
+	filePath, err := recordService.GetRecordFile(record)
+	if err != nil {
+		return
+	}
+	err = os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return
+	}
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+	res = &recorder{
+		record:        record,
+		recordService: recordService,
+		file:          file,
+		startTime:     startTime,
+		pending:       map[string][]byte{},
+	}
+
+	header := map[string]interface{}{
+		"version":   2,
+		"width":     record.Width,
+		"height":    record.Height,
+		"timestamp": startTime.Unix(),
+		"title":     baseLog.UserName + "@" + baseLog.Place + "-" + baseLog.PlaceId,
+	}
+	bs, _ := json.Marshal(header)
+	err = res.writeLine(bs)
+	if err != nil {
+		_ = file.Close()
+		res = nil
+		return
+	}
+
+	// 先标记正在录制，避免插入后被清理
+	setRecording(record.RecordId, true)
+	err = recordService.Insert(record)
+	if err != nil {
+		setRecording(record.RecordId, false)
+		_ = file.Close()
+		_ = os.Remove(filePath)
+		res = nil
+		return
+	}
+	return
+}
+
+func (this_ *recorder) writeLine(bs []byte) (err error) {
+	n, err := this_.file.Write(append(bs, '\n'))
+	this_.size += int64(n)
+	return
+}
+
+func (this_ *recorder) writeEvent(eventType string, data string) {
+	if this_ == nil {
+		return
+	}
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.isClosed {
+		return
+	}
+	seconds := float64(time.Since(this_.startTime).Microseconds()) / 1000000
+	bs, _ := json.Marshal([]interface{}{seconds, eventType, data})
+	err := this_.writeLine(bs)
+	if err != nil {
+		this_.recordService.Logger.Error("terminal record write error", zap.Any("recordId", this_.record.RecordId), zap.Error(err))
+	}
+}
+
+// writeBytes 写入输入、输出数据，字节流可能在 UTF-8 字符中间截断，截断的部分留到下次写入
+func (this_ *recorder) writeBytes(eventType string, bs []byte) {
+	if this_ == nil || len(bs) == 0 {
+		return
+	}
+	this_.lock.Lock()
+	data := append(this_.pending[eventType], bs...)
+	end := len(data)
+	// 最多回退 utf8.UTFMax-1 个字节查找不完整的字符
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax+1; i-- {
+		if !utf8.RuneStart(data[i]) {
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			end = i
+		}
+		break
+	}
+	this_.pending[eventType] = append([]byte{}, data[end:]...)
+	this_.lock.Unlock()
+
+	if end > 0 {
+		this_.writeEvent(eventType, string(data[:end]))
+	}
+}
+
+// Output 记录终端输出
+func (this_ *recorder) Output(bs []byte) {
+	this_.writeBytes("o", bs)
+}
+
+// Input 记录用户输入
+func (this_ *recorder) Input(bs []byte) {
+	this_.writeBytes("i", bs)
+}
+
+// Resize 记录窗口大小变更
+func (this_ *recorder) Resize(size *terminal.Size) {
+	if size == nil {
+		return
+	}
+	this_.writeEvent("r", strconv.Itoa(size.Cols)+"x"+strconv.Itoa(size.Rows))
+}
+
+// Close 关闭录像文件，记录结束时间和文件大小
+func (this_ *recorder) Close() {
+	if this_ == nil {
+		return
+	}
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.isClosed {
+		return
+	}
+	this_.isClosed = true
+	setRecording(this_.record.RecordId, false)
+	_ = this_.file.Close()
+	err := this_.recordService.UpdateEnd(this_.record.RecordId, this_.size, time.Now())
+	if err != nil {
+		this_.recordService.Logger.Error("terminal record update end error", zap.Any("recordId", this_.record.RecordId), zap.Error(err))
+	}
+}
@@ -0,0 +1,404 @@
+package module_terminal
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/team-ide/go-tool/util"
+	"sync"
+	"teamide/internal/context"
+	"teamide/pkg/terminal"
+	"unicode/utf8"
+)
+
+var (
+	sessionViewerChangeEventName = "terminal-session-viewer-change"
+	// 会话保留最近输出的大小，查看者加入时回放
+	sessionOutputBufferSize = 128 * 1024
+	// 广播输入队列大小，终端等待命令确认时广播输入在队列中等待
+	sessionBroadcastQueueSize = 1024
+)
+
+// session 终端会话，一个终端服务可以被多个 WebSocket 共同查看
+// 创建会话的 WebSocket 为所有者，其它查看者通过共享加入
+// 所有者断开后会话保留一段时间，期间所有者可以根据 key 重新连接
+type session struct {
+	key         string
+	service     terminal.Service
+	isWindow    bool
+	place       string
+	placeId     string
+	ownerId     int64
+	ownerName   string
+	commandLine *commandLine
+	recorder    *recorder
+	viewers     map[string]*viewer
+	shares      map[string]*SessionShare
+	lock        sync.Mutex
+	isClosed    bool
+	createTime  int64
+	// 当前所有者查看者，断开后为空，detachTime 为断开时间
+	ownerViewerId string
+	detachTime    int64
+	outputBuffer  *ringBuffer
+	// 广播组其它终端写入的输入，由会话的广播协程按顺序处理，closed 在会话结束时关闭
+	broadcastQueue chan *queuedInput
+	closed         chan struct{}
+	// 是否处于 rz、sz 文件传输中
+	fileTransfer bool
+}
+
+// viewer 会话查看者，只读查看者的输入会被忽略
+type viewer struct {
+	*wsWriter
+	viewerId  string
+	shareId   string
+	isOwner   bool
+	readWrite bool
+	joinTime  int64
+	// 查看者自己的用户信息和角色，命令审计和命令策略按查看者处理
+	baseLog      *TerminalLogModel
+	powerRoleIds []int64
+}
+
+// SessionShare 会话共享，UserId 为 0 时所有登录用户都可以只读加入，读写共享必须指定用户
+type SessionShare struct {
+	ShareId    string `json:"shareId,omitempty"`
+	Key        string `json:"key,omitempty"`
+	UserId     int64  `json:"userId,omitempty"`
+	ReadWrite  bool   `json:"readWrite,omitempty"`
+	CreateTime int64  `json:"createTime,omitempty"`
+}
+
+// SessionViewer 会话查看者信息
+type SessionViewer struct {
+	ViewerId    string `json:"viewerId,omitempty"`
+	ShareId     string `json:"shareId,omitempty"`
+	IsOwner     bool   `json:"isOwner,omitempty"`
+	ReadWrite   bool   `json:"readWrite,omitempty"`
+	UserId      int64  `json:"userId,omitempty"`
+	UserName    string `json:"userName,omitempty"`
+	UserAccount string `json:"userAccount,omitempty"`
+	Ip          string `json:"ip,omitempty"`
+	JoinTime    int64  `json:"joinTime,omitempty"`
+}
+
+func newSession(key string, service terminal.Service, isWindow bool, baseLog *TerminalLogModel, recorder_ *recorder) *session {
+	return &session{
+		key:          key,
+		service:      service,
+		isWindow:     isWindow,
+		place:        baseLog.Place,
+		placeId:      baseLog.PlaceId,
+		ownerId:      baseLog.UserId,
+		ownerName:    baseLog.UserName,
+		commandLine:  newCommandLine(),
+		recorder:     recorder_,
+		viewers:      map[string]*viewer{},
+		shares:       map[string]*SessionShare{},
+		createTime:   util.GetNowMilli(),
+		outputBuffer: newRingBuffer(sessionOutputBufferSize),
+
+		broadcastQueue: make(chan *queuedInput, sessionBroadcastQueueSize),
+		closed:         make(chan struct{}),
+	}
+}
+
+// SessionInfo 会话信息
+type SessionInfo struct {
+	Key         string `json:"key,omitempty"`
+	Place       string `json:"place,omitempty"`
+	PlaceId     string `json:"placeId,omitempty"`
+	IsWindows   bool   `json:"isWindows,omitempty"`
+	CreateTime  int64  `json:"createTime,omitempty"`
+	DetachTime  int64  `json:"detachTime,omitempty"`
+	ViewerCount int    `json:"viewerCount,omitempty"`
+}
+
+func (this_ *session) getInfo() (res *SessionInfo) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	res = &SessionInfo{
+		Key:         this_.key,
+		Place:       this_.place,
+		PlaceId:     this_.placeId,
+		IsWindows:   this_.isWindow,
+		CreateTime:  this_.createTime,
+		DetachTime:  this_.detachTime,
+		ViewerCount: len(this_.viewers),
+	}
+	return
+}
+
+// addViewer 添加查看者，会话已结束或共享已撤销时返回 false
+func (this_ *session) addViewer(viewer_ *viewer) (ok bool) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.isClosed {
+		return
+	}
+	// 共享可能在加入过程中被撤销
+	if viewer_.shareId != "" && this_.shares[viewer_.shareId] == nil {
+		return
+	}
+	viewer_.viewerId = util.GetUUID()
+	viewer_.joinTime = util.GetNowMilli()
+	// 持有锁回放最近输出，保证回放和之后的输出不重复、不遗漏
+	replay := this_.outputBuffer.Bytes()
+	if len(replay) > 0 {
+		_ = viewer_.Write(replay)
+	}
+	if viewer_.isOwner {
+		// 重新连接时替换原所有者，原所有者可能是未检测到断开的连接
+		if old := this_.viewers[this_.ownerViewerId]; old != nil {
+			delete(this_.viewers, old.viewerId)
+			_ = old.ws.Close()
+		}
+		this_.ownerViewerId = viewer_.viewerId
+		this_.detachTime = 0
+	}
+	this_.viewers[viewer_.viewerId] = viewer_
+	ok = true
+	if !viewer_.isOwner {
+		this_.notifyViewerChange()
+	}
+	return
+}
+
+// detach 所有者断开，返回断开时间，所有者已被替换时返回 0
+func (this_ *session) detach(viewerId string) (detachTime int64) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.isClosed || this_.ownerViewerId != viewerId {
+		return
+	}
+	if viewer_ := this_.viewers[viewerId]; viewer_ != nil {
+		delete(this_.viewers, viewerId)
+		_ = viewer_.ws.Close()
+	}
+	this_.ownerViewerId = ""
+	this_.detachTime = util.GetNowMilli()
+	detachTime = this_.detachTime
+	return
+}
+
+// getOwnerWriter 所有者的 WebSocket，所有者已断开时为 nil
+func (this_ *session) getOwnerWriter() (res *wsWriter) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if owner := this_.viewers[this_.ownerViewerId]; owner != nil {
+		res = owner.wsWriter
+	}
+	return
+}
+
+// isDetachedSince 是否从该断开时间起一直未重新连接
+func (this_ *session) isDetachedSince(detachTime int64) bool {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	return !this_.isClosed && this_.detachTime == detachTime
+}
+
+// removeViewer 移除查看者并关闭 WebSocket
+func (this_ *session) removeViewer(viewerId string) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	viewer_ := this_.viewers[viewerId]
+	if viewer_ == nil {
+		return
+	}
+	delete(this_.viewers, viewerId)
+	_ = viewer_.ws.Close()
+	if !viewer_.isOwner {
+		this_.notifyViewerChange()
+	}
+}
+
+func (this_ *session) notifyViewerChange() {
+	context.CallUserEvent(this_.ownerId, context.NewListenEvent(sessionViewerChangeEventName, map[string]interface{}{
+		"key": this_.key,
+	}))
+}
+
+// write 保存最近输出并分发给所有查看者，写入失败的查看者关闭连接，由读取协程处理断开
+func (this_ *session) write(bs []byte) {
+	this_.lock.Lock()
+	this_.outputBuffer.Write(bs)
+	var viewers []*viewer
+	for _, one := range this_.viewers {
+		viewers = append(viewers, one)
+	}
+	this_.lock.Unlock()
+
+	for _, one := range viewers {
+		e := one.Write(bs)
+		if e != nil {
+			_ = one.ws.Close()
+		}
+	}
+}
+
+// close 会话结束，关闭所有查看者
+func (this_ *session) close() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.isClosed {
+		return
+	}
+	this_.isClosed = true
+	close(this_.closed)
+	for _, one := range this_.viewers {
+		_ = one.ws.Close()
+	}
+	this_.viewers = map[string]*viewer{}
+	this_.shares = map[string]*SessionShare{}
+}
+
+func (this_ *session) addShare(userId int64, readWrite bool) (res *SessionShare) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if this_.isClosed || (readWrite && userId == 0) {
+		return
+	}
+	res = &SessionShare{
+		ShareId:    util.GetUUID(),
+		Key:        this_.key,
+		UserId:     userId,
+		ReadWrite:  readWrite,
+		CreateTime: util.GetNowMilli(),
+	}
+	this_.shares[res.ShareId] = res
+	return
+}
+
+func (this_ *session) getShare(shareId string) (res *SessionShare) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	res = this_.shares[shareId]
+	return
+}
+
+// revokeShare 撤销共享，通过该共享加入的查看者全部断开
+func (this_ *session) revokeShare(shareId string) {
+	this_.lock.Lock()
+	delete(this_.shares, shareId)
+	var viewerIds []string
+	for _, one := range this_.viewers {
+		if one.shareId == shareId {
+			viewerIds = append(viewerIds, one.viewerId)
+		}
+	}
+	this_.lock.Unlock()
+
+	for _, viewerId := range viewerIds {
+		this_.removeViewer(viewerId)
+	}
+}
+
+func (this_ *session) getShares() (res []*SessionShare) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	for _, one := range this_.shares {
+		res = append(res, one)
+	}
+	return
+}
+
+func (this_ *session) getViewers() (res []*SessionViewer) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	for _, one := range this_.viewers {
+		res = append(res, &SessionViewer{
+			ViewerId:    one.viewerId,
+			ShareId:     one.shareId,
+			IsOwner:     one.isOwner,
+			ReadWrite:   one.readWrite,
+			UserId:      one.baseLog.UserId,
+			UserName:    one.baseLog.UserName,
+			UserAccount: one.baseLog.UserAccount,
+			Ip:          one.baseLog.Ip,
+			JoinTime:    one.joinTime,
+		})
+	}
+	return
+}
+
+// wsWriter 终端输出和命令提示都会写入 WebSocket，需要加锁
+type wsWriter struct {
+	ws   *websocket.Conn
+	lock sync.Mutex
+}
+
+func (this_ *wsWriter) Write(bs []byte) (err error) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	err = this_.ws.WriteMessage(websocket.BinaryMessage, bs)
+	return
+}
+
+// writeNotice 在终端中显示红色提示
+func (this_ *wsWriter) writeNotice(notice string) {
+	if this_ == nil {
+		return
+	}
+	_ = this_.Write([]byte("\r\n\x1b[31m" + notice + "\x1b[0m\r\n"))
+}
+
+// ringBuffer 固定大小的环形缓冲，保留最近写入的数据
+type ringBuffer struct {
+	buf  []byte
+	end  int
+	full bool
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{
+		buf: make([]byte, size),
+	}
+}
+
+func (this_ *ringBuffer) Write(bs []byte) {
+	if len(bs) == 0 {
+		return
+	}
+	size := len(this_.buf)
+	if len(bs) >= size {
+		copy(this_.buf, bs[len(bs)-size:])
+		this_.end = 0
+		this_.full = true
+		return
+	}
+	n := copy(this_.buf[this_.end:], bs)
+	if n < len(bs) {
+		copy(this_.buf, bs[n:])
+		this_.full = true
+	}
+	this_.end = (this_.end + len(bs)) % size
+	if this_.end == 0 {
+		this_.full = true
+	}
+}
+
+// Bytes 按写入顺序返回数据，缓冲已满时跳过开头被截断的 UTF-8 字符
+func (this_ *ringBuffer) Bytes() (res []byte) {
+	if !this_.full {
+		res = append(res, this_.buf[:this_.end]...)
+		return
+	}
+	res = append(res, this_.buf[this_.end:]...)
+	res = append(res, this_.buf[:this_.end]...)
+	for len(res) > 0 && !utf8.RuneStart(res[0]) {
+		res = res[1:]
+	}
+	return
+}
@@ -10,6 +10,8 @@ import (
 	"sync"
 	"teamide/internal/context"
 	"teamide/internal/module/module_node"
+	"teamide/internal/module/module_power"
+	"teamide/internal/module/module_preferences"
 	"teamide/internal/module/module_toolbox"
 	"teamide/pkg/ssh"
 	"teamide/pkg/terminal"
@@ -22,7 +24,13 @@ func NewWorker(toolboxService_ *module_toolbox.ToolboxService, nodeService_ *mod
 		toolboxService:     toolboxService_,
 		nodeService:        nodeService_,
 		terminalLogService: NewTerminalLogService(toolboxService_.ServerContext),
+		recordService:      NewTerminalRecordService(toolboxService_.ServerContext),
+		policyService:      NewCommandPolicyService(toolboxService_.ServerContext),
+		powerUserService:   module_power.NewPowerUserService(toolboxService_.ServerContext),
+		preferencesService: module_preferences.NewPreferencesService(toolboxService_.ServerContext),
 		serviceCache:       make(map[string]terminal.Service),
+		serviceStarting:    make(map[string]*startingService),
+		sessionCache:       make(map[string]*session),
 	}
 }
 
@@ -31,8 +39,71 @@ type worker struct {
 	toolboxService     *module_toolbox.ToolboxService
 	nodeService        *module_node.NodeService
 	terminalLogService *TerminalLogService
+	recordService      *TerminalRecordService
+	policyService      *CommandPolicyService
+	powerUserService   *module_power.PowerUserService
+	preferencesService *module_preferences.PreferencesService
 	serviceCache       map[string]terminal.Service
 	serviceCacheLock   sync.Mutex
+	// 启动中的服务，关闭后取消 SSH 连接时的用户确认和认证等待
+	serviceStarting  map[string]*startingService
+	sessionCache     map[string]*session
+	sessionCacheLock sync.Mutex
+}
+
+func (this_ *worker) getSession(key string) (res *session) {
+	this_.sessionCacheLock.Lock()
+	defer this_.sessionCacheLock.Unlock()
+
+	res = this_.sessionCache[key]
+	return
+}
+
+// getShareSession 根据共享ID查询会话
+func (this_ *worker) getShareSession(shareId string) (res *session, share *SessionShare) {
+	this_.sessionCacheLock.Lock()
+	defer this_.sessionCacheLock.Unlock()
+
+	for _, one := range this_.sessionCache {
+		share = one.getShare(shareId)
+		if share != nil {
+			res = one
+			return
+		}
+	}
+	return
+}
+
+func (this_ *worker) removeSession(key string) {
+	this_.sessionCacheLock.Lock()
+	defer this_.sessionCacheLock.Unlock()
+
+	delete(this_.sessionCache, key)
+}
+
+// getPowerRoleIds 查询用户角色，命令策略按角色匹配
+func (this_ *worker) getPowerRoleIds(userId int64) (res []int64, err error) {
+	powerRoles, err := this_.powerUserService.QueryPowerRolesByUserId(userId)
+	if err != nil {
+		return
+	}
+	for _, one := range powerRoles {
+		res = append(res, one.PowerRoleId)
+	}
+	return
+}
+
+// startRecorder 开启终端录像时创建录像，录像失败不影响终端使用
+func (this_ *worker) startRecorder(key string, size *terminal.Size, baseLog *TerminalLogModel) (res *recorder) {
+	if !this_.Setting.TerminalRecordEnable {
+		return
+	}
+	res, err := newRecorder(this_.recordService, baseLog, size)
+	if err != nil {
+		this_.Logger.Error("terminal record start error", zap.Any("key", key), zap.Error(err))
+		return
+	}
+	return
 }
 
 func (this_ *worker) GetService(key string) (res terminal.Service) {
@@ -43,7 +114,21 @@ func (this_ *worker) GetService(key string) (res terminal.Service) {
 	return
 }
 
-func (this_ *worker) createService(place string, placeId string) (service terminal.Service, command string, err error) {
+// getLocalConfig 查询用户的本地终端偏好，本地和节点终端使用
+func (this_ *worker) getLocalConfig(userId int64) (res *terminal.LocalConfig, err error) {
+	res = &terminal.LocalConfig{}
+	if userId == 0 {
+		return
+	}
+	err = this_.preferencesService.GetOption(userId, PreferencesNameTerminal, res)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// createService 创建终端服务，done 关闭后取消 SSH 连接
+func (this_ *worker) createService(place string, placeId string, userId int64, done <-chan struct{}) (service terminal.Service, command string, err error) {
 
 	defer func() {
 		if e := recover(); e != nil {
@@ -53,7 +138,12 @@ func (this_ *worker) createService(place string, placeId string) (service termin
 
 	switch place {
 	case "local":
-		service = terminal.NewLocalService()
+		var localConfig *terminal.LocalConfig
+		localConfig, err = this_.getLocalConfig(userId)
+		if err != nil {
+			return
+		}
+		service = terminal.NewLocalService(localConfig)
 	case "ssh":
 		if placeId == "" {
 			err = errors.New("SSH配置不能为空")
@@ -75,11 +165,16 @@ func (this_ *worker) createService(place string, placeId string) (service termin
 		}
 
 		var config *ssh.Config
-		config, err = this_.toolboxService.GetSSHConfig(tD.Option)
+		config, err = this_.toolboxService.GetSSHConfig(tD.Option, userId)
 		if err != nil {
 			return
 		}
 		if config != nil {
+			config.ToolboxId = tD.ToolboxId
+			config.Done = done
+			for _, one := range config.JumpConfigs {
+				one.Done = done
+			}
 			command = config.Command
 		}
 
@@ -89,7 +184,12 @@ func (this_ *worker) createService(place string, placeId string) (service termin
 			err = errors.New("node配置不能为空")
 			return
 		}
-		service = module_node.NewTerminalService(placeId, this_.nodeService)
+		var localConfig *terminal.LocalConfig
+		localConfig, err = this_.getLocalConfig(userId)
+		if err != nil {
+			return
+		}
+		service = module_node.NewTerminalService(placeId, this_.nodeService, localConfig)
 	}
 	if service == nil {
 		err = errors.New("[" + place + "]文件服务不存在")
@@ -107,16 +207,49 @@ func (this_ *worker) Start(key string, place string, placeId string, size *termi
 		}
 	}()
 
+	// 先放入空的占位，创建和启动服务时不持有锁，SSH 连接时确认主机密钥、键盘交互认证需要等待用户输入
 	this_.serviceCacheLock.Lock()
-	defer this_.serviceCacheLock.Unlock()
-
-	service := this_.serviceCache[key]
-	if service != nil {
+	if _, ok := this_.serviceCache[key]; ok {
+		this_.serviceCacheLock.Unlock()
 		err = errors.New("会话服务[" + key + "]已存在")
 		return
 	}
+	this_.serviceCache[key] = nil
+	starting := &startingService{
+		userId: baseLog.UserId,
+		done:   make(chan struct{}),
+	}
+	done := starting.done
+	this_.serviceStarting[key] = starting
+	this_.serviceCacheLock.Unlock()
+
+	var service terminal.Service
+	var started bool
+	defer func() {
+		this_.serviceCacheLock.Lock()
+		if this_.serviceStarting[key] == starting {
+			delete(this_.serviceStarting, key)
+		}
+		this_.serviceCacheLock.Unlock()
+		if started {
+			return
+		}
+		this_.serviceCacheLock.Lock()
+		if one, ok := this_.serviceCache[key]; ok && one == nil {
+			delete(this_.serviceCache, key)
+		}
+		this_.serviceCacheLock.Unlock()
+		if service != nil {
+			service.Stop()
+		}
+	}()
+
+	powerRoleIds, err := this_.getPowerRoleIds(baseLog.UserId)
+	if err != nil {
+		return
+	}
 	var command string
-	service, command, err = this_.createService(place, placeId)
+	service, command, err = this_.createService(place, placeId, baseLog.UserId, done)
 	if err != nil {
 		return
 	}
@@ -128,6 +261,18 @@ func (this_ *worker) Start(key string, place string, placeId string, size *termi
 	if err != nil {
 		return
 	}
+
+	// 启动期间会话已关闭
+	this_.serviceCacheLock.Lock()
+	if _, ok := this_.serviceCache[key]; !ok {
+		this_.serviceCacheLock.Unlock()
+		err = errors.New("会话服务[" + key + "]已关闭")
+		return
+	}
+	this_.serviceCache[key] = service
+	this_.serviceCacheLock.Unlock()
+	started = true
+
 	if command != "" {
 		go func() {
 			command = strings.ReplaceAll(command, "\n\r", "\n")
@@ -159,32 +304,101 @@ func (this_ *worker) Start(key string, place string, placeId string, size *termi
 
 	// 执行配置的命令
 
-	go this_.startReadWS(key, isWindow, ws, service, baseLog)
-	go this_.startReadService(key, isWindow, ws, service)
+	recorder_ := this_.startRecorder(key, size, baseLog)
 
-	this_.serviceCache[key] = service
+	session_ := newSession(key, service, isWindow, baseLog, recorder_)
+	owner := &viewer{
+		wsWriter:     &wsWriter{ws: ws},
+		isOwner:      true,
+		readWrite:    true,
+		baseLog:      baseLog,
+		powerRoleIds: powerRoleIds,
+	}
+	session_.addViewer(owner)
+	this_.sessionCacheLock.Lock()
+	this_.sessionCache[key] = session_
+	this_.sessionCacheLock.Unlock()
+
+	go this_.startReadWS(session_, owner)
+	go this_.startReadService(session_)
+	go this_.startBroadcastInput(session_)
 	return
 }
 
-type logContext struct {
-	commandBytes []byte
-}
-
-func (this_ *worker) onCommand(logContext *logContext, commandBytes []byte, log TerminalLogModel) {
+// checkCommand 记录命令并根据命令策略判断是否允许执行，匹配确认策略时等待用户确认
+func (this_ *worker) checkCommand(key string, command string, baseLog *TerminalLogModel, powerRoleIds []int64, wsWriter_ *wsWriter) (allowed bool) {
+	log := *baseLog
+	log.Command = command
+	defer func() {
+		_ = this_.terminalLogService.Insert(&log)
+	}()
 
-	logContext.commandBytes = append(logContext.commandBytes, commandBytes...)
+	policy, err := this_.policyService.Match(baseLog.Place, baseLog.PlaceId, powerRoleIds, command)
+	if err != nil {
+		this_.Logger.Error("command policy match error", zap.Any("key", key), zap.Error(err))
+		log.Status = CommandStatusDenied
+		wsWriter_.writeNotice("命令策略查询失败，命令未执行")
+		return
+	}
+	if policy == nil {
+		allowed = true
+		return
+	}
+	if policy.Action == CommandPolicyActionDeny {
+		log.Status = CommandStatusDenied
+		wsWriter_.writeNotice("命令被策略[" + policy.Name + "]拒绝执行")
+		return
+	}
 
-	str := string(commandBytes)
-	if strings.Contains(str, "\n") ||
-		strings.Contains(str, "\r") {
-		command := string(logContext.commandBytes)
-		log.Command = command
-		_ = this_.terminalLogService.Insert(&log)
-		logContext.commandBytes = []byte{}
+	allowed = waitCommandConfirm(&CommandConfirm{
+		Key:        key,
+		Place:      baseLog.Place,
+		PlaceId:    baseLog.PlaceId,
+		Command:    command,
+		PolicyName: policy.Name,
+		userId:     baseLog.UserId,
+	})
+	if allowed {
+		log.Status = CommandStatusConfirmed
+	} else {
+		log.Status = CommandStatusCanceled
+		wsWriter_.writeNotice("命令匹配策略[" + policy.Name + "]，未确认执行")
 	}
+	return
+}
 
+// writeInput 将用户输入写入终端服务，回车时还原命令行并校验命令策略，拒绝执行时发送 Ctrl+C 取消当前行
+func (this_ *worker) writeInput(session_ *session, viewer_ *viewer, buf []byte) (err error) {
+	service := session_.service
+	var start int
+	for i, b := range buf {
+		command, enter := session_.commandLine.Input(b)
+		if !enter {
+			continue
+		}
+		_, err = service.Write(buf[start:i])
+		if err != nil {
+			return
+		}
+		start = i + 1
+		if strings.TrimSpace(command) != "" && !this_.checkCommand(session_.key, command, viewer_.baseLog, viewer_.powerRoleIds, viewer_.wsWriter) {
+			_, err = service.Write([]byte{0x03})
+		} else {
+			_, err = service.Write([]byte{b})
+		}
+		if err != nil {
+			return
+		}
+	}
+	if start < len(buf) {
+		_, err = service.Write(buf[start:])
+	}
+	return
 }
-func (this_ *worker) startReadWS(key string, isWindow bool, ws *websocket.Conn, service terminal.Service, baseLog *TerminalLogModel) {
+
+// startReadWS 读取查看者输入，所有者断开时根据配置保留或结束会话，其它查看者断开时只移除该查看者
+func (this_ *worker) startReadWS(session_ *session, viewer_ *viewer) {
+	key := session_.key
 
 	defer func() {
 		if e := recover(); e != nil {
@@ -192,19 +406,33 @@ func (this_ *worker) startReadWS(key string, isWindow bool, ws *websocket.Conn,
 		}
 	}()
 
-	defer func() { this_.stopAll(key, ws, service) }()
-	logContext_ := &logContext{}
+	defer func() {
+		if viewer_.isOwner {
+			this_.detachOwner(session_, viewer_)
+		} else {
+			session_.removeViewer(viewer_.viewerId)
+		}
+	}()
 	var buf []byte
 	var readErr error
 	var writeErr error
+	var readOnlyNoticed bool
 	for {
-		_, buf, readErr = ws.ReadMessage()
+		_, buf, readErr = viewer_.ws.ReadMessage()
 		if readErr != nil && readErr != io.EOF {
 			break
 		}
 		//this_.Logger.Info("ws on read", zap.Any("bs", string(buf)))
-		this_.onCommand(logContext_, buf, *baseLog)
-		_, writeErr = service.Write(buf)
+		if viewer_.readWrite {
+			session_.recorder.Input(buf)
+			writeErr = this_.writeInput(session_, viewer_, buf)
+			if writeErr == nil {
+				this_.broadcastInput(session_, viewer_, buf)
+			}
+		} else if len(buf) > 0 && !readOnlyNoticed {
+			readOnlyNoticed = true
+			viewer_.writeNotice("只读共享会话，输入将被忽略")
+		}
 
 		if writeErr != nil {
 			break
@@ -232,7 +460,9 @@ func (this_ *worker) startReadWS(key string, isWindow bool, ws *websocket.Conn,
 	return
 }
 
-func (this_ *worker) startReadService(key string, isWindow bool, ws *websocket.Conn, service terminal.Service) {
+func (this_ *worker) startReadService(session_ *session) {
+	key := session_.key
+	service := session_.service
 
 	defer func() {
 		if e := recover(); e != nil {
@@ -241,12 +471,11 @@ func (this_ *worker) startReadService(key string, isWindow bool, ws *websocket.C
 		this_.Logger.Info("service read end", zap.Any("key", key))
 	}()
 
-	defer func() { this_.stopAll(key, ws, service) }()
+	defer func() { this_.stopAll(session_) }()
 
 	var n int
 	var buf = make([]byte, 1024*32)
 	var readErr error
-	var writeErr error
 	for {
 		n, readErr = service.Read(buf)
 		if readErr != nil && readErr != io.EOF {
@@ -260,10 +489,10 @@ func (this_ *worker) startReadService(key string, isWindow bool, ws *websocket.C
 		//}
 
 		if n > 0 {
-			writeErr = ws.WriteMessage(websocket.BinaryMessage, buf[:n])
-			if writeErr != nil {
-				break
-			}
+			session_.recorder.Output(buf[:n])
+			session_.checkFileTransfer(buf[:n])
+			session_.commandLine.Output(buf[:n])
+			session_.write(buf[:n])
 		}
 		if readErr == io.EOF {
 			readErr = nil
@@ -279,15 +508,97 @@ func (this_ *worker) startReadService(key string, isWindow bool, ws *websocket.C
 		this_.Logger.Error("service read error", zap.Error(readErr))
 	}
 
-	if writeErr != nil {
-		this_.Logger.Error("ws write error", zap.Error(writeErr))
+	this_.Logger.Info("service read is end")
+
+	return
+}
+
+// detachOwner 所有者断开，配置了会话保留时间时终端服务继续运行，超时未重新连接再结束会话
+func (this_ *worker) detachOwner(session_ *session, owner *viewer) {
+	keepSeconds := this_.Setting.TerminalSessionKeepSeconds
+	if keepSeconds <= 0 || this_.GetService(session_.key) == nil {
+		this_.stopAll(session_)
+		return
+	}
+	detachTime := session_.detach(owner.viewerId)
+	if detachTime == 0 {
+		// 已重新连接或会话已结束
+		return
 	}
+	this_.Logger.Info("session detach", zap.Any("key", session_.key), zap.Any("keepSeconds", keepSeconds))
+	go func() {
+		time.Sleep(time.Second * time.Duration(keepSeconds))
+		if session_.isDetachedSince(detachTime) {
+			this_.Logger.Info("session detach timeout", zap.Any("key", session_.key))
+			this_.stopAll(session_)
+		}
+	}()
+}
 
-	this_.Logger.Info("service read is end")
+// Attach 所有者根据 key 重新连接会话，回放最近输出
+func (this_ *worker) Attach(session_ *session, size *terminal.Size, ws *websocket.Conn, baseLog *TerminalLogModel) (err error) {
+	if session_.ownerId != baseLog.UserId {
+		err = errors.New("会话[" + session_.key + "]不属于当前用户，无法连接")
+		return
+	}
+	powerRoleIds, err := this_.getPowerRoleIds(baseLog.UserId)
+	if err != nil {
+		return
+	}
+	owner := &viewer{
+		wsWriter:     &wsWriter{ws: ws},
+		isOwner:      true,
+		readWrite:    true,
+		baseLog:      baseLog,
+		powerRoleIds: powerRoleIds,
+	}
+	if !session_.addViewer(owner) {
+		err = errors.New("会话[" + session_.key + "]已结束")
+		return
+	}
+	this_.Logger.Info("session attach", zap.Any("key", session_.key))
+	if size != nil && size.Cols > 0 && size.Rows > 0 {
+		err = session_.service.ChangeSize(size)
+		if err != nil {
+			this_.Logger.Error("session attach change size error", zap.Any("key", session_.key), zap.Error(err))
+			err = nil
+		}
+		session_.recorder.Resize(size)
+	}
+	go this_.startReadWS(session_, owner)
+	return
+}
+
+// GetUserSessions 查询用户的会话，包括已断开等待重新连接的会话
+func (this_ *worker) GetUserSessions(userId int64) (res []*SessionInfo) {
+	this_.sessionCacheLock.Lock()
+	defer this_.sessionCacheLock.Unlock()
 
+	for _, one := range this_.sessionCache {
+		if one.ownerId == userId {
+			res = append(res, one.getInfo())
+		}
+	}
 	return
 }
 
+// startingService 启动中的服务，userId 为启动服务的用户
+type startingService struct {
+	userId int64
+	done   chan struct{}
+}
+
+// stopStartingService 关闭启动中的服务，只能关闭自己启动的服务
+func (this_ *worker) stopStartingService(key string, userId int64) {
+	this_.serviceCacheLock.Lock()
+	starting := this_.serviceStarting[key]
+	this_.serviceCacheLock.Unlock()
+	if starting == nil || starting.userId != userId {
+		return
+	}
+	this_.stopService(key)
+}
+
 func (this_ *worker) stopService(key string) {
 
 	defer func() {
@@ -299,16 +610,24 @@ func (this_ *worker) stopService(key string) {
 	this_.serviceCacheLock.Lock()
 	defer this_.serviceCacheLock.Unlock()
 
-	service := this_.serviceCache[key]
-	if service == nil {
+	service, ok := this_.serviceCache[key]
+	if !ok {
 		return
 	}
 	delete(this_.serviceCache, key)
+	// 服务启动中，取消连接等待，启动完成后会停止
+	if service == nil {
+		if starting := this_.serviceStarting[key]; starting != nil {
+			delete(this_.serviceStarting, key)
+			close(starting.done)
+		}
+		return
+	}
 	this_.Logger.Info("stop service", zap.Any("key", key))
 	service.Stop()
 }
 
-func (this_ *worker) stopAll(key string, ws *websocket.Conn, service terminal.Service) {
+func (this_ *worker) stopAll(session_ *session) {
 
 	defer func() {
 		if e := recover(); e != nil {
@@ -316,12 +635,11 @@ func (this_ *worker) stopAll(key string, ws *websocket.Conn, service terminal.Se
 		}
 	}()
 
-	this_.Logger.Info("stopAll", zap.Any("key", key))
-	this_.stopService(key)
-	if service != nil {
-		service.Stop()
-	}
-	if ws != nil {
-		_ = ws.Close()
-	}
+	this_.Logger.Info("stopAll", zap.Any("key", session_.key))
+	this_.stopService(session_.key)
+	this_.removeSession(session_.key)
+	this_.removeBroadcastKey(session_.key)
+	session_.recorder.Close()
+	session_.service.Stop()
+	session_.close()
 }
@@ -0,0 +1,39 @@
+package module_terminal
+
+import (
+	"bytes"
+)
+
+var (
+	// ZModem 十六进制帧头，终端执行 rz、sz 时输出
+	zmodemHexHeader = []byte("**\x18B0")
+	// ZFIN 帧，传输结束
+	zmodemFinHeader = []byte("**\x18B08")
+	// 连续的 CAN 字符，传输取消
+	zmodemCancel = []byte("\x18\x18\x18\x18\x18")
+)
+
+// checkFileTransfer 根据终端输出判断是否处于 rz、sz 文件传输中
+func (this_ *session) checkFileTransfer(bs []byte) {
+	var fileTransfer bool
+	if bytes.Contains(bs, zmodemFinHeader) || bytes.Contains(bs, zmodemCancel) {
+		fileTransfer = false
+	} else if bytes.Contains(bs, zmodemHexHeader) {
+		fileTransfer = true
+	} else {
+		return
+	}
+
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.fileTransfer = fileTransfer
+}
+
+// isFileTransfer 是否处于 rz、sz 文件传输中，上传的文件数据只在传输中写入终端
+func (this_ *session) isFileTransfer() bool {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	return this_.fileTransfer
+}
@@ -0,0 +1,31 @@
+package module_terminal
+
+import (
+	"testing"
+)
+
+func TestCheckFileTransfer(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs []string
+		want    bool
+	}{
+		{"plain output", []string{"$ ls\r\n"}, false},
+		{"rz start", []string{"$ rz\r\n", "rz waiting to receive.**\x18B0100000023be50\r\n"}, true},
+		{"sz start", []string{"**\x18B00000000000000\r\n"}, true},
+		{"rz finish", []string{"**\x18B0100000023be50\r\n", "**\x18B0800000000022d\r\n"}, false},
+		{"canceled", []string{"**\x18B0100000023be50\r\n", "\x18\x18\x18\x18\x18\x08\x08\x08\x08\x08"}, false},
+		{"output during transfer", []string{"**\x18B0100000023be50\r\n", "data"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &session{}
+			for _, output := range tt.outputs {
+				s.checkFileTransfer([]byte(output))
+			}
+			if got := s.isFileTransfer(); got != tt.want {
+				t.Errorf("isFileTransfer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
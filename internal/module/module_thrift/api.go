@@ -1,21 +1,19 @@
 package module_thrift
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/team-ide/go-tool/metric"
 	"github.com/team-ide/go-tool/task"
 	"github.com/team-ide/go-tool/thrift"
 	"github.com/team-ide/go-tool/util"
-	"golang.org/x/net/context"
-	"io"
-	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"teamide/internal/module/module_toolbox"
@@ -25,11 +23,13 @@ import (
 
 type api struct {
 	toolboxService *module_toolbox.ToolboxService
+	report         *module_toolbox.LoadTaskReport
 }
 
 func NewApi(toolboxService *module_toolbox.ToolboxService) *api {
 	return &api{
 		toolboxService: toolboxService,
+		report:         module_toolbox.NewLoadTaskReport(toolboxService),
 	}
 }
 
@@ -45,6 +45,13 @@ var (
 	downloadRecords       = base.AppendPower(&base.PowerAction{Action: "downloadRecords", Text: "执行信息", ShouldLogin: true, StandAlone: true, Parent: Power})
 	invokeMetric          = base.AppendPower(&base.PowerAction{Action: "invokeMetric", Text: "执行信息", ShouldLogin: true, StandAlone: true, Parent: Power})
 	invokeMarkdown        = base.AppendPower(&base.PowerAction{Action: "invokeMarkdown", Text: "执行信息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeCompare         = base.AppendPower(&base.PowerAction{Action: "invokeCompare", Text: "执行报告对比", ShouldLogin: true, StandAlone: true, Parent: Power})
+	invokeExport          = base.AppendPower(&base.PowerAction{Action: "invokeExport", Text: "执行报告导出", ShouldLogin: true, StandAlone: true, Parent: Power})
+	mockStart             = base.AppendPower(&base.PowerAction{Action: "mockStart", Text: "Mock服务启动", ShouldLogin: true, StandAlone: true, Parent: Power})
+	mockStop              = base.AppendPower(&base.PowerAction{Action: "mockStop", Text: "Mock服务停止", ShouldLogin: true, StandAlone: true, Parent: Power})
+	mockUpdate            = base.AppendPower(&base.PowerAction{Action: "mockUpdate", Text: "Mock服务修改", ShouldLogin: true, StandAlone: true, Parent: Power})
+	mockList              = base.AppendPower(&base.PowerAction{Action: "mockList", Text: "Mock服务列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	mockCalls             = base.AppendPower(&base.PowerAction{Action: "mockCalls", Text: "Mock调用记录", ShouldLogin: true, StandAlone: true, Parent: Power})
 	closePower            = base.AppendPower(&base.PowerAction{Action: "close", Text: "关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
 )
 
@@ -55,11 +62,18 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: invokeByServerAddress, Do: this_.invokeByServerAddress})
 	apis = append(apis, &base.ApiWorker{Power: invokeReports, Do: this_.invokeReports})
 	apis = append(apis, &base.ApiWorker{Power: invokeReportDelete, Do: this_.invokeReportDelete})
-	apis = append(apis, &base.ApiWorker{Power: downloadRecords, Do: this_.downloadRecords, IsGet: true})
+	apis = append(apis, &base.ApiWorker{Power: downloadRecords, Do: this_.report.DownloadRecords, IsGet: true})
 	apis = append(apis, &base.ApiWorker{Power: invokeStop, Do: this_.invokeStop})
 	apis = append(apis, &base.ApiWorker{Power: invokeInfo, Do: this_.invokeInfo})
 	apis = append(apis, &base.ApiWorker{Power: invokeMetric, Do: this_.invokeMetric})
 	apis = append(apis, &base.ApiWorker{Power: invokeMarkdown, Do: this_.invokeMarkdown})
+	apis = append(apis, &base.ApiWorker{Power: invokeCompare, Do: this_.invokeCompare})
+	apis = append(apis, &base.ApiWorker{Power: invokeExport, Do: this_.invokeExport, IsGet: true})
+	apis = append(apis, &base.ApiWorker{Power: mockStart, Do: this_.mockStart})
+	apis = append(apis, &base.ApiWorker{Power: mockStop, Do: this_.mockStop})
+	apis = append(apis, &base.ApiWorker{Power: mockUpdate, Do: this_.mockUpdate})
+	apis = append(apis, &base.ApiWorker{Power: mockList, Do: this_.mockList})
+	apis = append(apis, &base.ApiWorker{Power: mockCalls, Do: this_.mockCalls})
 	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
 
 	return
@@ -94,15 +108,53 @@ type BaseRequest struct {
 	Timeout       int      `json:"timeout,omitempty"`
 	Minute        bool     `json:"minute,omitempty"`
 	Second        bool     `json:"second,omitempty"`
+	Prometheus    bool     `json:"prometheus,omitempty"`
+
+	// 线程逐步启动时长 秒
+	RampUp int `json:"rampUp,omitempty"`
+	// 恒定 QPS 限流，与线程数无关
+	Qps float64 `json:"qps,omitempty"`
+	// 分阶段执行，配置后忽略 Frequency 和 Duration
+	Stages []*LoadStage `json:"stages,omitempty"`
 
 	ProtocolFactory string `json:"protocolFactory,omitempty"`
 	Buffered        bool   `json:"buffered,omitempty"`
 	Framed          bool   `json:"framed,omitempty"`
+	// header 协议内部使用的协议 binary、compact
+	HeaderProtocolId string `json:"headerProtocolId,omitempty"`
+	// 多路复用服务名称，服务端使用 TMultiplexedProcessor 时需要配置
+	MultiplexedServiceName string `json:"multiplexedServiceName,omitempty"`
+
+	// 传输方式 socket、tls、http，http 时 ServerAddress 为请求地址
+	Transport string `json:"transport,omitempty"`
+	// 请求头，http 传输和 header 协议时有效
+	Headers               map[string]string `json:"headers,omitempty"`
+	TLSCaCert             string            `json:"tlsCaCert,omitempty"`
+	TLSClientCert         string            `json:"tlsClientCert,omitempty"`
+	TLSClientKey          string            `json:"tlsClientKey,omitempty"`
+	TLSServerName         string            `json:"tlsServerName,omitempty"`
+	TLSInsecureSkipVerify bool              `json:"tlsInsecureSkipVerify,omitempty"`
+	// 由 initTLSConfig 根据证书配置创建
+	tlsConfig *tls.Config
 
 	PrometheusMetricsScheme     string `json:"prometheusMetricsScheme,omitempty"`
 	PrometheusMetricsAddress    string `json:"prometheusMetricsAddress,omitempty"`
 	PrometheusSummaryCountMatch string `json:"prometheusSummaryCountMatch,omitempty"`
 	PrometheusSummarySumMatch   string `json:"prometheusSummarySumMatch,omitempty"`
+	// sum 指标的单位 s、ms、us、ns，默认 s
+	PrometheusSumUnit string `json:"prometheusSumUnit,omitempty"`
+
+	MockId string `json:"mockId,omitempty"`
+	// Mock 服务监听地址，如 :9090，只有端口时监听 127.0.0.1，tls 时使用 TLSClientCert 和 TLSClientKey 作为服务端证书
+	MockAddress string        `json:"mockAddress,omitempty"`
+	MockMethods []*MockMethod `json:"mockMethods,omitempty"`
+	CleanCalls  bool          `json:"cleanCalls,omitempty"`
+
+	// 对比或导出的任务，第一个为对比基准，为空时使用全部任务按时间升序
+	TaskKeys []string `json:"taskKeys,omitempty"`
+	// 导出格式 html、csv、junit
+	ExportFormat string           `json:"exportFormat,omitempty"`
+	Threshold    *ReportThreshold `json:"threshold,omitempty"`
 }
 
 func (this_ *api) context(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
@@ -201,6 +253,10 @@ func (this_ *api) invokeByServerAddress(requestBean *base.RequestBean, c *gin.Co
 	if !base.RequestJSON(request, c) {
 		return
 	}
+	err = this_.initTLSConfig(request)
+	if err != nil {
+		return
+	}
 
 	filename := service.GetFormatDir() + "/" + request.RelativePath
 
@@ -258,7 +314,7 @@ func (this_ *api) invokeByServerAddress(requestBean *base.RequestBean, c *gin.Co
 			_ = client.TTransport.Close()
 		}()
 
-		_, err = client.Send(context.Background(), param)
+		_, err = client.Send(sendContext(request), param)
 		if err != nil {
 			err = errors.New("client Send error:" + err.Error())
 		}
@@ -281,34 +337,53 @@ func (this_ *api) invokeByServerAddress(requestBean *base.RequestBean, c *gin.Co
 		}
 	} else {
 		var parentDir string
-		parentDir, err = this_.getTaskParentDir(request)
+		parentDir, err = this_.report.GetTaskParentDir(this_.getTaskParentDirRelativePath(request))
 		if err != nil {
 			return
 		}
 		var t *task.Task
 
+		var profile *loadProfile
+		profile, err = newLoadProfile(request)
+		if err != nil {
+			return
+		}
 		executor := &invokeExecutor{
 			BaseRequest:  request,
 			filename:     filename,
 			args:         args,
 			workerClient: make(map[int]*thrift.ServiceClient),
 			service:      service,
+			profile:      profile,
 		}
-		t, err = task.New(&task.Options{
+		options := &task.Options{
 			Key:       fmt.Sprintf("%d", time.Now().UnixNano()),
 			Worker:    request.Worker,
 			Frequency: request.Frequency,
 			Duration:  request.Duration,
 			Executor:  executor,
-		})
+		}
+		if profile.hasStages() {
+			options.Frequency = 0
+			options.Worker, options.Duration = profile.getTaskWorkerAndDuration()
+		}
+		t, err = task.New(options)
 		if err != nil {
 			return
 		}
+		profile.task = t
+		if request.PrometheusMetricsAddress != "" {
+			executor.prometheus, err = newPrometheusDataCollect(request)
+			if err != nil {
+				return
+			}
+			executor.prometheus.start()
+		}
 		executor.taskDir = parentDir + "" + t.Key
 		_ = this_.saveTaskInfo(executor, request, t)
 		go func() {
 			defer func() {
-				removeTask(t.Key)
+				this_.report.RemoveTask(t.Key)
 				_ = this_.saveTaskInfo(executor, request, t)
 				executor.stop()
 				_ = this_.saveTaskInfo(executor, request, t)
@@ -319,26 +394,11 @@ func (this_ *api) invokeByServerAddress(requestBean *base.RequestBean, c *gin.Co
 			}
 		}()
 		go t.Run()
-		addTask(t)
+		this_.report.AddTask(t)
 	}
 	return
 }
 
-func (this_ *api) getTaskParentDir(request *BaseRequest) (taskDir string, err error) {
-	taskDir = this_.toolboxService.GetFilesDir()
-	taskDir += this_.getTaskParentDirRelativePath(request)
-
-	ex, err := util.PathExists(taskDir)
-	if err != nil {
-		return
-	}
-	if !ex {
-		err = os.MkdirAll(taskDir, fs.ModePerm)
-	}
-
-	return
-}
-
 func (this_ *api) getTaskParentDirRelativePath(request *BaseRequest) (taskDir string) {
 	taskDir = fmt.Sprintf("%s/toolbox-%d/%s", "thrift-tasks", request.ToolboxId, request.RelativePath) + "/" + request.ServiceName + "/" + request.MethodName + "/"
 
@@ -346,133 +406,51 @@ func (this_ *api) getTaskParentDirRelativePath(request *BaseRequest) (taskDir st
 }
 
 func (this_ *api) saveTaskInfo(executor *invokeExecutor, request *BaseRequest, task *task.Task) (err error) {
-	ex, err := util.PathExists(executor.taskDir)
-	if err != nil {
-		return
-	}
-	if !ex {
-		err = os.MkdirAll(executor.taskDir, fs.ModePerm)
-	}
-
-	bs, _ := json.Marshal(request)
-	requestMd5 := util.GetMD5(string(bs))
-	data := map[string]interface{}{}
-	data["requestMd5"] = requestMd5
-	data["request"] = request
-	data["task"] = task
-	data["taskKey"] = task.Key
-	c := task.Metric.Count()
-	topItems := c.TopItems
-	c.TopItems = []*metric.Item{}
-	data["metric"] = c
-	bs, _ = json.MarshalIndent(data, "", "  ")
-	err = util.WriteFile(executor.taskDir+"/info.json", bs)
-	if err != nil {
-		return
-	}
-	c.TopItems = topItems
-	bs, _ = json.MarshalIndent(c, "", "  ")
-	_ = util.WriteFile(executor.taskDir+"/metric.json", bs)
-	bs, _ = json.MarshalIndent(task.Metric.CountMinute(), "", "  ")
-	_ = util.WriteFile(executor.taskDir+"/metric.minute.json", bs)
-	bs, _ = json.MarshalIndent(task.Metric.CountSecond(), "", "  ")
-	_ = util.WriteFile(executor.taskDir+"/metric.second.json", bs)
-
-	paramList := executor.getAndCleanParamList()
-	var recordsFile *os.File
-	if ex, _ = util.PathExists(executor.taskDir + "/records.txt"); ex {
-		recordsFile, _ = os.OpenFile(executor.taskDir+"/records.txt", os.O_WRONLY|os.O_APPEND, 0666)
-	} else {
-		recordsFile, _ = os.Create(executor.taskDir + "/records.txt")
-	}
-	if recordsFile != nil {
-		defer func() { _ = recordsFile.Close() }()
-
-		for _, param := range paramList {
-			param.ArgFields = nil
-			param.ResultType = nil
-			param.ExceptionFields = nil
-			bs, _ = json.Marshal(param)
-			_, _ = recordsFile.Write(bs)
-			_, _ = recordsFile.WriteString("\n")
+	extend := map[string]interface{}{}
+	extend["task"] = executor.profile.getTaskInfo(task)
+	if stageCounts := executor.profile.getStageCounts(); len(stageCounts) > 0 {
+		extend["stages"] = stageCounts
+	}
+	if executor.prometheus != nil {
+		prometheusData := executor.prometheus.getData()
+		report := correlatePrometheus(prometheusData, prometheusUnitToMilli(request.PrometheusSumUnit), task.Metric.CountSecond())
+		extend["prometheus"] = &prometheusReport{
+			ServerCount: report.ServerCount,
+			ServerAvg:   report.ServerAvg,
+			ClientCount: report.ClientCount,
+			ClientAvg:   report.ClientAvg,
+			OverheadAvg: report.OverheadAvg,
 		}
+		bs, _ := json.MarshalIndent(map[string]interface{}{
+			"data":   prometheusData,
+			"report": report,
+		}, "", "  ")
+		_ = util.WriteFile(executor.taskDir+"/prometheus.json", bs)
 	}
 
-	return
-}
-func (this_ *api) loadTask(taskDir string) (data map[string]interface{}, err error) {
-	defer func() {
-		if len(data) == 0 {
-			data = nil
-		}
-	}()
-	data = map[string]interface{}{}
-
-	var bs []byte
-	if ex, _ := util.PathExists(taskDir + "/info.json"); ex {
-		if bs, err = os.ReadFile(taskDir + "/info.json"); err != nil {
-			return
-		}
-		err = util.JSONDecodeUseNumber(bs, &data)
-		if err != nil {
-			return
-		}
-		if data["taskKey"] != nil {
-			taskKey := util.GetStringValue(data["taskKey"])
-			t := getTask(taskKey)
-			if t != nil {
-				data["isEnd"] = false
-			} else {
-				data["isEnd"] = true
-			}
-		}
-
+	var records []interface{}
+	for _, param := range executor.getAndCleanParamList() {
+		param.ArgFields = nil
+		param.ResultType = nil
+		param.ExceptionFields = nil
+		records = append(records, param)
 	}
-
+	err = this_.report.SaveTaskInfo(executor.taskDir, request, task, extend, records)
 	return
 }
-
 func (this_ *api) loadTasks(requestBean *base.RequestBean, c *gin.Context) (taskList []map[string]interface{}, err error) {
 	request := &BaseRequest{}
 	if !base.RequestJSON(request, c) {
 		return
 	}
 
-	parentDir, err := this_.getTaskParentDir(request)
-	if err != nil {
-		return
-	}
-
-	parentDirRelativePath := this_.getTaskParentDirRelativePath(request)
-
-	fileList, err := os.ReadDir(parentDir)
-	if err != nil {
-		return
-	}
-	var taskInfo map[string]interface{}
-	var names []string
-	for _, f := range fileList {
-		if !f.IsDir() {
-			continue
-		}
-		names = append(names, f.Name())
-
-	}
+	taskList, err = this_.loadTaskList(request)
+	return
+}
 
-	sort.Slice(names, func(i, j int) bool {
-		return strings.ToLower(names[i]) < strings.ToLower(names[j]) //升序  即前面的值比后面的小 忽略大小写排序
-	})
-	size := len(names)
-	for i := size - 1; i >= 0; i-- {
-		taskInfo, err = this_.loadTask(parentDir + names[i])
-		if err != nil {
-			return
-		}
-		if taskInfo != nil {
-			taskInfo["taskRelativePath"] = parentDirRelativePath + names[i]
-			taskList = append(taskList, taskInfo)
-		}
-	}
+// loadTaskList 按任务目录名倒序，最新的在前
+func (this_ *api) loadTaskList(request *BaseRequest) (taskList []map[string]interface{}, err error) {
+	taskList, err = this_.report.LoadTaskList(this_.getTaskParentDirRelativePath(request))
 	return
 }
 func (this_ *api) invokeReports(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
@@ -491,93 +469,145 @@ func (this_ *api) invokeMarkdown(requestBean *base.RequestBean, c *gin.Context)
 	res = toMarkdown(taskList)
 	return
 }
-func (this_ *api) downloadRecords(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	c.Header("Content-Type", "application/octet-stream")
-	c.Header("Content-Transfer-Encoding", "binary")
 
-	this_.toolboxService.Logger.Info("下载执行记录 start")
-	res = base.HttpNotResponse
-	defer func() {
+// loadCompareTasks 按 TaskKeys 顺序加载，未指定时加载全部任务并按时间升序
+func (this_ *api) loadCompareTasks(request *BaseRequest) (taskList []map[string]interface{}, err error) {
+	if len(request.TaskKeys) == 0 {
+		var list []map[string]interface{}
+		list, err = this_.loadTaskList(request)
 		if err != nil {
-			_, _ = c.Writer.WriteString(err.Error())
+			return
 		}
-	}()
-
-	request := map[string]string{}
-
-	err = c.Bind(&request)
+		for i := len(list) - 1; i >= 0; i-- {
+			taskList = append(taskList, list[i])
+		}
+		return
+	}
+	taskParentDir, err := this_.report.GetTaskParentDir(this_.getTaskParentDirRelativePath(request))
 	if err != nil {
 		return
 	}
-
-	fileName := "" + request["serviceName"] + "." + request["methodName"] + "-执行记录.txt"
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=utf-8''%s", url.QueryEscape(fileName)))
-
-	// 此处不设置 文件大小，如果设置文件大小，将无法终止下载
-	//c.Header("Content-Length", fmt.Sprint(fileInfo.Size))
-	c.Header("download-file-name", fileName)
-
-	taskDir := this_.toolboxService.GetFilesDir() + request["taskRelativePath"]
-
-	if ex, _ := util.PathExists(taskDir + "/records.txt"); ex {
-		var f *os.File
-		f, err = os.Open(taskDir + "/records.txt")
+	var taskInfo map[string]interface{}
+	for _, taskKey := range request.TaskKeys {
+		taskInfo, err = this_.report.LoadTask(taskParentDir + taskKey)
 		if err != nil {
 			return
 		}
-		defer func() { _ = f.Close() }()
-		_, err = io.Copy(c.Writer, f)
+		if taskInfo == nil {
+			err = errors.New("task [" + taskKey + "] not found")
+			return
+		}
+		taskList = append(taskList, taskInfo)
 	}
-	c.Status(http.StatusOK)
 	return
 }
-func (this_ *api) invokeReportDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 
+func (this_ *api) invokeCompare(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	request := &BaseRequest{}
 	if !base.RequestJSON(request, c) {
 		return
 	}
 
-	taskParentDir, err := this_.getTaskParentDir(request)
+	taskList, err := this_.loadCompareTasks(request)
 	if err != nil {
 		return
 	}
-
-	t := getTask(request.TaskKey)
-
-	if t != nil {
-		t.Stop()
+	if len(taskList) < 2 {
+		err = errors.New("compare requires at least two tasks")
+		return
 	}
-	taskDir := taskParentDir + "" + request.TaskKey
+	items := compareReports(taskList, request.Threshold)
 
-	if ex, _ := util.PathExists(taskDir); ex {
-		err = os.RemoveAll(taskDir)
-	}
+	data := map[string]interface{}{}
+	data["items"] = items
+	data["markdown"] = toCompareMarkdown(items)
+	res = data
 	return
 }
 
-var taskCache = map[string]*task.Task{}
-var taskLocker = &sync.Mutex{}
+func (this_ *api) invokeExport(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	res = base.HttpNotResponse
+	defer func() {
+		if err != nil {
+			_, _ = c.Writer.WriteString(err.Error())
+		}
+	}()
 
-func getTask(taskKey string) *task.Task {
-	taskLocker.Lock()
-	defer taskLocker.Unlock()
+	data := map[string]string{}
+	err = c.Bind(&data)
+	if err != nil {
+		return
+	}
 
-	return taskCache[taskKey]
-}
+	request := &BaseRequest{
+		RelativePath: data["relativePath"],
+		ServiceName:  data["serviceName"],
+		MethodName:   data["methodName"],
+		ExportFormat: data["exportFormat"],
+		Threshold: &ReportThreshold{
+			MaxErrorRate: parseMetricValue(data["maxErrorRate"]),
+			MaxAvg:       parseMetricValue(data["maxAvg"]),
+			MaxT99:       parseMetricValue(data["maxT99"]),
+			MinTps:       parseMetricValue(data["minTps"]),
+		},
+	}
+	request.ToolboxId, _ = strconv.ParseInt(data["toolboxId"], 10, 64)
+	if data["taskKeys"] != "" {
+		request.TaskKeys = strings.Split(data["taskKeys"], ",")
+	}
+
+	taskList, err := this_.loadCompareTasks(request)
+	if err != nil {
+		return
+	}
+	if len(taskList) == 0 {
+		err = errors.New("task not found")
+		return
+	}
+	items := compareReports(taskList, request.Threshold)
 
-func addTask(task *task.Task) {
-	taskLocker.Lock()
-	defer taskLocker.Unlock()
+	var bs []byte
+	var fileName = request.ServiceName + "." + request.MethodName + "-测试报告"
+	var contentType string
+	switch request.ExportFormat {
+	case "csv":
+		bs, err = toCSV(items)
+		fileName += ".csv"
+		contentType = "text/csv; charset=utf-8"
+	case "junit":
+		bs, err = toJUnit(items, request.Threshold)
+		fileName += ".xml"
+		contentType = "application/xml; charset=utf-8"
+	default:
+		// 页面展示使用第一个任务的请求配置
+		taskRequest := &BaseRequest{}
+		bs, _ = json.Marshal(taskList[0]["request"])
+		_ = json.Unmarshal(bs, taskRequest)
+		bs, err = toHTML(taskRequest, items)
+		fileName += ".html"
+		contentType = "text/html; charset=utf-8"
+	}
+	if err != nil {
+		return
+	}
 
-	taskCache[task.Key] = task
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=utf-8''%s", url.QueryEscape(fileName)))
+	c.Header("download-file-name", fileName)
+	c.Status(http.StatusOK)
+	_, err = c.Writer.Write(bs)
+	return
 }
 
-func removeTask(taskKey string) {
-	taskLocker.Lock()
-	defer taskLocker.Unlock()
+func (this_ *api) invokeReportDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 
-	delete(taskCache, taskKey)
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	err = this_.report.DeleteTask(this_.getTaskParentDirRelativePath(request), request.TaskKey)
+	return
 }
 
 func (this_ *api) invokeStop(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
@@ -586,11 +616,7 @@ func (this_ *api) invokeStop(requestBean *base.RequestBean, c *gin.Context) (res
 		return
 	}
 
-	t := getTask(request.TaskKey)
-
-	if t != nil {
-		t.Stop()
-	}
+	this_.report.StopTask(request.TaskKey)
 	return
 }
 
@@ -601,12 +627,12 @@ func (this_ *api) invokeInfo(requestBean *base.RequestBean, c *gin.Context) (res
 		return
 	}
 
-	taskParentDir, err := this_.getTaskParentDir(request)
+	taskParentDir, err := this_.report.GetTaskParentDir(this_.getTaskParentDirRelativePath(request))
 	if err != nil {
 		return
 	}
 
-	res, err = this_.loadTask(taskParentDir + request.TaskKey)
+	res, err = this_.report.LoadTask(taskParentDir + request.TaskKey)
 
 	return
 }
@@ -618,37 +644,140 @@ func (this_ *api) invokeMetric(requestBean *base.RequestBean, c *gin.Context) (r
 		return
 	}
 
-	taskParentDir, err := this_.getTaskParentDir(request)
+	taskParentDir, err := this_.report.GetTaskParentDir(this_.getTaskParentDirRelativePath(request))
 	if err != nil {
 		return
 	}
 
 	taskDir := taskParentDir + request.TaskKey
-	var data []*metric.Count
-	var bs []byte
-	if request.Minute {
-		if ex, _ := util.PathExists(taskDir + "/metric.minute.json"); ex {
-			if bs, err = os.ReadFile(taskDir + "/metric.minute.json"); err != nil {
-				return
-			}
-			err = util.JSONDecodeUseNumber(bs, &data)
-			if err != nil {
-				return
-			}
-		}
-	} else if request.Second {
-		if ex, _ := util.PathExists(taskDir + "/metric.second.json"); ex {
-			if bs, err = os.ReadFile(taskDir + "/metric.second.json"); err != nil {
+	if request.Prometheus {
+		// 服务端采集数据及与客户端每秒统计的对比
+		var prometheusData map[string]interface{}
+		var bs []byte
+		if ex, _ := util.PathExists(taskDir + "/prometheus.json"); ex {
+			if bs, err = os.ReadFile(taskDir + "/prometheus.json"); err != nil {
 				return
 			}
-			err = util.JSONDecodeUseNumber(bs, &data)
+			err = util.JSONDecodeUseNumber(bs, &prometheusData)
 			if err != nil {
 				return
 			}
 		}
+		res = prometheusData
+		return
+	}
+	res, err = this_.report.LoadTaskMetric(taskDir, request.Minute, request.Second)
+	return
+}
+
+func (this_ *api) mockStart(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getOrCreateWorkspace(config)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	err = this_.initTLSConfig(request)
+	if err != nil {
+		return
+	}
+	address, err := checkMockAddress(request.MockAddress, this_.toolboxService.Setting.MockServerBindAnyEnable)
+	if err != nil {
+		return
 	}
-	res = data
 
+	server := &mockServer{
+		ToolboxId:    request.ToolboxId,
+		RelativePath: request.RelativePath,
+		ServiceName:  request.ServiceName,
+		Address:      address,
+		Transport:    request.Transport,
+		Methods:      request.MockMethods,
+		request:      request,
+		workspace:    service,
+		filename:     util.FormatPath(service.GetFormatDir() + "/" + request.RelativePath),
+	}
+	err = startMockServer(server)
+	if err != nil {
+		return
+	}
+	res = server.getInfo()
+	return
+}
+
+// getMockRequest 校验工具归属，Mock服务只能在所属工具下操作
+func (this_ *api) getMockRequest(requestBean *base.RequestBean, c *gin.Context) (request *BaseRequest, err error) {
+	_, err = this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	request = &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		request = nil
+		return
+	}
+	return
+}
+
+func (this_ *api) mockStop(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request, err := this_.getMockRequest(requestBean, c)
+	if err != nil || request == nil {
+		return
+	}
+
+	stopMockServer(request.ToolboxId, request.MockId)
+	return
+}
+
+func (this_ *api) mockUpdate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request, err := this_.getMockRequest(requestBean, c)
+	if err != nil || request == nil {
+		return
+	}
+
+	server := getMockServer(request.ToolboxId, request.MockId)
+	if server == nil {
+		err = errors.New("mock server [" + request.MockId + "] not found")
+		return
+	}
+	server.setMethods(request.MockMethods)
+	res = server.getInfo()
+	return
+}
+
+func (this_ *api) mockList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request, err := this_.getMockRequest(requestBean, c)
+	if err != nil || request == nil {
+		return
+	}
+
+	res = getMockServers(request.ToolboxId)
+	return
+}
+
+func (this_ *api) mockCalls(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request, err := this_.getMockRequest(requestBean, c)
+	if err != nil || request == nil {
+		return
+	}
+
+	server := getMockServer(request.ToolboxId, request.MockId)
+	if server == nil {
+		err = errors.New("mock server [" + request.MockId + "] not found")
+		return
+	}
+	if request.CleanCalls {
+		server.cleanCalls()
+		return
+	}
+	res = server.getCalls()
 	return
 }
 
@@ -658,6 +787,12 @@ func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res inte
 		return
 	}
 
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	stopMockServers(request.ToolboxId)
 	removeWorkspace(config.ThriftDir)
 	return
 }
@@ -23,6 +23,8 @@ type invokeExecutor struct {
 	taskDir          string
 	paramList        []*thrift.MethodParam
 	paramListLock    sync.Mutex
+	profile          *loadProfile
+	prometheus       *prometheusDataCollect
 }
 
 func (this_ *invokeExecutor) getAndCleanParamList() (paramList []*thrift.MethodParam) {
@@ -42,6 +44,10 @@ func (this_ *invokeExecutor) addParam(param *thrift.MethodParam) {
 }
 
 func (this_ *invokeExecutor) stop() {
+	if this_.prometheus != nil {
+		this_.prometheus.stop()
+	}
+
 	this_.workerClientLock.Lock()
 	defer this_.workerClientLock.Unlock()
 
@@ -52,47 +58,77 @@ func (this_ *invokeExecutor) stop() {
 }
 
 func NewClient(request *BaseRequest) (client *thrift.ServiceClient, err error) {
-	var protocolFactory go_thrift.TProtocolFactory
-
-	switch request.ProtocolFactory {
-	case "compact":
-		protocolFactory = go_thrift.NewTCompactProtocolFactoryConf(nil)
-	case "simpleJSON":
-		protocolFactory = go_thrift.NewTSimpleJSONProtocolFactoryConf(nil)
-	case "json":
-		protocolFactory = go_thrift.NewTJSONProtocolFactory()
-	case "binary":
-		protocolFactory = go_thrift.NewTBinaryProtocolFactoryConf(nil)
-	default:
-		protocolFactory = go_thrift.NewTBinaryProtocolFactoryConf(nil)
+	conf := &go_thrift.TConfiguration{
+		ConnectTimeout: time.Millisecond * time.Duration(request.Timeout),
+		SocketTimeout:  time.Millisecond * time.Duration(request.Timeout),
 	}
 
-	var transportFactory go_thrift.TTransportFactory
-	if request.Buffered {
-		transportFactory = go_thrift.NewTBufferedTransportFactory(8192)
-	} else {
-		transportFactory = go_thrift.NewTTransportFactory()
-	}
+	protocolFactory := newProtocolFactory(request, conf)
+	transportFactory := newTransportFactory(request, conf)
 
-	if request.Framed {
-		transportFactory = go_thrift.NewTFramedTransportFactoryConf(transportFactory, nil)
+	transport, err := newTransport(request, conf)
+	if err != nil {
+		return
 	}
 
-	transport := go_thrift.NewTSocketConf(request.ServerAddress, nil)
-	_ = transport.SetConnTimeout(time.Millisecond * time.Duration(request.Timeout))
-	_ = transport.SetSocketTimeout(time.Millisecond * time.Duration(request.Timeout))
-
 	if err = transport.Open(); err != nil {
-		err = errors.New("opening socket to " + request.ServerAddress + " error:" + err.Error())
+		err = errors.New("opening transport to " + request.ServerAddress + " error:" + err.Error())
 		return
 	}
 	var useTransport go_thrift.TTransport
 	useTransport, err = transportFactory.GetTransport(transport)
 	if err != nil {
+		_ = transport.Close()
 		err = errors.New("transportFactory.GetTransport error:" + err.Error())
 		return
 	}
-	client = thrift.NewServiceClientFactory(useTransport, protocolFactory)
+	if request.MultiplexedServiceName == "" {
+		client = thrift.NewServiceClientFactory(useTransport, protocolFactory)
+		return
+	}
+	// 多路复用服务只需包装写协议，方法名前加上 服务名:
+	inProtocol := protocolFactory.GetProtocol(useTransport)
+	var outProtocol go_thrift.TProtocol = go_thrift.NewTMultiplexedProtocol(inProtocol, request.MultiplexedServiceName)
+	if headerProtocol, ok := inProtocol.(*go_thrift.THeaderProtocol); ok {
+		outProtocol = &multiplexedHeaderProtocol{
+			TProtocol:      outProtocol,
+			headerProtocol: headerProtocol,
+		}
+	}
+	client = thrift.NewServiceClientProtocol(useTransport, inProtocol, outProtocol)
+	return
+}
+
+// multiplexedHeaderProtocol 多路复用包装 header 协议后，客户端无法识别 header 协议，请求头不会写入，
+// 这里在写入消息前将上下文中的请求头设置到内部的 header 协议
+type multiplexedHeaderProtocol struct {
+	go_thrift.TProtocol
+	headerProtocol *go_thrift.THeaderProtocol
+}
+
+func (this_ *multiplexedHeaderProtocol) WriteMessageBegin(ctx context.Context, name string, typeId go_thrift.TMessageType, seqId int32) (err error) {
+	this_.headerProtocol.ClearWriteHeaders()
+	for _, key := range go_thrift.GetWriteHeaderList(ctx) {
+		if value, ok := go_thrift.GetHeader(ctx, key); ok {
+			this_.headerProtocol.SetWriteHeader(key, value)
+		}
+	}
+	err = this_.TProtocol.WriteMessageBegin(ctx, name, typeId, seqId)
+	return
+}
+
+// sendContext 调用上下文，header 协议时将请求头写入上下文随请求发送
+func sendContext(request *BaseRequest) (ctx context.Context) {
+	ctx = context.Background()
+	if request.ProtocolFactory != "header" || len(request.Headers) == 0 {
+		return
+	}
+	var keys []string
+	for key, value := range request.Headers {
+		ctx = go_thrift.SetHeader(ctx, key, value)
+		keys = append(keys, key)
+	}
+	ctx = go_thrift.SetWriteHeaderList(ctx, keys)
 	return
 }
 
@@ -217,6 +253,11 @@ func formatArgs(args []interface{}, param *task.ExecutorParam) (res []interface{
 	return
 }
 func (this_ *invokeExecutor) Before(param *task.ExecutorParam) (err error) {
+	err = this_.profile.wait(param.WorkerIndex)
+	if err != nil {
+		return
+	}
+
 	args, err := formatArgs(this_.args, param)
 	if err != nil {
 		return
@@ -247,10 +288,11 @@ func (this_ *invokeExecutor) Execute(param *task.ExecutorParam) (err error) {
 	}
 	//util.Logger.Info("test Execute", zap.Any("param", param))
 
-	_, err = client.Send(context.Background(), methodParam)
+	_, err = client.Send(sendContext(this_.BaseRequest), methodParam)
 	if err != nil {
 		methodParam.Error = err.Error()
 	}
+	this_.profile.record(param.WorkerIndex, param.ExecuteStartTime, time.Now(), err)
 	this_.addParam(methodParam)
 	return
 }
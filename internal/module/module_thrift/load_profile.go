@@ -0,0 +1,232 @@
+package module_thrift
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/metric"
+	"github.com/team-ide/go-tool/task"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// errLoadProfileEnd 任务停止或阶段结束时结束等待中的执行，不是调用失败，不计入任务执行统计
+	errLoadProfileEnd         = errors.New("load profile end")
+	loadProfileIdleWaitPeriod = time.Millisecond * 100
+)
+
+// LoadStage 压测阶段，按顺序执行，如 50 QPS 执行 1 分钟后 200 QPS 执行 5 分钟
+type LoadStage struct {
+	Duration int     `json:"duration"` // 阶段时长 秒
+	Qps      float64 `json:"qps"`      // 每秒请求数，小于等于0不限制
+	Worker   int     `json:"worker"`   // 阶段并发数，小于等于0使用全部线程
+}
+
+type StageCount struct {
+	Index     int           `json:"index"`
+	Duration  int           `json:"duration"`
+	Qps       float64       `json:"qps"`
+	Worker    int           `json:"worker"`
+	StartTime int64         `json:"startTime"`
+	EndTime   int64         `json:"endTime"`
+	Metric    *metric.Count `json:"metric"`
+}
+
+// loadProfile 压测负载控制，线程逐步启动、恒定 QPS 限流和分阶段执行
+type loadProfile struct {
+	rampUp    time.Duration
+	qps       float64
+	stages    []*LoadStage
+	worker    int
+	startOnce sync.Once
+	startTime time.Time
+	task      *task.Task
+
+	limiterLock sync.Mutex
+	limiterQps  float64
+	limiterNext time.Time
+
+	stageMetrics []*metric.Metric
+
+	// endCount 因 errLoadProfileEnd 结束的执行次数
+	endCount int64
+}
+
+func newLoadProfile(request *BaseRequest) (profile *loadProfile, err error) {
+	profile = &loadProfile{
+		rampUp: time.Second * time.Duration(request.RampUp),
+		qps:    request.Qps,
+		stages: request.Stages,
+		worker: request.Worker,
+	}
+	for _, stage := range profile.stages {
+		if stage == nil || stage.Duration <= 0 {
+			err = errors.New("stage duration must be greater than 0")
+			return
+		}
+		if stage.Worker > profile.worker {
+			profile.worker = stage.Worker
+		}
+		profile.stageMetrics = append(profile.stageMetrics, &metric.Metric{})
+	}
+	return
+}
+
+// hasStages 分阶段执行时由阶段总时长控制任务结束
+func (this_ *loadProfile) hasStages() bool {
+	return len(this_.stages) > 0
+}
+
+// getTaskWorkerAndDuration 任务线程数取各阶段最大值，时长按分钟向上取整，阶段结束后主动停止任务
+func (this_ *loadProfile) getTaskWorkerAndDuration() (worker int, duration int) {
+	worker = this_.worker
+	var seconds int
+	for _, stage := range this_.stages {
+		seconds += stage.Duration
+	}
+	duration = (seconds + 59) / 60
+	return
+}
+
+func (this_ *loadProfile) start() {
+	this_.startOnce.Do(func() {
+		this_.startTime = time.Now()
+	})
+}
+
+// getStage 当前所处阶段，超出所有阶段返回 -1
+func (this_ *loadProfile) getStage(now time.Time) (index int, stage *LoadStage) {
+	elapsed := now.Sub(this_.startTime)
+	var end time.Duration
+	for i, one := range this_.stages {
+		end += time.Second * time.Duration(one.Duration)
+		if elapsed < end {
+			return i, one
+		}
+	}
+	return -1, nil
+}
+
+func (this_ *loadProfile) isStopped() bool {
+	return this_.task != nil && this_.task.IsStopped()
+}
+
+// wait 执行前等待，直到当前线程可执行且获得限流许可
+func (this_ *loadProfile) wait(workerIndex int) (err error) {
+	this_.start()
+	for {
+		if this_.isStopped() {
+			return this_.end()
+		}
+		now := time.Now()
+		qps := this_.qps
+		worker := this_.worker
+		if this_.hasStages() {
+			index, stage := this_.getStage(now)
+			if index < 0 {
+				if this_.task != nil {
+					this_.task.Stop()
+				}
+				return this_.end()
+			}
+			qps = stage.Qps
+			if stage.Worker > 0 {
+				worker = stage.Worker
+			}
+		}
+		if workerIndex < this_.activeWorker(worker, now) {
+			if qps > 0 {
+				this_.acquire(qps)
+			}
+			return
+		}
+		time.Sleep(loadProfileIdleWaitPeriod)
+	}
+}
+
+// end 记录结束的执行，统计时从任务的执行失败数中去除
+func (this_ *loadProfile) end() error {
+	atomic.AddInt64(&this_.endCount, 1)
+	return errLoadProfileEnd
+}
+
+// getTaskInfo 任务信息，去除负载控制结束等待产生的执行和失败次数
+func (this_ *loadProfile) getTaskInfo(t *task.Task) (res *task.Task) {
+	info := *t
+	endCount := int(atomic.LoadInt64(&this_.endCount))
+	info.ExecutorBeforeCount -= endCount
+	info.ExecutorErrorCount -= endCount
+	res = &info
+	return
+}
+
+// activeWorker 线程逐步启动，RampUp 时长内线性增加到 worker
+func (this_ *loadProfile) activeWorker(worker int, now time.Time) int {
+	if this_.rampUp <= 0 {
+		return worker
+	}
+	elapsed := now.Sub(this_.startTime)
+	if elapsed >= this_.rampUp {
+		return worker
+	}
+	active := int(float64(worker)*float64(elapsed)/float64(this_.rampUp)) + 1
+	if active > worker {
+		active = worker
+	}
+	return active
+}
+
+// acquire 按 qps 为每次请求分配执行时间点，所有线程共享，与线程数无关
+func (this_ *loadProfile) acquire(qps float64) {
+	interval := time.Duration(float64(time.Second) / qps)
+
+	this_.limiterLock.Lock()
+	now := time.Now()
+	if this_.limiterQps != qps || this_.limiterNext.Before(now) {
+		this_.limiterQps = qps
+		this_.limiterNext = now
+	}
+	slot := this_.limiterNext
+	this_.limiterNext = slot.Add(interval)
+	this_.limiterLock.Unlock()
+
+	if wait := slot.Sub(now); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// record 记录阶段统计
+func (this_ *loadProfile) record(workerIndex int, startTime time.Time, endTime time.Time, err error) {
+	if !this_.hasStages() {
+		return
+	}
+	index, _ := this_.getStage(startTime)
+	if index < 0 {
+		return
+	}
+	item := this_.stageMetrics[index].NewItem(workerIndex, startTime)
+	item.End(endTime, err)
+}
+
+func (this_ *loadProfile) getStageCounts() (counts []*StageCount) {
+	if !this_.hasStages() || this_.startTime.IsZero() {
+		return
+	}
+	stageStart := this_.startTime
+	for i, stage := range this_.stages {
+		stageEnd := stageStart.Add(time.Second * time.Duration(stage.Duration))
+		count := &StageCount{
+			Index:     i,
+			Duration:  stage.Duration,
+			Qps:       stage.Qps,
+			Worker:    stage.Worker,
+			StartTime: stageStart.UnixMilli(),
+			EndTime:   stageEnd.UnixMilli(),
+			Metric:    this_.stageMetrics[i].Count(),
+		}
+		count.Metric.TopItems = []*metric.Item{}
+		counts = append(counts, count)
+		stageStart = stageEnd
+	}
+	return
+}
@@ -5,32 +5,12 @@ import (
 	"fmt"
 	"github.com/team-ide/go-tool/metric"
 	"github.com/team-ide/go-tool/util"
+	"teamide/internal/module/module_toolbox"
 	"time"
 )
 
 func toMarkdown(taskList []map[string]interface{}) (content string) {
-
-	var groupList []*[]map[string]interface{}
-	groupCache := map[string]*[]map[string]interface{}{}
-	for _, one := range taskList {
-		if one["requestMd5"] == nil {
-			continue
-		}
-		requestMd5 := one["requestMd5"].(string)
-		group := groupCache[requestMd5]
-		if group == nil {
-			group = &[]map[string]interface{}{}
-			groupCache[requestMd5] = group
-			groupList = append(groupList, group)
-		}
-		*group = append(*group, one)
-	}
-
-	content += fmt.Sprintf("# 测试结果  \n\n")
-	for index, group := range groupList {
-
-		content += groupToMarkdown(index, *group)
-	}
+	content = module_toolbox.LoadTaskToMarkdown(taskList, groupToMarkdown)
 	return
 }
 
@@ -52,11 +32,21 @@ func groupToMarkdown(index int, group []map[string]interface{}) (content string)
 
 	content += fmt.Sprintf("#### 测试信息  \n\n")
 	content += fmt.Sprintf("* 线程数：%d  \n", request.Worker)
-	if request.Frequency > 0 {
+	if len(request.Stages) > 0 {
+		for i, stage := range request.Stages {
+			content += fmt.Sprintf("* 阶段-%d：%d秒 QPS：%s 线程数：%d  \n", i+1, stage.Duration, qpsText(stage.Qps), stage.Worker)
+		}
+	} else if request.Frequency > 0 {
 		content += fmt.Sprintf("* 执行次数：%d  \n", request.Frequency)
 	} else {
 		content += fmt.Sprintf("* 执行时长：%d  \n", request.Duration)
 	}
+	if request.RampUp > 0 {
+		content += fmt.Sprintf("* 线程启动时长：%d秒  \n", request.RampUp)
+	}
+	if request.Qps > 0 && len(request.Stages) == 0 {
+		content += fmt.Sprintf("* QPS限制：%s  \n", qpsText(request.Qps))
+	}
 	content += fmt.Sprintf("* 测试地址：%s  \n", request.ServerAddress)
 	content += fmt.Sprintf("* 超时时长：%d  \n", request.Timeout)
 	content += fmt.Sprintf("* ProtocolFactory类型：%s  \n", request.ProtocolFactory)
@@ -73,24 +63,51 @@ func groupToMarkdown(index int, group []map[string]interface{}) (content string)
 	}
 
 	content += fmt.Sprintf("\n\n")
-	content += fmt.Sprintf("#### 测试记录  \n\n")
+	content += module_toolbox.LoadTaskMetricToMarkdown(group)
 
-	content += fmt.Sprintf("| 任务时间 | 总/成功/失败 |执行用时|累计用时 |TPS |Avg |Min |Max |T50 |T80 | T90 | T99 |  \n")
-	content += fmt.Sprintf("| :------: | :------: |:------: |:------:|:------: |:------: |:------: |:------: |:------: |:------: | :------: | :------: |  \n")
+	for i, task := range group {
+		if task["prometheus"] == nil {
+			continue
+		}
+		bs, _ = json.Marshal(task["prometheus"])
+		report := &prometheusReport{}
+		_ = json.Unmarshal(bs, report)
+		content += prometheusToMarkdown(i, request, report)
+	}
 
-	for _, task := range group {
-		bs, _ = json.Marshal(task["metric"])
-		count := &metric.Count{}
-		_ = json.Unmarshal(bs, count)
+	for i, task := range group {
+		if task["stages"] == nil {
+			continue
+		}
+		bs, _ = json.Marshal(task["stages"])
+		var stageCounts []*StageCount
+		_ = json.Unmarshal(bs, &stageCounts)
+		if len(stageCounts) == 0 {
+			continue
+		}
+		content += stagesToMarkdown(i, stageCounts)
+	}
+	return
+}
 
+func stagesToMarkdown(index int, stageCounts []*StageCount) (content string) {
+	content += fmt.Sprintf("#### 记录-%d 阶段统计  \n\n", index+1)
+
+	content += fmt.Sprintf("| 阶段 | 阶段时间 | 目标QPS/线程数 | 总/成功/失败 |TPS |Avg |Min |Max |T50 |T80 | T90 | T99 |  \n")
+	content += fmt.Sprintf("| :------: | :------: | :------: | :------: |:------: |:------: |:------: |:------: |:------: |:------: | :------: | :------: |  \n")
+	for _, stage := range stageCounts {
+		count := stage.Metric
+		if count == nil {
+			count = &metric.Count{}
+		}
 		content += fmt.Sprintf("|")
+		content += fmt.Sprintf(" %d |", stage.Index+1)
 		content += fmt.Sprintf(" %s <br>-<br> %s |",
-			util.TimeFormat(time.UnixMilli(count.StartTime/int64(time.Millisecond)), "2006-01-02 15:04:05.000"),
-			util.TimeFormat(time.UnixMilli(count.EndTime/int64(time.Millisecond)), "2006-01-02 15:04:05.000"),
+			util.TimeFormat(time.UnixMilli(stage.StartTime), "2006-01-02 15:04:05"),
+			util.TimeFormat(time.UnixMilli(stage.EndTime), "2006-01-02 15:04:05"),
 		)
+		content += fmt.Sprintf(" %s <br> %d |", qpsText(stage.Qps), stage.Worker)
 		content += fmt.Sprintf(" %d <br> <font color='green'>%d</font> <br> <font color='red'>%d</font> |", count.Count, count.SuccessCount, count.ErrorCount)
-		content += fmt.Sprintf(" %s |", toTime(count.TotalTime/1000000))
-		content += fmt.Sprintf(" %s |", toTime(count.UseTime/1000000))
 		content += fmt.Sprintf(" %s |", count.Tps)
 		content += fmt.Sprintf(" %s |", count.Avg)
 		content += fmt.Sprintf(" %s |", count.Min)
@@ -105,34 +122,21 @@ func groupToMarkdown(index int, group []map[string]interface{}) (content string)
 	return
 }
 
-type tS struct {
-	Size float64
-	Unit string
+func prometheusToMarkdown(index int, request *BaseRequest, report *prometheusReport) (content string) {
+	content += fmt.Sprintf("#### 记录-%d 服务端指标  \n\n", index+1)
+	content += fmt.Sprintf("* 指标地址：%s  \n", request.PrometheusMetricsAddress)
+	content += fmt.Sprintf("* 服务端次数：%.0f  \n", report.ServerCount)
+	content += fmt.Sprintf("* 服务端平均耗时：%.2f毫秒  \n", report.ServerAvg)
+	content += fmt.Sprintf("* 客户端次数：%d  \n", report.ClientCount)
+	content += fmt.Sprintf("* 客户端平均耗时：%.2f毫秒  \n", report.ClientAvg)
+	content += fmt.Sprintf("* 平均额外耗时（客户端-服务端）：%.2f毫秒  \n", report.OverheadAvg)
+	content += fmt.Sprintf("\n\n")
+	return
 }
 
-var (
-	tList = []*tS{
-		{Size: 1000 * 60 * 60 * 24, Unit: "天"},
-		{Size: 1000 * 60 * 60, Unit: "时"},
-		{Size: 1000 * 60, Unit: "分"},
-		{Size: 1000, Unit: "秒"},
+func qpsText(qps float64) string {
+	if qps <= 0 {
+		return "不限"
 	}
-)
-
-func toTime(size int64) (v string) {
-
-	var timeUnit string
-	var timeV = float64(size)
-
-	for _, s := range tList {
-		if timeUnit == "" && timeV >= s.Size {
-			timeV = timeV / s.Size
-			timeUnit = s.Unit
-		}
-	}
-	if timeUnit == "" {
-		timeUnit = "毫秒"
-	}
-	v = fmt.Sprintf("%.2f%s", timeV, timeUnit)
-	return
+	return fmt.Sprintf("%.2f", qps)
 }
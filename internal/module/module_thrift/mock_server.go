@@ -0,0 +1,501 @@
+package module_thrift
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	go_thrift "github.com/apache/thrift/lib/go/thrift"
+	"github.com/team-ide/go-tool/javascript"
+	"github.com/team-ide/go-tool/thrift"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"teamide/internal/module/module_toolbox"
+	"time"
+)
+
+var (
+	mockServerCache     = map[string]*mockServer{}
+	mockServerCacheLock = &sync.Mutex{}
+	mockCallsMaxSize    = 1000
+	// 响应脚本最长执行时间，超时后中断脚本
+	mockScriptTimeout = 10 * time.Second
+)
+
+// MockMethod 方法的模拟响应，未配置的方法返回根据 IDL 生成的示例数据
+type MockMethod struct {
+	MethodName string `json:"methodName"`
+	// json: Response 为静态 JSON；script: Response 为 JavaScript 函数体，可使用 args、method、index，通过 return 返回结果
+	ResponseType string `json:"responseType"`
+	Response     string `json:"response"`
+	// 配置后按该异常字段返回，值为 Response 的结果
+	ExceptionName string `json:"exceptionName,omitempty"`
+	// 响应延迟 毫秒
+	Delay int `json:"delay,omitempty"`
+}
+
+type MockCall struct {
+	Index         int64                  `json:"index"`
+	Time          int64                  `json:"time"`
+	MethodName    string                 `json:"methodName"`
+	SeqId         int32                  `json:"seqId"`
+	Args          map[string]interface{} `json:"args,omitempty"`
+	Result        interface{}            `json:"result,omitempty"`
+	ExceptionName string                 `json:"exceptionName,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	UseTime       int64                  `json:"useTime"`
+}
+
+// MockServerInfo Mock服务信息，由 mockServer 加锁复制
+type MockServerInfo struct {
+	MockId       string        `json:"mockId"`
+	ToolboxId    int64         `json:"toolboxId"`
+	RelativePath string        `json:"relativePath"`
+	ServiceName  string        `json:"serviceName"`
+	Address      string        `json:"address"`
+	Transport    string        `json:"transport"`
+	StartTime    int64         `json:"startTime"`
+	CallCount    int64         `json:"callCount"`
+	Methods      []*MockMethod `json:"methods"`
+}
+
+// mockServer 根据 IDL 中的服务启动本地监听，按配置模拟每个方法的响应
+// CallCount、Methods 在调用过程中修改，读取需要加锁，返回给前端使用 getInfo
+type mockServer struct {
+	MockId       string
+	ToolboxId    int64
+	RelativePath string
+	ServiceName  string
+	Address      string
+	Transport    string
+	StartTime    int64
+	CallCount    int64
+	Methods      []*MockMethod
+
+	request     *BaseRequest
+	workspace   *thrift.Workspace
+	filename    string
+	server      *go_thrift.TSimpleServer
+	httpServer  *http.Server
+	methodCache map[string]*MockMethod
+	calls       []*MockCall
+	lock        sync.Mutex
+}
+
+func startMockServer(server *mockServer) (err error) {
+	if server.workspace.GetService(server.filename, server.ServiceName) == nil {
+		err = errors.New("service [" + server.ServiceName + "] not found in [" + server.RelativePath + "]")
+		return
+	}
+	if server.Address == "" {
+		err = errors.New("mock address is empty")
+		return
+	}
+	server.MockId = util.GetUUID()
+	server.StartTime = util.GetNowMilli()
+	server.setMethods(server.Methods)
+
+	err = server.listen()
+	if err != nil {
+		return
+	}
+
+	mockServerCacheLock.Lock()
+	mockServerCache[server.MockId] = server
+	mockServerCacheLock.Unlock()
+	return
+}
+
+// getMockServer 查询工具下的Mock服务，不属于该工具时返回 nil
+func getMockServer(toolboxId int64, mockId string) *mockServer {
+	mockServerCacheLock.Lock()
+	defer mockServerCacheLock.Unlock()
+
+	server := mockServerCache[mockId]
+	if server == nil || server.ToolboxId != toolboxId {
+		return nil
+	}
+	return server
+}
+
+func getMockServers(toolboxId int64) (list []*MockServerInfo) {
+	mockServerCacheLock.Lock()
+	defer mockServerCacheLock.Unlock()
+
+	for _, one := range mockServerCache {
+		if one.ToolboxId == toolboxId {
+			list = append(list, one.getInfo())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartTime < list[j].StartTime
+	})
+	return
+}
+
+func stopMockServer(toolboxId int64, mockId string) {
+	mockServerCacheLock.Lock()
+	server := mockServerCache[mockId]
+	if server == nil || server.ToolboxId != toolboxId {
+		mockServerCacheLock.Unlock()
+		return
+	}
+	delete(mockServerCache, mockId)
+	mockServerCacheLock.Unlock()
+
+	server.stop()
+}
+
+// stopMockServers 停止工具下的全部Mock服务，工具关闭时调用
+func stopMockServers(toolboxId int64) {
+	mockServerCacheLock.Lock()
+	var list []*mockServer
+	for mockId, one := range mockServerCache {
+		if one.ToolboxId == toolboxId {
+			list = append(list, one)
+			delete(mockServerCache, mockId)
+		}
+	}
+	mockServerCacheLock.Unlock()
+
+	for _, one := range list {
+		one.stop()
+	}
+}
+
+// checkMockAddress 未开启监听非本机地址时，只能监听本机地址，只有端口时监听 127.0.0.1
+func checkMockAddress(address string, bindAnyEnable bool) (res string, err error) {
+	res = strings.TrimSpace(address)
+	if bindAnyEnable {
+		return
+	}
+	host, port, e := net.SplitHostPort(res)
+	if e != nil {
+		err = errors.New("mock address [" + address + "] error:" + e.Error())
+		return
+	}
+	if host == "" {
+		res = net.JoinHostPort("127.0.0.1", port)
+		return
+	}
+	if host == "localhost" {
+		return
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return
+	}
+	err = errors.New("mock address [" + address + "] is not a loopback address, please contact the administrator to enable mockServerBindAnyEnable")
+	return
+}
+
+func (this_ *mockServer) listen() (err error) {
+	conf := &go_thrift.TConfiguration{}
+	protocolFactory := newProtocolFactory(this_.request, conf)
+	transportFactory := newTransportFactory(this_.request, conf)
+
+	var processor go_thrift.TProcessor = this_
+	if this_.request.MultiplexedServiceName != "" {
+		multiplexedProcessor := go_thrift.NewTMultiplexedProcessor()
+		multiplexedProcessor.RegisterProcessor(this_.request.MultiplexedServiceName, this_)
+		processor = multiplexedProcessor
+	}
+
+	switch this_.Transport {
+	case "", "socket", "tls":
+		var serverTransport go_thrift.TServerTransport
+		if this_.Transport == "tls" {
+			var tlsConfig *tls.Config
+			tlsConfig, err = newMockTLSConfig(this_.request)
+			if err != nil {
+				return
+			}
+			serverTransport, err = go_thrift.NewTSSLServerSocket(this_.Address, tlsConfig)
+		} else {
+			serverTransport, err = go_thrift.NewTServerSocket(this_.Address)
+		}
+		if err != nil {
+			return
+		}
+		this_.server = go_thrift.NewTSimpleServer4(processor, serverTransport, transportFactory, protocolFactory)
+		if err = this_.server.Listen(); err != nil {
+			err = errors.New("mock listen " + this_.Address + " error:" + err.Error())
+			return
+		}
+		go func() {
+			if e := this_.server.AcceptLoop(); e != nil {
+				util.Logger.Error("thrift mock server accept error", zap.Any("address", this_.Address), zap.Error(e))
+			}
+		}()
+	case "http":
+		var listener net.Listener
+		listener, err = net.Listen("tcp", this_.Address)
+		if err != nil {
+			err = errors.New("mock listen " + this_.Address + " error:" + err.Error())
+			return
+		}
+		this_.httpServer = &http.Server{
+			Handler: http.HandlerFunc(go_thrift.NewThriftHandlerFunc(processor, protocolFactory, protocolFactory)),
+		}
+		go func() {
+			if e := this_.httpServer.Serve(listener); e != nil && e != http.ErrServerClosed {
+				util.Logger.Error("thrift mock http server error", zap.Any("address", this_.Address), zap.Error(e))
+			}
+		}()
+	default:
+		err = errors.New("mock transport [" + this_.Transport + "] not support")
+	}
+	return
+}
+
+// newMockTLSConfig 服务端证书使用 TLSClientCert 和 TLSClientKey，配置 CA 时校验客户端证书
+func newMockTLSConfig(request *BaseRequest) (tlsConfig *tls.Config, err error) {
+	if request.TLSClientCert == "" || request.TLSClientKey == "" {
+		err = errors.New("mock tls server cert and key must be set")
+		return
+	}
+	if request.tlsConfig == nil {
+		err = errors.New("tls config not init")
+		return
+	}
+	tlsConfig = request.tlsConfig.Clone()
+	if tlsConfig.RootCAs != nil {
+		tlsConfig.ClientCAs = tlsConfig.RootCAs
+		tlsConfig.RootCAs = nil
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return
+}
+
+func (this_ *mockServer) stop() {
+	if this_.server != nil {
+		// Stop 先关闭监听，再等待已建立的连接由客户端关闭，不阻塞调用方
+		go func() {
+			_ = this_.server.Stop()
+		}()
+	}
+	if this_.httpServer != nil {
+		_ = this_.httpServer.Close()
+	}
+}
+
+func (this_ *mockServer) getInfo() (res *MockServerInfo) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	res = &MockServerInfo{
+		MockId:       this_.MockId,
+		ToolboxId:    this_.ToolboxId,
+		RelativePath: this_.RelativePath,
+		ServiceName:  this_.ServiceName,
+		Address:      this_.Address,
+		Transport:    this_.Transport,
+		StartTime:    this_.StartTime,
+		CallCount:    this_.CallCount,
+		Methods:      append([]*MockMethod{}, this_.Methods...),
+	}
+	return
+}
+
+func (this_ *mockServer) setMethods(methods []*MockMethod) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.Methods = methods
+	this_.methodCache = map[string]*MockMethod{}
+	for _, one := range methods {
+		if one != nil && one.MethodName != "" {
+			this_.methodCache[one.MethodName] = one
+		}
+	}
+}
+
+func (this_ *mockServer) getMethod(name string) *MockMethod {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	return this_.methodCache[name]
+}
+
+// nextIndex 收到调用时分配序号，脚本中的 index 为该序号
+func (this_ *mockServer) nextIndex() int64 {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.CallCount++
+	return this_.CallCount
+}
+
+func (this_ *mockServer) addCall(call *MockCall) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.calls = append(this_.calls, call)
+	if len(this_.calls) > mockCallsMaxSize {
+		this_.calls = this_.calls[len(this_.calls)-mockCallsMaxSize:]
+	}
+}
+
+// getCalls 最近收到的调用，倒序
+func (this_ *mockServer) getCalls() (calls []*MockCall) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	for i := len(this_.calls) - 1; i >= 0; i-- {
+		calls = append(calls, this_.calls[i])
+	}
+	return
+}
+
+func (this_ *mockServer) cleanCalls() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.calls = []*MockCall{}
+}
+
+func (this_ *mockServer) ProcessorMap() map[string]go_thrift.TProcessorFunction {
+	return map[string]go_thrift.TProcessorFunction{}
+}
+
+func (this_ *mockServer) AddToProcessorMap(string, go_thrift.TProcessorFunction) {
+}
+
+func (this_ *mockServer) Process(ctx context.Context, in, out go_thrift.TProtocol) (ok bool, exception go_thrift.TException) {
+	name, _, seqId, err := in.ReadMessageBegin(ctx)
+	if err != nil {
+		return false, go_thrift.WrapTException(err)
+	}
+
+	startTime := time.Now()
+	call := &MockCall{
+		Index:      this_.nextIndex(),
+		Time:       util.GetNowMilli(),
+		MethodName: name,
+		SeqId:      seqId,
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			call.Error = fmt.Sprint(e)
+			util.Logger.Error("thrift mock process panic", zap.Any("method", name), zap.Any("error", e))
+			exception = writeMockApplicationException(ctx, out, name, seqId, go_thrift.INTERNAL_ERROR, call.Error)
+			ok = false
+		}
+		call.UseTime = time.Since(startTime).Milliseconds()
+		this_.addCall(call)
+	}()
+
+	methodNode := this_.workspace.GetServiceMethod(this_.filename, this_.ServiceName, name)
+	if methodNode == nil {
+		_ = in.Skip(ctx, go_thrift.STRUCT)
+		_ = in.ReadMessageEnd(ctx)
+		call.Error = "unknown method " + name
+		return false, writeMockApplicationException(ctx, out, name, seqId, go_thrift.UNKNOWN_METHOD, call.Error)
+	}
+	param, err := this_.workspace.GetMethodParam(this_.filename, this_.ServiceName, name)
+	if err != nil {
+		call.Error = err.Error()
+		return false, go_thrift.WrapTException(err)
+	}
+
+	call.Args, err = thrift.ReadStructFields(ctx, in, param.ArgFields)
+	if err != nil {
+		call.Error = err.Error()
+		return false, go_thrift.WrapTException(err)
+	}
+	if err = in.ReadMessageEnd(ctx); err != nil {
+		call.Error = err.Error()
+		return false, go_thrift.WrapTException(err)
+	}
+
+	method := this_.getMethod(name)
+	call.Result, err = this_.getResult(method, param, call)
+	if err != nil {
+		call.Error = err.Error()
+		return true, writeMockApplicationException(ctx, out, name, seqId, go_thrift.INTERNAL_ERROR, call.Error)
+	}
+	if method != nil && method.Delay > 0 {
+		time.Sleep(time.Millisecond * time.Duration(method.Delay))
+	}
+	if methodNode.Oneway {
+		return true, nil
+	}
+
+	var fields []*thrift.Field
+	value := map[string]interface{}{}
+	if method != nil && method.ExceptionName != "" {
+		for _, one := range param.ExceptionFields {
+			if one.Name == method.ExceptionName {
+				fields = append(fields, one)
+				break
+			}
+		}
+		if len(fields) == 0 {
+			call.Error = "exception [" + method.ExceptionName + "] not found in method " + name
+			return true, writeMockApplicationException(ctx, out, name, seqId, go_thrift.INTERNAL_ERROR, call.Error)
+		}
+		call.ExceptionName = method.ExceptionName
+		value[method.ExceptionName] = call.Result
+	} else if param.ResultType != nil && param.ResultType.TypeId != go_thrift.VOID {
+		fields = append(fields, &thrift.Field{Num: 0, Name: "success", Type: param.ResultType})
+		value["success"] = call.Result
+	}
+
+	if err = out.WriteMessageBegin(ctx, name, go_thrift.REPLY, seqId); err == nil {
+		if err = thrift.WriteStructFields(ctx, out, name+"_result", fields, value); err == nil {
+			if err = out.WriteMessageEnd(ctx); err == nil {
+				err = out.Flush(ctx)
+			}
+		}
+	}
+	if err != nil {
+		call.Error = err.Error()
+		return false, go_thrift.WrapTException(err)
+	}
+	return true, nil
+}
+
+func (this_ *mockServer) getResult(method *MockMethod, param *thrift.MethodParam, call *MockCall) (res interface{}, err error) {
+	if method == nil {
+		if param.ResultType != nil && param.ResultType.TypeId != go_thrift.VOID {
+			res = this_.workspace.GetFieldDemoDataByType(this_.filename, param.ResultType)
+		}
+		return
+	}
+	switch method.ResponseType {
+	case "script":
+		scriptContext := javascript.NewContext()
+		scriptContext["args"] = call.Args
+		scriptContext["method"] = call.MethodName
+		scriptContext["index"] = call.Index
+		res, err = module_toolbox.RunScript(method.Response, scriptContext, mockScriptTimeout)
+		if err != nil {
+			err = errors.New("mock script error:" + err.Error())
+			return
+		}
+	default:
+		if method.Response == "" {
+			return
+		}
+		err = util.JSONDecodeUseNumber([]byte(method.Response), &res)
+		if err != nil {
+			// 非 JSON 内容作为字符串返回
+			res = method.Response
+			err = nil
+		}
+	}
+	return
+}
+
+func writeMockApplicationException(ctx context.Context, out go_thrift.TProtocol, name string, seqId int32, typeId int32, message string) go_thrift.TException {
+	exception := go_thrift.NewTApplicationException(typeId, message)
+	_ = out.WriteMessageBegin(ctx, name, go_thrift.EXCEPTION, seqId)
+	_ = exception.Write(ctx, out)
+	_ = out.WriteMessageEnd(ctx)
+	_ = out.Flush(ctx)
+	return exception
+}
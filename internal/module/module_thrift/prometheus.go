@@ -2,37 +2,130 @@ package module_thrift
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/metric"
+	"github.com/team-ide/go-tool/util"
 	"io"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	prometheusCollectPeriod = time.Second * 5
+	prometheusHttpClient    = &http.Client{Timeout: time.Second * 5}
 )
 
 type prometheusData struct {
-	Time         int64  `json:"time"`
-	Text         string `json:"text"`
-	SummaryCount int64  `json:"summaryCount"`
-	SummarySum   int64  `json:"summarySum"` // 毫秒
+	Time         int64              `json:"time"`
+	SummaryCount float64            `json:"summaryCount"`
+	SummarySum   float64            `json:"summarySum"` // 毫秒
+	Quantiles    map[string]float64 `json:"quantiles,omitempty"`
+	Error        string             `json:"error,omitempty"`
 }
 
 type prometheusDataCollect struct {
 	*BaseRequest
-	data []*prometheusData
+	data     []*prometheusData
+	dataLock sync.Mutex
+	stopChan chan struct{}
+	stopOnce sync.Once
+	waitDone chan struct{}
+}
+
+func newPrometheusDataCollect(request *BaseRequest) (collect *prometheusDataCollect, err error) {
+	if request.PrometheusSummaryCountMatch == "" || request.PrometheusSummarySumMatch == "" {
+		err = errors.New("prometheus summary count match and sum match must be set")
+		return
+	}
+	if _, err = parsePrometheusSelector(request.PrometheusSummaryCountMatch); err != nil {
+		return
+	}
+	if _, err = parsePrometheusSelector(request.PrometheusSummarySumMatch); err != nil {
+		return
+	}
+	collect = &prometheusDataCollect{
+		BaseRequest: request,
+		stopChan:    make(chan struct{}),
+		waitDone:    make(chan struct{}),
+	}
+	return
 }
 
+// start 测试期间定时采集，stop 后再采集一次作为结束数据
 func (this_ *prometheusDataCollect) start() {
+	go func() {
+		defer close(this_.waitDone)
+
+		this_.collect()
+		ticker := time.NewTicker(prometheusCollectPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-this_.stopChan:
+				this_.collect()
+				return
+			case <-ticker.C:
+				this_.collect()
+			}
+		}
+	}()
+}
+
+func (this_ *prometheusDataCollect) stop() {
+	this_.stopOnce.Do(func() {
+		close(this_.stopChan)
+	})
+	<-this_.waitDone
+}
+
+func (this_ *prometheusDataCollect) getData() (data []*prometheusData) {
+	this_.dataLock.Lock()
+	defer this_.dataLock.Unlock()
 
+	data = append(data, this_.data...)
+	return
+}
+
+func (this_ *prometheusDataCollect) getMetricsUrl() string {
+	address := this_.PrometheusMetricsAddress
+	if strings.HasPrefix(address, "http://") || strings.HasPrefix(address, "https://") {
+		return address
+	}
+	scheme := this_.PrometheusMetricsScheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	return scheme + "://" + address
 }
 
 func (this_ *prometheusDataCollect) collect() {
+	one := &prometheusData{
+		Time: util.GetNowMilli(),
+	}
+	err := this_.collectTo(one)
+	if err != nil {
+		one.Error = err.Error()
+	}
 
-	//nowTime := util.GetNow()
+	this_.dataLock.Lock()
+	defer this_.dataLock.Unlock()
+	this_.data = append(this_.data, one)
+}
 
-	res, err := http.Get(this_.PrometheusMetricsAddress)
+func (this_ *prometheusDataCollect) collectTo(one *prometheusData) (err error) {
+	res, err := prometheusHttpClient.Get(this_.getMetricsUrl())
 	if err != nil {
 		return
 	}
 	defer func() { _ = res.Body.Close() }()
 	if res.StatusCode != 200 {
+		err = errors.New("prometheus metrics status " + res.Status)
 		return
 	}
 
@@ -40,15 +133,417 @@ func (this_ *prometheusDataCollect) collect() {
 	if err != nil {
 		return
 	}
+	families := parsePrometheusText(lines)
+
+	countSelector, _ := parsePrometheusSelector(this_.PrometheusSummaryCountMatch)
+	sumSelector, _ := parsePrometheusSelector(this_.PrometheusSummarySumMatch)
+
+	one.SummaryCount = sumPrometheusSamples(families, countSelector)
+	one.SummarySum = sumPrometheusSamples(families, sumSelector) * prometheusUnitToMilli(this_.PrometheusSumUnit)
+	one.Quantiles = getPrometheusQuantiles(families, countSelector)
+	return
+}
+
+// prometheusUnitToMilli sum 的单位转换为毫秒，默认秒
+func prometheusUnitToMilli(unit string) float64 {
+	switch unit {
+	case "ms":
+		return 1
+	case "us":
+		return 0.001
+	case "ns":
+		return 0.000001
+	default:
+		return 1000
+	}
+}
+
+type prometheusSample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+type prometheusFamily struct {
+	Name    string              `json:"name"`
+	Type    string              `json:"type"`
+	Help    string              `json:"help"`
+	Samples []*prometheusSample `json:"samples"`
+}
 
+// parsePrometheusText 解析 Prometheus 文本格式，summary 和 histogram 的 _sum、_count、_bucket 归入同一个指标族
+func parsePrometheusText(lines []string) (families map[string]*prometheusFamily) {
+	families = map[string]*prometheusFamily{}
+	getFamily := func(name string) *prometheusFamily {
+		family := families[name]
+		if family == nil {
+			family = &prometheusFamily{Name: name, Type: "untyped"}
+			families[name] = family
+		}
+		return family
+	}
 	for _, line := range lines {
-		if strings.HasPrefix(line, this_.PrometheusSummaryCountMatch) {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			ss := strings.Fields(line)
+			if len(ss) < 3 {
+				continue
+			}
+			switch ss[1] {
+			case "TYPE":
+				if len(ss) >= 4 {
+					getFamily(ss[2]).Type = ss[3]
+				}
+			case "HELP":
+				getFamily(ss[2]).Help = strings.TrimSpace(strings.SplitN(line, ss[2], 2)[1])
+			}
+			continue
+		}
+		sample, err := parsePrometheusSample(line, true)
+		if err != nil {
+			continue
+		}
+		familyName := sample.Name
+		for _, suffix := range []string{"_bucket", "_count", "_sum"} {
+			baseName := strings.TrimSuffix(sample.Name, suffix)
+			if baseName == sample.Name {
+				continue
+			}
+			if family := families[baseName]; family != nil && (family.Type == "summary" || family.Type == "histogram") {
+				familyName = baseName
+			}
+			break
+		}
+		family := getFamily(familyName)
+		family.Samples = append(family.Samples, sample)
+	}
+	return
+}
 
-		} else if strings.HasPrefix(line, this_.PrometheusSummarySumMatch) {
+// parsePrometheusSample 解析一行数据，如 name{a="1",b="2"} 1.5 1690000000000
+func parsePrometheusSample(line string, hasValue bool) (sample *prometheusSample, err error) {
+	line = strings.TrimSpace(line)
+	sample = &prometheusSample{Labels: map[string]string{}}
 
+	var rest string
+	index := strings.IndexAny(line, "{ \t")
+	if index < 0 {
+		sample.Name = line
+	} else {
+		sample.Name = line[:index]
+		rest = line[index:]
+	}
+	if sample.Name == "" {
+		err = errors.New("metric name is empty")
+		return
+	}
+	if strings.HasPrefix(rest, "{") {
+		rest, err = parsePrometheusLabels(rest[1:], sample.Labels)
+		if err != nil {
+			return
 		}
 	}
+	if !hasValue {
+		return
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		err = errors.New("metric [" + sample.Name + "] value is empty")
+		return
+	}
+	sample.Value, err = parsePrometheusValue(fields[0])
+	return
+}
 
+// parsePrometheusLabels 解析标签直到 }，返回剩余内容
+func parsePrometheusLabels(str string, labels map[string]string) (rest string, err error) {
+	for {
+		str = strings.TrimLeft(str, " \t,")
+		if str == "" {
+			err = errors.New("labels not closed")
+			return
+		}
+		if str[0] == '}' {
+			rest = str[1:]
+			return
+		}
+		eq := strings.Index(str, "=")
+		if eq < 0 {
+			err = errors.New("label format error")
+			return
+		}
+		name := strings.TrimSpace(str[:eq])
+		str = strings.TrimLeft(str[eq+1:], " \t")
+		if str == "" || str[0] != '"' {
+			err = errors.New("label [" + name + "] value must be quoted")
+			return
+		}
+		var value strings.Builder
+		var i = 1
+		var closed bool
+		for ; i < len(str); i++ {
+			c := str[i]
+			if c == '\\' && i+1 < len(str) {
+				i++
+				switch str[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(str[i])
+				}
+				continue
+			}
+			if c == '"' {
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			err = errors.New("label [" + name + "] value not closed")
+			return
+		}
+		labels[name] = value.String()
+		str = str[i+1:]
+	}
+}
+
+func parsePrometheusValue(str string) (value float64, err error) {
+	switch str {
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(str, 64)
+}
+
+// parsePrometheusSelector 匹配配置，如 thrift_request_seconds_count{method="get"}，标签为子集匹配
+func parsePrometheusSelector(str string) (selector *prometheusSample, err error) {
+	selector, err = parsePrometheusSample(str, false)
+	if err != nil {
+		err = errors.New("prometheus match [" + str + "] error:" + err.Error())
+		return
+	}
+	return
+}
+
+func (this_ *prometheusSample) match(selector *prometheusSample) bool {
+	if this_.Name != selector.Name {
+		return false
+	}
+	for key, value := range selector.Labels {
+		if this_.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// sumPrometheusSamples 匹配到多条时累加，如多个实例或未指定全部标签
+func sumPrometheusSamples(families map[string]*prometheusFamily, selector *prometheusSample) (value float64) {
+	for _, family := range families {
+		for _, sample := range family.Samples {
+			if sample.match(selector) && !math.IsNaN(sample.Value) {
+				value += sample.Value
+			}
+		}
+	}
+	return
+}
+
+// getPrometheusQuantiles summary 直接读取 quantile，histogram 返回各 le 的累计数量，由相邻两次采集计算区间分位
+func getPrometheusQuantiles(families map[string]*prometheusFamily, countSelector *prometheusSample) (quantiles map[string]float64) {
+	baseName := strings.TrimSuffix(countSelector.Name, "_count")
+	family := families[baseName]
+	if family == nil {
+		return
+	}
+	quantiles = map[string]float64{}
+	for _, sample := range family.Samples {
+		var key string
+		switch {
+		case family.Type == "summary" && sample.Name == baseName:
+			key = "quantile:" + sample.Labels["quantile"]
+		case family.Type == "histogram" && sample.Name == baseName+"_bucket":
+			key = "le:" + sample.Labels["le"]
+		default:
+			continue
+		}
+		if !matchLabelsExcept(sample.Labels, countSelector.Labels) || math.IsNaN(sample.Value) {
+			continue
+		}
+		quantiles[key] += sample.Value
+	}
+	if len(quantiles) == 0 {
+		quantiles = nil
+	}
+	return
+}
+
+func matchLabelsExcept(labels map[string]string, selectorLabels map[string]string) bool {
+	for key, value := range selectorLabels {
+		if key == "quantile" || key == "le" {
+			continue
+		}
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// histogramQuantile 根据区间内各桶增量估算分位值，与 Prometheus histogram_quantile 相同按线性插值
+func histogramQuantile(q float64, before map[string]float64, after map[string]float64) (value float64, ok bool) {
+	type bucket struct {
+		le    float64
+		count float64
+	}
+	var buckets []*bucket
+	for key, count := range after {
+		if !strings.HasPrefix(key, "le:") {
+			continue
+		}
+		le, err := parsePrometheusValue(strings.TrimPrefix(key, "le:"))
+		if err != nil {
+			continue
+		}
+		buckets = append(buckets, &bucket{le: le, count: count - before[key]})
+	}
+	if len(buckets) == 0 {
+		return
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].le < buckets[j].le
+	})
+	total := buckets[len(buckets)-1].count
+	if total <= 0 {
+		return
+	}
+	rank := q * total
+	var lowerLe, lowerCount float64
+	for _, b := range buckets {
+		if b.count >= rank {
+			if math.IsInf(b.le, 1) {
+				return lowerLe, true
+			}
+			if b.count == lowerCount {
+				return b.le, true
+			}
+			return lowerLe + (b.le-lowerLe)*(rank-lowerCount)/(b.count-lowerCount), true
+		}
+		lowerLe, lowerCount = b.le, b.count
+	}
+	return
+}
+
+// prometheusInterval 相邻两次采集之间服务端和客户端的统计
+type prometheusInterval struct {
+	StartTime       int64              `json:"startTime"`
+	EndTime         int64              `json:"endTime"`
+	ServerCount     float64            `json:"serverCount"`
+	ServerAvg       float64            `json:"serverAvg"` // 毫秒
+	ServerQuantiles map[string]float64 `json:"serverQuantiles,omitempty"`
+	ClientCount     int                `json:"clientCount"`
+	ClientAvg       float64            `json:"clientAvg"` // 毫秒
+	// 客户端平均耗时减服务端平均耗时，近似网络和序列化开销
+	OverheadAvg float64 `json:"overheadAvg"`
+}
+
+type prometheusReport struct {
+	Intervals   []*prometheusInterval `json:"intervals,omitempty"`
+	ServerCount float64               `json:"serverCount"`
+	ServerAvg   float64               `json:"serverAvg"`
+	ClientCount int                   `json:"clientCount"`
+	ClientAvg   float64               `json:"clientAvg"`
+	OverheadAvg float64               `json:"overheadAvg"`
+}
+
+// correlatePrometheus 按采集区间汇总客户端每秒统计，和服务端数据对比
+func correlatePrometheus(data []*prometheusData, unitToMilli float64, clientCounts []*metric.Count) (report *prometheusReport) {
+	report = &prometheusReport{}
+	var valid []*prometheusData
+	for _, one := range data {
+		if one.Error == "" {
+			valid = append(valid, one)
+		}
+	}
+	var serverSum, clientSum float64
+	for i := 1; i < len(valid); i++ {
+		before, after := valid[i-1], valid[i]
+		interval := &prometheusInterval{
+			StartTime:   before.Time,
+			EndTime:     after.Time,
+			ServerCount: after.SummaryCount - before.SummaryCount,
+		}
+		// 服务端重启后计数器归零，跳过该区间
+		if interval.ServerCount < 0 {
+			continue
+		}
+		sum := after.SummarySum - before.SummarySum
+		if interval.ServerCount > 0 {
+			interval.ServerAvg = sum / interval.ServerCount
+		}
+		interval.ServerQuantiles = intervalQuantiles(before.Quantiles, after.Quantiles, unitToMilli)
+
+		var intervalClientSum float64
+		for _, count := range clientCounts {
+			startMilli := count.StartTime / int64(time.Millisecond)
+			if startMilli < before.Time || startMilli >= after.Time {
+				continue
+			}
+			interval.ClientCount += count.Count
+			intervalClientSum += count.AvgValue * float64(count.Count)
+		}
+		if interval.ClientCount > 0 {
+			interval.ClientAvg = intervalClientSum / float64(interval.ClientCount)
+		}
+		if interval.ServerCount > 0 && interval.ClientCount > 0 {
+			interval.OverheadAvg = interval.ClientAvg - interval.ServerAvg
+		}
+
+		report.ServerCount += interval.ServerCount
+		serverSum += sum
+		report.ClientCount += interval.ClientCount
+		clientSum += intervalClientSum
+		report.Intervals = append(report.Intervals, interval)
+	}
+	if report.ServerCount > 0 {
+		report.ServerAvg = serverSum / report.ServerCount
+	}
+	if report.ClientCount > 0 {
+		report.ClientAvg = clientSum / float64(report.ClientCount)
+	}
+	if report.ServerCount > 0 && report.ClientCount > 0 {
+		report.OverheadAvg = report.ClientAvg - report.ServerAvg
+	}
+	return
+}
+
+// intervalQuantiles summary 分位取区间结束时的值，histogram 按桶增量计算 p50、p90、p99，结果为毫秒
+func intervalQuantiles(before map[string]float64, after map[string]float64, unitToMilli float64) (res map[string]float64) {
+	if len(after) == 0 {
+		return
+	}
+	res = map[string]float64{}
+	for key, value := range after {
+		if strings.HasPrefix(key, "quantile:") {
+			res["p"+strings.TrimPrefix(key, "quantile:")] = value * unitToMilli
+		}
+	}
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		if value, ok := histogramQuantile(q, before, after); ok {
+			res[fmt.Sprintf("p%g", q)] = value * unitToMilli
+		}
+	}
+	if len(res) == 0 {
+		res = nil
+	}
+	return
 }
 
 // ReadLine 逐行读取文件
@@ -61,11 +556,14 @@ func ReadLine(rd io.Reader) (lines []string, err error) {
 		if err != nil {
 			if err == io.EOF { //读取结束，会报EOF
 				err = nil
+				// 最后一行没有换行符
+				if line != "" {
+					lines = append(lines, line)
+				}
 				return
 			}
 			return nil, err
 		}
 		lines = append(lines, line)
 	}
-	return
 }
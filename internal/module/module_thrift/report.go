@@ -0,0 +1,430 @@
+package module_thrift
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/team-ide/go-tool/metric"
+	"github.com/team-ide/go-tool/util"
+	"html/template"
+	"strconv"
+	"time"
+)
+
+// ReportSummary 单次执行的关键指标，耗时单位毫秒，错误率为百分比
+type ReportSummary struct {
+	TaskKey      string  `json:"taskKey"`
+	ServiceName  string  `json:"serviceName"`
+	MethodName   string  `json:"methodName"`
+	StartTime    int64   `json:"startTime"`
+	EndTime      int64   `json:"endTime"`
+	Worker       int     `json:"worker"`
+	Count        int     `json:"count"`
+	SuccessCount int     `json:"successCount"`
+	ErrorCount   int     `json:"errorCount"`
+	ErrorRate    float64 `json:"errorRate"`
+	Tps          float64 `json:"tps"`
+	Avg          float64 `json:"avg"`
+	Min          float64 `json:"min"`
+	Max          float64 `json:"max"`
+	T50          float64 `json:"t50"`
+	T90          float64 `json:"t90"`
+	T99          float64 `json:"t99"`
+}
+
+// ReportDelta 与基准执行的差值，Percent 结尾的为变化百分比，错误率为百分点差值
+type ReportDelta struct {
+	Tps           float64 `json:"tps"`
+	TpsPercent    float64 `json:"tpsPercent"`
+	Avg           float64 `json:"avg"`
+	AvgPercent    float64 `json:"avgPercent"`
+	T90           float64 `json:"t90"`
+	T90Percent    float64 `json:"t90Percent"`
+	T99           float64 `json:"t99"`
+	T99Percent    float64 `json:"t99Percent"`
+	ErrorRate     float64 `json:"errorRate"`
+	ErrorRateText string  `json:"errorRateText"`
+}
+
+type ReportCompareItem struct {
+	*ReportSummary
+	// 第一条为基准，不计算差值
+	Delta *ReportDelta `json:"delta,omitempty"`
+	// 不满足阈值的原因，用于 JUnit 失败信息
+	Failures []string `json:"failures,omitempty"`
+}
+
+// ReportThreshold 导出 JUnit 时的通过条件，小于等于0不校验
+type ReportThreshold struct {
+	MaxErrorRate float64 `json:"maxErrorRate,omitempty"`
+	MaxAvg       float64 `json:"maxAvg,omitempty"`
+	MaxT99       float64 `json:"maxT99,omitempty"`
+	MinTps       float64 `json:"minTps,omitempty"`
+}
+
+func newReportSummary(taskInfo map[string]interface{}) (summary *ReportSummary) {
+	summary = &ReportSummary{
+		TaskKey: util.GetStringValue(taskInfo["taskKey"]),
+	}
+	var bs []byte
+	request := &BaseRequest{}
+	bs, _ = json.Marshal(taskInfo["request"])
+	_ = json.Unmarshal(bs, request)
+	summary.ServiceName = request.ServiceName
+	summary.MethodName = request.MethodName
+	summary.Worker = request.Worker
+
+	count := &metric.Count{}
+	bs, _ = json.Marshal(taskInfo["metric"])
+	_ = json.Unmarshal(bs, count)
+	summary.StartTime = count.StartTime / int64(time.Millisecond)
+	summary.EndTime = count.EndTime / int64(time.Millisecond)
+	summary.Count = count.Count
+	summary.SuccessCount = count.SuccessCount
+	summary.ErrorCount = count.ErrorCount
+	if count.Count > 0 {
+		summary.ErrorRate = float64(count.ErrorCount) * 100 / float64(count.Count)
+	}
+	summary.Tps = count.TpsValue
+	summary.Avg = count.AvgValue
+	summary.Min = parseMetricValue(count.Min)
+	summary.Max = parseMetricValue(count.Max)
+	summary.T50 = parseMetricValue(count.T50)
+	summary.T90 = parseMetricValue(count.T90)
+	summary.T99 = parseMetricValue(count.T99)
+	return
+}
+
+func parseMetricValue(value string) float64 {
+	v, _ := strconv.ParseFloat(value, 64)
+	return v
+}
+
+// compareReports 以第一条执行为基准，计算其它执行的指标变化
+func compareReports(taskList []map[string]interface{}, threshold *ReportThreshold) (items []*ReportCompareItem) {
+	var base *ReportSummary
+	for _, taskInfo := range taskList {
+		item := &ReportCompareItem{
+			ReportSummary: newReportSummary(taskInfo),
+		}
+		if base == nil {
+			base = item.ReportSummary
+		} else {
+			item.Delta = &ReportDelta{
+				Tps:        item.Tps - base.Tps,
+				TpsPercent: deltaPercent(base.Tps, item.Tps),
+				Avg:        item.Avg - base.Avg,
+				AvgPercent: deltaPercent(base.Avg, item.Avg),
+				T90:        item.T90 - base.T90,
+				T90Percent: deltaPercent(base.T90, item.T90),
+				T99:        item.T99 - base.T99,
+				T99Percent: deltaPercent(base.T99, item.T99),
+				ErrorRate:  item.ErrorRate - base.ErrorRate,
+			}
+			item.Delta.ErrorRateText = fmt.Sprintf("%+.2f", item.Delta.ErrorRate)
+		}
+		item.Failures = checkThreshold(item.ReportSummary, threshold)
+		items = append(items, item)
+	}
+	return
+}
+
+func deltaPercent(base float64, value float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return (value - base) * 100 / base
+}
+
+func checkThreshold(summary *ReportSummary, threshold *ReportThreshold) (failures []string) {
+	if threshold == nil {
+		return
+	}
+	if threshold.MaxErrorRate > 0 && summary.ErrorRate > threshold.MaxErrorRate {
+		failures = append(failures, fmt.Sprintf("error rate %.2f%% > %.2f%%", summary.ErrorRate, threshold.MaxErrorRate))
+	}
+	if threshold.MaxAvg > 0 && summary.Avg > threshold.MaxAvg {
+		failures = append(failures, fmt.Sprintf("avg %.2fms > %.2fms", summary.Avg, threshold.MaxAvg))
+	}
+	if threshold.MaxT99 > 0 && summary.T99 > threshold.MaxT99 {
+		failures = append(failures, fmt.Sprintf("t99 %.2fms > %.2fms", summary.T99, threshold.MaxT99))
+	}
+	if threshold.MinTps > 0 && summary.Tps < threshold.MinTps {
+		failures = append(failures, fmt.Sprintf("tps %.2f < %.2f", summary.Tps, threshold.MinTps))
+	}
+	return
+}
+
+func reportTimeText(milli int64) string {
+	return util.TimeFormat(time.UnixMilli(milli), "2006-01-02 15:04:05.000")
+}
+
+func toCompareMarkdown(items []*ReportCompareItem) (content string) {
+	content += fmt.Sprintf("#### 执行对比（基准：%s）  \n\n", items[0].TaskKey)
+	content += fmt.Sprintf("| 任务 | 开始时间 | TPS | Avg | T90 | T99 | 错误率(%%) |  \n")
+	content += fmt.Sprintf("| :------: | :------: | :------: | :------: | :------: | :------: | :------: |  \n")
+	for _, item := range items {
+		content += fmt.Sprintf("| %s | %s |", item.TaskKey, reportTimeText(item.StartTime))
+		if item.Delta == nil {
+			content += fmt.Sprintf(" %.2f | %.2f | %.2f | %.2f | %.2f |", item.Tps, item.Avg, item.T90, item.T99, item.ErrorRate)
+		} else {
+			content += fmt.Sprintf(" %.2f (%+.2f%%) |", item.Tps, item.Delta.TpsPercent)
+			content += fmt.Sprintf(" %.2f (%+.2f%%) |", item.Avg, item.Delta.AvgPercent)
+			content += fmt.Sprintf(" %.2f (%+.2f%%) |", item.T90, item.Delta.T90Percent)
+			content += fmt.Sprintf(" %.2f (%+.2f%%) |", item.T99, item.Delta.T99Percent)
+			content += fmt.Sprintf(" %.2f (%s) |", item.ErrorRate, item.Delta.ErrorRateText)
+		}
+		content += fmt.Sprintf("\n")
+	}
+	content += fmt.Sprintf("\n\n")
+	return
+}
+
+func toCSV(items []*ReportCompareItem) (bs []byte, err error) {
+	buf := &bytes.Buffer{}
+	// 写入 BOM，Excel 打开中文不乱码
+	buf.WriteString("\xEF\xBB\xBF")
+	writer := csv.NewWriter(buf)
+	err = writer.Write([]string{
+		"服务", "方法", "任务", "开始时间", "结束时间", "线程数", "总数", "成功", "失败", "错误率(%)",
+		"TPS", "Avg", "Min", "Max", "T50", "T90", "T99",
+		"TPS变化(%)", "Avg变化(%)", "T90变化(%)", "T99变化(%)", "错误率变化",
+	})
+	if err != nil {
+		return
+	}
+	for _, item := range items {
+		record := []string{
+			item.ServiceName, item.MethodName, item.TaskKey,
+			reportTimeText(item.StartTime), reportTimeText(item.EndTime),
+			strconv.Itoa(item.Worker), strconv.Itoa(item.Count), strconv.Itoa(item.SuccessCount), strconv.Itoa(item.ErrorCount),
+			formatFloat(item.ErrorRate), formatFloat(item.Tps), formatFloat(item.Avg), formatFloat(item.Min), formatFloat(item.Max),
+			formatFloat(item.T50), formatFloat(item.T90), formatFloat(item.T99),
+		}
+		if item.Delta != nil {
+			record = append(record,
+				formatFloat(item.Delta.TpsPercent), formatFloat(item.Delta.AvgPercent),
+				formatFloat(item.Delta.T90Percent), formatFloat(item.Delta.T99Percent), item.Delta.ErrorRateText,
+			)
+		} else {
+			record = append(record, "", "", "", "", "")
+		}
+		if err = writer.Write(record); err != nil {
+			return
+		}
+	}
+	writer.Flush()
+	err = writer.Error()
+	bs = buf.Bytes()
+	return
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Time       string           `xml:"time,attr"`
+	Timestamp  string           `xml:"timestamp,attr,omitempty"`
+	Properties []*junitProperty `xml:"properties>property,omitempty"`
+	Cases      []*junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Content string `xml:",chardata"`
+}
+
+// toJUnit 每次执行为一个测试用例，不满足阈值时标记失败
+func toJUnit(items []*ReportCompareItem, threshold *ReportThreshold) (bs []byte, err error) {
+	suites := &junitTestSuites{}
+	suiteCache := map[string]*junitTestSuite{}
+	for _, item := range items {
+		name := item.ServiceName + "." + item.MethodName
+		suite := suiteCache[name]
+		if suite == nil {
+			suite = &junitTestSuite{
+				Name:      name,
+				Timestamp: time.UnixMilli(item.StartTime).Format("2006-01-02T15:04:05"),
+			}
+			if threshold != nil {
+				suite.Properties = append(suite.Properties,
+					&junitProperty{Name: "maxErrorRate", Value: formatFloat(threshold.MaxErrorRate)},
+					&junitProperty{Name: "maxAvg", Value: formatFloat(threshold.MaxAvg)},
+					&junitProperty{Name: "maxT99", Value: formatFloat(threshold.MaxT99)},
+					&junitProperty{Name: "minTps", Value: formatFloat(threshold.MinTps)},
+				)
+			}
+			suiteCache[name] = suite
+			suites.Suites = append(suites.Suites, suite)
+		}
+		useSeconds := float64(item.EndTime-item.StartTime) / 1000
+		testCase := &junitTestCase{
+			Name:      item.TaskKey,
+			ClassName: name,
+			Time:      strconv.FormatFloat(useSeconds, 'f', 3, 64),
+			SystemOut: fmt.Sprintf("count=%d success=%d error=%d errorRate=%.2f%% tps=%.2f avg=%.2fms t90=%.2fms t99=%.2fms",
+				item.Count, item.SuccessCount, item.ErrorCount, item.ErrorRate, item.Tps, item.Avg, item.T90, item.T99),
+		}
+		if len(item.Failures) > 0 {
+			testCase.Failure = &junitFailure{
+				Message: item.Failures[0],
+				Type:    "threshold",
+			}
+			for _, one := range item.Failures {
+				testCase.Failure.Content += one + "\n"
+			}
+			suite.Failures++
+			suites.Failures++
+		}
+		suite.Tests++
+		suites.Tests++
+		suiteTime, _ := strconv.ParseFloat(suite.Time, 64)
+		suite.Time = strconv.FormatFloat(suiteTime+useSeconds, 'f', 3, 64)
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	bs, err = xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return
+	}
+	bs = append([]byte(xml.Header), bs...)
+	return
+}
+
+var reportHtmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time": reportTimeText,
+	"f2":   formatFloat,
+	"inc": func(i int) int {
+		return i + 1
+	},
+	"delta": func(v float64) template.HTML {
+		color := "#888"
+		if v > 0 {
+			color = "#d03050"
+		} else if v < 0 {
+			color = "#18a058"
+		}
+		return template.HTML(fmt.Sprintf(`<span style="color:%s">%+.2f%%</span>`, color, v))
+	},
+	"deltaTps": func(v float64) template.HTML {
+		color := "#888"
+		if v > 0 {
+			color = "#18a058"
+		} else if v < 0 {
+			color = "#d03050"
+		}
+		return template.HTML(fmt.Sprintf(`<span style="color:%s">%+.2f%%</span>`, color, v))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "Microsoft YaHei", sans-serif; margin: 24px; color: #333; }
+h1 { font-size: 22px; }
+h2 { font-size: 18px; margin-top: 28px; }
+table { border-collapse: collapse; margin: 12px 0; font-size: 13px; }
+th, td { border: 1px solid #ddd; padding: 6px 10px; text-align: center; }
+th { background: #f5f5f5; }
+.error { color: #d03050; }
+.success { color: #18a058; }
+pre { background: #f7f7f7; padding: 8px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div>生成时间：{{.Time}}</div>
+{{with .Request}}
+<h2>测试信息</h2>
+<table>
+<tr><th>服务名称</th><td>{{.ServiceName}}</td><th>方法名称</th><td>{{.MethodName}}</td></tr>
+<tr><th>测试地址</th><td>{{.ServerAddress}}</td><th>线程数</th><td>{{.Worker}}</td></tr>
+<tr><th>ProtocolFactory类型</th><td>{{.ProtocolFactory}}</td><th>Transport</th><td>{{.Transport}}</td></tr>
+</table>
+{{range $i, $arg := .Args}}<div>参数-{{inc $i}}：</div><pre>{{$arg}}</pre>{{end}}
+{{end}}
+<h2>测试记录</h2>
+<table>
+<tr><th>任务</th><th>任务时间</th><th>线程数</th><th>总/成功/失败</th><th>错误率(%)</th><th>TPS</th><th>Avg</th><th>Min</th><th>Max</th><th>T50</th><th>T90</th><th>T99</th></tr>
+{{range .Items}}
+<tr{{if .Failures}} class="error"{{end}}>
+<td>{{.TaskKey}}</td>
+<td>{{time .StartTime}}<br>-<br>{{time .EndTime}}</td>
+<td>{{.Worker}}</td>
+<td>{{.Count}}<br><span class="success">{{.SuccessCount}}</span><br><span class="error">{{.ErrorCount}}</span></td>
+<td>{{f2 .ErrorRate}}</td>
+<td>{{f2 .Tps}}</td>
+<td>{{f2 .Avg}}</td>
+<td>{{f2 .Min}}</td>
+<td>{{f2 .Max}}</td>
+<td>{{f2 .T50}}</td>
+<td>{{f2 .T90}}</td>
+<td>{{f2 .T99}}</td>
+</tr>
+{{end}}
+</table>
+{{if gt (len .Items) 1}}
+<h2>执行对比（基准：{{(index .Items 0).TaskKey}}）</h2>
+<table>
+<tr><th>任务</th><th>TPS</th><th>Avg</th><th>T90</th><th>T99</th><th>错误率变化</th></tr>
+{{range .Items}}{{if .Delta}}
+<tr>
+<td>{{.TaskKey}}</td>
+<td>{{f2 .Tps}} {{deltaTps .Delta.TpsPercent}}</td>
+<td>{{f2 .Avg}} {{delta .Delta.AvgPercent}}</td>
+<td>{{f2 .T90}} {{delta .Delta.T90Percent}}</td>
+<td>{{f2 .T99}} {{delta .Delta.T99Percent}}</td>
+<td>{{.Delta.ErrorRateText}}</td>
+</tr>
+{{end}}{{end}}
+</table>
+{{end}}
+{{range .Items}}{{if .Failures}}
+<div class="error">{{.TaskKey}} 未通过：{{range .Failures}}{{.}}；{{end}}</div>
+{{end}}{{end}}
+</body>
+</html>
+`))
+
+// toHTML 生成不依赖外部资源的 HTML 报告
+func toHTML(request *BaseRequest, items []*ReportCompareItem) (bs []byte, err error) {
+	buf := &bytes.Buffer{}
+	err = reportHtmlTemplate.Execute(buf, map[string]interface{}{
+		"Title":   "测试报告 " + request.ServiceName + "." + request.MethodName,
+		"Time":    util.TimeFormat(time.Now(), "2006-01-02 15:04:05"),
+		"Request": request,
+		"Items":   items,
+	})
+	if err != nil {
+		return
+	}
+	bs = buf.Bytes()
+	return
+}
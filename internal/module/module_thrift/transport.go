@@ -0,0 +1,119 @@
+package module_thrift
+
+import (
+	"errors"
+	go_thrift "github.com/apache/thrift/lib/go/thrift"
+	"net/http"
+	"strings"
+	"teamide/internal/module/module_toolbox"
+	"time"
+)
+
+func newProtocolFactory(request *BaseRequest, conf *go_thrift.TConfiguration) (protocolFactory go_thrift.TProtocolFactory) {
+	switch request.ProtocolFactory {
+	case "compact":
+		protocolFactory = go_thrift.NewTCompactProtocolFactoryConf(conf)
+	case "simpleJSON":
+		protocolFactory = go_thrift.NewTSimpleJSONProtocolFactoryConf(conf)
+	case "json":
+		protocolFactory = go_thrift.NewTJSONProtocolFactory()
+	case "binary":
+		protocolFactory = go_thrift.NewTBinaryProtocolFactoryConf(conf)
+	case "header":
+		// header 协议自带传输层封装，内部协议由 HeaderProtocolId 指定
+		if request.HeaderProtocolId == "compact" {
+			conf.THeaderProtocolID = go_thrift.THeaderProtocolIDPtrMust(go_thrift.THeaderProtocolCompact)
+		}
+		protocolFactory = go_thrift.NewTHeaderProtocolFactoryConf(conf)
+	default:
+		protocolFactory = go_thrift.NewTBinaryProtocolFactoryConf(conf)
+	}
+	return
+}
+
+func newTransportFactory(request *BaseRequest, conf *go_thrift.TConfiguration) (transportFactory go_thrift.TTransportFactory) {
+	if request.Buffered {
+		transportFactory = go_thrift.NewTBufferedTransportFactory(8192)
+	} else {
+		transportFactory = go_thrift.NewTTransportFactory()
+	}
+
+	if request.Framed {
+		transportFactory = go_thrift.NewTFramedTransportFactoryConf(transportFactory, conf)
+	}
+	return
+}
+
+// newTransport 按 Transport 创建底层传输，默认为 TSocket
+func newTransport(request *BaseRequest, conf *go_thrift.TConfiguration) (transport go_thrift.TTransport, err error) {
+	switch request.Transport {
+	case "", "socket":
+		transport = go_thrift.NewTSocketConf(request.ServerAddress, conf)
+	case "tls":
+		if request.tlsConfig == nil {
+			err = errors.New("tls config not init")
+			return
+		}
+		conf.TLSConfig = request.tlsConfig
+		transport = go_thrift.NewTSSLSocketConf(request.ServerAddress, conf)
+	case "http":
+		transport, err = newHttpTransport(request)
+	default:
+		err = errors.New("transport [" + request.Transport + "] not support")
+	}
+	return
+}
+
+func newHttpTransport(request *BaseRequest) (transport go_thrift.TTransport, err error) {
+	url := request.ServerAddress
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = "http://" + url
+	}
+	httpTransport := &http.Transport{}
+	if strings.HasPrefix(url, "https://") {
+		if request.tlsConfig == nil {
+			err = errors.New("tls config not init")
+			return
+		}
+		httpTransport.TLSClientConfig = request.tlsConfig
+	}
+	httpClient := &http.Client{
+		Transport: httpTransport,
+		Timeout:   time.Millisecond * time.Duration(request.Timeout),
+	}
+	transport, err = go_thrift.NewTHttpClientWithOptions(url, go_thrift.THttpClientOptions{
+		Client: httpClient,
+	})
+	if err != nil {
+		err = errors.New("new http client " + url + " error:" + err.Error())
+		return
+	}
+	httpClientTransport := transport.(*go_thrift.THttpClient)
+	for key, value := range request.Headers {
+		httpClientTransport.SetHeader(key, value)
+	}
+	return
+}
+
+// useTLS 是否使用 TLS 传输，Mock 服务 tls 时也使用
+func (this_ *BaseRequest) useTLS() bool {
+	if this_.Transport == "tls" {
+		return true
+	}
+	return this_.Transport == "http" && strings.HasPrefix(this_.ServerAddress, "https://")
+}
+
+// initTLSConfig 使用 TLS 传输时创建证书配置
+func (this_ *api) initTLSConfig(request *BaseRequest) (err error) {
+	if !request.useTLS() {
+		return
+	}
+	request.tlsConfig, err = this_.toolboxService.NewTLSConfig(&module_toolbox.TLSOptions{
+		CaCert:             request.TLSCaCert,
+		ClientCert:         request.TLSClientCert,
+		ClientKey:          request.TLSClientKey,
+		ServerName:         request.TLSServerName,
+		InsecureSkipVerify: request.TLSInsecureSkipVerify,
+	})
+	return
+}
@@ -53,6 +53,25 @@ var (
 	PowerQuickCommandInsert = base.AppendPower(&base.PowerAction{Action: "insert", Text: "工具快速指令新增", Parent: PowerQuickCommand, ShouldLogin: true, StandAlone: true})
 	PowerQuickCommandUpdate = base.AppendPower(&base.PowerAction{Action: "update", Text: "工具快速指令修改", Parent: PowerQuickCommand, ShouldLogin: true, StandAlone: true})
 	PowerQuickCommandDelete = base.AppendPower(&base.PowerAction{Action: "delete", Text: "工具快速指令删除", Parent: PowerQuickCommand, ShouldLogin: true, StandAlone: true})
+
+	PowerKnownHost             = base.AppendPower(&base.PowerAction{Action: "knownHost", Text: "SSH主机公钥", Parent: Power, ShouldLogin: true, StandAlone: true})
+	PowerKnownHostQuery        = base.AppendPower(&base.PowerAction{Action: "query", Text: "SSH主机公钥查询", Parent: PowerKnownHost, ShouldLogin: true, StandAlone: true, ShouldPower: true})
+	PowerKnownHostDelete       = base.AppendPower(&base.PowerAction{Action: "delete", Text: "SSH主机公钥删除", Parent: PowerKnownHost, ShouldLogin: true, StandAlone: true, ShouldPower: true})
+	PowerKnownHostConfirmQuery = base.AppendPower(&base.PowerAction{Action: "confirmQuery", Text: "SSH主机公钥待确认查询", Parent: PowerKnownHost, ShouldLogin: true, StandAlone: true})
+	PowerKnownHostConfirm      = base.AppendPower(&base.PowerAction{Action: "confirm", Text: "SSH主机公钥确认", Parent: PowerKnownHost, ShouldLogin: true, StandAlone: true})
+
+	PowerSSHChallenge       = base.AppendPower(&base.PowerAction{Action: "sshChallenge", Text: "SSH认证问题", Parent: Power, ShouldLogin: true, StandAlone: true})
+	PowerSSHChallengeQuery  = base.AppendPower(&base.PowerAction{Action: "query", Text: "SSH认证问题查询", Parent: PowerSSHChallenge, ShouldLogin: true, StandAlone: true})
+	PowerSSHChallengeAnswer = base.AppendPower(&base.PowerAction{Action: "answer", Text: "SSH认证问题回答", Parent: PowerSSHChallenge, ShouldLogin: true, StandAlone: true})
+
+	PowerSSHForward       = base.AppendPower(&base.PowerAction{Action: "sshForward", Text: "SSH端口转发", Parent: Power, ShouldLogin: true, StandAlone: true})
+	PowerSSHForwardQuery  = base.AppendPower(&base.PowerAction{Action: "query", Text: "SSH端口转发查询", Parent: PowerSSHForward, ShouldLogin: true, StandAlone: true})
+	PowerSSHForwardInsert = base.AppendPower(&base.PowerAction{Action: "insert", Text: "SSH端口转发新增", Parent: PowerSSHForward, ShouldLogin: true, StandAlone: true})
+	PowerSSHForwardUpdate = base.AppendPower(&base.PowerAction{Action: "update", Text: "SSH端口转发修改", Parent: PowerSSHForward, ShouldLogin: true, StandAlone: true})
+	PowerSSHForwardDelete = base.AppendPower(&base.PowerAction{Action: "delete", Text: "SSH端口转发删除", Parent: PowerSSHForward, ShouldLogin: true, StandAlone: true})
+	PowerSSHForwardStart  = base.AppendPower(&base.PowerAction{Action: "start", Text: "SSH端口转发启动", Parent: PowerSSHForward, ShouldLogin: true, StandAlone: true})
+	PowerSSHForwardStop   = base.AppendPower(&base.PowerAction{Action: "stop", Text: "SSH端口转发停止", Parent: PowerSSHForward, ShouldLogin: true, StandAlone: true})
+	PowerSSHForwardStatus = base.AppendPower(&base.PowerAction{Action: "status", Text: "SSH端口转发状态", Parent: PowerSSHForward, ShouldLogin: true, StandAlone: true})
 )
 
 func (this_ *ToolboxApi) GetApis() (apis []*base.ApiWorker) {
@@ -86,6 +105,22 @@ func (this_ *ToolboxApi) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: PowerQuickCommandUpdate, Do: this_.updateQuickCommand})
 	apis = append(apis, &base.ApiWorker{Power: PowerQuickCommandDelete, Do: this_.deleteQuickCommand})
 
+	apis = append(apis, &base.ApiWorker{Power: PowerKnownHostQuery, Do: this_.queryKnownHost})
+	apis = append(apis, &base.ApiWorker{Power: PowerKnownHostDelete, Do: this_.deleteKnownHost})
+	apis = append(apis, &base.ApiWorker{Power: PowerKnownHostConfirmQuery, Do: this_.queryHostKeyConfirm})
+	apis = append(apis, &base.ApiWorker{Power: PowerKnownHostConfirm, Do: this_.confirmHostKey})
+
+	apis = append(apis, &base.ApiWorker{Power: PowerSSHChallengeQuery, Do: this_.querySSHChallenge})
+	apis = append(apis, &base.ApiWorker{Power: PowerSSHChallengeAnswer, Do: this_.answerSSHChallenge})
+
+	apis = append(apis, &base.ApiWorker{Power: PowerSSHForwardQuery, Do: this_.querySSHForward})
+	apis = append(apis, &base.ApiWorker{Power: PowerSSHForwardInsert, Do: this_.insertSSHForward})
+	apis = append(apis, &base.ApiWorker{Power: PowerSSHForwardUpdate, Do: this_.updateSSHForward})
+	apis = append(apis, &base.ApiWorker{Power: PowerSSHForwardDelete, Do: this_.deleteSSHForward})
+	apis = append(apis, &base.ApiWorker{Power: PowerSSHForwardStart, Do: this_.startSSHForward})
+	apis = append(apis, &base.ApiWorker{Power: PowerSSHForwardStop, Do: this_.stopSSHForward})
+	apis = append(apis, &base.ApiWorker{Power: PowerSSHForwardStatus, Do: this_.sshForwardStatus})
+
 	return
 }
 
@@ -95,12 +130,22 @@ type QuickCommandType struct {
 	Value int    `json:"value,omitempty"`
 }
 
+const (
+	QuickCommandTypeSSHCommand = 1
+	// QuickCommandTypeHttpCollection HTTP 请求集合，Option 为集合 JSON
+	QuickCommandTypeHttpCollection = 2
+	// QuickCommandTypeHttpEnvironment HTTP 环境变量，Option 为环境 JSON
+	QuickCommandTypeHttpEnvironment = 3
+)
+
 var (
 	QuickCommandTypes []*QuickCommandType
 )
 
 func init() {
-	QuickCommandTypes = append(QuickCommandTypes, &QuickCommandType{Name: "SSH Command", Text: "", Value: 1})
+	QuickCommandTypes = append(QuickCommandTypes, &QuickCommandType{Name: "SSH Command", Text: "", Value: QuickCommandTypeSSHCommand})
+	QuickCommandTypes = append(QuickCommandTypes, &QuickCommandType{Name: "HTTP Collection", Text: "HTTP请求集合", Value: QuickCommandTypeHttpCollection})
+	QuickCommandTypes = append(QuickCommandTypes, &QuickCommandType{Name: "HTTP Environment", Text: "HTTP环境变量", Value: QuickCommandTypeHttpEnvironment})
 }
 
 func GetQuickCommandTypes() []*QuickCommandType {
@@ -0,0 +1,123 @@
+package module_toolbox
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"teamide/pkg/base"
+)
+
+type QueryKnownHostRequest struct {
+	*ToolboxKnownHostModel
+}
+
+type QueryKnownHostResponse struct {
+	KnownHosts []*ToolboxKnownHostModel `json:"knownHosts,omitempty"`
+}
+
+func (this_ *ToolboxApi) queryKnownHost(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &QueryKnownHostRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &QueryKnownHostResponse{}
+
+	bean := &ToolboxKnownHostModel{}
+	if request.ToolboxKnownHostModel != nil {
+		bean.ToolboxId = request.ToolboxId
+		bean.Host = request.Host
+	}
+	// 只能查询自己记录的主机公钥
+	bean.UserId = requestBean.JWT.UserId
+
+	response.KnownHosts, err = this_.ToolboxService.QueryKnownHost(bean)
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
+
+type DeleteKnownHostRequest struct {
+	*ToolboxKnownHostModel
+}
+
+type DeleteKnownHostResponse struct {
+}
+
+func (this_ *ToolboxApi) deleteKnownHost(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &DeleteKnownHostRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &DeleteKnownHostResponse{}
+
+	if request.ToolboxKnownHostModel == nil || request.KnownHostId == 0 {
+		err = errors.New("主机公钥ID不能为空")
+		return
+	}
+	find, err := this_.ToolboxService.GetKnownHost(request.KnownHostId)
+	if err != nil {
+		return
+	}
+	if find == nil || find.UserId != requestBean.JWT.UserId {
+		err = errors.New("主机公钥不存在或不属于当前用户，无法删除")
+		return
+	}
+	_, err = this_.ToolboxService.DeleteKnownHost(request.KnownHostId)
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
+
+type QueryHostKeyConfirmResponse struct {
+	Confirms []*HostKeyConfirm `json:"confirms,omitempty"`
+}
+
+func (this_ *ToolboxApi) queryHostKeyConfirm(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	response := &QueryHostKeyConfirmResponse{}
+
+	response.Confirms = QueryHostKeyConfirms(requestBean.JWT.UserId)
+
+	res = response
+	return
+}
+
+type ConfirmHostKeyRequest struct {
+	ConfirmId string `json:"confirmId,omitempty"`
+	Accepted  bool   `json:"accepted,omitempty"`
+}
+
+type ConfirmHostKeyResponse struct {
+}
+
+func (this_ *ToolboxApi) confirmHostKey(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &ConfirmHostKeyRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &ConfirmHostKeyResponse{}
+
+	confirm := GetHostKeyConfirm(request.ConfirmId)
+	if confirm == nil {
+		err = errors.New("主机公钥确认已超时或不存在")
+		return
+	}
+	if confirm.userId != requestBean.JWT.UserId {
+		err = errors.New("工具[" + confirm.ToolboxName + "]不属于当前用户，无法操作")
+		return
+	}
+	err = this_.ToolboxService.ConfirmHostKey(confirm, requestBean.JWT.UserId, request.Accepted)
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
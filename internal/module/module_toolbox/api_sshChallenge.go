@@ -0,0 +1,54 @@
+package module_toolbox
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"teamide/pkg/base"
+)
+
+type QuerySSHChallengeResponse struct {
+	Challenges []*SSHChallenge `json:"challenges,omitempty"`
+}
+
+func (this_ *ToolboxApi) querySSHChallenge(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	response := &QuerySSHChallengeResponse{}
+
+	response.Challenges = QuerySSHChallenges(requestBean.JWT.UserId)
+
+	res = response
+	return
+}
+
+type AnswerSSHChallengeRequest struct {
+	ChallengeId string   `json:"challengeId,omitempty"`
+	Answers     []string `json:"answers,omitempty"`
+}
+
+type AnswerSSHChallengeResponse struct {
+}
+
+func (this_ *ToolboxApi) answerSSHChallenge(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &AnswerSSHChallengeRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &AnswerSSHChallengeResponse{}
+
+	challenge := GetSSHChallenge(request.ChallengeId)
+	if challenge == nil {
+		err = errors.New("SSH认证问题已超时或不存在")
+		return
+	}
+	if challenge.userId != requestBean.JWT.UserId {
+		err = errors.New("工具[" + challenge.ToolboxName + "]不属于当前用户，无法操作")
+		return
+	}
+	err = AnswerSSHChallenge(challenge, request.Answers)
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
@@ -0,0 +1,251 @@
+package module_toolbox
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"teamide/internal/module/module_node"
+	"teamide/pkg/base"
+)
+
+// checkSSHForwardToolbox 校验SSH工具归属当前用户
+func (this_ *ToolboxApi) checkSSHForwardToolbox(requestBean *base.RequestBean, toolboxId int64) (err error) {
+	toolbox, err := this_.ToolboxService.Get(toolboxId)
+	if err != nil {
+		return
+	}
+	if toolbox == nil || toolbox.ToolboxType != sshWorker_.Name {
+		err = errors.New("SSH工具不存在")
+		return
+	}
+	if toolbox.UserId != 0 && toolbox.UserId != requestBean.JWT.UserId {
+		err = errors.New("工具[" + toolbox.Name + "]不属于当前用户，无法操作")
+		return
+	}
+	return
+}
+
+// getUserSSHForward 查询端口转发并校验归属当前用户
+func (this_ *ToolboxApi) getUserSSHForward(requestBean *base.RequestBean, forwardId int64) (res *ToolboxSSHForwardModel, err error) {
+	if forwardId == 0 {
+		err = errors.New("端口转发ID不能为空")
+		return
+	}
+	res, err = this_.ToolboxService.GetSSHForward(forwardId)
+	if err != nil {
+		return
+	}
+	if res == nil {
+		err = errors.New("端口转发[" + strconv.FormatInt(forwardId, 10) + "]不存在")
+		return
+	}
+	if res.UserId != 0 && res.UserId != requestBean.JWT.UserId {
+		err = errors.New("端口转发[" + res.Name + "]不属于当前用户，无法操作")
+		return
+	}
+	return
+}
+
+type QuerySSHForwardRequest struct {
+	ToolboxId int64 `json:"toolboxId,omitempty"`
+}
+
+type QuerySSHForwardResponse struct {
+	SSHForwards []*ToolboxSSHForwardModel `json:"sshForwards,omitempty"`
+}
+
+func (this_ *ToolboxApi) querySSHForward(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &QuerySSHForwardRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &QuerySSHForwardResponse{}
+
+	err = this_.checkSSHForwardToolbox(requestBean, request.ToolboxId)
+	if err != nil {
+		return
+	}
+	response.SSHForwards, err = this_.ToolboxService.QuerySSHForward(&ToolboxSSHForwardModel{ToolboxId: request.ToolboxId})
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
+
+type InsertSSHForwardRequest struct {
+	*ToolboxSSHForwardModel
+}
+
+type InsertSSHForwardResponse struct {
+	ForwardId int64 `json:"forwardId,omitempty"`
+}
+
+func (this_ *ToolboxApi) insertSSHForward(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &InsertSSHForwardRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &InsertSSHForwardResponse{}
+
+	bean := request.ToolboxSSHForwardModel
+	if bean == nil {
+		err = errors.New("端口转发配置不能为空")
+		return
+	}
+	err = this_.checkSSHForwardToolbox(requestBean, bean.ToolboxId)
+	if err != nil {
+		return
+	}
+	bean.ForwardId = 0
+	bean.UserId = requestBean.JWT.UserId
+
+	_, err = this_.ToolboxService.InsertSSHForward(bean)
+	if err != nil {
+		return
+	}
+	response.ForwardId = bean.ForwardId
+
+	res = response
+	return
+}
+
+type UpdateSSHForwardRequest struct {
+	*ToolboxSSHForwardModel
+}
+
+type UpdateSSHForwardResponse struct {
+}
+
+func (this_ *ToolboxApi) updateSSHForward(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &UpdateSSHForwardRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &UpdateSSHForwardResponse{}
+
+	bean := request.ToolboxSSHForwardModel
+	if bean == nil {
+		err = errors.New("端口转发配置不能为空")
+		return
+	}
+	_, err = this_.getUserSSHForward(requestBean, bean.ForwardId)
+	if err != nil {
+		return
+	}
+	_, err = this_.ToolboxService.UpdateSSHForward(bean)
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
+
+type SSHForwardRequest struct {
+	ForwardId int64 `json:"forwardId,omitempty"`
+}
+
+type SSHForwardResponse struct {
+}
+
+func (this_ *ToolboxApi) deleteSSHForward(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &SSHForwardRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &SSHForwardResponse{}
+
+	_, err = this_.getUserSSHForward(requestBean, request.ForwardId)
+	if err != nil {
+		return
+	}
+	_, err = this_.ToolboxService.DeleteSSHForward(request.ForwardId)
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
+
+func (this_ *ToolboxApi) startSSHForward(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &SSHForwardRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &SSHForwardResponse{}
+
+	sshForward, err := this_.getUserSSHForward(requestBean, request.ForwardId)
+	if err != nil {
+		return
+	}
+	err = this_.ToolboxService.StartSSHForward(sshForward)
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
+
+func (this_ *ToolboxApi) stopSSHForward(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &SSHForwardRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &SSHForwardResponse{}
+
+	_, err = this_.getUserSSHForward(requestBean, request.ForwardId)
+	if err != nil {
+		return
+	}
+	this_.ToolboxService.StopSSHForward(request.ForwardId)
+
+	res = response
+	return
+}
+
+type SSHForwardStatusRequest struct {
+	ForwardIdList []int64 `json:"forwardIdList,omitempty"`
+}
+
+type SSHForwardStatusResponse struct {
+	StatusList []*SSHForwardStatusData `json:"statusList,omitempty"`
+}
+
+type SSHForwardStatusData struct {
+	*SSHForwardStatus
+	MonitorData *module_node.MonitorDataFormat `json:"monitorData,omitempty"`
+}
+
+func (this_ *ToolboxApi) sshForwardStatus(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &SSHForwardStatusRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &SSHForwardStatusResponse{}
+
+	for _, forwardId := range request.ForwardIdList {
+		_, err = this_.getUserSSHForward(requestBean, forwardId)
+		if err != nil {
+			return
+		}
+		status := GetSSHForwardStatus(forwardId)
+		response.StatusList = append(response.StatusList, &SSHForwardStatusData{
+			SSHForwardStatus: status,
+			MonitorData:      module_node.ToMonitorDataFormat(status.MonitorData),
+		})
+	}
+
+	res = response
+	return
+}
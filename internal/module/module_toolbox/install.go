@@ -254,6 +254,94 @@ CREATE TABLE ` + TableToolboxQuickCommand + ` (
 		},
 
 		/** 工具表添加顺序号 结束 **/
+
+		// 创建工具箱 SSH主机公钥 表
+		{
+			Version: "1.0.4",
+			Module:  ModuleToolbox,
+			Stage:   `创建表[` + TableToolboxKnownHost + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableToolboxKnownHost + ` (
+	knownHostId bigint(20) NOT NULL COMMENT '主机公钥ID',
+	toolboxId bigint(20) NOT NULL COMMENT '工具箱ID',
+	host varchar(200) NOT NULL COMMENT '主机地址',
+	keyType varchar(50) NOT NULL COMMENT '公钥类型',
+	fingerprint varchar(200) NOT NULL COMMENT '公钥指纹',
+	publicKey text DEFAULT NULL COMMENT '公钥',
+	userId bigint(20) DEFAULT NULL COMMENT '确认用户ID',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	PRIMARY KEY (knownHostId),
+	KEY index_toolboxId (toolboxId),
+	KEY index_host (host)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableToolboxKnownHostComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableToolboxKnownHost + ` (
+	knownHostId bigint(20) NOT NULL,
+	toolboxId bigint(20) NOT NULL,
+	host varchar(200) NOT NULL,
+	keyType varchar(50) NOT NULL,
+	fingerprint varchar(200) NOT NULL,
+	publicKey text DEFAULT NULL,
+	userId bigint(20) DEFAULT NULL,
+	createTime datetime NOT NULL,
+	PRIMARY KEY (knownHostId)
+);
+`,
+					`CREATE INDEX ` + TableToolboxKnownHost + `_index_toolboxId on ` + TableToolboxKnownHost + ` (toolboxId);`,
+					`CREATE INDEX ` + TableToolboxKnownHost + `_index_host on ` + TableToolboxKnownHost + ` (host);`,
+				},
+			},
+		},
+
+		/** 工具箱SSH主机公钥 结束 **/
+
+		// 创建工具箱 SSH端口转发 表
+		{
+			Version: "1.0.5",
+			Module:  ModuleToolbox,
+			Stage:   `创建表[` + TableToolboxSSHForward + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableToolboxSSHForward + ` (
+	forwardId bigint(20) NOT NULL COMMENT '转发ID',
+	toolboxId bigint(20) NOT NULL COMMENT '工具箱ID',
+	forwardType varchar(20) NOT NULL COMMENT '转发类型',
+	name varchar(50) DEFAULT NULL COMMENT '名称',
+	bindAddress varchar(200) NOT NULL COMMENT '监听地址',
+	targetAddress varchar(200) DEFAULT NULL COMMENT '目标地址',
+	autoStart int(10) DEFAULT NULL COMMENT '自动启动',
+	userId bigint(20) DEFAULT NULL COMMENT '用户ID',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	updateTime datetime DEFAULT NULL COMMENT '修改时间',
+	PRIMARY KEY (forwardId),
+	KEY index_toolboxId (toolboxId),
+	KEY index_userId (userId)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableToolboxSSHForwardComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableToolboxSSHForward + ` (
+	forwardId bigint(20) NOT NULL,
+	toolboxId bigint(20) NOT NULL,
+	forwardType varchar(20) NOT NULL,
+	name varchar(50) DEFAULT NULL,
+	bindAddress varchar(200) NOT NULL,
+	targetAddress varchar(200) DEFAULT NULL,
+	autoStart int(10) DEFAULT NULL,
+	userId bigint(20) DEFAULT NULL,
+	createTime datetime NOT NULL,
+	updateTime datetime DEFAULT NULL,
+	PRIMARY KEY (forwardId)
+);
+`,
+					`CREATE INDEX ` + TableToolboxSSHForward + `_index_toolboxId on ` + TableToolboxSSHForward + ` (toolboxId);`,
+					`CREATE INDEX ` + TableToolboxSSHForward + `_index_userId on ` + TableToolboxSSHForward + ` (userId);`,
+				},
+			},
+		},
+
+		/** 工具箱SSH端口转发 结束 **/
 	}
 
 }
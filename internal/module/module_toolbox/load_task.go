@@ -0,0 +1,280 @@
+package module_toolbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/metric"
+	"github.com/team-ide/go-tool/task"
+	"github.com/team-ide/go-tool/util"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"teamide/pkg/base"
+)
+
+// LoadTaskReport 压测任务和报告，Thrift、gRPC 等工具共用
+// 每个任务一个目录，保存 info.json 任务信息、metric*.json 统计和 records.txt 执行记录
+type LoadTaskReport struct {
+	toolboxService *ToolboxService
+	taskCache      map[string]*task.Task
+	taskLocker     sync.Mutex
+}
+
+func NewLoadTaskReport(toolboxService *ToolboxService) *LoadTaskReport {
+	return &LoadTaskReport{
+		toolboxService: toolboxService,
+		taskCache:      map[string]*task.Task{},
+	}
+}
+
+// GetTask 查询运行中的任务
+func (this_ *LoadTaskReport) GetTask(taskKey string) *task.Task {
+	this_.taskLocker.Lock()
+	defer this_.taskLocker.Unlock()
+
+	return this_.taskCache[taskKey]
+}
+
+func (this_ *LoadTaskReport) AddTask(task *task.Task) {
+	this_.taskLocker.Lock()
+	defer this_.taskLocker.Unlock()
+
+	this_.taskCache[task.Key] = task
+}
+
+func (this_ *LoadTaskReport) RemoveTask(taskKey string) {
+	this_.taskLocker.Lock()
+	defer this_.taskLocker.Unlock()
+
+	delete(this_.taskCache, taskKey)
+}
+
+// StopTask 停止运行中的任务
+func (this_ *LoadTaskReport) StopTask(taskKey string) {
+	t := this_.GetTask(taskKey)
+	if t != nil {
+		t.Stop()
+	}
+}
+
+// GetTaskParentDir 任务父目录，relativePath 为文件目录下的相对路径，不存在则创建
+func (this_ *LoadTaskReport) GetTaskParentDir(relativePath string) (taskDir string, err error) {
+	taskDir = this_.toolboxService.GetFilesDir() + relativePath
+
+	ex, err := util.PathExists(taskDir)
+	if err != nil {
+		return
+	}
+	if !ex {
+		err = os.MkdirAll(taskDir, fs.ModePerm)
+	}
+
+	return
+}
+
+// SaveTaskInfo 保存任务信息和统计，extend 为额外的任务信息，会覆盖默认字段，records 追加到执行记录
+func (this_ *LoadTaskReport) SaveTaskInfo(taskDir string, request interface{}, task *task.Task, extend map[string]interface{}, records []interface{}) (err error) {
+	ex, err := util.PathExists(taskDir)
+	if err != nil {
+		return
+	}
+	if !ex {
+		err = os.MkdirAll(taskDir, fs.ModePerm)
+	}
+
+	bs, _ := json.Marshal(request)
+	requestMd5 := util.GetMD5(string(bs))
+	data := map[string]interface{}{}
+	data["requestMd5"] = requestMd5
+	data["request"] = request
+	data["task"] = task
+	data["taskKey"] = task.Key
+	c := task.Metric.Count()
+	topItems := c.TopItems
+	c.TopItems = []*metric.Item{}
+	data["metric"] = c
+	for key, value := range extend {
+		data[key] = value
+	}
+	bs, _ = json.MarshalIndent(data, "", "  ")
+	err = util.WriteFile(taskDir+"/info.json", bs)
+	if err != nil {
+		return
+	}
+	c.TopItems = topItems
+	bs, _ = json.MarshalIndent(c, "", "  ")
+	_ = util.WriteFile(taskDir+"/metric.json", bs)
+	bs, _ = json.MarshalIndent(task.Metric.CountMinute(), "", "  ")
+	_ = util.WriteFile(taskDir+"/metric.minute.json", bs)
+	bs, _ = json.MarshalIndent(task.Metric.CountSecond(), "", "  ")
+	_ = util.WriteFile(taskDir+"/metric.second.json", bs)
+
+	var recordsFile *os.File
+	if ex, _ = util.PathExists(taskDir + "/records.txt"); ex {
+		recordsFile, _ = os.OpenFile(taskDir+"/records.txt", os.O_WRONLY|os.O_APPEND, 0666)
+	} else {
+		recordsFile, _ = os.Create(taskDir + "/records.txt")
+	}
+	if recordsFile != nil {
+		defer func() { _ = recordsFile.Close() }()
+
+		for _, record := range records {
+			bs, _ = json.Marshal(record)
+			_, _ = recordsFile.Write(bs)
+			_, _ = recordsFile.WriteString("\n")
+		}
+	}
+
+	return
+}
+
+// LoadTask 读取任务信息，任务不存在返回 nil
+func (this_ *LoadTaskReport) LoadTask(taskDir string) (data map[string]interface{}, err error) {
+	defer func() {
+		if len(data) == 0 {
+			data = nil
+		}
+	}()
+	data = map[string]interface{}{}
+
+	var bs []byte
+	if ex, _ := util.PathExists(taskDir + "/info.json"); ex {
+		if bs, err = os.ReadFile(taskDir + "/info.json"); err != nil {
+			return
+		}
+		err = util.JSONDecodeUseNumber(bs, &data)
+		if err != nil {
+			return
+		}
+		if data["taskKey"] != nil {
+			taskKey := util.GetStringValue(data["taskKey"])
+			data["isEnd"] = this_.GetTask(taskKey) == nil
+		}
+	}
+
+	return
+}
+
+// LoadTaskList 按任务目录名倒序，最新的在前
+func (this_ *LoadTaskReport) LoadTaskList(parentDirRelativePath string) (taskList []map[string]interface{}, err error) {
+	parentDir, err := this_.GetTaskParentDir(parentDirRelativePath)
+	if err != nil {
+		return
+	}
+
+	fileList, err := os.ReadDir(parentDir)
+	if err != nil {
+		return
+	}
+	var taskInfo map[string]interface{}
+	var names []string
+	for _, f := range fileList {
+		if !f.IsDir() {
+			continue
+		}
+		names = append(names, f.Name())
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return strings.ToLower(names[i]) < strings.ToLower(names[j]) //升序  即前面的值比后面的小 忽略大小写排序
+	})
+	size := len(names)
+	for i := size - 1; i >= 0; i-- {
+		taskInfo, err = this_.LoadTask(parentDir + names[i])
+		if err != nil {
+			return
+		}
+		if taskInfo != nil {
+			taskInfo["taskRelativePath"] = parentDirRelativePath + names[i]
+			taskList = append(taskList, taskInfo)
+		}
+	}
+	return
+}
+
+// LoadTaskMetric 读取任务按分钟或按秒的统计
+func (this_ *LoadTaskReport) LoadTaskMetric(taskDir string, minute bool, second bool) (data []*metric.Count, err error) {
+	var filename string
+	if minute {
+		filename = taskDir + "/metric.minute.json"
+	} else if second {
+		filename = taskDir + "/metric.second.json"
+	}
+	if filename == "" {
+		return
+	}
+	if ex, _ := util.PathExists(filename); ex {
+		var bs []byte
+		if bs, err = os.ReadFile(filename); err != nil {
+			return
+		}
+		err = util.JSONDecodeUseNumber(bs, &data)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// DeleteTask 停止并删除任务目录
+func (this_ *LoadTaskReport) DeleteTask(parentDirRelativePath string, taskKey string) (err error) {
+	taskParentDir, err := this_.GetTaskParentDir(parentDirRelativePath)
+	if err != nil {
+		return
+	}
+
+	this_.StopTask(taskKey)
+
+	taskDir := taskParentDir + "" + taskKey
+	if ex, _ := util.PathExists(taskDir); ex {
+		err = os.RemoveAll(taskDir)
+	}
+	return
+}
+
+// DownloadRecords 下载任务执行记录
+func (this_ *LoadTaskReport) DownloadRecords(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	res = base.HttpNotResponse
+	defer func() {
+		if err != nil {
+			_, _ = c.Writer.WriteString(err.Error())
+		}
+	}()
+
+	request := map[string]string{}
+
+	err = c.Bind(&request)
+	if err != nil {
+		return
+	}
+
+	fileName := "" + request["serviceName"] + "." + request["methodName"] + "-执行记录.txt"
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=utf-8''%s", url.QueryEscape(fileName)))
+
+	// 此处不设置 文件大小，如果设置文件大小，将无法终止下载
+	//c.Header("Content-Length", fmt.Sprint(fileInfo.Size))
+	c.Header("download-file-name", fileName)
+
+	taskDir := this_.toolboxService.GetFilesDir() + request["taskRelativePath"]
+
+	if ex, _ := util.PathExists(taskDir + "/records.txt"); ex {
+		var f *os.File
+		f, err = os.Open(taskDir + "/records.txt")
+		if err != nil {
+			return
+		}
+		defer func() { _ = f.Close() }()
+		_, err = io.Copy(c.Writer, f)
+	}
+	c.Status(http.StatusOK)
+	return
+}
@@ -0,0 +1,102 @@
+package module_toolbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/team-ide/go-tool/metric"
+	"github.com/team-ide/go-tool/util"
+	"time"
+)
+
+// LoadTaskToMarkdown 压测任务按请求分组生成测试结果，groupToMarkdown 生成每组的内容
+func LoadTaskToMarkdown(taskList []map[string]interface{}, groupToMarkdown func(index int, group []map[string]interface{}) string) (content string) {
+
+	var groupList []*[]map[string]interface{}
+	groupCache := map[string]*[]map[string]interface{}{}
+	for _, one := range taskList {
+		if one["requestMd5"] == nil {
+			continue
+		}
+		requestMd5 := one["requestMd5"].(string)
+		group := groupCache[requestMd5]
+		if group == nil {
+			group = &[]map[string]interface{}{}
+			groupCache[requestMd5] = group
+			groupList = append(groupList, group)
+		}
+		*group = append(*group, one)
+	}
+
+	content += fmt.Sprintf("# 测试结果  \n\n")
+	for index, group := range groupList {
+
+		content += groupToMarkdown(index, *group)
+	}
+	return
+}
+
+// LoadTaskMetricToMarkdown 每组的测试记录表格
+func LoadTaskMetricToMarkdown(group []map[string]interface{}) (content string) {
+	content += fmt.Sprintf("#### 测试记录  \n\n")
+
+	content += fmt.Sprintf("| 任务时间 | 总/成功/失败 |执行用时|累计用时 |TPS |Avg |Min |Max |T50 |T80 | T90 | T99 |  \n")
+	content += fmt.Sprintf("| :------: | :------: |:------: |:------:|:------: |:------: |:------: |:------: |:------: |:------: | :------: | :------: |  \n")
+
+	for _, task := range group {
+		bs, _ := json.Marshal(task["metric"])
+		count := &metric.Count{}
+		_ = json.Unmarshal(bs, count)
+
+		content += fmt.Sprintf("|")
+		content += fmt.Sprintf(" %s <br>-<br> %s |",
+			util.TimeFormat(time.UnixMilli(count.StartTime/int64(time.Millisecond)), "2006-01-02 15:04:05.000"),
+			util.TimeFormat(time.UnixMilli(count.EndTime/int64(time.Millisecond)), "2006-01-02 15:04:05.000"),
+		)
+		content += fmt.Sprintf(" %d <br> <font color='green'>%d</font> <br> <font color='red'>%d</font> |", count.Count, count.SuccessCount, count.ErrorCount)
+		content += fmt.Sprintf(" %s |", toTime(count.TotalTime/1000000))
+		content += fmt.Sprintf(" %s |", toTime(count.UseTime/1000000))
+		content += fmt.Sprintf(" %s |", count.Tps)
+		content += fmt.Sprintf(" %s |", count.Avg)
+		content += fmt.Sprintf(" %s |", count.Min)
+		content += fmt.Sprintf(" %s |", count.Max)
+		content += fmt.Sprintf(" %s |", count.T50)
+		content += fmt.Sprintf(" %s |", count.T80)
+		content += fmt.Sprintf(" %s |", count.T90)
+		content += fmt.Sprintf(" %s |", count.T99)
+		content += fmt.Sprintf("\n")
+	}
+	content += fmt.Sprintf("\n\n")
+	return
+}
+
+type tS struct {
+	Size float64
+	Unit string
+}
+
+var (
+	tList = []*tS{
+		{Size: 1000 * 60 * 60 * 24, Unit: "天"},
+		{Size: 1000 * 60 * 60, Unit: "时"},
+		{Size: 1000 * 60, Unit: "分"},
+		{Size: 1000, Unit: "秒"},
+	}
+)
+
+func toTime(size int64) (v string) {
+
+	var timeUnit string
+	var timeV = float64(size)
+
+	for _, s := range tList {
+		if timeUnit == "" && timeV >= s.Size {
+			timeV = timeV / s.Size
+			timeUnit = s.Unit
+		}
+	}
+	if timeUnit == "" {
+		timeUnit = "毫秒"
+	}
+	v = fmt.Sprintf("%.2f%s", timeV, timeUnit)
+	return
+}
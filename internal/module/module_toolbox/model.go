@@ -22,6 +22,12 @@ const (
 	// TableToolboxQuickCommand 工具箱快速命令
 	TableToolboxQuickCommand        = "TM_TOOLBOX_QUICK_COMMAND"
 	TableToolboxQuickCommandComment = "工具箱快速命令"
+	// TableToolboxKnownHost 工具箱SSH主机公钥
+	TableToolboxKnownHost        = "TM_TOOLBOX_KNOWN_HOST"
+	TableToolboxKnownHostComment = "工具箱SSH主机公钥"
+	// TableToolboxSSHForward 工具箱SSH端口转发
+	TableToolboxSSHForward        = "TM_TOOLBOX_SSH_FORWARD"
+	TableToolboxSSHForwardComment = "工具箱SSH端口转发"
 )
 
 // ToolboxModel 工具箱模型，和工具箱表对应
@@ -90,3 +96,30 @@ type ToolboxQuickCommandModel struct {
 	CreateTime       time.Time `json:"createTime,omitempty"`
 	UpdateTime       time.Time `json:"updateTime,omitempty"`
 }
+
+// ToolboxKnownHostModel 工具箱SSH主机公钥，首次连接确认后记录，后续连接校验
+type ToolboxKnownHostModel struct {
+	KnownHostId int64     `json:"knownHostId,omitempty"`
+	ToolboxId   int64     `json:"toolboxId,omitempty"`
+	Host        string    `json:"host,omitempty"`
+	KeyType     string    `json:"keyType,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	PublicKey   string    `json:"publicKey,omitempty"`
+	UserId      int64     `json:"userId,omitempty"`
+	CreateTime  time.Time `json:"createTime,omitempty"`
+}
+
+// ToolboxSSHForwardModel 工具箱SSH端口转发，forwardType 为 local（-L）、remote（-R）、dynamic（-D）
+type ToolboxSSHForwardModel struct {
+	ForwardId     int64  `json:"forwardId,omitempty"`
+	ToolboxId     int64  `json:"toolboxId,omitempty"`
+	ForwardType   string `json:"forwardType,omitempty"`
+	Name          string `json:"name,omitempty"`
+	BindAddress   string `json:"bindAddress,omitempty"`
+	TargetAddress string `json:"targetAddress,omitempty"`
+	// AutoStart 为 1 时服务启动后自动启动转发
+	AutoStart  int8      `json:"autoStart,omitempty"`
+	UserId     int64     `json:"userId,omitempty"`
+	CreateTime time.Time `json:"createTime,omitempty"`
+	UpdateTime time.Time `json:"updateTime,omitempty"`
+}
@@ -0,0 +1,31 @@
+package module_toolbox
+
+import (
+	"github.com/dop251/goja"
+	"time"
+)
+
+// RunScript 执行 JavaScript 函数体，通过 return 返回结果，超过 timeout 时中断执行
+func RunScript(script string, context map[string]interface{}, timeout time.Duration) (res interface{}, err error) {
+	vm := goja.New()
+	for key, value := range context {
+		err = vm.Set(key, value)
+		if err != nil {
+			return
+		}
+	}
+	timer := time.AfterFunc(timeout, func() {
+		vm.Interrupt("script timeout after " + timeout.String())
+	})
+	defer timer.Stop()
+
+	v, err := vm.RunScript("", `(function (){
+`+script+`
+})()
+`)
+	if err != nil {
+		return
+	}
+	res = v.Export()
+	return
+}
@@ -0,0 +1,158 @@
+package module_toolbox
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"strings"
+	"sync"
+	"teamide/internal/context"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+var (
+	sshChallengeCache     = map[string]*SSHChallenge{}
+	sshChallengeCacheLock = &sync.Mutex{}
+	// 等待用户回答认证问题的超时时间
+	sshChallengeTimeout = time.Second * 120
+
+	sshChallengeEventName = "ssh-keyboard-interactive"
+)
+
+// SSHChallenge SSH键盘交互认证问题，如动态口令，推送到浏览器等待用户回答
+type SSHChallenge struct {
+	ChallengeId string   `json:"challengeId"`
+	ToolboxId   int64    `json:"toolboxId"`
+	ToolboxName string   `json:"toolboxName"`
+	Address     string   `json:"address"`
+	Username    string   `json:"username"`
+	Name        string   `json:"name"`
+	Instruction string   `json:"instruction"`
+	Questions   []string `json:"questions"`
+	Echos       []bool   `json:"echos"`
+	userId      int64
+	answers     []string
+	done        chan struct{}
+	doneOnce    sync.Once
+}
+
+func (this_ *SSHChallenge) finish(answers []string) {
+	this_.doneOnce.Do(func() {
+		this_.answers = answers
+		close(this_.done)
+	})
+}
+
+// InitKeyboardInteractive 注册SSH键盘交互认证
+func (this_ *ToolboxService) InitKeyboardInteractive() {
+	ssh.KeyboardInteractiveChallenger = this_.AnswerKeyboardInteractive
+}
+
+// InitSSHAgent 注册是否允许使用服务端 ssh-agent，根据系统设置判断，默认关闭
+func (this_ *ToolboxService) InitSSHAgent() {
+	ssh.AgentEnabled = func() bool {
+		return this_.Setting.SSHAgentEnable
+	}
+}
+
+// AnswerKeyboardInteractive 回答SSH键盘交互认证问题，只询问密码时使用配置的密码，否则推送到浏览器等待用户输入
+func (this_ *ToolboxService) AnswerKeyboardInteractive(config ssh.Config, name, instruction string, questions []string, echos []bool) (answers []string, err error) {
+	if config.Password != "" {
+		for _, question := range questions {
+			if !strings.Contains(strings.ToLower(question), "password") {
+				answers = nil
+				break
+			}
+			answers = append(answers, config.Password)
+		}
+		if len(answers) == len(questions) {
+			return
+		}
+	}
+
+	challenge := &SSHChallenge{
+		ChallengeId: util.GetUUID(),
+		ToolboxId:   config.ToolboxId,
+		Address:     config.Address,
+		Username:    config.Username,
+		Name:        name,
+		Instruction: instruction,
+		Questions:   questions,
+		Echos:       echos,
+		done:        make(chan struct{}),
+	}
+	if config.ToolboxId != 0 {
+		var toolbox *ToolboxModel
+		toolbox, _ = this_.Get(config.ToolboxId)
+		if toolbox != nil {
+			challenge.ToolboxName = toolbox.Name
+			challenge.userId = toolbox.UserId
+		}
+	}
+	// 工具没有所属用户时推送给发起连接的用户
+	if challenge.userId == 0 {
+		challenge.userId = config.UserId
+	}
+
+	sshChallengeCacheLock.Lock()
+	sshChallengeCache[challenge.ChallengeId] = challenge
+	sshChallengeCacheLock.Unlock()
+	defer func() {
+		sshChallengeCacheLock.Lock()
+		delete(sshChallengeCache, challenge.ChallengeId)
+		sshChallengeCacheLock.Unlock()
+	}()
+
+	callToolboxUserEvent(challenge.userId, context.NewListenEvent(sshChallengeEventName, challenge))
+
+	// 调用方不能持有全局锁等待，连接取消时不再等待回答
+	select {
+	case <-challenge.done:
+	case <-config.Done:
+		challenge.finish(nil)
+	case <-time.After(sshChallengeTimeout):
+		challenge.finish(nil)
+	}
+	if challenge.answers == nil {
+		err = errors.New("SSH[" + config.Address + "]认证问题未回答，已取消连接")
+		return
+	}
+	answers = challenge.answers
+	return
+}
+
+// GetSSHChallenge 查询等待回答的认证问题
+func GetSSHChallenge(challengeId string) (res *SSHChallenge) {
+	sshChallengeCacheLock.Lock()
+	defer sshChallengeCacheLock.Unlock()
+
+	res = sshChallengeCache[challengeId]
+	return
+}
+
+// QuerySSHChallenges 查询用户等待回答的认证问题
+func QuerySSHChallenges(userId int64) (res []*SSHChallenge) {
+	sshChallengeCacheLock.Lock()
+	defer sshChallengeCacheLock.Unlock()
+
+	for _, one := range sshChallengeCache {
+		if one.userId == userId {
+			res = append(res, one)
+		}
+	}
+	return
+}
+
+// AnswerSSHChallenge 回答认证问题，answers 为空表示取消
+func AnswerSSHChallenge(challenge *SSHChallenge, answers []string) (err error) {
+	if answers == nil {
+		challenge.finish(nil)
+		return
+	}
+	if len(answers) != len(challenge.Questions) {
+		err = errors.New("认证问题数量和回答数量不一致")
+		return
+	}
+	challenge.finish(answers)
+	return
+}
@@ -0,0 +1,277 @@
+package module_toolbox
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	goSSH "golang.org/x/crypto/ssh"
+	"net"
+	"strings"
+	"sync"
+	"teamide/internal/context"
+	"teamide/internal/module/module_id"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+var (
+	hostKeyConfirmCache     = map[string]*HostKeyConfirm{}
+	hostKeyConfirmCacheLock = &sync.Mutex{}
+	// 等待用户确认主机公钥的超时时间
+	hostKeyConfirmTimeout = time.Second * 60
+
+	hostKeyConfirmEventName = "ssh-host-key-confirm"
+	hostKeyChangedEventName = "ssh-host-key-changed"
+)
+
+// HostKeyConfirm 首次连接主机时等待用户确认的公钥信息
+type HostKeyConfirm struct {
+	ConfirmId   string `json:"confirmId"`
+	ToolboxId   int64  `json:"toolboxId"`
+	ToolboxName string `json:"toolboxName"`
+	Host        string `json:"host"`
+	KeyType     string `json:"keyType"`
+	Fingerprint string `json:"fingerprint"`
+	// 已记录的公钥指纹，仅在公钥变更时有值
+	KnownFingerprint string `json:"knownFingerprint,omitempty"`
+	userId           int64
+	publicKey        string
+	accepted         bool
+	done             chan struct{}
+	doneOnce         sync.Once
+}
+
+func (this_ *HostKeyConfirm) finish(accepted bool) {
+	this_.doneOnce.Do(func() {
+		this_.accepted = accepted
+		close(this_.done)
+	})
+}
+
+// InitHostKeyChecker 注册SSH主机公钥校验，需要在安装表结构之后调用
+func (this_ *ToolboxService) InitHostKeyChecker() {
+	ssh.HostKeyChecker = this_.CheckHostKey
+}
+
+// CheckHostKey 校验SSH主机公钥，首次连接推送确认事件等待用户确认，公钥变更直接拒绝
+// 已记录公钥但没有一个匹配时视为变更，包括主机提供了其它类型的公钥
+func (this_ *ToolboxService) CheckHostKey(config ssh.Config, hostname string, remote net.Addr, key goSSH.PublicKey) (err error) {
+	fingerprint := goSSH.FingerprintSHA256(key)
+	confirm := &HostKeyConfirm{
+		ToolboxId:   config.ToolboxId,
+		Host:        hostname,
+		KeyType:     key.Type(),
+		Fingerprint: fingerprint,
+		publicKey:   strings.TrimSpace(string(goSSH.MarshalAuthorizedKey(key))),
+	}
+	if config.ToolboxId != 0 {
+		var toolbox *ToolboxModel
+		toolbox, _ = this_.Get(config.ToolboxId)
+		if toolbox != nil {
+			confirm.ToolboxName = toolbox.Name
+			confirm.userId = toolbox.UserId
+		}
+	}
+	// 工具没有所属用户时推送给发起连接的用户
+	if confirm.userId == 0 {
+		confirm.userId = config.UserId
+	}
+
+	// 公钥由确认的用户记录，只使用该用户记录的公钥
+	knownHosts, err := this_.QueryKnownHost(&ToolboxKnownHostModel{ToolboxId: config.ToolboxId, UserId: confirm.userId, Host: hostname})
+	if err != nil {
+		return
+	}
+	var changed *ToolboxKnownHostModel
+	for _, one := range knownHosts {
+		if one.Fingerprint == fingerprint {
+			return
+		}
+		// 优先展示相同类型的已记录公钥
+		if changed == nil || (changed.KeyType != key.Type() && one.KeyType == key.Type()) {
+			changed = one
+		}
+	}
+
+	if changed != nil {
+		confirm.KnownFingerprint = changed.Fingerprint
+		this_.Logger.Warn("ssh host key changed", zap.Any("toolboxId", config.ToolboxId), zap.Any("host", hostname), zap.Any("knownFingerprint", changed.Fingerprint), zap.Any("fingerprint", fingerprint))
+		callToolboxUserEvent(confirm.userId, context.NewListenEvent(hostKeyChangedEventName, confirm))
+		err = errors.New("主机[" + hostname + "]公钥已变更，可能存在中间人攻击，已记录指纹[" + changed.Fingerprint + "]，当前指纹[" + fingerprint + "]，如确认主机已更换公钥，请先删除已记录的公钥")
+		return
+	}
+
+	// 首次连接，等待用户确认，超时视为拒绝
+	confirm = startHostKeyConfirm(confirm)
+	select {
+	case <-confirm.done:
+	case <-config.Done:
+		err = errors.New("主机[" + hostname + "]连接已取消")
+		return
+	}
+	if !confirm.accepted {
+		err = errors.New("主机[" + hostname + "]公钥[" + fingerprint + "]未确认，已取消连接")
+		return
+	}
+	return
+}
+
+// startHostKeyConfirm 推送确认事件，同一主机公钥正在确认时复用，避免重复弹出确认
+func startHostKeyConfirm(confirm *HostKeyConfirm) (res *HostKeyConfirm) {
+	hostKeyConfirmCacheLock.Lock()
+	for _, one := range hostKeyConfirmCache {
+		if one.ToolboxId == confirm.ToolboxId && one.Host == confirm.Host && one.Fingerprint == confirm.Fingerprint {
+			hostKeyConfirmCacheLock.Unlock()
+			res = one
+			return
+		}
+	}
+	confirm.ConfirmId = util.GetUUID()
+	confirm.done = make(chan struct{})
+	hostKeyConfirmCache[confirm.ConfirmId] = confirm
+	hostKeyConfirmCacheLock.Unlock()
+
+	go func() {
+		select {
+		case <-confirm.done:
+		case <-time.After(hostKeyConfirmTimeout):
+			confirm.finish(false)
+		}
+		hostKeyConfirmCacheLock.Lock()
+		delete(hostKeyConfirmCache, confirm.ConfirmId)
+		hostKeyConfirmCacheLock.Unlock()
+	}()
+
+	callToolboxUserEvent(confirm.userId, context.NewListenEvent(hostKeyConfirmEventName, confirm))
+	res = confirm
+	return
+}
+
+// callToolboxUserEvent 推送给工具所属用户或发起连接的用户，用户未知时不推送，等待超时
+func callToolboxUserEvent(userId int64, event *context.ListenEvent) {
+	if userId == 0 {
+		util.Logger.Warn("toolbox user event user is empty", zap.Any("event", event.Event))
+		return
+	}
+	context.CallUserEvent(userId, event)
+}
+
+// GetHostKeyConfirm 查询等待确认的主机公钥
+func GetHostKeyConfirm(confirmId string) (res *HostKeyConfirm) {
+	hostKeyConfirmCacheLock.Lock()
+	defer hostKeyConfirmCacheLock.Unlock()
+
+	res = hostKeyConfirmCache[confirmId]
+	return
+}
+
+// QueryHostKeyConfirms 查询用户等待确认的主机公钥
+func QueryHostKeyConfirms(userId int64) (res []*HostKeyConfirm) {
+	hostKeyConfirmCacheLock.Lock()
+	defer hostKeyConfirmCacheLock.Unlock()
+
+	for _, one := range hostKeyConfirmCache {
+		if one.userId == userId {
+			res = append(res, one)
+		}
+	}
+	return
+}
+
+// ConfirmHostKey 确认或拒绝主机公钥，确认后记录公钥
+func (this_ *ToolboxService) ConfirmHostKey(confirm *HostKeyConfirm, userId int64, accepted bool) (err error) {
+	if accepted {
+		_, err = this_.InsertKnownHost(&ToolboxKnownHostModel{
+			ToolboxId:   confirm.ToolboxId,
+			Host:        confirm.Host,
+			KeyType:     confirm.KeyType,
+			Fingerprint: confirm.Fingerprint,
+			PublicKey:   confirm.publicKey,
+			UserId:      userId,
+		})
+		if err != nil {
+			confirm.finish(false)
+			return
+		}
+	}
+	confirm.finish(accepted)
+	return
+}
+
+// GetKnownHost 查询单个
+func (this_ *ToolboxService) GetKnownHost(knownHostId int64) (res *ToolboxKnownHostModel, err error) {
+	res = &ToolboxKnownHostModel{}
+
+	sql := `SELECT * FROM ` + TableToolboxKnownHost + ` WHERE knownHostId=? `
+	find, err := this_.DatabaseWorker.QueryOne(sql, []interface{}{knownHostId}, res)
+	if err != nil {
+		this_.Logger.Error("GetKnownHost Error", zap.Error(err))
+		return
+	}
+
+	if !find {
+		res = nil
+	}
+	return
+}
+
+// QueryKnownHost 查询
+func (this_ *ToolboxService) QueryKnownHost(knownHost *ToolboxKnownHostModel) (res []*ToolboxKnownHostModel, err error) {
+
+	var values []interface{}
+	sql := `SELECT * FROM ` + TableToolboxKnownHost + ` WHERE 1=1 `
+
+	// 工具和用户始终作为条件，工具ID为0时只查询不属于工具的记录
+	sql += " AND toolboxId = ? AND userId = ?"
+	values = append(values, knownHost.ToolboxId, knownHost.UserId)
+	if knownHost.Host != "" {
+		sql += " AND host = ?"
+		values = append(values, knownHost.Host)
+	}
+	sql += " ORDER BY createTime DESC"
+
+	err = this_.DatabaseWorker.Query(sql, values, &res)
+	if err != nil {
+		this_.Logger.Error("QueryKnownHost Error", zap.Error(err))
+		return
+	}
+
+	return
+}
+
+// InsertKnownHost 新增
+func (this_ *ToolboxService) InsertKnownHost(knownHost *ToolboxKnownHostModel) (rowsAffected int64, err error) {
+
+	if knownHost.KnownHostId == 0 {
+		knownHost.KnownHostId, err = this_.idService.GetNextID(module_id.IDTypeToolboxKnownHost)
+		if err != nil {
+			return
+		}
+	}
+	if knownHost.CreateTime.IsZero() {
+		knownHost.CreateTime = time.Now()
+	}
+
+	sql := `INSERT INTO ` + TableToolboxKnownHost + `(knownHostId, toolboxId, host, keyType, fingerprint, publicKey, userId, createTime) VALUES (?, ?, ?, ?, ?, ?, ?, ?) `
+
+	rowsAffected, err = this_.DatabaseWorker.Exec(sql, []interface{}{knownHost.KnownHostId, knownHost.ToolboxId, knownHost.Host, knownHost.KeyType, knownHost.Fingerprint, knownHost.PublicKey, knownHost.UserId, knownHost.CreateTime})
+	if err != nil {
+		this_.Logger.Error("InsertKnownHost Error", zap.Error(err))
+		return
+	}
+
+	return
+}
+
+// DeleteKnownHost 删除，删除后下次连接需要重新确认主机公钥
+func (this_ *ToolboxService) DeleteKnownHost(knownHostId int64) (rowsAffected int64, err error) {
+
+	sql := `DELETE FROM ` + TableToolboxKnownHost + ` WHERE knownHostId=? `
+	rowsAffected, err = this_.DatabaseWorker.Exec(sql, []interface{}{knownHostId})
+	if err != nil {
+		this_.Logger.Error("DeleteKnownHost Error", zap.Error(err))
+		return
+	}
+
+	return
+}
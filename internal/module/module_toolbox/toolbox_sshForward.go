@@ -0,0 +1,368 @@
+package module_toolbox
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"net"
+	"strings"
+	"sync"
+	"teamide/internal/module/module_id"
+	"teamide/pkg/node"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+const (
+	SSHForwardStatusStarting int8 = 1
+	SSHForwardStatusStarted  int8 = 2
+	SSHForwardStatusStopped  int8 = 3
+	SSHForwardStatusError    int8 = 4
+)
+
+var (
+	sshForwardRunnerCache     = map[int64]*sshForwardRunner{}
+	sshForwardRunnerCacheLock = &sync.Mutex{}
+)
+
+// SSHForwardStatus 端口转发运行状态
+type SSHForwardStatus struct {
+	ForwardId      int64             `json:"forwardId,omitempty"`
+	Status         int8              `json:"status,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	ConnCount      int64             `json:"connCount,omitempty"`
+	TotalConnCount int64             `json:"totalConnCount,omitempty"`
+	StartTime      int64             `json:"startTime,omitempty"`
+	MonitorData    *node.MonitorData `json:"monitorData,omitempty"`
+}
+
+type sshForwardRunner struct {
+	forwardId int64
+	status    int8
+	error     string
+	startTime int64
+	forward   *ssh.Forward
+	// 启动过程中收到停止请求，启动完成后立即停止
+	stopping bool
+}
+
+// StartSSHForward 启动端口转发，已启动或正在启动时忽略
+func (this_ *ToolboxService) StartSSHForward(sshForward *ToolboxSSHForwardModel) (err error) {
+	sshForwardRunnerCacheLock.Lock()
+	runner := sshForwardRunnerCache[sshForward.ForwardId]
+	if runner != nil && (runner.status == SSHForwardStatusStarting || runner.status == SSHForwardStatusStarted) {
+		sshForwardRunnerCacheLock.Unlock()
+		return
+	}
+	runner = &sshForwardRunner{
+		forwardId: sshForward.ForwardId,
+		status:    SSHForwardStatusStarting,
+	}
+	sshForwardRunnerCache[sshForward.ForwardId] = runner
+	sshForwardRunnerCacheLock.Unlock()
+
+	// 连接时可能需要等待用户确认主机公钥，不能持有锁
+	forward, err := this_.startSSHForward(sshForward, runner)
+
+	sshForwardRunnerCacheLock.Lock()
+	if err != nil {
+		runner.status = SSHForwardStatusError
+		runner.error = err.Error()
+		sshForwardRunnerCacheLock.Unlock()
+		return
+	}
+	if sshForwardRunnerCache[sshForward.ForwardId] != runner || runner.stopping {
+		// 启动过程中已被删除或停止，停止回调需要加锁，在锁外停止
+		sshForwardRunnerCacheLock.Unlock()
+		forward.Stop()
+		return
+	}
+	if runner.status == SSHForwardStatusStarting {
+		runner.status = SSHForwardStatusStarted
+		runner.startTime = util.GetNowMilli()
+	}
+	runner.forward = forward
+	sshForwardRunnerCacheLock.Unlock()
+	return
+}
+
+func (this_ *ToolboxService) startSSHForward(sshForward *ToolboxSSHForwardModel, runner *sshForwardRunner) (forward *ssh.Forward, err error) {
+	// 已保存的转发在关闭设置后同样需要校验
+	err = this_.checkSSHForwardBindAddress(sshForward)
+	if err != nil {
+		return
+	}
+	toolbox, err := this_.Get(sshForward.ToolboxId)
+	if err != nil {
+		return
+	}
+	if toolbox == nil || toolbox.ToolboxType != sshWorker_.Name {
+		err = errors.New("SSH工具不存在")
+		return
+	}
+	sshConfig, err := this_.GetSSHConfig(toolbox.Option, sshForward.UserId)
+	if err != nil {
+		return
+	}
+	sshConfig.ToolboxId = toolbox.ToolboxId
+
+	forward, err = ssh.StartForward(*sshConfig, ssh.ForwardConfig{
+		Type:          sshForward.ForwardType,
+		BindAddress:   sshForward.BindAddress,
+		TargetAddress: sshForward.TargetAddress,
+	}, func(e error) {
+		sshForwardRunnerCacheLock.Lock()
+		defer sshForwardRunnerCacheLock.Unlock()
+		if e != nil {
+			runner.status = SSHForwardStatusError
+			runner.error = e.Error()
+		} else {
+			runner.status = SSHForwardStatusStopped
+		}
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// StopSSHForward 停止端口转发，正在启动时标记停止，启动完成后停止
+func (this_ *ToolboxService) StopSSHForward(forwardId int64) {
+	sshForwardRunnerCacheLock.Lock()
+	runner := sshForwardRunnerCache[forwardId]
+	if runner == nil {
+		sshForwardRunnerCacheLock.Unlock()
+		return
+	}
+	forward := runner.forward
+	if forward == nil && runner.status == SSHForwardStatusStarting {
+		runner.stopping = true
+		runner.status = SSHForwardStatusStopped
+	}
+	sshForwardRunnerCacheLock.Unlock()
+	if forward == nil {
+		return
+	}
+	forward.Stop()
+}
+
+// removeSSHForward 停止并移除端口转发运行状态，正在启动的转发在启动完成后停止
+func (this_ *ToolboxService) removeSSHForward(forwardId int64) {
+	sshForwardRunnerCacheLock.Lock()
+	runner := sshForwardRunnerCache[forwardId]
+	delete(sshForwardRunnerCache, forwardId)
+	var forward *ssh.Forward
+	if runner != nil {
+		forward = runner.forward
+	}
+	sshForwardRunnerCacheLock.Unlock()
+	if forward == nil {
+		return
+	}
+	forward.Stop()
+}
+
+// GetSSHForwardStatus 查询端口转发运行状态，未启动过时为停止
+func GetSSHForwardStatus(forwardId int64) (res *SSHForwardStatus) {
+	sshForwardRunnerCacheLock.Lock()
+	defer sshForwardRunnerCacheLock.Unlock()
+
+	res = &SSHForwardStatus{
+		ForwardId: forwardId,
+		Status:    SSHForwardStatusStopped,
+	}
+	runner := sshForwardRunnerCache[forwardId]
+	if runner == nil {
+		return
+	}
+	res.Status = runner.status
+	res.Error = runner.error
+	res.StartTime = runner.startTime
+	if runner.forward != nil {
+		res.ConnCount = runner.forward.GetConnCount()
+		res.TotalConnCount = runner.forward.GetTotalConnCount()
+		res.MonitorData = runner.forward.MonitorData
+	}
+	return
+}
+
+// IsSSHForwardRunning 端口转发是否正在启动或已启动
+func IsSSHForwardRunning(forwardId int64) bool {
+	status := GetSSHForwardStatus(forwardId).Status
+	return status == SSHForwardStatusStarting || status == SSHForwardStatusStarted
+}
+
+// InitSSHForward 启动配置了自动启动的端口转发，需要在安装表结构之后调用
+func (this_ *ToolboxService) InitSSHForward() {
+	list, err := this_.QuerySSHForward(&ToolboxSSHForwardModel{AutoStart: 1})
+	if err != nil {
+		return
+	}
+	for _, one := range list {
+		sshForward := one
+		go func() {
+			e := this_.StartSSHForward(sshForward)
+			if e != nil {
+				this_.Logger.Error("ssh forward auto start error", zap.Any("forwardId", sshForward.ForwardId), zap.Error(e))
+			}
+		}()
+	}
+}
+
+// GetSSHForward 查询单个
+func (this_ *ToolboxService) GetSSHForward(forwardId int64) (res *ToolboxSSHForwardModel, err error) {
+	res = &ToolboxSSHForwardModel{}
+
+	sql := `SELECT * FROM ` + TableToolboxSSHForward + ` WHERE forwardId=? `
+	find, err := this_.DatabaseWorker.QueryOne(sql, []interface{}{forwardId}, res)
+	if err != nil {
+		this_.Logger.Error("GetSSHForward Error", zap.Error(err))
+		return
+	}
+
+	if !find {
+		res = nil
+	}
+	return
+}
+
+// QuerySSHForward 查询
+func (this_ *ToolboxService) QuerySSHForward(sshForward *ToolboxSSHForwardModel) (res []*ToolboxSSHForwardModel, err error) {
+
+	var values []interface{}
+	sql := `SELECT * FROM ` + TableToolboxSSHForward + ` WHERE 1=1 `
+
+	if sshForward.ToolboxId != 0 {
+		sql += " AND toolboxId = ?"
+		values = append(values, sshForward.ToolboxId)
+	}
+	if sshForward.UserId != 0 {
+		sql += " AND userId = ?"
+		values = append(values, sshForward.UserId)
+	}
+	if sshForward.AutoStart != 0 {
+		sql += " AND autoStart = ?"
+		values = append(values, sshForward.AutoStart)
+	}
+	sql += " ORDER BY createTime ASC"
+
+	err = this_.DatabaseWorker.Query(sql, values, &res)
+	if err != nil {
+		this_.Logger.Error("QuerySSHForward Error", zap.Error(err))
+		return
+	}
+
+	return
+}
+
+// checkSSHForward 校验转发类型和地址
+func (this_ *ToolboxService) checkSSHForward(sshForward *ToolboxSSHForwardModel) (err error) {
+	switch sshForward.ForwardType {
+	case ssh.ForwardTypeLocal, ssh.ForwardTypeRemote:
+		if strings.TrimSpace(sshForward.TargetAddress) == "" {
+			err = errors.New("转发目标地址不能为空")
+			return
+		}
+	case ssh.ForwardTypeDynamic:
+	default:
+		err = errors.New("不支持的转发类型[" + sshForward.ForwardType + "]")
+		return
+	}
+	if strings.TrimSpace(sshForward.BindAddress) == "" {
+		err = errors.New("转发监听地址不能为空")
+		return
+	}
+	err = this_.checkSSHForwardBindAddress(sshForward)
+	return
+}
+
+// checkSSHForwardBindAddress 本地、动态转发默认只能监听本机地址，避免转发开放给整个网络，系统设置开启后不限制
+func (this_ *ToolboxService) checkSSHForwardBindAddress(sshForward *ToolboxSSHForwardModel) (err error) {
+	if sshForward.ForwardType == ssh.ForwardTypeRemote || this_.Setting.SSHForwardBindAnyEnable {
+		return
+	}
+	address := strings.TrimSpace(sshForward.BindAddress)
+	// 只有端口时监听 127.0.0.1
+	if !strings.Contains(address, ":") {
+		return
+	}
+	host, _, e := net.SplitHostPort(address)
+	if e != nil {
+		err = errors.New("转发监听地址[" + address + "]格式错误:" + e.Error())
+		return
+	}
+	if host == "localhost" {
+		return
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return
+	}
+	err = errors.New("转发监听地址[" + address + "]不是本机地址，如需监听其它地址请联系管理员开启")
+	return
+}
+
+// InsertSSHForward 新增
+func (this_ *ToolboxService) InsertSSHForward(sshForward *ToolboxSSHForwardModel) (rowsAffected int64, err error) {
+
+	err = this_.checkSSHForward(sshForward)
+	if err != nil {
+		return
+	}
+	if sshForward.ForwardId == 0 {
+		sshForward.ForwardId, err = this_.idService.GetNextID(module_id.IDTypeToolboxSSHForward)
+		if err != nil {
+			return
+		}
+	}
+	if sshForward.CreateTime.IsZero() {
+		sshForward.CreateTime = time.Now()
+	}
+
+	sql := `INSERT INTO ` + TableToolboxSSHForward + `(forwardId, toolboxId, forwardType, name, bindAddress, targetAddress, autoStart, userId, createTime) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) `
+
+	rowsAffected, err = this_.DatabaseWorker.Exec(sql, []interface{}{sshForward.ForwardId, sshForward.ToolboxId, sshForward.ForwardType, sshForward.Name, sshForward.BindAddress, sshForward.TargetAddress, sshForward.AutoStart, sshForward.UserId, sshForward.CreateTime})
+	if err != nil {
+		this_.Logger.Error("InsertSSHForward Error", zap.Error(err))
+		return
+	}
+
+	return
+}
+
+// UpdateSSHForward 更新，运行中的转发需要先停止
+func (this_ *ToolboxService) UpdateSSHForward(sshForward *ToolboxSSHForwardModel) (rowsAffected int64, err error) {
+
+	err = this_.checkSSHForward(sshForward)
+	if err != nil {
+		return
+	}
+	if IsSSHForwardRunning(sshForward.ForwardId) {
+		err = errors.New("端口转发运行中，请先停止")
+		return
+	}
+
+	sql := `UPDATE ` + TableToolboxSSHForward + ` SET updateTime=?,forwardType=?,name=?,bindAddress=?,targetAddress=?,autoStart=? WHERE forwardId=? `
+
+	rowsAffected, err = this_.DatabaseWorker.Exec(sql, []interface{}{time.Now(), sshForward.ForwardType, sshForward.Name, sshForward.BindAddress, sshForward.TargetAddress, sshForward.AutoStart, sshForward.ForwardId})
+	if err != nil {
+		this_.Logger.Error("UpdateSSHForward Error", zap.Error(err))
+		return
+	}
+
+	return
+}
+
+// DeleteSSHForward 删除，删除前停止转发
+func (this_ *ToolboxService) DeleteSSHForward(forwardId int64) (rowsAffected int64, err error) {
+
+	this_.removeSSHForward(forwardId)
+
+	sql := `DELETE FROM ` + TableToolboxSSHForward + ` WHERE forwardId=? `
+	rowsAffected, err = this_.DatabaseWorker.Exec(sql, []interface{}{forwardId})
+	if err != nil {
+		this_.Logger.Error("DeleteSSHForward Error", zap.Error(err))
+		return
+	}
+
+	return
+}
@@ -0,0 +1,75 @@
+package module_toolbox
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"strings"
+)
+
+// TLSOptions 客户端证书配置，证书支持直接填写 PEM 内容或文件目录下的相对路径
+type TLSOptions struct {
+	CaCert             string
+	ClientCert         string
+	ClientKey          string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// NewTLSConfig 根据证书配置创建 TLS 配置，Thrift、gRPC 等工具共用
+func (this_ *ToolboxService) NewTLSConfig(options *TLSOptions) (tlsConfig *tls.Config, err error) {
+	tlsConfig = &tls.Config{
+		ServerName:         options.ServerName,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+	if options.CaCert != "" {
+		var bs []byte
+		bs, err = this_.ReadPem(options.CaCert)
+		if err != nil {
+			err = errors.New("read ca cert error:" + err.Error())
+			return
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bs) {
+			err = errors.New("ca cert is not valid pem")
+			return
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if options.ClientCert != "" || options.ClientKey != "" {
+		var certBs, keyBs []byte
+		certBs, err = this_.ReadPem(options.ClientCert)
+		if err != nil {
+			err = errors.New("read client cert error:" + err.Error())
+			return
+		}
+		keyBs, err = this_.ReadPem(options.ClientKey)
+		if err != nil {
+			err = errors.New("read client key error:" + err.Error())
+			return
+		}
+		var cert tls.Certificate
+		cert, err = tls.X509KeyPair(certBs, keyBs)
+		if err != nil {
+			err = errors.New("load client cert error:" + err.Error())
+			return
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+// ReadPem 读取证书，文件路径只能是文件目录下的相对路径，不允许读取其它文件
+func (this_ *ToolboxService) ReadPem(value string) (bs []byte, err error) {
+	if strings.Contains(value, "-----BEGIN") {
+		bs = []byte(value)
+		return
+	}
+	path, err := this_.GetFilesFileInDir(value)
+	if err != nil {
+		return
+	}
+	bs, err = os.ReadFile(path)
+	return
+}
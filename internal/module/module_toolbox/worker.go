@@ -12,6 +12,7 @@ import (
 	"github.com/team-ide/go-tool/zookeeper"
 	"go.uber.org/zap"
 	"strconv"
+	"strings"
 	"teamide/pkg/base"
 	"teamide/pkg/form"
 	"teamide/pkg/ssh"
@@ -101,6 +102,16 @@ func (this_ *ToolboxService) FormatOption(toolboxData *ToolboxModel) (err error)
 			}
 		}
 		break
+	case etcdWorker_:
+		if optionMap["password"] != nil {
+			str, ok := optionMap["password"].(string)
+			if ok {
+				optionMap["password"] = this_.EncryptOptionAttr(str)
+			} else {
+				delete(optionMap, "password")
+			}
+		}
+		break
 	case elasticsearchWorker_:
 		if optionMap["password"] != nil {
 			str, ok := optionMap["password"].(string)
@@ -121,6 +132,26 @@ func (this_ *ToolboxService) FormatOption(toolboxData *ToolboxModel) (err error)
 			}
 		}
 		break
+	case rabbitmqWorker_:
+		if optionMap["password"] != nil {
+			str, ok := optionMap["password"].(string)
+			if ok {
+				optionMap["password"] = this_.EncryptOptionAttr(str)
+			} else {
+				delete(optionMap, "password")
+			}
+		}
+		break
+	case mongodbWorker_:
+		if optionMap["password"] != nil {
+			str, ok := optionMap["password"].(string)
+			if ok {
+				optionMap["password"] = this_.EncryptOptionAttr(str)
+			} else {
+				delete(optionMap, "password")
+			}
+		}
+		break
 	case otherWorker_:
 		break
 	case sshWorker_:
@@ -143,16 +174,77 @@ func (this_ *ToolboxService) FormatOption(toolboxData *ToolboxModel) (err error)
 	return
 }
 
-func (this_ *ToolboxService) GetSSHConfig(option string) (config *ssh.Config, err error) {
+// GetSSHConfig 解析SSH配置，userId 为使用该配置的用户，跳板机必须属于该用户
+func (this_ *ToolboxService) GetSSHConfig(option string, userId int64) (config *ssh.Config, err error) {
+	config, err = this_.getSSHConfig(option, userId, 0)
+	return
+}
+
+// 跳板机最大层级，防止循环引用
+var maxJumpDepth = 10
+
+func (this_ *ToolboxService) getSSHConfig(option string, userId int64, depth int) (config *ssh.Config, err error) {
 	optionBytes := []byte(option)
 	err = json.Unmarshal(optionBytes, &config)
 	if err != nil {
 		return
 	}
+	config.UserId = userId
 	config.Password = this_.DecryptOptionAttr(config.Password)
 	if config.PublicKey != "" {
 		config.PublicKey = this_.GetFilesFile(config.PublicKey)
 	}
+	if config.Certificate != "" {
+		config.Certificate = this_.GetFilesFile(config.Certificate)
+	}
+	if config.JumpToolboxIds != "" {
+		config.JumpConfigs, err = this_.getJumpConfigs(config.JumpToolboxIds, userId, depth+1)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// getJumpConfigs 按顺序解析跳板机配置，跳板机自身配置的跳板机展开在其前面
+func (this_ *ToolboxService) getJumpConfigs(jumpToolboxIds string, userId int64, depth int) (res []*ssh.Config, err error) {
+	if depth > maxJumpDepth {
+		err = errors.New("跳板机层级超过" + strconv.Itoa(maxJumpDepth) + "，请检查是否循环引用")
+		return
+	}
+	for _, s := range strings.Split(jumpToolboxIds, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		jumpToolboxId, e := strconv.ParseInt(s, 10, 64)
+		if e != nil {
+			err = errors.New("跳板机工具ID[" + s + "]格式错误")
+			return
+		}
+		var jumpToolbox *ToolboxModel
+		jumpToolbox, err = this_.Get(jumpToolboxId)
+		if err != nil {
+			return
+		}
+		if jumpToolbox == nil || jumpToolbox.ToolboxType != sshWorker_.Name {
+			err = errors.New("跳板机SSH工具[" + s + "]不存在")
+			return
+		}
+		if jumpToolbox.UserId != 0 && jumpToolbox.UserId != userId {
+			err = errors.New("跳板机SSH工具[" + jumpToolbox.Name + "]不属于当前用户，无法使用")
+			return
+		}
+		var jumpConfig *ssh.Config
+		jumpConfig, err = this_.getSSHConfig(jumpToolbox.Option, userId, depth)
+		if err != nil {
+			return
+		}
+		jumpConfig.ToolboxId = jumpToolboxId
+		res = append(res, jumpConfig.JumpConfigs...)
+		jumpConfig.JumpConfigs = nil
+		res = append(res, jumpConfig)
+	}
 	return
 }
 
@@ -167,7 +259,13 @@ func (this_ *ToolboxService) BindConfig(requestBean *base.RequestBean, c *gin.Co
 		return
 	}
 
-	find, err := this_.Get(bindConfigRequest.ToolboxId)
+	sshConfig, err = this_.BindToolboxConfig(requestBean, bindConfigRequest.ToolboxId, config)
+	return
+}
+
+// BindToolboxConfig 根据工具ID绑定配置，校验工具归属并解密配置中的加密属性
+func (this_ *ToolboxService) BindToolboxConfig(requestBean *base.RequestBean, toolboxId int64, config interface{}) (sshConfig *ssh.Config, err error) {
+	find, err := this_.Get(toolboxId)
 	if err != nil {
 		return
 	}
@@ -178,7 +276,7 @@ func (this_ *ToolboxService) BindConfig(requestBean *base.RequestBean, c *gin.Co
 		}
 	}
 	if find == nil {
-		find = this_.GetOtherToolbox(bindConfigRequest.ToolboxId)
+		find = this_.GetOtherToolbox(toolboxId)
 	}
 	option := ""
 	if find != nil {
@@ -215,11 +313,16 @@ func (this_ *ToolboxService) BindConfig(requestBean *base.RequestBean, c *gin.Co
 						return
 					}
 					if sshToolbox != nil {
-						sshConfig, err = this_.GetSSHConfig(sshToolbox.Option)
+						var userId int64
+						if requestBean.JWT != nil {
+							userId = requestBean.JWT.UserId
+						}
+						sshConfig, err = this_.GetSSHConfig(sshToolbox.Option, userId)
 						if err != nil {
 							err = errors.New("ssh toolbox config error:" + err.Error())
 							return
 						}
+						sshConfig.ToolboxId = sshToolbox.ToolboxId
 						this_.Logger.Info("BindConfig find sshConfig", zap.Any("sshConfig", sshConfig))
 					}
 				}
@@ -278,9 +381,14 @@ var (
 	sshWorker_           = sshWorker()
 	redisWorker_         = redisWorker()
 	zookeeperWorker_     = zookeeperWorker()
+	etcdWorker_          = etcdWorker()
 	elasticsearchWorker_ = elasticsearchWorker()
 	kafkaWorker_         = kafkaWorker()
+	rabbitmqWorker_      = rabbitmqWorker()
 	thriftWorker_        = thriftWorker()
+	grpcWorker_          = grpcWorker()
+	httpWorker_          = httpWorker()
+	mongodbWorker_       = mongodbWorker()
 	otherWorker_         = otherWorker()
 )
 
@@ -289,9 +397,14 @@ func init() {
 	*toolboxTypes = append(*toolboxTypes, sshWorker_)
 	*toolboxTypes = append(*toolboxTypes, redisWorker_)
 	*toolboxTypes = append(*toolboxTypes, zookeeperWorker_)
+	*toolboxTypes = append(*toolboxTypes, etcdWorker_)
 	*toolboxTypes = append(*toolboxTypes, elasticsearchWorker_)
 	*toolboxTypes = append(*toolboxTypes, kafkaWorker_)
+	*toolboxTypes = append(*toolboxTypes, rabbitmqWorker_)
 	*toolboxTypes = append(*toolboxTypes, thriftWorker_)
+	*toolboxTypes = append(*toolboxTypes, grpcWorker_)
+	*toolboxTypes = append(*toolboxTypes, httpWorker_)
+	*toolboxTypes = append(*toolboxTypes, mongodbWorker_)
 	//*toolboxTypes = append(*toolboxTypes, otherWorker_)
 }
 
@@ -615,6 +728,16 @@ func sshWorker() *ToolboxType {
 				{Label: "Username", Name: "username"},
 				{Label: "Password", Name: "password", Type: "password"},
 				{Label: "PublicKey", Name: "publicKey", Type: "file", Placeholder: "请上传PublicKey文件"},
+				{Label: "证书（-cert.pub，和PublicKey一起使用）", Name: "certificate", Type: "file", Placeholder: "请上传证书文件"},
+				{Label: "认证方式（按顺序尝试，逗号分隔：publicKey,agent,keyboardInteractive,password，为空时使用PublicKey或密码）", Name: "authMethods"},
+				{
+					Label: "转发ssh-agent", Name: "forwardAgent", Type: "select",
+					Options: []*form.Option{
+						{Text: "否", Value: ""},
+						{Text: "是", Value: "1"},
+					},
+				},
+				{Label: "跳板机（SSH工具ID，多个逗号分隔，按顺序连接）", Name: "jumpToolboxIds", Placeholder: "如：1,2，先连接1再通过1连接2"},
 				{Label: "连接后执行命令(回车执行多条，sleep 5，表示等待5秒执行下一条)", Name: "command", Type: "textarea", Placeholder: "请上传PublicKey文件"},
 			},
 		},
@@ -668,6 +791,72 @@ func zookeeperWorker() *ToolboxType {
 				},
 				{Label: "Username", Name: "username"},
 				{Label: "Password", Name: "password", Type: "password"},
+				{Label: "AdminServer地址（http://127.0.0.1:8080，诊断使用，不配置时使用四字命令）", Name: "adminServerUrl"},
+			},
+		},
+	}
+
+	return worker_
+}
+
+func etcdWorker() *ToolboxType {
+	worker_ := &ToolboxType{
+		Name: "etcd",
+		Text: "Etcd",
+		ConfigForm: &form.Form{
+			Fields: []*form.Field{
+				{
+					Label: "SSH隧道", Name: "sshToolboxId", Type: "select",
+					OptionsName: "sshToolboxOptions",
+					Rules:       []*form.Rule{},
+				},
+				{
+					Label: "连接地址（127.0.0.1:2379，多个地址逗号分隔）", Name: "endpoints", DefaultValue: "127.0.0.1:2379",
+					Rules: []*form.Rule{
+						{Required: true, Message: "连接地址不能为空"},
+					},
+				},
+				{Label: "Username", Name: "username"},
+				{Label: "Password", Name: "password", Type: "password"},
+				{Label: "CA证书", Name: "caCertPath", Type: "file", Placeholder: "请上传CA证书"},
+				{Label: "客户端证书", Name: "certPath", Type: "file", Placeholder: "请上传客户端证书"},
+				{Label: "客户端私钥", Name: "keyPath", Type: "file", Placeholder: "请上传客户端私钥"},
+				{Label: "证书ServerName", Name: "serverName"},
+				{
+					Label: "跳过证书校验", Name: "insecureSkipVerify", Type: "select",
+					Options: []*form.Option{
+						{Text: "否", Value: ""},
+						{Text: "是", Value: "1"},
+					},
+				},
+			},
+		},
+	}
+
+	return worker_
+}
+
+func rabbitmqWorker() *ToolboxType {
+	worker_ := &ToolboxType{
+		Name: "rabbitmq",
+		Text: "RabbitMQ",
+		ConfigForm: &form.Form{
+			Fields: []*form.Field{
+				{
+					Label: "SSH隧道", Name: "sshToolboxId", Type: "select",
+					OptionsName: "sshToolboxOptions",
+					Rules:       []*form.Rule{},
+				},
+				{
+					Label: "连接地址（127.0.0.1:5672 或 amqp://...）", Name: "address", DefaultValue: "127.0.0.1:5672",
+					Rules: []*form.Rule{
+						{Required: true, Message: "连接地址不能为空"},
+					},
+				},
+				{Label: "Username", Name: "username"},
+				{Label: "Password", Name: "password", Type: "password"},
+				{Label: "Vhost（默认/）", Name: "vhost"},
+				{Label: "管理插件地址（http://127.0.0.1:15672，查询列表需要）", Name: "managementUrl"},
 			},
 		},
 	}
@@ -694,6 +883,89 @@ func thriftWorker() *ToolboxType {
 	return worker_
 }
 
+func grpcWorker() *ToolboxType {
+	worker_ := &ToolboxType{
+		Name: "grpc",
+		Text: "gRPC",
+		ConfigForm: &form.Form{
+			Fields: []*form.Field{
+				{
+					Label: "Proto文件目录（为空时使用服务端反射）", Name: "protoDir",
+				},
+			},
+		},
+	}
+
+	return worker_
+}
+
+func httpWorker() *ToolboxType {
+	worker_ := &ToolboxType{
+		Name: "http",
+		Text: "HTTP",
+		ConfigForm: &form.Form{
+			Fields: []*form.Field{
+				{
+					Label: "SSH隧道", Name: "sshToolboxId", Type: "select",
+					OptionsName: "sshToolboxOptions",
+					Rules:       []*form.Rule{},
+				},
+				{
+					Label: "节点网络代理", Name: "netProxyId", Type: "select",
+					OptionsName: "netProxyOptions",
+					Rules:       []*form.Rule{},
+				},
+				{Label: "基础地址（http://127.0.0.1:8080）", Name: "baseUrl"},
+				{Label: "超时时长（毫秒，默认30000）", Name: "timeout"},
+				{
+					Label: "跳过证书校验", Name: "insecureSkipVerify", Type: "select",
+					Options: []*form.Option{
+						{Text: "否", Value: ""},
+						{Text: "是", Value: "1"},
+					},
+				},
+				{
+					Label: "跟随重定向", Name: "followRedirect", Type: "select", DefaultValue: "1",
+					Options: []*form.Option{
+						{Text: "否", Value: ""},
+						{Text: "是", Value: "1"},
+					},
+				},
+			},
+		},
+	}
+
+	return worker_
+}
+
+func mongodbWorker() *ToolboxType {
+	worker_ := &ToolboxType{
+		Name: "mongodb",
+		Text: "MongoDB",
+		ConfigForm: &form.Form{
+			Fields: []*form.Field{
+				{
+					Label: "SSH隧道", Name: "sshToolboxId", Type: "select",
+					OptionsName: "sshToolboxOptions",
+					Rules:       []*form.Rule{},
+				},
+				{
+					Label: "连接地址（127.0.0.1:27017 或 mongodb://...）", Name: "address", DefaultValue: "127.0.0.1:27017",
+					Rules: []*form.Rule{
+						{Required: true, Message: "连接地址不能为空"},
+					},
+				},
+				{Label: "Username", Name: "username"},
+				{Label: "Password", Name: "password", Type: "password"},
+				{Label: "认证数据库（默认admin）", Name: "authSource"},
+				{Label: "默认数据库（无数据库列表权限时使用）", Name: "database"},
+			},
+		},
+	}
+
+	return worker_
+}
+
 func otherWorker() *ToolboxType {
 	worker_ := &ToolboxType{
 		Name: "other",
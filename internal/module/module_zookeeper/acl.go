@@ -0,0 +1,186 @@
+package module_zookeeper
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-zookeeper/zk"
+	"github.com/team-ide/go-tool/util"
+	"github.com/team-ide/go-tool/zookeeper"
+	"strings"
+	"time"
+)
+
+// ACLInfo 节点权限，Perms 为 cdrwa 组合，digest 方案设置时可填写 Password 由服务端计算摘要
+type ACLInfo struct {
+	Scheme   string `json:"scheme"`
+	Id       string `json:"id"`
+	Perms    string `json:"perms"`
+	Password string `json:"password,omitempty"`
+}
+
+type NodeACL struct {
+	Path string              `json:"path"`
+	ACLs []*ACLInfo          `json:"acls"`
+	Stat *zookeeper.StatInfo `json:"stat,omitempty"`
+}
+
+var aclPermList = []struct {
+	Char string
+	Perm int32
+}{
+	{Char: "c", Perm: zk.PermCreate},
+	{Char: "d", Perm: zk.PermDelete},
+	{Char: "r", Perm: zk.PermRead},
+	{Char: "w", Perm: zk.PermWrite},
+	{Char: "a", Perm: zk.PermAdmin},
+}
+
+func permsToString(perms int32) (res string) {
+	for _, one := range aclPermList {
+		if perms&one.Perm != 0 {
+			res += one.Char
+		}
+	}
+	return
+}
+
+func permsFromString(str string) (perms int32, err error) {
+	for _, c := range strings.ToLower(str) {
+		var find bool
+		for _, one := range aclPermList {
+			if string(c) == one.Char {
+				perms |= one.Perm
+				find = true
+				break
+			}
+		}
+		if !find {
+			err = errors.New("acl perms [" + str + "] not support, use the combination of cdrwa")
+			return
+		}
+	}
+	return
+}
+
+func getACL(service zookeeper.IService, path string) (res *NodeACL, err error) {
+	acls, stat, err := service.GetConn().GetACL(path)
+	if err != nil {
+		err = errors.New("path [" + path + "] get acl error:" + err.Error())
+		return
+	}
+	res = &NodeACL{
+		Path: path,
+	}
+	for _, acl := range acls {
+		res.ACLs = append(res.ACLs, &ACLInfo{
+			Scheme: acl.Scheme,
+			Id:     acl.ID,
+			Perms:  permsToString(acl.Perms),
+		})
+	}
+	if stat != nil {
+		res.Stat = zookeeper.StatToInfo(stat)
+	}
+	return
+}
+
+func toACL(info *ACLInfo) (acl zk.ACL, err error) {
+	perms, err := permsFromString(info.Perms)
+	if err != nil {
+		return
+	}
+	if perms == 0 {
+		err = errors.New("acl perms is empty")
+		return
+	}
+	switch info.Scheme {
+	case "world":
+		acl = zk.WorldACL(perms)[0]
+	case "auth":
+		acl = zk.AuthACL(perms)[0]
+	case "digest":
+		if info.Password != "" {
+			acl = zk.DigestACL(perms, info.Id, info.Password)[0]
+		} else {
+			// 未填写密码时 Id 应为 user:摘要 格式
+			if !strings.Contains(info.Id, ":") {
+				err = errors.New("digest acl id must be user:digest or set password")
+				return
+			}
+			acl = zk.ACL{Perms: perms, Scheme: "digest", ID: info.Id}
+		}
+	case "ip":
+		if info.Id == "" {
+			err = errors.New("ip acl id is empty")
+			return
+		}
+		acl = zk.ACL{Perms: perms, Scheme: "ip", ID: info.Id}
+	default:
+		err = errors.New("acl scheme [" + info.Scheme + "] not support")
+		return
+	}
+	return
+}
+
+// setACL 设置节点权限，version 为 -1 时不校验版本
+func setACL(service zookeeper.IService, path string, infoList []*ACLInfo, version int32) (res *NodeACL, err error) {
+	if len(infoList) == 0 {
+		err = errors.New("acl list is empty")
+		return
+	}
+	var acls []zk.ACL
+	for _, info := range infoList {
+		var acl zk.ACL
+		acl, err = toACL(info)
+		if err != nil {
+			return
+		}
+		acls = append(acls, acl)
+	}
+	_, err = service.GetConn().SetACL(path, acls, version)
+	if err != nil {
+		err = errors.New("path [" + path + "] set acl error:" + err.Error())
+		return
+	}
+	res, err = getACL(service, path)
+	return
+}
+
+// NodeStat 节点状态详情，在 StatInfo 基础上补充时间格式化和临时节点标识
+type NodeStat struct {
+	*zookeeper.StatInfo
+	Path            string `json:"path"`
+	CtimeText       string `json:"ctimeText"`
+	MtimeText       string `json:"mtimeText"`
+	Ephemeral       bool   `json:"ephemeral"`
+	EphemeralOwnerX string `json:"ephemeralOwnerX,omitempty"`
+	CzxidX          string `json:"czxidX"`
+	MzxidX          string `json:"mzxidX"`
+	PzxidX          string `json:"pzxidX"`
+}
+
+func getStat(service zookeeper.IService, path string) (res *NodeStat, err error) {
+	_, stat, err := service.GetConn().Exists(path)
+	if err != nil {
+		err = errors.New("path [" + path + "] stat error:" + err.Error())
+		return
+	}
+	if stat == nil || stat.Czxid == 0 {
+		err = errors.New("path [" + path + "] not exists")
+		return
+	}
+	res = &NodeStat{
+		StatInfo:  zookeeper.StatToInfo(stat),
+		Path:      path,
+		CtimeText: util.GetFormatByTime(time.UnixMilli(stat.Ctime)),
+		MtimeText: util.GetFormatByTime(time.UnixMilli(stat.Mtime)),
+		Ephemeral: stat.EphemeralOwner != 0,
+		CzxidX:    fmt.Sprintf("0x%x", stat.Czxid),
+		MzxidX:    fmt.Sprintf("0x%x", stat.Mzxid),
+		PzxidX:    fmt.Sprintf("0x%x", stat.Pzxid),
+	}
+	if res.Ephemeral {
+		res.EphemeralOwnerX = fmt.Sprintf("0x%x", stat.EphemeralOwner)
+	}
+	return
+}
@@ -29,6 +29,16 @@ var (
 	getChildrenPower = base.AppendPower(&base.PowerAction{Action: "getChildren", Text: "Zookeeper查询子节点", ShouldLogin: true, StandAlone: true, Parent: Power})
 	deletePower      = base.AppendPower(&base.PowerAction{Action: "delete", Text: "Zookeeper删除节点", ShouldLogin: true, StandAlone: true, Parent: Power})
 	closePower       = base.AppendPower(&base.PowerAction{Action: "close", Text: "Zookeeper关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
+	watchPower       = base.AppendPower(&base.PowerAction{Action: "watch", Text: "Zookeeper监听节点", ShouldLogin: true, StandAlone: true, Parent: Power})
+	unwatchPower     = base.AppendPower(&base.PowerAction{Action: "unwatch", Text: "Zookeeper取消监听", ShouldLogin: true, StandAlone: true, Parent: Power})
+	watchesPower     = base.AppendPower(&base.PowerAction{Action: "watches", Text: "Zookeeper监听列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	getACLPower      = base.AppendPower(&base.PowerAction{Action: "getACL", Text: "Zookeeper查看ACL", ShouldLogin: true, StandAlone: true, Parent: Power})
+	setACLPower      = base.AppendPower(&base.PowerAction{Action: "setACL", Text: "Zookeeper修改ACL", ShouldLogin: true, StandAlone: true, Parent: Power})
+	statPower        = base.AppendPower(&base.PowerAction{Action: "stat", Text: "Zookeeper节点状态", ShouldLogin: true, StandAlone: true, Parent: Power})
+	mntrPower        = base.AppendPower(&base.PowerAction{Action: "mntr", Text: "Zookeeper监控指标", ShouldLogin: true, StandAlone: true, Parent: Power})
+	srvrStatPower    = base.AppendPower(&base.PowerAction{Action: "srvrStat", Text: "Zookeeper服务状态", ShouldLogin: true, StandAlone: true, Parent: Power})
+	consPower        = base.AppendPower(&base.PowerAction{Action: "cons", Text: "Zookeeper连接信息", ShouldLogin: true, StandAlone: true, Parent: Power})
+	wchsPower        = base.AppendPower(&base.PowerAction{Action: "wchs", Text: "Zookeeper监听统计", ShouldLogin: true, StandAlone: true, Parent: Power})
 )
 
 func (this_ *api) GetApis() (apis []*base.ApiWorker) {
@@ -38,6 +48,16 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: getChildrenPower, Do: this_.getChildren})
 	apis = append(apis, &base.ApiWorker{Power: deletePower, Do: this_.delete})
 	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
+	apis = append(apis, &base.ApiWorker{Power: watchPower, Do: this_.watch})
+	apis = append(apis, &base.ApiWorker{Power: unwatchPower, Do: this_.unwatch})
+	apis = append(apis, &base.ApiWorker{Power: watchesPower, Do: this_.watches})
+	apis = append(apis, &base.ApiWorker{Power: getACLPower, Do: this_.getACL})
+	apis = append(apis, &base.ApiWorker{Power: setACLPower, Do: this_.setACL})
+	apis = append(apis, &base.ApiWorker{Power: statPower, Do: this_.stat})
+	apis = append(apis, &base.ApiWorker{Power: mntrPower, Do: this_.mntr})
+	apis = append(apis, &base.ApiWorker{Power: srvrStatPower, Do: this_.srvrStat})
+	apis = append(apis, &base.ApiWorker{Power: consPower, Do: this_.cons})
+	apis = append(apis, &base.ApiWorker{Power: wchsPower, Do: this_.wchs})
 
 	return
 }
@@ -108,8 +128,14 @@ func getService(zkConfig *zookeeper.Config, sshConfig *ssh.Config) (res zookeepe
 }
 
 type BaseRequest struct {
-	Path string `json:"path"`
-	Data string `json:"data"`
+	WorkerId  string `json:"workerId"`
+	Path      string `json:"path"`
+	Data      string `json:"data"`
+	WatchType string `json:"watchType"`
+	WatchId   string `json:"watchId"`
+
+	ACLs    []*ACLInfo `json:"acls"`
+	Version *int32     `json:"version"`
 }
 
 func (this_ *api) info(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
@@ -261,5 +287,161 @@ func (this_ *api) delete(requestBean *base.RequestBean, c *gin.Context) (res int
 }
 
 func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.WorkerId != "" {
+		stopWorkerWatchers(request.WorkerId, requestBean.JWT.UserId)
+	}
+	return
+}
+
+func (this_ *api) watch(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	// 先校验连接，避免监听协程反复重试
+	_, err = getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	w := &watcher{
+		WorkerId:     request.WorkerId,
+		Path:         request.Path,
+		WatchType:    request.WatchType,
+		userId:       requestBean.JWT.UserId,
+		clientTabKey: requestBean.ClientTabKey,
+		config:       config,
+		sshConfig:    sshConfig,
+	}
+	err = startWatcher(w)
+	if err != nil {
+		return
+	}
+	res = w.info()
+	return
+}
+
+func (this_ *api) unwatch(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	stopWatcher(request.WatchId, requestBean.JWT.UserId)
+	return
+}
+
+func (this_ *api) watches(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res = getWorkerWatchers(request.WorkerId, requestBean.JWT.UserId)
+	return
+}
+
+func (this_ *api) getACL(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = getACL(service, request.Path)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) setACL(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	// 未传版本时不校验 aversion
+	var version int32 = -1
+	if request.Version != nil {
+		version = *request.Version
+	}
+	res, err = setACL(service, request.Path, request.ACLs, version)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) stat(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	config, sshConfig, err := this_.getConfig(requestBean, c)
+	if err != nil {
+		return
+	}
+	service, err := getService(config, sshConfig)
+	if err != nil {
+		return
+	}
+
+	request := &BaseRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	res, err = getStat(service, request.Path)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *api) mntr(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.diagnose(requestBean, c, "mntr")
+}
+
+func (this_ *api) srvrStat(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.diagnose(requestBean, c, "stat")
+}
+
+func (this_ *api) cons(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.diagnose(requestBean, c, "cons")
+}
+
+func (this_ *api) wchs(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	return this_.diagnose(requestBean, c, "wchs")
+}
+
+func (this_ *api) diagnose(requestBean *base.RequestBean, c *gin.Context, cmd string) (res interface{}, err error) {
+	config := &diagnoseConfig{Config: &zookeeper.Config{}}
+	sshConfig, err := this_.toolboxService.BindConfig(requestBean, c, config)
+	if err != nil {
+		return
+	}
+	config.Password = this_.toolboxService.DecryptOptionAttr(config.Password)
+
+	res, err = diagnose(config, sshConfig, cmd)
+	if err != nil {
+		return
+	}
 	return
 }
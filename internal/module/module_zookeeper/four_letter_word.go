@@ -0,0 +1,340 @@
+package module_zookeeper
+
+import (
+	"context"
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"github.com/team-ide/go-tool/zookeeper"
+	goSSH "golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+var (
+	fourLetterWordTimeout = time.Second * 5
+	fourLetterWordCmdList = []string{"mntr", "stat", "cons", "wchs"}
+)
+
+// ServerDiagnose 单个服务端的诊断结果
+type ServerDiagnose struct {
+	Server string      `json:"server"`
+	Data   interface{} `json:"data,omitempty"`
+	Text   string      `json:"text,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// diagnoseConfig 诊断使用的工具配置，工具配置了 adminServerUrl 时调用 AdminServer 的 /commands 接口
+type diagnoseConfig struct {
+	*zookeeper.Config
+	AdminServerUrl string `json:"adminServerUrl"`
+}
+
+// diagnose 对配置中的每个服务端执行四字命令，配置 adminServerUrl 时改为调用 AdminServer，均通过工具配置的 SSH 隧道连接
+func diagnose(config *diagnoseConfig, sshConfig *ssh.Config, cmd string) (res []*ServerDiagnose, err error) {
+	if util.StringIndexOf(fourLetterWordCmdList, cmd) < 0 {
+		err = errors.New("four letter word [" + cmd + "] not support")
+		return
+	}
+
+	var sshClient *goSSH.Client
+	if sshConfig != nil {
+		sshClient, err = ssh.NewClient(*sshConfig)
+		if err != nil {
+			return
+		}
+		defer func() { _ = sshClient.Close() }()
+	}
+
+	if config.AdminServerUrl != "" {
+		one := &ServerDiagnose{Server: config.AdminServerUrl}
+		one.Data, one.Error = adminServerCommand(sshClient, config.AdminServerUrl, cmd)
+		res = append(res, one)
+		return
+	}
+
+	for _, server := range getServers(config.Address) {
+		one := &ServerDiagnose{Server: server}
+		res = append(res, one)
+
+		text, e := fourLetterWord(sshClient, server, cmd)
+		if e != nil {
+			one.Error = e.Error()
+			continue
+		}
+		one.Text = text
+		// 命令未加入白名单时服务端返回提示信息
+		if strings.Contains(text, "is not executed because it is not in the whitelist") {
+			one.Error = strings.TrimSpace(text)
+			continue
+		}
+		switch cmd {
+		case "mntr":
+			one.Data = parseMntr(text)
+		case "stat":
+			one.Data = parseStat(text)
+		case "cons":
+			one.Data = parseCons(text)
+		case "wchs":
+			one.Data = parseWchs(text)
+		}
+	}
+	return
+}
+
+func getServers(address string) (servers []string) {
+	for _, one := range strings.FieldsFunc(address, func(r rune) bool {
+		return r == ',' || r == ';'
+	}) {
+		one = strings.TrimSpace(one)
+		if one == "" {
+			continue
+		}
+		if !strings.Contains(one, ":") {
+			one += ":2181"
+		}
+		servers = append(servers, one)
+	}
+	return
+}
+
+func fourLetterWord(sshClient *goSSH.Client, server string, cmd string) (res string, err error) {
+	var conn net.Conn
+	if sshClient != nil {
+		conn, err = sshClient.Dial("tcp", server)
+	} else {
+		conn, err = net.DialTimeout("tcp", server, fourLetterWordTimeout)
+	}
+	if err != nil {
+		err = errors.New("server [" + server + "] connect error:" + err.Error())
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	_ = conn.SetDeadline(time.Now().Add(fourLetterWordTimeout))
+	_, err = conn.Write([]byte(cmd))
+	if err != nil {
+		return
+	}
+	// 服务端写完结果后关闭连接
+	bs, err := io.ReadAll(conn)
+	if err != nil {
+		return
+	}
+	res = string(bs)
+	return
+}
+
+func adminServerCommand(sshClient *goSSH.Client, adminServerUrl string, cmd string) (res interface{}, errText string) {
+	if !strings.HasPrefix(adminServerUrl, "http://") && !strings.HasPrefix(adminServerUrl, "https://") {
+		errText = "admin server url [" + adminServerUrl + "] must start with http:// or https://"
+		return
+	}
+	client := &http.Client{Timeout: fourLetterWordTimeout}
+	if sshClient != nil {
+		client.Transport = &http.Transport{
+			DialContext: func(_ context.Context, network, addr string) (net.Conn, error) {
+				return sshClient.Dial(network, addr)
+			},
+		}
+	}
+	url := strings.TrimSuffix(adminServerUrl, "/")
+	if !strings.HasSuffix(url, "/commands") {
+		url += "/commands"
+	}
+	resp, err := client.Get(url + "/" + cmd)
+	if err != nil {
+		errText = err.Error()
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	bs, err := io.ReadAll(resp.Body)
+	if err != nil {
+		errText = err.Error()
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		errText = "admin server status " + resp.Status + ":" + string(bs)
+		return
+	}
+	data := map[string]interface{}{}
+	err = util.JSONDecodeUseNumber(bs, &data)
+	if err != nil {
+		errText = err.Error()
+		return
+	}
+	res = data
+	return
+}
+
+func toValue(str string) interface{} {
+	if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(str, 64); err == nil {
+		return f
+	}
+	return str
+}
+
+// parseMntr 解析 mntr，每行为 key\tvalue
+func parseMntr(text string) (res map[string]interface{}) {
+	res = map[string]interface{}{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		ss := strings.SplitN(line, "\t", 2)
+		if len(ss) != 2 {
+			ss = strings.Fields(line)
+			if len(ss) != 2 {
+				continue
+			}
+		}
+		res[strings.TrimSpace(ss[0])] = toValue(strings.TrimSpace(ss[1]))
+	}
+	return
+}
+
+type ServerStat struct {
+	Version     string              `json:"version"`
+	Clients     []*ServerConnection `json:"clients"`
+	LatencyMin  interface{}         `json:"latencyMin"`
+	LatencyAvg  interface{}         `json:"latencyAvg"`
+	LatencyMax  interface{}         `json:"latencyMax"`
+	Received    interface{}         `json:"received"`
+	Sent        interface{}         `json:"sent"`
+	Connections interface{}         `json:"connections"`
+	Outstanding interface{}         `json:"outstanding"`
+	Zxid        string              `json:"zxid"`
+	Mode        string              `json:"mode"`
+	NodeCount   interface{}         `json:"nodeCount"`
+}
+
+// parseStat 解析 stat，Clients 段为连接列表，其余为 key: value
+func parseStat(text string) (res *ServerStat) {
+	res = &ServerStat{}
+	var inClients bool
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			inClients = false
+			continue
+		}
+		if line == "Clients:" {
+			inClients = true
+			continue
+		}
+		if inClients {
+			if c := parseConnection(line); c != nil {
+				res.Clients = append(res.Clients, c)
+			}
+			continue
+		}
+		index := strings.Index(line, ":")
+		if index < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:index])
+		value := strings.TrimSpace(line[index+1:])
+		switch key {
+		case "Zookeeper version":
+			res.Version = value
+		case "Latency min/avg/max":
+			ss := strings.Split(value, "/")
+			if len(ss) == 3 {
+				res.LatencyMin = toValue(ss[0])
+				res.LatencyAvg = toValue(ss[1])
+				res.LatencyMax = toValue(ss[2])
+			}
+		case "Received":
+			res.Received = toValue(value)
+		case "Sent":
+			res.Sent = toValue(value)
+		case "Connections":
+			res.Connections = toValue(value)
+		case "Outstanding":
+			res.Outstanding = toValue(value)
+		case "Zxid":
+			res.Zxid = value
+		case "Mode":
+			res.Mode = value
+		case "Node count":
+			res.NodeCount = toValue(value)
+		}
+	}
+	return
+}
+
+// ServerConnection 连接信息，如 /127.0.0.1:52842[1](queued=0,recved=1,sent=0,sid=0x1000,...)
+type ServerConnection struct {
+	Address  string                 `json:"address"`
+	Interest interface{}            `json:"interest,omitempty"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+}
+
+var connectionRegexp = regexp.MustCompile(`^/?([^\[\s]+)\[(\d+)]\((.*)\)$`)
+
+func parseConnection(line string) (res *ServerConnection) {
+	match := connectionRegexp.FindStringSubmatch(line)
+	if len(match) != 4 {
+		return
+	}
+	res = &ServerConnection{
+		Address:  match[1],
+		Interest: toValue(match[2]),
+		Fields:   map[string]interface{}{},
+	}
+	for _, field := range strings.Split(match[3], ",") {
+		ss := strings.SplitN(field, "=", 2)
+		if len(ss) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(ss[0])
+		value := strings.TrimSpace(ss[1])
+		if key == "sid" || strings.HasSuffix(key, "zxid") {
+			res.Fields[key] = value
+		} else {
+			res.Fields[key] = toValue(value)
+		}
+	}
+	return
+}
+
+// parseCons 解析 cons，每行一个连接
+func parseCons(text string) (res []*ServerConnection) {
+	for _, line := range strings.Split(text, "\n") {
+		if c := parseConnection(strings.TrimSpace(line)); c != nil {
+			res = append(res, c)
+		}
+	}
+	return
+}
+
+type ServerWatches struct {
+	Connections interface{} `json:"connections"`
+	Paths       interface{} `json:"paths"`
+	Total       interface{} `json:"total"`
+}
+
+var wchsRegexp = regexp.MustCompile(`(\d+)\s+connections watching\s+(\d+)\s+paths`)
+var wchsTotalRegexp = regexp.MustCompile(`Total watches:\s*(\d+)`)
+
+// parseWchs 解析 wchs，如 1 connections watching 2 paths\nTotal watches:2
+func parseWchs(text string) (res *ServerWatches) {
+	res = &ServerWatches{}
+	if match := wchsRegexp.FindStringSubmatch(text); len(match) == 3 {
+		res.Connections = toValue(match[1])
+		res.Paths = toValue(match[2])
+	}
+	if match := wchsTotalRegexp.FindStringSubmatch(text); len(match) == 2 {
+		res.Total = toValue(match[1])
+	}
+	return
+}
@@ -0,0 +1,313 @@
+package module_zookeeper
+
+import (
+	"errors"
+	"github.com/go-zookeeper/zk"
+	"github.com/team-ide/go-tool/util"
+	"github.com/team-ide/go-tool/zookeeper"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"teamide/internal/context"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+var (
+	watcherCache           = map[string]*watcher{}
+	workerWatcherIdsCache  = map[string][]string{}
+	watcherCacheLock       = &sync.Mutex{}
+	watchEventName         = "zookeeper-watch-event"
+	watchRetryWaitDuration = time.Second * 3
+	// 检查客户端标签页是否关闭的间隔，关闭后停止监听
+	watchTabCheckDuration = time.Minute
+)
+
+// watcher 节点监听，zk 的 watch 触发一次后失效，每次事件后重新注册
+type watcher struct {
+	WatchId      string `json:"watchId"`
+	WorkerId     string `json:"workerId"`
+	Path         string `json:"path"`
+	WatchType    string `json:"watchType"` // data: 节点数据 children: 子节点
+	StartTime    int64  `json:"startTime"`
+	EventCount   int64  `json:"eventCount"` // 原子读写
+	userId       int64
+	clientTabKey string
+	config       *zookeeper.Config
+	sshConfig    *ssh.Config
+	children     []string
+	stopChan     chan struct{}
+	stopOnce     sync.Once
+}
+
+type WatchEvent struct {
+	WatchId   string              `json:"watchId"`
+	WorkerId  string              `json:"workerId"`
+	Path      string              `json:"path"`
+	WatchType string              `json:"watchType"`
+	EventType string              `json:"eventType"`
+	Exists    bool                `json:"exists"`
+	Data      string              `json:"data,omitempty"`
+	Stat      *zookeeper.StatInfo `json:"stat,omitempty"`
+	Children  []string            `json:"children,omitempty"`
+	Added     []string            `json:"added,omitempty"`
+	Removed   []string            `json:"removed,omitempty"`
+	Error     string              `json:"error,omitempty"`
+	Time      int64               `json:"time"`
+}
+
+func startWatcher(w *watcher) (err error) {
+	if w.Path == "" {
+		err = errors.New("watch path is empty")
+		return
+	}
+	if w.WatchType != "data" && w.WatchType != "children" {
+		err = errors.New("watch type [" + w.WatchType + "] not support")
+		return
+	}
+	w.WatchId = util.GetUUID()
+	w.StartTime = util.GetNowMilli()
+	w.stopChan = make(chan struct{})
+
+	watcherCacheLock.Lock()
+	watcherCache[w.WatchId] = w
+	workerWatcherIdsCache[w.WorkerId] = append(workerWatcherIdsCache[w.WorkerId], w.WatchId)
+	watcherCacheLock.Unlock()
+
+	go w.run()
+	return
+}
+
+// getWorkerWatchers 用户在工作区下的监听
+func getWorkerWatchers(workerId string, userId int64) (list []*watcher) {
+	watcherCacheLock.Lock()
+	defer watcherCacheLock.Unlock()
+
+	for _, watchId := range workerWatcherIdsCache[workerId] {
+		w := watcherCache[watchId]
+		if w != nil && w.userId == userId {
+			list = append(list, w.info())
+		}
+	}
+	return
+}
+
+// stopWatcher 停止监听，只能停止自己的监听
+func stopWatcher(watchId string, userId int64) {
+	watcherCacheLock.Lock()
+	defer watcherCacheLock.Unlock()
+
+	w := watcherCache[watchId]
+	if w == nil || w.userId != userId {
+		return
+	}
+	w.stop()
+	delete(watcherCache, watchId)
+
+	var newIds []string
+	for _, id := range workerWatcherIdsCache[w.WorkerId] {
+		if id != watchId {
+			newIds = append(newIds, id)
+		}
+	}
+	if len(newIds) == 0 {
+		delete(workerWatcherIdsCache, w.WorkerId)
+	} else {
+		workerWatcherIdsCache[w.WorkerId] = newIds
+	}
+}
+
+// stopWorkerWatchers 工作区关闭时停止用户在工作区下的监听
+func stopWorkerWatchers(workerId string, userId int64) {
+	watcherCacheLock.Lock()
+	ids := append([]string{}, workerWatcherIdsCache[workerId]...)
+	watcherCacheLock.Unlock()
+
+	for _, watchId := range ids {
+		stopWatcher(watchId, userId)
+	}
+}
+
+// info 监听信息副本，用于返回给前端
+func (this_ *watcher) info() *watcher {
+	return &watcher{
+		WatchId:    this_.WatchId,
+		WorkerId:   this_.WorkerId,
+		Path:       this_.Path,
+		WatchType:  this_.WatchType,
+		StartTime:  this_.StartTime,
+		EventCount: atomic.LoadInt64(&this_.EventCount),
+	}
+}
+
+func (this_ *watcher) stop() {
+	this_.stopOnce.Do(func() {
+		close(this_.stopChan)
+	})
+}
+
+func (this_ *watcher) isStopped() bool {
+	select {
+	case <-this_.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+func (this_ *watcher) callEvent(event *WatchEvent) {
+	event.WatchId = this_.WatchId
+	event.WorkerId = this_.WorkerId
+	event.Path = this_.Path
+	event.WatchType = this_.WatchType
+	event.Time = util.GetNowMilli()
+	atomic.AddInt64(&this_.EventCount, 1)
+	context.CallClientTabKeyEvent(this_.clientTabKey, context.NewListenEvent(watchEventName, event))
+}
+
+func (this_ *watcher) run() {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("zookeeper watcher panic", zap.Any("path", this_.Path), zap.Any("error", e))
+		}
+	}()
+
+	var eventType = "init"
+	for !this_.isStopped() {
+		// 每次注册都从缓存获取服务，保证服务在监听期间不被回收，连接断开后重新创建
+		service, err := getService(this_.config, this_.sshConfig)
+		if err != nil {
+			this_.callEvent(&WatchEvent{EventType: "error", Error: err.Error()})
+			if !this_.wait(watchRetryWaitDuration) {
+				return
+			}
+			continue
+		}
+
+		event := &WatchEvent{EventType: eventType}
+		var ch <-chan zk.Event
+		if this_.WatchType == "data" {
+			ch, err = this_.watchData(service.GetConn(), event)
+		} else {
+			ch, err = this_.watchChildren(service.GetConn(), event)
+		}
+		if err != nil {
+			this_.callEvent(&WatchEvent{EventType: "error", Error: err.Error()})
+			if !this_.wait(watchRetryWaitDuration) {
+				return
+			}
+			continue
+		}
+		this_.callEvent(event)
+
+		if !this_.waitEvent(ch, &eventType) {
+			return
+		}
+	}
+}
+
+// waitEvent 等待节点事件，停止或客户端标签页关闭时返回 false
+func (this_ *watcher) waitEvent(ch <-chan zk.Event, eventType *string) bool {
+	ticker := time.NewTicker(watchTabCheckDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-this_.stopChan:
+			return false
+		case <-ticker.C:
+			if this_.tabClosed() {
+				return false
+			}
+		case e := <-ch:
+			*eventType = e.Type.String()
+			if e.Type == zk.EventNotWatching {
+				if e.Err != nil {
+					this_.callEvent(&WatchEvent{EventType: *eventType, Error: e.Err.Error()})
+				}
+				return this_.wait(watchRetryWaitDuration)
+			}
+			return true
+		}
+	}
+}
+
+func (this_ *watcher) wait(duration time.Duration) bool {
+	select {
+	case <-this_.stopChan:
+		return false
+	case <-time.After(duration):
+		return !this_.tabClosed()
+	}
+}
+
+// tabClosed 客户端标签页已关闭时停止监听
+func (this_ *watcher) tabClosed() bool {
+	if context.GetListener(this_.clientTabKey) != nil {
+		return false
+	}
+	util.Logger.Info("zookeeper watcher client tab closed", zap.Any("watchId", this_.WatchId), zap.Any("path", this_.Path))
+	stopWatcher(this_.WatchId, this_.userId)
+	return true
+}
+
+func (this_ *watcher) watchData(conn *zk.Conn, event *WatchEvent) (ch <-chan zk.Event, err error) {
+	data, stat, ch, err := conn.GetW(this_.Path)
+	if err == zk.ErrNoNode {
+		// 节点不存在时监听节点创建
+		_, _, ch, err = conn.ExistsW(this_.Path)
+		return
+	}
+	if err != nil {
+		return
+	}
+	event.Exists = true
+	event.Data = string(data)
+	if stat != nil {
+		event.Stat = zookeeper.StatToInfo(stat)
+	}
+	return
+}
+
+func (this_ *watcher) watchChildren(conn *zk.Conn, event *WatchEvent) (ch <-chan zk.Event, err error) {
+	children, stat, ch, err := conn.ChildrenW(this_.Path)
+	if err == zk.ErrNoNode {
+		_, _, ch, err = conn.ExistsW(this_.Path)
+		event.Removed = this_.children
+		this_.children = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	sort.Strings(children)
+	event.Exists = true
+	event.Children = children
+	if stat != nil {
+		event.Stat = zookeeper.StatToInfo(stat)
+	}
+	event.Added, event.Removed = diffChildren(this_.children, children)
+	this_.children = children
+	return
+}
+
+func diffChildren(oldChildren []string, newChildren []string) (added []string, removed []string) {
+	oldCache := map[string]bool{}
+	for _, one := range oldChildren {
+		oldCache[one] = true
+	}
+	newCache := map[string]bool{}
+	for _, one := range newChildren {
+		newCache[one] = true
+		if !oldCache[one] {
+			added = append(added, one)
+		}
+	}
+	for _, one := range oldChildren {
+		if !newCache[one] {
+			removed = append(removed, one)
+		}
+	}
+	return
+}
@@ -74,7 +74,7 @@ func (this_ *connCache) send(connId string, bytes []byte) (err error) {
 		_, err = conn.Write(bytes)
 
 		end := util.GetNow().UnixNano()
-		this_.MonitorData.monitorWrite(int64(len(bytes)), end-start)
+		this_.MonitorData.MonitorWrite(int64(len(bytes)), end-start)
 		//Logger.Info(this_.server.GetServerInfo() + " 代理服务 " + this_.netProxy.Inner.GetInfoStr() + " 连接 [" + connId + "] 发送 [" + fmt.Sprint(len(bytes)) + "]")
 	} else {
 		//Logger.Warn(this_.server.GetServerInfo() + " 代理服务 " + this_.netProxy.Inner.GetInfoStr() + " 连接 [" + connId + "] 不存在")
@@ -86,10 +86,11 @@ type FileWorkData struct {
 }
 
 type TerminalWorkData struct {
-	Key       string         `json:"key,omitempty"`
-	ReadKey   string         `json:"readKey,omitempty"`
-	Size      *terminal.Size `json:"size,omitempty"`
-	IsWindows bool           `json:"isWindows,omitempty"`
+	Key         string                `json:"key,omitempty"`
+	ReadKey     string                `json:"readKey,omitempty"`
+	Size        *terminal.Size        `json:"size,omitempty"`
+	IsWindows   bool                  `json:"isWindows,omitempty"`
+	LocalConfig *terminal.LocalConfig `json:"localConfig,omitempty"`
 }
 
 type StatusChange struct {
@@ -269,7 +270,7 @@ func ReadBytes(reader io.Reader, MonitorData *MonitorData) (bytes []byte, err er
 		}
 	}
 	end := util.GetNow().UnixNano()
-	MonitorData.monitorWrite(int64(length+4), end-start)
+	MonitorData.MonitorWrite(int64(length+4), end-start)
 	return
 }
 
@@ -301,6 +302,6 @@ func WriteBytes(writer io.Writer, bytes []byte, MonitorData *MonitorData) (err e
 		return
 	}
 	end := util.GetNow().UnixNano()
-	MonitorData.monitorWrite(int64(length), end-start)
+	MonitorData.MonitorWrite(int64(length), end-start)
 	return
 }
@@ -26,7 +26,7 @@ type MonitorData struct {
 	writeLock          sync.Mutex
 }
 
-func (this_ *MonitorData) monitorRead(bytesSize int64, useTime int64) {
+func (this_ *MonitorData) MonitorRead(bytesSize int64, useTime int64) {
 	this_.readLock.Lock()
 	defer this_.readLock.Unlock()
 
@@ -47,7 +47,7 @@ func (this_ *MonitorData) monitorRead(bytesSize int64, useTime int64) {
 	this_.ReadTime += useTime
 }
 
-func (this_ *MonitorData) monitorWrite(bytesSize int64, useTime int64) {
+func (this_ *MonitorData) MonitorWrite(bytesSize int64, useTime int64) {
 	this_.writeLock.Lock()
 	defer this_.writeLock.Unlock()
 
@@ -112,7 +112,7 @@ func (this_ *InnerServer) onConn(conn net.Conn) {
 		}
 
 		end := util.GetNow().UnixNano()
-		this_.MonitorData.monitorRead(int64(n), end-start)
+		this_.MonitorData.MonitorRead(int64(n), end-start)
 
 		e = this_.worker.netProxySend(false, this_.netProxy.LineNodeIdList, netProxyId, connId, buf[:n])
 		if e != nil {
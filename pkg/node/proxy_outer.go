@@ -65,7 +65,7 @@ func (this_ *OuterListener) newConn(connId string) (err error) {
 			}
 
 			end := util.GetNow().UnixNano()
-			this_.MonitorData.monitorRead(int64(n), end-start)
+			this_.MonitorData.MonitorRead(int64(n), end-start)
 
 			e = this_.worker.netProxySend(true, this_.netProxy.ReverseLineNodeIdList, netProxyId, connId, buf[:n])
 			if e != nil {
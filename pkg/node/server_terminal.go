@@ -6,7 +6,7 @@ import (
 	"teamide/pkg/terminal"
 )
 
-func (this_ *Server) TerminalStart(lineNodeIdList []string, size *terminal.Size, onRead func(buf []byte) (err error)) (key string, err error) {
+func (this_ *Server) TerminalStart(lineNodeIdList []string, size *terminal.Size, localConfig *terminal.LocalConfig, onRead func(buf []byte) (err error)) (key string, err error) {
 	readKey := util.GetUUID()
 	this_.addOnBytesCache(readKey, &OnBytes{
 		start: func() (err error) {
@@ -24,7 +24,7 @@ func (this_ *Server) TerminalStart(lineNodeIdList []string, size *terminal.Size,
 	})
 	Logger.Info("terminal start add byte cache on ready", zap.Any("readKey", readKey), zap.Any("lineNodeIdList", lineNodeIdList))
 
-	key, err = this_.workTerminalStart(lineNodeIdList, size, readKey)
+	key, err = this_.workTerminalStart(lineNodeIdList, size, readKey, localConfig)
 	if err != nil {
 		return
 	}
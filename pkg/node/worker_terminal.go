@@ -7,13 +7,14 @@ import (
 	"teamide/pkg/terminal"
 )
 
-func (this_ *Worker) workTerminalStart(lineNodeIdList []string, size *terminal.Size, readKey string) (key string, err error) {
+func (this_ *Worker) workTerminalStart(lineNodeIdList []string, size *terminal.Size, readKey string, localConfig *terminal.LocalConfig) (key string, err error) {
 	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
 		res, e := this_.Call(listener, methodTerminalStart, &Message{
 			LineNodeIdList: lineNodeIdList,
 			TerminalWorkData: &TerminalWorkData{
-				Size:    size,
-				ReadKey: readKey,
+				Size:        size,
+				ReadKey:     readKey,
+				LocalConfig: localConfig,
 			},
 		})
 		if e != nil {
@@ -30,7 +31,7 @@ func (this_ *Worker) workTerminalStart(lineNodeIdList []string, size *terminal.S
 		return
 	}
 
-	service := terminal.NewLocalService()
+	service := terminal.NewLocalService(localConfig)
 	err = service.Start(size)
 	if err != nil {
 		return
@@ -140,7 +141,7 @@ func (this_ *Worker) workTerminalIsWindows(lineNodeIdList []string) (isWindows b
 		return
 	}
 
-	service := terminal.NewLocalService()
+	service := terminal.NewLocalService(nil)
 	isWindows, err = service.IsWindows()
 
 	return
@@ -0,0 +1,191 @@
+package ssh
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"net"
+	"os"
+	"strings"
+)
+
+const (
+	AuthMethodPublicKey           = "publicKey"
+	AuthMethodAgent               = "agent"
+	AuthMethodKeyboardInteractive = "keyboardInteractive"
+	AuthMethodPassword            = "password"
+)
+
+var (
+	// KeyboardInteractiveChallenger 键盘交互认证（如动态口令），由工具箱注册，推送问题到浏览器等待用户输入
+	KeyboardInteractiveChallenger func(config Config, name, instruction string, questions []string, echos []bool) (answers []string, err error)
+	// AgentEnabled 是否允许使用服务所在主机的 ssh-agent 认证和转发，由工具箱根据系统设置注册，未注册时不允许
+	AgentEnabled func() bool
+)
+
+// getAuthMethods 按配置的顺序创建认证方式，未配置顺序时使用私钥或密码
+func getAuthMethods(config Config) (auth []ssh.AuthMethod, closeAuth func(), err error) {
+	var agentConn net.Conn
+	closeAuth = func() {
+		if agentConn != nil {
+			_ = agentConn.Close()
+		}
+	}
+
+	var methods []string
+	for _, one := range strings.Split(config.AuthMethods, ",") {
+		one = strings.TrimSpace(one)
+		if one != "" {
+			methods = append(methods, one)
+		}
+	}
+	if len(methods) == 0 {
+		if config.PublicKey != "" {
+			methods = append(methods, AuthMethodPublicKey)
+		} else if config.Password != "" {
+			methods = append(methods, AuthMethodPassword)
+		}
+	}
+
+	for _, method := range methods {
+		switch method {
+		case AuthMethodPublicKey:
+			if config.PublicKey == "" {
+				continue
+			}
+			var signer ssh.Signer
+			signer, err = getPublicKeySigner(config)
+			if err != nil {
+				return
+			}
+			auth = append(auth, ssh.PublicKeys(signer))
+		case AuthMethodAgent:
+			if agentConn != nil {
+				continue
+			}
+			agentClient, conn, e := dialAgent()
+			if e != nil {
+				// ssh-agent 不可用时跳过，继续尝试其它认证方式
+				util.Logger.Warn("ssh agent auth skip", zap.Error(e))
+				continue
+			}
+			agentConn = conn
+			auth = append(auth, ssh.PublicKeysCallback(agentClient.Signers))
+		case AuthMethodKeyboardInteractive:
+			auth = append(auth, ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+				return keyboardInteractive(config, name, instruction, questions, echos)
+			}))
+		case AuthMethodPassword:
+			if config.Password == "" {
+				continue
+			}
+			auth = append(auth, ssh.Password(config.Password))
+		default:
+			err = errors.New("不支持的SSH认证方式[" + method + "]")
+			return
+		}
+	}
+	return
+}
+
+// getPublicKeySigner 读取私钥，配置了证书时使用证书签名
+func getPublicKeySigner(config Config) (signer ssh.Signer, err error) {
+	publicKeyBytes, err := os.ReadFile(config.PublicKey)
+	if err != nil {
+		return
+	}
+	if config.Password != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(publicKeyBytes, []byte(config.Password))
+	} else {
+		signer, err = ssh.ParsePrivateKey(publicKeyBytes)
+	}
+	if err != nil {
+		return
+	}
+	if config.Certificate == "" {
+		return
+	}
+	certBytes, err := os.ReadFile(config.Certificate)
+	if err != nil {
+		return
+	}
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+	if err != nil {
+		err = errors.New("parse certificate error:" + err.Error())
+		return
+	}
+	cert, ok := publicKey.(*ssh.Certificate)
+	if !ok {
+		err = errors.New("certificate [" + config.Certificate + "] is not ssh certificate")
+		return
+	}
+	signer, err = ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// dialAgent 连接服务所在主机 SSH_AUTH_SOCK 指向的 ssh-agent
+func dialAgent() (agentClient agent.ExtendedAgent, conn net.Conn, err error) {
+	if AgentEnabled == nil || !AgentEnabled() {
+		err = errors.New("ssh-agent is not enabled, please contact the administrator")
+		return
+	}
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		err = errors.New("SSH_AUTH_SOCK is empty, ssh-agent is not running")
+		return
+	}
+	conn, err = net.Dial("unix", socket)
+	if err != nil {
+		err = errors.New("dial ssh-agent error:" + err.Error())
+		return
+	}
+	agentClient = agent.NewClient(conn)
+	return
+}
+
+// forwardAgent 将服务所在主机的 ssh-agent 转发到会话，客户端关闭后断开 ssh-agent 连接
+func forwardAgent(client *ssh.Client, session *ssh.Session) (err error) {
+	agentClient, conn, err := dialAgent()
+	if err != nil {
+		return
+	}
+	err = agent.ForwardToAgent(client, agentClient)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go func() {
+		_ = client.Wait()
+		_ = conn.Close()
+	}()
+	err = agent.RequestAgentForwarding(session)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func keyboardInteractive(config Config, name, instruction string, questions []string, echos []bool) (answers []string, err error) {
+	if len(questions) == 0 {
+		return
+	}
+	if KeyboardInteractiveChallenger != nil {
+		answers, err = KeyboardInteractiveChallenger(config, name, instruction, questions, echos)
+		return
+	}
+	// 未注册交互时只能回答密码
+	for _, question := range questions {
+		if !strings.Contains(strings.ToLower(question), "password") {
+			util.Logger.Warn("ssh keyboard interactive question can not answer", zap.Any("question", question))
+			err = errors.New("SSH认证问题[" + question + "]无法回答")
+			return
+		}
+		answers = append(answers, config.Password)
+	}
+	return
+}
@@ -6,13 +6,15 @@ import (
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/ssh"
-	"os"
+	"net"
 	"sync"
 	"time"
 )
 
 var (
 	ShellCache = map[string]*ShellClient{}
+	// HostKeyChecker 主机公钥校验，由工具箱注册，未注册时不校验主机公钥
+	HostKeyChecker func(config Config, hostname string, remote net.Addr, key ssh.PublicKey) error
 )
 
 type Config struct {
@@ -21,7 +23,23 @@ type Config struct {
 	Username  string `json:"username"`
 	Password  string `json:"password"`
 	PublicKey string `json:"publicKey"`
-	Command   string `json:"command"`
+	// Certificate OpenSSH 用户证书（-cert.pub），和私钥一起使用
+	Certificate string `json:"certificate"`
+	// AuthMethods 认证方式，按顺序尝试，逗号分隔：publicKey,agent,keyboardInteractive,password，为空时使用私钥或密码
+	AuthMethods string `json:"authMethods"`
+	// ForwardAgent 为 1 时终端会话转发服务端的 ssh-agent
+	ForwardAgent string `json:"forwardAgent"`
+	Command      string `json:"command"`
+	// JumpToolboxIds 跳板机SSH工具ID，多个逗号分隔，按顺序连接
+	JumpToolboxIds string `json:"jumpToolboxIds"`
+	// ToolboxId 所属工具ID，用于区分主机公钥记录
+	ToolboxId int64 `json:"-"`
+	// UserId 使用该配置的用户，主机公钥确认等事件推送给该用户
+	UserId int64 `json:"-"`
+	// JumpConfigs 解析后的跳板机配置，第一个为直接连接的跳板机
+	JumpConfigs []*Config `json:"-"`
+	// Done 关闭后取消连接，不再等待用户确认主机公钥或回答认证问题
+	Done <-chan struct{} `json:"-"`
 }
 
 type Client struct {
@@ -128,33 +146,40 @@ func (this_ *Client) WSWriteData(obj interface{}) {
 	return
 }
 
+// NewClient 创建SSH客户端，配置了跳板机时依次通过跳板机连接
 func NewClient(config Config) (client *ssh.Client, err error) {
-	var (
-		auth         []ssh.AuthMethod
-		clientConfig *ssh.ClientConfig
-		sshConfig    ssh.Config
-	)
-	auth = []ssh.AuthMethod{}
+	clientConfig, closeAuth, err := newClientConfig(config)
+	if err != nil {
+		return
+	}
+	defer closeAuth()
+	if len(config.JumpConfigs) > 0 {
+		client, err = dialByJump(config, clientConfig)
+		return
+	}
+	client, err = ssh.Dial(getNetwork(config), config.Address, clientConfig)
+	if err != nil {
+		return
+	}
+	return
+}
 
-	if config.PublicKey != "" {
-		var publicKeyBytes []byte
-		publicKeyBytes, err = os.ReadFile(config.PublicKey)
-		if err != nil {
-			return
-		}
-		var publicKeySigner ssh.Signer
-		if config.Password != "" {
-			publicKeySigner, err = ssh.ParsePrivateKeyWithPassphrase(publicKeyBytes, []byte(config.Password))
-		} else {
-			publicKeySigner, err = ssh.ParsePrivateKey(publicKeyBytes)
-		}
-		if err != nil {
-			return
-		}
-		auth = append(auth, ssh.PublicKeys(publicKeySigner))
+func getNetwork(config Config) string {
+	if config.Type == "" {
+		return "tcp"
+	}
+	return config.Type
+}
 
-	} else if config.Password != "" {
-		auth = append(auth, ssh.Password(config.Password))
+// newClientConfig 创建SSH客户端配置，closeAuth 用于在握手结束后关闭认证使用的 ssh-agent 连接
+func newClientConfig(config Config) (clientConfig *ssh.ClientConfig, closeAuth func(), err error) {
+	var (
+		auth      []ssh.AuthMethod
+		sshConfig ssh.Config
+	)
+	auth, closeAuth, err = getAuthMethods(config)
+	if err != nil {
+		return
 	}
 
 	sshConfig = ssh.Config{
@@ -165,11 +190,16 @@ func NewClient(config Config) (client *ssh.Client, err error) {
 		Auth:            auth,
 		Timeout:         5 * time.Second,
 		Config:          sshConfig,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //这个可以, 但是不够安全
-	}
-	client, err = ssh.Dial(config.Type, config.Address, clientConfig)
-	if err != nil {
-		return
+		HostKeyCallback: getHostKeyCallback(config),
 	}
 	return
 }
+
+func getHostKeyCallback(config Config) ssh.HostKeyCallback {
+	if HostKeyChecker == nil {
+		return ssh.InsecureIgnoreHostKey()
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		return HostKeyChecker(config, hostname, remote, key)
+	}
+}
@@ -0,0 +1,328 @@
+package ssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"teamide/pkg/node"
+)
+
+const (
+	// ForwardTypeLocal 本地转发（-L），监听本地地址，通过SSH连接目标地址
+	ForwardTypeLocal = "local"
+	// ForwardTypeRemote 远程转发（-R），监听SSH主机地址，连接本地可访问的目标地址
+	ForwardTypeRemote = "remote"
+	// ForwardTypeDynamic 动态转发（-D），监听本地地址作为 SOCKS5 代理
+	ForwardTypeDynamic = "dynamic"
+)
+
+type ForwardConfig struct {
+	Type          string `json:"type"`
+	BindAddress   string `json:"bindAddress"`
+	TargetAddress string `json:"targetAddress"`
+}
+
+// Forward SSH端口转发，SSH连接断开后停止
+type Forward struct {
+	Config      ForwardConfig
+	sshConfig   Config
+	client      *ssh.Client
+	listener    net.Listener
+	MonitorData *node.MonitorData
+	// 当前连接数
+	connCount int64
+	// 累计连接数
+	totalConnCount int64
+	conns          map[net.Conn]bool
+	connsLock      sync.Mutex
+	stopped        bool
+	stopOnce       sync.Once
+	onStop         func(err error)
+}
+
+// StartForward 创建SSH连接并开始转发，onStop 在转发停止后调用，异常停止时 err 不为空
+func StartForward(sshConfig Config, config ForwardConfig, onStop func(err error)) (forward *Forward, err error) {
+	config.BindAddress = formatForwardAddress(config.BindAddress)
+	if config.BindAddress == "" {
+		err = errors.New("转发监听地址不能为空")
+		return
+	}
+	if config.Type != ForwardTypeDynamic {
+		config.TargetAddress = formatForwardAddress(config.TargetAddress)
+		if config.TargetAddress == "" {
+			err = errors.New("转发目标地址不能为空")
+			return
+		}
+	}
+
+	forward = &Forward{
+		Config:      config,
+		sshConfig:   sshConfig,
+		MonitorData: &node.MonitorData{},
+		conns:       map[net.Conn]bool{},
+		onStop:      onStop,
+	}
+	forward.client, err = NewClient(sshConfig)
+	if err != nil {
+		return
+	}
+
+	switch config.Type {
+	case ForwardTypeLocal, ForwardTypeDynamic:
+		forward.listener, err = net.Listen("tcp", config.BindAddress)
+	case ForwardTypeRemote:
+		forward.listener, err = forward.client.Listen("tcp", config.BindAddress)
+	default:
+		err = errors.New("不支持的转发类型[" + config.Type + "]")
+	}
+	if err != nil {
+		_ = forward.client.Close()
+		return
+	}
+
+	util.Logger.Info("ssh forward started", zap.Any("address", sshConfig.Address), zap.Any("config", config))
+	go forward.accept()
+	go func() {
+		e := forward.client.Wait()
+		if e == nil {
+			e = errors.New("SSH[" + sshConfig.Address + "]连接已断开")
+		}
+		forward.stop(e)
+	}()
+	return
+}
+
+// formatForwardAddress 只配置端口时使用 127.0.0.1
+func formatForwardAddress(address string) string {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return ""
+	}
+	if !strings.Contains(address, ":") {
+		return "127.0.0.1:" + address
+	}
+	return address
+}
+
+// Stop 停止转发，关闭监听、所有连接和SSH连接
+func (this_ *Forward) Stop() {
+	this_.stop(nil)
+}
+
+func (this_ *Forward) stop(err error) {
+	this_.stopOnce.Do(func() {
+		_ = this_.listener.Close()
+		this_.connsLock.Lock()
+		this_.stopped = true
+		for conn := range this_.conns {
+			_ = conn.Close()
+		}
+		this_.connsLock.Unlock()
+		_ = this_.client.Close()
+
+		if err != nil {
+			util.Logger.Warn("ssh forward stopped", zap.Any("address", this_.sshConfig.Address), zap.Any("config", this_.Config), zap.Error(err))
+		} else {
+			util.Logger.Info("ssh forward stopped", zap.Any("address", this_.sshConfig.Address), zap.Any("config", this_.Config))
+		}
+		if this_.onStop != nil {
+			this_.onStop(err)
+		}
+	})
+}
+
+// GetConnCount 当前连接数
+func (this_ *Forward) GetConnCount() int64 {
+	return atomic.LoadInt64(&this_.connCount)
+}
+
+// GetTotalConnCount 累计连接数
+func (this_ *Forward) GetTotalConnCount() int64 {
+	return atomic.LoadInt64(&this_.totalConnCount)
+}
+
+func (this_ *Forward) accept() {
+	for {
+		conn, err := this_.listener.Accept()
+		if err != nil {
+			// 监听被关闭时为正常停止
+			this_.stop(nil)
+			return
+		}
+		go this_.handle(conn)
+	}
+}
+
+func (this_ *Forward) addConn(conn net.Conn) bool {
+	this_.connsLock.Lock()
+	defer this_.connsLock.Unlock()
+
+	if this_.stopped {
+		return false
+	}
+	this_.conns[conn] = true
+	return true
+}
+
+func (this_ *Forward) removeConn(conn net.Conn) {
+	this_.connsLock.Lock()
+	defer this_.connsLock.Unlock()
+
+	delete(this_.conns, conn)
+}
+
+func (this_ *Forward) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	if !this_.addConn(conn) {
+		return
+	}
+	defer this_.removeConn(conn)
+
+	atomic.AddInt64(&this_.connCount, 1)
+	atomic.AddInt64(&this_.totalConnCount, 1)
+	defer atomic.AddInt64(&this_.connCount, -1)
+
+	var target net.Conn
+	var err error
+	switch this_.Config.Type {
+	case ForwardTypeLocal:
+		target, err = this_.client.Dial("tcp", this_.Config.TargetAddress)
+	case ForwardTypeRemote:
+		target, err = net.Dial("tcp", this_.Config.TargetAddress)
+	case ForwardTypeDynamic:
+		target, err = this_.socks5(conn)
+	}
+	if err != nil {
+		util.Logger.Warn("ssh forward dial error", zap.Any("config", this_.Config), zap.Error(err))
+		return
+	}
+	if !this_.addConn(target) {
+		_ = target.Close()
+		return
+	}
+	defer this_.removeConn(target)
+	defer func() { _ = target.Close() }()
+
+	go func() {
+		// 读取客户端数据写入目标，记录为读取
+		this_.copy(target, conn, true)
+		_ = target.Close()
+	}()
+	// 读取目标数据写回客户端，记录为写入
+	this_.copy(conn, target, false)
+}
+
+func (this_ *Forward) copy(dst net.Conn, src net.Conn, isRead bool) {
+	var buf = make([]byte, 1024*32)
+	for {
+		start := util.GetNow().UnixNano()
+		n, err := src.Read(buf)
+		if n > 0 {
+			if isRead {
+				end := util.GetNow().UnixNano()
+				this_.MonitorData.MonitorRead(int64(n), end-start)
+			}
+			start = util.GetNow().UnixNano()
+			_, e := dst.Write(buf[:n])
+			if e != nil {
+				return
+			}
+			if !isRead {
+				end := util.GetNow().UnixNano()
+				this_.MonitorData.MonitorWrite(int64(n), end-start)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// socks5 处理 SOCKS5 握手，只支持无认证的 CONNECT，通过SSH连接请求的地址
+func (this_ *Forward) socks5(conn net.Conn) (target net.Conn, err error) {
+	var buf = make([]byte, 262)
+	if _, err = io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	if buf[0] != 0x05 {
+		err = errors.New("socks version [" + strconv.Itoa(int(buf[0])) + "] not supported")
+		return
+	}
+	methods := buf[2 : 2+int(buf[1])]
+	if _, err = io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	// 只支持无需认证，客户端未提供时回复没有可接受的认证方式
+	if bytes.IndexByte(methods, 0x00) < 0 {
+		_, _ = conn.Write([]byte{0x05, 0xFF})
+		err = errors.New("socks client does not offer no authentication method")
+		return
+	}
+	if _, err = conn.Write([]byte{0x05, 0x00}); err != nil {
+		return
+	}
+
+	if _, err = io.ReadFull(conn, buf[:4]); err != nil {
+		return
+	}
+	if buf[1] != 0x01 {
+		// 只支持 CONNECT
+		_, _ = conn.Write([]byte{0x05, 0x07, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		err = errors.New("socks command [" + strconv.Itoa(int(buf[1])) + "] not supported")
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 0x01:
+		if _, err = io.ReadFull(conn, buf[:net.IPv4len]); err != nil {
+			return
+		}
+		host = net.IP(buf[:net.IPv4len]).String()
+	case 0x03:
+		if _, err = io.ReadFull(conn, buf[:1]); err != nil {
+			return
+		}
+		size := int(buf[0])
+		if _, err = io.ReadFull(conn, buf[:size]); err != nil {
+			return
+		}
+		host = string(buf[:size])
+	case 0x04:
+		if _, err = io.ReadFull(conn, buf[:net.IPv6len]); err != nil {
+			return
+		}
+		host = net.IP(buf[:net.IPv6len]).String()
+	default:
+		_, _ = conn.Write([]byte{0x05, 0x08, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		err = errors.New("socks address type [" + strconv.Itoa(int(buf[3])) + "] not supported")
+		return
+	}
+	if _, err = io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	port := binary.BigEndian.Uint16(buf[:2])
+	address := net.JoinHostPort(host, strconv.Itoa(int(port)))
+
+	target, err = this_.client.Dial("tcp", address)
+	if err != nil {
+		_, _ = conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		err = errors.New("socks dial [" + address + "] error:" + err.Error())
+		return
+	}
+	if _, err = conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0}); err != nil {
+		_ = target.Close()
+		target = nil
+		return
+	}
+	return
+}
@@ -0,0 +1,156 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"strings"
+	"sync"
+)
+
+var (
+	jumpClientCache     = map[string]*jumpClient{}
+	jumpClientCacheLock = &sync.Mutex{}
+)
+
+// jumpClient 跳板机客户端，相同的跳板机链路共用，所有使用者关闭后才关闭
+type jumpClient struct {
+	key      string
+	client   *ssh.Client
+	refCount int
+}
+
+func getJumpKey(chain []*Config) string {
+	var keys []string
+	for _, one := range chain {
+		keys = append(keys, fmt.Sprint(one.ToolboxId, "-", one.Username, "@", one.Address))
+	}
+	return strings.Join(keys, ">")
+}
+
+// dialByJump 通过跳板机链路连接目标主机，目标连接关闭后释放跳板机
+func dialByJump(config Config, clientConfig *ssh.ClientConfig) (client *ssh.Client, err error) {
+	jump, err := acquireJumpClient(config.JumpConfigs)
+	if err != nil {
+		return
+	}
+	client, err = dialClient(jump.client, config, clientConfig)
+	if err != nil {
+		releaseJumpClient(jump)
+		return
+	}
+	go func() {
+		_ = client.Wait()
+		releaseJumpClient(jump)
+	}()
+	return
+}
+
+// dialClient 通过已连接的客户端创建到目标主机的SSH连接
+func dialClient(through *ssh.Client, config Config, clientConfig *ssh.ClientConfig) (client *ssh.Client, err error) {
+	conn, err := through.Dial(getNetwork(config), config.Address)
+	if err != nil {
+		err = errors.New("jump dial [" + config.Address + "] error:" + err.Error())
+		return
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, config.Address, clientConfig)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	client = ssh.NewClient(c, chans, reqs)
+	return
+}
+
+// acquireJumpClient 获取链路最后一个跳板机的客户端，不存在时依次创建链路上的跳板机
+func acquireJumpClient(chain []*Config) (res *jumpClient, err error) {
+	key := getJumpKey(chain)
+
+	jumpClientCacheLock.Lock()
+	res = jumpClientCache[key]
+	if res != nil {
+		res.refCount++
+		jumpClientCacheLock.Unlock()
+		return
+	}
+	jumpClientCacheLock.Unlock()
+
+	hopConfig := *chain[len(chain)-1]
+	hopConfig.JumpConfigs = nil
+	clientConfig, closeAuth, err := newClientConfig(hopConfig)
+	if err != nil {
+		return
+	}
+	defer closeAuth()
+	var parent *jumpClient
+	var client *ssh.Client
+	if len(chain) > 1 {
+		parent, err = acquireJumpClient(chain[:len(chain)-1])
+		if err != nil {
+			return
+		}
+		client, err = dialClient(parent.client, hopConfig, clientConfig)
+	} else {
+		client, err = ssh.Dial(getNetwork(hopConfig), hopConfig.Address, clientConfig)
+	}
+	if err != nil {
+		if parent != nil {
+			releaseJumpClient(parent)
+		}
+		err = errors.New("jump [" + hopConfig.Address + "] connect error:" + err.Error())
+		return
+	}
+
+	jumpClientCacheLock.Lock()
+	if find := jumpClientCache[key]; find != nil {
+		// 并发创建了相同的跳板机，使用已有的
+		find.refCount++
+		jumpClientCacheLock.Unlock()
+		_ = client.Close()
+		if parent != nil {
+			releaseJumpClient(parent)
+		}
+		res = find
+		return
+	}
+	res = &jumpClient{
+		key:      key,
+		client:   client,
+		refCount: 1,
+	}
+	jumpClientCache[key] = res
+	jumpClientCacheLock.Unlock()
+
+	util.Logger.Info("ssh jump client connected", zap.Any("key", key))
+	go func() {
+		// 跳板机断开或关闭后移出缓存，并释放上一级跳板机
+		_ = client.Wait()
+		jumpClientCacheLock.Lock()
+		if jumpClientCache[key] == res {
+			delete(jumpClientCache, key)
+		}
+		jumpClientCacheLock.Unlock()
+		util.Logger.Info("ssh jump client closed", zap.Any("key", key))
+		if parent != nil {
+			releaseJumpClient(parent)
+		}
+	}()
+	return
+}
+
+func releaseJumpClient(jump *jumpClient) {
+	jumpClientCacheLock.Lock()
+	jump.refCount--
+	if jump.refCount > 0 {
+		jumpClientCacheLock.Unlock()
+		return
+	}
+	if jumpClientCache[jump.key] == jump {
+		delete(jumpClientCache, jump.key)
+	}
+	jumpClientCacheLock.Unlock()
+
+	_ = jump.client.Close()
+}
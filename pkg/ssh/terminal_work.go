@@ -88,6 +88,14 @@ func (this_ *terminalService) Start(size *terminal.Size) (err error) {
 	}
 	util.Logger.Info("SSH NewSession success", zap.Any("address", this_.config.Address))
 
+	if this_.config.ForwardAgent == "1" {
+		err = forwardAgent(this_.sshClient, this_.sshSession)
+		if err != nil {
+			util.Logger.Error("SSH Forward Agent Error", zap.Error(err))
+			return
+		}
+	}
+
 	err = NewSSHShell(size, this_.sshSession)
 	if err != nil {
 		util.Logger.Error("Create SSH Shell Error", zap.Error(err))
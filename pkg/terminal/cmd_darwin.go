@@ -12,14 +12,18 @@ func IsWindows() bool {
 	return false
 }
 
-func start(size *Size) (starter *terminalStart, err error) {
+func start(size *Size, config *LocalConfig) (starter *terminalStart, err error) {
 	obj := ptyMasterNew()
 	command := "bash"
 	_, err = os.Stat("/bin/bash")
 	if os.IsNotExist(err) {
 		command = "sh"
 	}
-	err = obj.Start(command, nil, nil, size.Cols, size.Rows)
+	env := config.getEnv()
+	if _, find := config.Env["TERM"]; !find && os.Getenv("TERM") == "" {
+		env = append(env, "TERM=xterm-256color")
+	}
+	err = obj.Start(config.getShell(command), config.Args, env, config.getDir(), size.Cols, size.Rows)
 	if err != nil {
 		return
 	}
@@ -44,9 +48,10 @@ func ptyMasterNew() *ptyMaster {
 	return &ptyMaster{}
 }
 
-func (this_ *ptyMaster) Start(command string, args []string, envVars []string, cols int, rows int) (err error) {
+func (this_ *ptyMaster) Start(command string, args []string, envVars []string, dir string, cols int, rows int) (err error) {
 	this_.command = exec.Command(command, args...)
 	this_.command.Env = envVars
+	this_.command.Dir = dir
 	this_.ptyFile, err = pty.Start(this_.command)
 
 	if err != nil {
@@ -10,8 +10,15 @@ func IsWindows() bool {
 	return true
 }
 
-func start(size *Size) (starter *terminalStart, err error) {
-	cmd := exec.Command("conhost", "--headless")
+func start(size *Size, config *LocalConfig) (starter *terminalStart, err error) {
+	args := []string{"--headless"}
+	if shell := config.getShell(""); shell != "" {
+		args = append(args, shell)
+		args = append(args, config.Args...)
+	}
+	cmd := exec.Command("conhost", args...)
+	cmd.Env = config.getEnv()
+	cmd.Dir = config.getDir()
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		util.Logger.Error("cmd StdoutPipe error", zap.Error(err))
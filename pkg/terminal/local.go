@@ -4,11 +4,100 @@ import (
 	"errors"
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 )
 
-func NewLocalService() (res *localService) {
-	res = &localService{}
+// NewLocalService 创建本地终端服务，config 为空时使用默认 Shell
+func NewLocalService(config *LocalConfig) (res *localService) {
+	if config == nil {
+		config = &LocalConfig{}
+	}
+	res = &localService{
+		config: config,
+	}
+	return
+}
+
+// LocalConfig 本地终端配置
+type LocalConfig struct {
+	Shell string            `json:"shell,omitempty"` // Shell 路径，为空或不存在时使用默认 Shell
+	Args  []string          `json:"args,omitempty"`  // Shell 参数
+	Env   map[string]string `json:"env,omitempty"`   // 环境变量，覆盖当前进程的环境变量，值为空时删除
+	Dir   string            `json:"dir,omitempty"`   // 启动目录，为空或不存在时使用用户目录
+}
+
+// getShell 配置的 Shell 可以找到时使用配置，否则使用默认 Shell
+func (this_ *LocalConfig) getShell(defaultShell string) string {
+	if this_.Shell == "" {
+		return defaultShell
+	}
+	_, err := exec.LookPath(this_.Shell)
+	if err != nil {
+		util.Logger.Warn("terminal local shell not found, use default shell", zap.Any("shell", this_.Shell), zap.Any("defaultShell", defaultShell), zap.Error(err))
+		return defaultShell
+	}
+	return this_.Shell
+}
+
+// getEnv 当前进程的环境变量加上配置的环境变量
+func (this_ *LocalConfig) getEnv() (res []string) {
+	env := map[string]string{}
+	var names []string
+	for _, one := range os.Environ() {
+		index := strings.Index(one, "=")
+		if index <= 0 {
+			continue
+		}
+		name := one[:index]
+		if _, find := env[name]; !find {
+			names = append(names, name)
+		}
+		env[name] = one[index+1:]
+	}
+	var overrideNames []string
+	for name := range this_.Env {
+		overrideNames = append(overrideNames, name)
+	}
+	sort.Strings(overrideNames)
+	for _, name := range overrideNames {
+		value := this_.Env[name]
+		if value == "" {
+			delete(env, name)
+			continue
+		}
+		if _, find := env[name]; !find {
+			names = append(names, name)
+		}
+		env[name] = value
+	}
+	for _, name := range names {
+		if value, find := env[name]; find {
+			res = append(res, name+"="+value)
+		}
+	}
+	return
+}
+
+// getDir 配置的目录存在时使用配置，支持 ~ 开头，否则使用用户目录
+func (this_ *LocalConfig) getDir() (res string) {
+	home, _ := os.UserHomeDir()
+	if this_.Dir == "" {
+		return home
+	}
+	res = this_.Dir
+	if home != "" && (res == "~" || strings.HasPrefix(res, "~/")) {
+		res = filepath.Join(home, res[1:])
+	}
+	info, err := os.Stat(res)
+	if err != nil || !info.IsDir() {
+		util.Logger.Warn("terminal local dir not found, use home dir", zap.Any("dir", this_.Dir), zap.Any("home", home))
+		return home
+	}
 	return
 }
 
@@ -28,6 +117,7 @@ func (this_ *terminalStart) Read(p []byte) (n int, err error) {
 }
 
 type localService struct {
+	config        *LocalConfig
 	terminalStart *terminalStart
 	onClose       func()
 	readeLock     sync.Mutex
@@ -57,7 +147,7 @@ func (this_ *localService) ChangeSize(size *Size) (err error) {
 
 func (this_ *localService) Start(size *Size) (err error) {
 
-	this_.terminalStart, err = start(size)
+	this_.terminalStart, err = start(size, this_.config)
 
 	if err != nil {
 		util.Logger.Error("terminal local start error", zap.Error(err))